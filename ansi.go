@@ -0,0 +1,64 @@
+package htlib
+
+import "strings"
+
+// StripANSI removes ANSI escape sequences (CSI, OSC, DCS and other
+// escape-introduced sequences) from s, leaving printable text and plain
+// control characters such as newlines intact.
+func StripANSI(s string) string {
+	if strings.IndexByte(s, 0x1b) < 0 {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+
+	for i := 0; i < len(s); {
+		if s[i] != 0x1b {
+			b.WriteByte(s[i])
+			i++
+			continue
+		}
+		i = skipEscape(s, i)
+	}
+
+	return b.String()
+}
+
+// skipEscape returns the index just past the escape sequence starting at i.
+// An unterminated sequence consumes the rest of the string.
+func skipEscape(s string, i int) int {
+	i++ // ESC
+	if i >= len(s) {
+		return i
+	}
+
+	switch s[i] {
+	case '[': // CSI: parameters and intermediates, then a final byte
+		for i++; i < len(s); i++ {
+			if s[i] >= 0x40 && s[i] <= 0x7e {
+				return i + 1
+			}
+		}
+		return i
+
+	case ']', 'P', 'X', '^', '_': // OSC, DCS, SOS, PM, APC: terminated by BEL or ST
+		for i++; i < len(s); i++ {
+			if s[i] == 0x07 {
+				return i + 1
+			}
+			if s[i] == 0x1b && i+1 < len(s) && s[i+1] == '\\' {
+				return i + 2
+			}
+		}
+		return i
+
+	default: // Intermediates followed by a final byte, e.g. ESC ( B
+		for ; i < len(s); i++ {
+			if s[i] < 0x20 || s[i] > 0x2f {
+				return i + 1
+			}
+		}
+		return i
+	}
+}
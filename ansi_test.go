@@ -0,0 +1,29 @@
+package htlib
+
+import "testing"
+
+func TestStripANSI(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"plain text", "hello\r\nworld", "hello\r\nworld"},
+		{"sgr colors", "\x1b[1;31mred\x1b[0m", "red"},
+		{"cursor movement", "a\x1b[2;5Hb\x1b[?25l", "ab"},
+		{"osc title with bel", "\x1b]0;title\x07prompt$ ", "prompt$ "},
+		{"osc with st", "\x1b]133;A\x1b\\$ ", "$ "},
+		{"charset designation", "\x1b(0lqqk\x1b(B", "lqqk"},
+		{"keypad mode", "\x1b=text\x1b>", "text"},
+		{"unterminated csi", "text\x1b[12", "text"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := StripANSI(tt.input)
+			if result != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package htlib
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+)
+
+// CastHeader is the first line of an asciicast v2 file.
+type CastHeader struct {
+	Version   int   `json:"version"`
+	Width     int   `json:"width"`
+	Height    int   `json:"height"`
+	Timestamp int64 `json:"timestamp,omitempty"`
+	// IdleTimeLimit tells players to shorten pauses to this many seconds
+	IdleTimeLimit float64           `json:"idle_time_limit,omitempty"`
+	Command       string            `json:"command,omitempty"`
+	Title         string            `json:"title,omitempty"`
+	Env           map[string]string `json:"env,omitempty"`
+}
+
+// CastOption configures the asciicast encoder.
+type CastOption func(*castEncoder)
+
+// WithIdleTimeLimit sets the idle_time_limit of the cast, so players such
+// as asciinema shorten pauses longer than d, for example while a test
+// waits for a timeout.
+func WithIdleTimeLimit(d time.Duration) CastOption {
+	return func(e *castEncoder) {
+		e.header.IdleTimeLimit = d.Seconds()
+	}
+}
+
+// RecordCast starts writing the session to w as an asciicast v2 file, the
+// format of asciinema, which can be played in a terminal with
+// `asciinema play` or embedded in web pages with asciinema-player:
+//
+//	f, _ := os.Create("session.cast")
+//	defer f.Close()
+//	rec, err := vt.RecordCast(f, htlib.WithIdleTimeLimit(2*time.Second))
+//	// ... drive the session ...
+//	err = rec.Close()
+//
+// The header carries the title and command from Metadata. See RecordTo.
+func (vt *VirtualTerminal) RecordCast(w io.Writer, opts ...CastOption) (*StreamRecorder, error) {
+	return vt.RecordTo(NewCastEncoder(w, opts...))
+}
+
+// NewCastEncoder returns a RecordingEncoder that writes asciicast v2 to w.
+func NewCastEncoder(w io.Writer, opts ...CastOption) RecordingEncoder {
+	e := &castEncoder{w: w, header: CastHeader{Version: 2}}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// castEncoder writes asciicast v2.
+type castEncoder struct {
+	w      io.Writer
+	header CastHeader
+}
+
+func (e *castEncoder) Begin(cols, rows int, start time.Time, metadata Metadata) error {
+	e.header.Width, e.header.Height = cols, rows
+	e.header.Timestamp = start.Unix()
+	e.header.Command, e.header.Title = metadata.Command, metadata.Title
+	if term, ok := metadata.Env["TERM"]; ok {
+		e.header.Env = map[string]string{"TERM": term}
+	}
+
+	header, err := json.Marshal(e.header)
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(append(header, '\n'))
+	return err
+}
+
+func (e *castEncoder) Output(offset time.Duration, seq string) error {
+	return e.event(offset, "o", seq)
+}
+
+func (e *castEncoder) Resize(offset time.Duration, cols, rows int) error {
+	return e.event(offset, "r", Size{Cols: cols, Rows: rows}.String())
+}
+
+// event writes one event line, such as [1.250000, "o", "hello\r\n"].
+func (e *castEncoder) event(offset time.Duration, code, data string) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	line := []byte{'['}
+	line = strconv.AppendFloat(line, offset.Seconds(), 'f', 6, 64)
+	line = append(line, ", \""...)
+	line = append(line, code...)
+	line = append(line, "\", "...)
+	line = append(line, encoded...)
+	line = append(line, "]\n"...)
+	_, err = e.w.Write(line)
+	return err
+}
@@ -0,0 +1,71 @@
+package htlib
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCastRecorder(t *testing.T) {
+	vt, _ := newTestTerminal()
+	defer vt.Close()
+	vt.config.Metadata.Title = "demo"
+	vt.trackEvent(OutputEvent{Seq: "$ "})
+
+	var buf syncBuffer
+	r, err := vt.RecordCast(&buf, WithIdleTimeLimit(2*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(d time.Duration) time.Time { return r.start.Add(d) }
+	send := func(event Event) {
+		vt.trackEvent(event)
+		vt.dispatch(event)
+	}
+	lines := func() []string {
+		return strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	}
+
+	send(OutputEvent{Seq: "ls\r\n", Time: at(1500 * time.Millisecond)})
+	send(ResizeEvent{Cols: 80, Rows: 24, Time: at(2 * time.Second)})
+	waitUntil(t, func() bool { return len(lines()) == 4 })
+
+	r.Pause()
+	send(OutputEvent{Seq: "secret", Time: at(3 * time.Second)})
+	time.Sleep(50 * time.Millisecond)
+	r.Resume()
+	send(OutputEvent{Seq: "!", Time: at(4 * time.Second)})
+	waitUntil(t, func() bool { return len(lines()) == 6 })
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+
+	got := lines()
+	var header CastHeader
+	if err := json.Unmarshal([]byte(got[0]), &header); err != nil {
+		t.Fatal(err)
+	}
+	if header.Version != 2 || header.Width != 120 || header.Height != 40 || header.Title != "demo" || header.IdleTimeLimit != 2 {
+		t.Errorf("unexpected header %+v", header)
+	}
+	if !strings.HasPrefix(got[1], `[0.000000, "o", "\u001bc`) || !strings.Contains(got[1], "$") {
+		t.Errorf("expected the initial screen, got %s", got[1])
+	}
+	if got[2] != `[1.500000, "o", "ls\r\n"]` || got[3] != `[2.000000, "r", "80x24"]` {
+		t.Errorf("unexpected events %q", got[2:4])
+	}
+
+	// Output while paused is replaced by the screen on resume
+	if strings.Contains(got[4], "secret\"") || !strings.Contains(got[4], "secret") || !strings.Contains(got[4], `\u001bc`) {
+		t.Errorf("expected the screen after resuming, got %s", got[4])
+	}
+	var last []any
+	if err := json.Unmarshal([]byte(got[5]), &last); err != nil {
+		t.Fatal(err)
+	}
+	if offset := last[0].(float64); offset > 4-0.05 || last[2] != "!" {
+		t.Errorf("expected the paused time to be cut, got %s", got[5])
+	}
+}
@@ -0,0 +1,83 @@
+package htlib
+
+import (
+	"context"
+
+	"github.com/io41/htlib.go/htproto"
+)
+
+// Command is a protocol command that can be sent to ht as part of a Batch.
+// Create commands with InputCommand, KeysCommand, ResizeCommand,
+// SnapshotCommand and MouseCommand.
+type Command struct {
+	cmd command
+}
+
+// InputCommand returns a command that sends raw input, like Input.
+func InputCommand(text string) Command {
+	return Command{htproto.Input(text)}
+}
+
+// KeysCommand returns a command that sends named keys, like SendKeys.
+func KeysCommand(keys ...string) Command {
+	return Command{htproto.SendKeys(keys...)}
+}
+
+// ResizeCommand returns a command that resizes the terminal, like Resize.
+func ResizeCommand(cols, rows int) Command {
+	return Command{htproto.Resize(cols, rows)}
+}
+
+// SnapshotCommand returns a command that requests a snapshot, like TakeSnapshot.
+func SnapshotCommand() Command {
+	return Command{htproto.TakeSnapshot()}
+}
+
+// MouseCommand returns a mouse command. event is one of "click", "press",
+// "release" or "drag"; button, row and col are as for MouseClick.
+func MouseCommand(event, button string, row, col int, modifiers MouseModifiers) Command {
+	return Command{htproto.Mouse(event, button, row, col, modifiers.Shift, modifiers.Ctrl, modifiers.Alt)}
+}
+
+// Batch sends several commands to ht in a single write, reducing syscall
+// and scheduling overhead for scripted bursts such as
+// "resize + keys + snapshot". ht has no multi-command message, so the
+// commands are still processed one after another in the given order, but
+// they are written as a unit: commands sent from other goroutines never
+// come between them. Keys are encoded with the KeyProfile as by SendKeys.
+func (vt *VirtualTerminal) Batch(ctx context.Context, cmds ...Command) error {
+	if len(cmds) == 0 {
+		return nil
+	}
+	return vt.sendCommands(ctx, vt.batchCommands(cmds)...)
+}
+
+// batchCommands returns the protocol commands of a batch.
+func (vt *VirtualTerminal) batchCommands(cmds []Command) []command {
+	raw := make([]command, 0, len(cmds))
+	for _, c := range cmds {
+		if c.cmd.Type == htproto.CommandSendKeys {
+			raw = append(raw, vt.keyCommands(c.cmd.Keys)...)
+		} else {
+			raw = append(raw, c.cmd)
+		}
+	}
+	return raw
+}
+
+// Apply sends commands like Batch and waits until ht has applied them, so
+// that the next step can rely on them: input has been written to the
+// program, a resize is done. ht does not acknowledge commands, but it
+// handles them in order, so Apply follows them with a snapshot request and
+// returns the snapshot once it arrives:
+//
+//	screen, err := vt.Apply(ctx, htlib.ResizeCommand(100, 30))
+//	// screen.Cols == 100
+//
+// Apply does not wait for the program to react to input; see WaitForText
+// and WaitForPrompt for that. ht does not report invalid commands either,
+// but it may log them to standard error, see Stderr. With no commands,
+// Apply waits until ht has handled everything sent before.
+func (vt *VirtualTerminal) Apply(ctx context.Context, cmds ...Command) (*SnapshotEvent, error) {
+	return vt.requestSnapshot(ctx, vt.batchCommands(cmds)...)
+}
@@ -0,0 +1,132 @@
+package htlib
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// nopWriteCloser captures writes made to a terminal's stdin.
+type nopWriteCloser struct {
+	io.Writer
+	writes int
+}
+
+func (w *nopWriteCloser) Write(p []byte) (int, error) {
+	w.writes++
+	return w.Writer.Write(p)
+}
+
+func (w *nopWriteCloser) Close() error { return nil }
+
+func TestBatchSingleWrite(t *testing.T) {
+	var buf bytes.Buffer
+	stdin := &nopWriteCloser{Writer: &buf}
+
+	vt := New(DefaultConfig())
+	vt.started = true
+	vt.stdin = stdin
+
+	err := vt.Batch(context.Background(),
+		ResizeCommand(80, 24),
+		KeysCommand("l", "s", KeyEnter),
+		InputCommand("echo hi\n"),
+		MouseCommand("click", "left", 1, 2, MouseModifiers{Ctrl: true}),
+		SnapshotCommand(),
+	)
+	if err != nil {
+		t.Fatalf("failed to send batch: %v", err)
+	}
+
+	if stdin.writes != 1 {
+		t.Errorf("expected 1 write, got %d", stdin.writes)
+	}
+
+	expected := strings.Join([]string{
+		`{"type":"resize","cols":80,"rows":24}`,
+		`{"type":"sendKeys","keys":["l","s","Enter"]}`,
+		`{"type":"input","payload":"echo hi\n"}`,
+		`{"type":"mouse","event":"click","button":"left","row":1,"col":2,"ctrl":true}`,
+		`{"type":"takeSnapshot"}`,
+	}, "\n") + "\n"
+	if buf.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestBatchBeforeStart(t *testing.T) {
+	vt := New(DefaultConfig())
+
+	if err := vt.Batch(context.Background(), SnapshotCommand()); err != ErrNotStarted {
+		t.Errorf("expected ErrNotStarted, got %v", err)
+	}
+}
+
+func TestApply(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	vt := New(DefaultConfig())
+	defer vt.Close()
+	if err := vt.Start(ctx); err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+
+	screen, err := vt.Apply(ctx, ResizeCommand(100, 30), InputCommand("echo applied\r"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if screen.Cols != 100 || screen.Rows != 30 {
+		t.Errorf("expected the resize to be applied, got %dx%d", screen.Cols, screen.Rows)
+	}
+	if _, err := vt.Apply(ctx); err != nil {
+		t.Errorf("expected an empty Apply to work as a barrier, got %v", err)
+	}
+}
+
+func TestBatchIsAtomic(t *testing.T) {
+	vt, stdin := newTestTerminal()
+	defer vt.Close()
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for g := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var cmds []Command
+			for i := range 3 {
+				cmds = append(cmds, InputCommand(fmt.Sprintf("%d-%d", g, i)))
+			}
+			if err := vt.Batch(ctx, cmds...); err != nil {
+				t.Error(err)
+			}
+			vt.Input(ctx, "between")
+		}()
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSpace(stdin.String()), "\n")
+	for i, line := range lines {
+		if !strings.Contains(line, `-0"`) {
+			continue
+		}
+		prefix := line[:strings.Index(line, "-0")]
+		if i+2 >= len(lines) || !strings.Contains(lines[i+1], prefix+"-1") || !strings.Contains(lines[i+2], prefix+"-2") {
+			t.Fatalf("batch interleaved with other commands:\n%s", strings.Join(lines, "\n"))
+		}
+	}
+
+	// Keys go through the key profile, as with SendKeys
+	vt.SetKeyProfile(AutoKeys())
+	if err := vt.Batch(ctx, KeysCommand(KeyEnter)); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(stdin.String(), `{"type":"input","payload":"\r"}`+"\n") {
+		t.Errorf("expected the key to be encoded locally, got %q", stdin.String())
+	}
+}
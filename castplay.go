@@ -0,0 +1,236 @@
+package htlib
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cast is an asciicast v2 recording loaded with ReadCast.
+type Cast struct {
+	Header CastHeader
+	Events []CastEvent
+}
+
+// CastEvent is one event of a Cast.
+type CastEvent struct {
+	// Offset is the time since the start of the recording
+	Offset time.Duration
+	// Code is "o" for output, "i" for input, "r" for a resize to Data
+	// ("COLSxROWS") and "m" for a marker
+	Code string
+	Data string
+}
+
+// ReadCast reads an asciicast v2 file, as written by RecordCast or
+// `asciinema rec`. It returns an error matching ErrInvalidRecording if r
+// is not in that format.
+func ReadCast(r io.Reader) (*Cast, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16<<20)
+
+	var cast Cast
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: empty cast", ErrInvalidRecording)
+	}
+	if err := json.Unmarshal(scanner.Bytes(), &cast.Header); err != nil {
+		return nil, fmt.Errorf("%w: bad cast header: %v", ErrInvalidRecording, err)
+	}
+	if cast.Header.Version != 2 {
+		return nil, fmt.Errorf("%w: asciicast version %d, want 2", ErrInvalidRecording, cast.Header.Version)
+	}
+
+	for line := 2; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var fields [3]json.RawMessage
+		var seconds float64
+		var event CastEvent
+		if err := json.Unmarshal(scanner.Bytes(), &fields); err != nil ||
+			json.Unmarshal(fields[0], &seconds) != nil ||
+			json.Unmarshal(fields[1], &event.Code) != nil ||
+			json.Unmarshal(fields[2], &event.Data) != nil {
+			return nil, fmt.Errorf("%w: bad cast event on line %d", ErrInvalidRecording, line)
+		}
+		event.Offset = time.Duration(seconds * float64(time.Second))
+		cast.Events = append(cast.Events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return &cast, nil
+}
+
+// Duration returns the offset of the last event.
+func (c *Cast) Duration() time.Duration {
+	if len(c.Events) == 0 {
+		return 0
+	}
+	return c.Events[len(c.Events)-1].Offset
+}
+
+// ScreenAt returns the screen as it was at time offset, for comparing a
+// live session against a recorded one.
+func (c *Cast) ScreenAt(offset time.Duration) *Screen {
+	model := newScreenModel(c.Header.Width, c.Header.Height)
+	for _, e := range c.Events {
+		if e.Offset > offset {
+			break
+		}
+		switch e.Code {
+		case "o":
+			model.feed(e.Data)
+		case "r":
+			if cols, rows, ok := parseCastSize(e.Data); ok {
+				model.setSize(cols, rows)
+			}
+		}
+	}
+	return model.snapshot()
+}
+
+// PlaybackOption configures the playback of a Cast or an event log.
+type PlaybackOption func(*playbackOptions)
+
+type playbackOptions struct {
+	speed   float64
+	maxWait time.Duration
+}
+
+// WithSpeed plays a recording faster (factor > 1) or slower (factor < 1).
+func WithSpeed(factor float64) PlaybackOption {
+	return func(o *playbackOptions) {
+		o.speed = factor
+	}
+}
+
+// WithMaxWait shortens pauses between events to at most d, after speed is
+// applied. For casts the default is their idle_time_limit, if they have
+// one.
+func WithMaxWait(d time.Duration) PlaybackOption {
+	return func(o *playbackOptions) {
+		o.maxWait = d
+	}
+}
+
+// play calls fn for each event at its time, scaled by the options.
+func (c *Cast) play(ctx context.Context, opts []PlaybackOption, fn func(CastEvent) error) error {
+	o := playbackOptions{maxWait: time.Duration(c.Header.IdleTimeLimit * float64(time.Second))}
+	return replay(ctx, len(c.Events), o, opts, func(i int) time.Duration {
+		return c.Events[i].Offset
+	}, func(i int) error {
+		return fn(c.Events[i])
+	})
+}
+
+// replay calls fn for events 0 to n-1 at the times given by offset, scaled
+// by the options applied to o.
+func replay(ctx context.Context, n int, o playbackOptions, opts []PlaybackOption, offset func(int) time.Duration, fn func(int) error) error {
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.speed <= 0 {
+		o.speed = 1
+	}
+
+	var last time.Duration
+	for i := 0; i < n; i++ {
+		next := offset(i)
+		wait := time.Duration(float64(next-last) / o.speed)
+		if o.maxWait > 0 {
+			wait = min(wait, o.maxWait)
+		}
+		last = max(last, next)
+
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			}
+		}
+		if err := fn(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Play replays the cast as a synthetic event stream with its original
+// timing: an InitEvent for the recorded size, then output and resize
+// events. The stream feeds code that consumes events, such as output
+// processors or custom monitors, without ht. The channel is closed at the
+// end of the cast or when ctx is done.
+func (c *Cast) Play(ctx context.Context, opts ...PlaybackOption) <-chan Event {
+	ch := make(chan Event)
+	go func() {
+		defer close(ch)
+		send := func(event Event) error {
+			select {
+			case ch <- event:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		init := InitEvent{Cols: c.Header.Width, Rows: c.Header.Height, Time: time.Now()}
+		if send(init) != nil {
+			return
+		}
+		c.play(ctx, opts, func(e CastEvent) error {
+			switch e.Code {
+			case "o":
+				return send(OutputEvent{Seq: e.Data, Time: time.Now()})
+			case "r":
+				if cols, rows, ok := parseCastSize(e.Data); ok {
+					return send(ResizeEvent{Cols: cols, Rows: rows, Time: time.Now()})
+				}
+			}
+			return nil
+		})
+	}()
+	return ch
+}
+
+// PlayCast replays the input of a cast recorded with input capture
+// (`asciinema rec --stdin`) into the terminal with its original timing,
+// resizing the terminal where the recording did. Recorded output is not
+// replayed; the program in the terminal produces its own, which can be
+// compared with the recording:
+//
+//	cast, _ := htlib.ReadCast(f)
+//	err := vt.PlayCast(ctx, cast, htlib.WithSpeed(4))
+//	if vt.Screen().Text() != cast.ScreenAt(cast.Duration()).Text() { ... }
+func (vt *VirtualTerminal) PlayCast(ctx context.Context, cast *Cast, opts ...PlaybackOption) error {
+	return cast.play(ctx, opts, func(e CastEvent) error {
+		switch e.Code {
+		case "i":
+			return vt.Input(ctx, e.Data)
+		case "r":
+			if cols, rows, ok := parseCastSize(e.Data); ok {
+				return vt.Resize(ctx, cols, rows)
+			}
+		}
+		return nil
+	})
+}
+
+// parseCastSize parses the "COLSxROWS" data of a resize event.
+func parseCastSize(s string) (cols, rows int, ok bool) {
+	c, r, found := strings.Cut(s, "x")
+	cols, errCols := strconv.Atoi(c)
+	rows, errRows := strconv.Atoi(r)
+	return cols, rows, found && errCols == nil && errRows == nil && cols > 0 && rows > 0
+}
@@ -0,0 +1,100 @@
+package htlib
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testCast = `{"version": 2, "width": 20, "height": 3, "idle_time_limit": 0.05}
+[0.010000, "o", "$ "]
+[0.500000, "i", "ls\r"]
+[0.510000, "o", "ls\r\n"]
+
+[0.520000, "o", "a.txt\r\n$ "]
+[0.600000, "r", "30x4"]
+[0.700000, "m", "done"]
+`
+
+func TestReadCast(t *testing.T) {
+	cast, err := ReadCast(strings.NewReader(testCast))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cast.Header.Width != 20 || len(cast.Events) != 6 || cast.Duration() != 700*time.Millisecond {
+		t.Fatalf("unexpected cast %+v", cast)
+	}
+	if e := cast.Events[1]; e.Code != "i" || e.Data != "ls\r" || e.Offset != 500*time.Millisecond {
+		t.Errorf("unexpected event %+v", e)
+	}
+	if text := cast.ScreenAt(510 * time.Millisecond).Text(); text != "$ ls\n\n" {
+		t.Errorf("unexpected screen %q", text)
+	}
+	if screen := cast.ScreenAt(time.Hour); screen.Text() != "$ ls\na.txt\n$\n" || screen.Cols != 30 {
+		t.Errorf("unexpected final screen %q", screen.Text())
+	}
+
+	for _, bad := range []string{"", `{"version": 1}`, `{"version": 2}` + "\n[1, \"o\"]"} {
+		if _, err := ReadCast(strings.NewReader(bad)); !errors.Is(err, ErrInvalidRecording) {
+			t.Errorf("expected ErrInvalidRecording for %q, got %v", bad, err)
+		}
+	}
+}
+
+func TestCastPlay(t *testing.T) {
+	cast, err := ReadCast(strings.NewReader(testCast))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The idle time limit caps every pause at 50ms
+	start := time.Now()
+	var events []Event
+	for event := range cast.Play(context.Background(), WithSpeed(2)) {
+		events = append(events, event)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected playback to be shortened, took %v", elapsed)
+	}
+	if len(events) != 5 {
+		t.Fatalf("expected init, three outputs and a resize, got %+v", events)
+	}
+	if init, ok := events[0].(InitEvent); !ok || init.Cols != 20 {
+		t.Errorf("unexpected first event %+v", events[0])
+	}
+	if resize, ok := events[4].(ResizeEvent); !ok || resize.Cols != 30 || resize.Rows != 4 {
+		t.Errorf("unexpected last event %+v", events[4])
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := cast.Play(ctx, WithMaxWait(time.Hour))
+	<-ch
+	cancel()
+	for range ch {
+	}
+}
+
+func TestPlayCast(t *testing.T) {
+	cast, err := ReadCast(strings.NewReader(testCast))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vt, stdin := newTestTerminal()
+	defer vt.Close()
+
+	if err := vt.PlayCast(context.Background(), cast, WithMaxWait(time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"type":"input","payload":"ls\r"}` + "\n" + `{"type":"resize","cols":30,"rows":4}` + "\n"
+	if got := stdin.String(); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := vt.PlayCast(ctx, cast, WithMaxWait(time.Hour)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the context error, got %v", err)
+	}
+}
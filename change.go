@@ -0,0 +1,100 @@
+package htlib
+
+import (
+	"slices"
+	"time"
+)
+
+// ChangeEvent reports which parts of the screen changed. With
+// Config.ChangeEvents set, one follows every init, output and resize event
+// that changed the screen or moved the cursor, so consumers that render or
+// forward the screen can redraw only what changed:
+//
+//	case htlib.ChangeEvent:
+//	    screen := vt.Screen()
+//	    for _, r := range e.Regions {
+//	        redraw(r, screen.Region(r))
+//	    }
+//
+// Changes are relative to the screen at the previous ChangeEvent, so
+// content that was drawn and erased in between is not reported.
+type ChangeEvent struct {
+	// Regions are the changed cells, one rectangle per changed row spanning
+	// its first to last changed column, top to bottom
+	Regions []Rect
+	// Cursor is the cursor after the change
+	Cursor Cursor
+	// CursorMoved is set if the cursor position, visibility or shape
+	// changed
+	CursorMoved bool
+	Time        time.Time
+}
+
+func (e ChangeEvent) Type() EventType { return EventTypeChange }
+
+// Rows returns the 0-based rows that changed, in order.
+func (e ChangeEvent) Rows() []int {
+	rows := make([]int, len(e.Regions))
+	for i, r := range e.Regions {
+		rows[i] = r.Row
+	}
+	return rows
+}
+
+// changeTracker remembers the screen last reported in a ChangeEvent. It is
+// only used by the goroutine reading events.
+type changeTracker struct {
+	cells  [][]Cell
+	cursor Cursor
+	init   bool
+}
+
+// update compares screen with the state last reported and remembers it.
+// It returns false if nothing changed.
+func (t *changeTracker) update(screen *Screen, now time.Time) (ChangeEvent, bool) {
+	event := ChangeEvent{
+		Cursor:      screen.Cursor,
+		CursorMoved: !t.init || screen.Cursor != t.cursor,
+		Time:        now,
+	}
+	for row, line := range screen.cells {
+		if row >= len(t.cells) || len(t.cells[row]) != len(line) {
+			event.Regions = append(event.Regions, Rect{Row: row, Col: 0, Rows: 1, Cols: len(line)})
+			continue
+		}
+		if slices.Equal(t.cells[row], line) {
+			continue
+		}
+		first, last := 0, len(line)-1
+		for line[first] == t.cells[row][first] {
+			first++
+		}
+		for line[last] == t.cells[row][last] {
+			last--
+		}
+		event.Regions = append(event.Regions, Rect{Row: row, Col: first, Rows: 1, Cols: last - first + 1})
+	}
+
+	t.cells, t.cursor, t.init = screen.cells, screen.Cursor, true
+	return event, len(event.Regions) > 0 || event.CursorMoved
+}
+
+// dispatchChange sends a ChangeEvent after an event that may have changed
+// the screen. It returns false if the terminal was closed while
+// dispatching.
+func (vt *VirtualTerminal) dispatchChange(event Event) bool {
+	if !vt.config.ChangeEvents {
+		return true
+	}
+	switch event.(type) {
+	case InitEvent, OutputEvent, ResizeEvent:
+	default:
+		return true
+	}
+
+	change, changed := vt.changes.update(vt.Screen(), time.Now())
+	if !changed {
+		return true
+	}
+	return vt.dispatch(change)
+}
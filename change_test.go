@@ -0,0 +1,81 @@
+package htlib
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestChangeEvents(t *testing.T) {
+	config := DefaultConfig()
+	config.ChangeEvents = true
+	vt := New(config)
+	vt.started = true
+	defer vt.Close()
+
+	sub := vt.Subscribe()
+	next := func() *ChangeEvent {
+		t.Helper()
+		for {
+			select {
+			case event := <-sub:
+				if change, ok := event.(ChangeEvent); ok {
+					return &change
+				}
+			default:
+				return nil
+			}
+		}
+	}
+
+	vt.handleLine(`{"type":"init","data":{"cols":10,"rows":3,"seq":"\u001b[2J\u001b[Hab","text":"ab"}}`)
+	change := next()
+	if change == nil || len(change.Regions) != 3 || !change.CursorMoved {
+		t.Fatalf("expected the whole screen to be reported first, got %+v", change)
+	}
+
+	// Only the changed cells of the changed row are reported
+	vt.handleLine(`{"type":"output","data":{"seq":"\u001b[3;4Hxyz\u001b[1;1H"}}`)
+	change = next()
+	if change == nil {
+		t.Fatal("expected a change event")
+	}
+	if expected := []Rect{{Row: 2, Col: 3, Rows: 1, Cols: 3}}; !reflect.DeepEqual(change.Regions, expected) {
+		t.Errorf("expected regions %+v, got %+v", expected, change.Regions)
+	}
+	if !reflect.DeepEqual(change.Rows(), []int{2}) || !change.CursorMoved || change.Cursor.Row != 0 {
+		t.Errorf("unexpected change %+v", change)
+	}
+
+	// Redrawing the same content only moves the cursor
+	vt.handleLine(`{"type":"output","data":{"seq":"ab"}}`)
+	change = next()
+	if change == nil || len(change.Regions) != 0 || !change.CursorMoved || change.Cursor.Col != 2 {
+		t.Errorf("expected only the cursor move, got %+v", change)
+	}
+	vt.handleLine(`{"type":"output","data":{"seq":"\u001b[1;3H"}}`)
+	if change = next(); change != nil {
+		t.Errorf("expected no more changes, got %+v", change)
+	}
+
+	// Snapshots are not screen changes
+	vt.handleLine(`{"type":"snapshot","data":{"cols":10,"rows":3,"seq":"","text":""}}`)
+	if change = next(); change != nil {
+		t.Errorf("expected no change for a snapshot, got %+v", change)
+	}
+}
+
+func TestChangeEventsDisabled(t *testing.T) {
+	vt, _ := newTestTerminal()
+	defer vt.Close()
+
+	sub := vt.Subscribe()
+	vt.handleLine(`{"type":"output","data":{"seq":"hello"}}`)
+	if event := <-sub; event.Type() != EventTypeOutput {
+		t.Errorf("expected the output event, got %+v", event)
+	}
+	select {
+	case event := <-sub:
+		t.Errorf("expected no change events by default, got %+v", event)
+	default:
+	}
+}
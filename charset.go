@@ -0,0 +1,116 @@
+package htlib
+
+import "strings"
+
+// decSpecialGraphics maps characters of the DEC Special Graphics character
+// set to their Unicode equivalents.
+var decSpecialGraphics = map[byte]rune{
+	'`': '◆', 'a': '▒', 'b': '␉', 'c': '␌', 'd': '␍', 'e': '␊', 'f': '°',
+	'g': '±', 'h': '␤', 'i': '␋', 'j': '┘', 'k': '┐', 'l': '┌', 'm': '└',
+	'n': '┼', 'o': '⎺', 'p': '⎻', 'q': '─', 'r': '⎼', 's': '⎽', 't': '├',
+	'u': '┤', 'v': '┴', 'w': '┬', 'x': '│', 'y': '≤', 'z': '≥', '{': 'π',
+	'|': '≠', '}': '£', '~': '·',
+}
+
+// TranslateLineDrawing rewrites a raw VT100 sequence so that characters
+// printed in the DEC Special Graphics character set appear as Unicode
+// box-drawing characters. Charset designations (ESC ( 0, ESC ) 0) and
+// shifts (SO/SI) are consumed, so "\x1b(0lqqk\x1b(B" becomes "┌──┐".
+func TranslateLineDrawing(seq string) string {
+	var t charsetTranslator
+	return t.translate(seq) + t.pending
+}
+
+// charsetTranslator tracks G0/G1 charset designations and shift state
+// across chunks of terminal output.
+type charsetTranslator struct {
+	g0Graphics bool
+	g1Graphics bool
+	shifted    bool // SO active, G1 selected
+
+	// pending holds an escape sequence split across chunks
+	pending string
+}
+
+// active reports whether the currently selected charset is DEC Special Graphics.
+func (t *charsetTranslator) active() bool {
+	if t.shifted {
+		return t.g1Graphics
+	}
+	return t.g0Graphics
+}
+
+// translate converts a chunk of output, carrying state to the next call.
+func (t *charsetTranslator) translate(seq string) string {
+	seq = t.pending + seq
+	t.pending = ""
+
+	if !t.active() && !strings.ContainsAny(seq, "\x0e\x0f\x1b") {
+		return seq
+	}
+
+	var b strings.Builder
+	b.Grow(len(seq))
+
+	for i := 0; i < len(seq); {
+		c := seq[i]
+		switch {
+		case c == 0x0e: // SO
+			t.shifted = true
+			i++
+		case c == 0x0f: // SI
+			t.shifted = false
+			i++
+		case c == 0x1b:
+			end := skipEscape(seq, i)
+			if end >= len(seq) && !escapeComplete(seq[i:]) {
+				t.pending = seq[i:]
+				return b.String()
+			}
+			if !t.designate(seq[i:end]) {
+				b.WriteString(seq[i:end])
+			}
+			i = end
+		default:
+			if r, ok := decSpecialGraphics[c]; ok && t.active() {
+				b.WriteRune(r)
+			} else {
+				b.WriteByte(c)
+			}
+			i++
+		}
+	}
+
+	return b.String()
+}
+
+// designate applies a charset designation sequence, reporting whether esc was one.
+func (t *charsetTranslator) designate(esc string) bool {
+	if len(esc) != 3 || (esc[1] != '(' && esc[1] != ')') {
+		return false
+	}
+	graphics := esc[2] == '0'
+	if esc[1] == '(' {
+		t.g0Graphics = graphics
+	} else {
+		t.g1Graphics = graphics
+	}
+	return true
+}
+
+// escapeComplete reports whether esc, which runs to the end of a chunk, is
+// a complete escape sequence rather than one cut off mid-way.
+func escapeComplete(esc string) bool {
+	if len(esc) < 2 {
+		return false
+	}
+	last := esc[len(esc)-1]
+	switch esc[1] {
+	case '[':
+		return len(esc) > 2 && last >= 0x40 && last <= 0x7e
+	case ']', 'P', 'X', '^', '_':
+		return last == 0x07 || strings.HasSuffix(esc, "\x1b\\")
+	default:
+		return last < 0x20 || last > 0x2f
+	}
+}
@@ -0,0 +1,58 @@
+package htlib
+
+import "testing"
+
+func TestTranslateLineDrawing(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"g0 designation", "\x1b(0lqqk\x1b(B", "┌──┐"},
+		{"text outside graphics mode", "lqqk", "lqqk"},
+		{"shift out to g1", "\x1b)0a\x0exqx\x0fb", "a│─│b"},
+		{"other escapes preserved", "\x1b(0\x1b[1mq\x1b[0m\x1b(B", "\x1b[1m─\x1b[0m"},
+		{"utf-8 passthrough", "\x1b(0é\x1b(B", "é"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := TranslateLineDrawing(tt.input)
+			if result != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestCharsetTranslatorAcrossChunks(t *testing.T) {
+	var tr charsetTranslator
+
+	chunks := []string{"\x1b", "(0lq", "k\x1b[", "0m", "m\x1b(B", "m"}
+	var result string
+	for _, chunk := range chunks {
+		result += tr.translate(chunk)
+	}
+
+	expected := "┌─┐\x1b[0m└m"
+	if result != expected {
+		t.Errorf("expected %q, got %q", expected, result)
+	}
+}
+
+func TestParseEventTranslatesLineDrawing(t *testing.T) {
+	vt := New(Config{TranslateLineDrawing: true})
+
+	first, err := vt.parseEvent(`{"type":"output","data":{"seq":"\u001b(0lq"}}`)
+	if err != nil {
+		t.Fatalf("failed to parse event: %v", err)
+	}
+	second, err := vt.parseEvent(`{"type":"output","data":{"seq":"k\u001b(B"}}`)
+	if err != nil {
+		t.Fatalf("failed to parse event: %v", err)
+	}
+
+	if seq := first.(OutputEvent).Seq + second.(OutputEvent).Seq; seq != "┌─┐" {
+		t.Errorf("expected %q, got %q", "┌─┐", seq)
+	}
+}
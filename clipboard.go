@@ -0,0 +1,310 @@
+package htlib
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Clipboard is a clipboard a ClipboardBridge copies to and pastes from.
+type Clipboard interface {
+	ReadClipboard(ctx context.Context) (string, error)
+	WriteClipboard(ctx context.Context, text string) error
+}
+
+// SystemClipboard returns the clipboard of the host, accessed through
+// pbcopy/pbpaste on macOS, clip.exe and PowerShell on Windows, and
+// wl-copy/wl-paste, xclip or xsel elsewhere, whichever is installed. If
+// none is, its methods return an error matching ErrUnsupported.
+func SystemClipboard() Clipboard {
+	return systemClipboard{}
+}
+
+// systemClipboard runs the host's clipboard tools.
+type systemClipboard struct{}
+
+// clipboardTool is a pair of commands that write and read a clipboard.
+type clipboardTool struct {
+	copy, paste []string
+}
+
+// clipboardTools returns the tools to try on the current platform, in
+// order of preference.
+func clipboardTools() []clipboardTool {
+	switch runtime.GOOS {
+	case "darwin":
+		return []clipboardTool{{[]string{"pbcopy"}, []string{"pbpaste"}}}
+	case "windows":
+		return []clipboardTool{{[]string{"clip.exe"}, []string{"powershell.exe", "-NoProfile", "-Command", "Get-Clipboard -Raw"}}}
+	}
+	var tools []clipboardTool
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		tools = append(tools, clipboardTool{[]string{"wl-copy"}, []string{"wl-paste", "--no-newline"}})
+	}
+	return append(tools,
+		clipboardTool{[]string{"xclip", "-selection", "clipboard"}, []string{"xclip", "-selection", "clipboard", "-o"}},
+		clipboardTool{[]string{"xsel", "--clipboard", "--input"}, []string{"xsel", "--clipboard", "--output"}},
+	)
+}
+
+// command returns the first installed copy or paste command.
+func (systemClipboard) command(ctx context.Context, paste bool) (*exec.Cmd, error) {
+	for _, tool := range clipboardTools() {
+		argv := tool.copy
+		if paste {
+			argv = tool.paste
+		}
+		if path, err := exec.LookPath(argv[0]); err == nil {
+			return exec.CommandContext(ctx, path, argv[1:]...), nil
+		}
+	}
+	return nil, fmt.Errorf("%w: no clipboard tool found", ErrUnsupported)
+}
+
+// ReadClipboard returns the contents of the host clipboard.
+func (c systemClipboard) ReadClipboard(ctx context.Context) (string, error) {
+	cmd, err := c.command(ctx, true)
+	if err != nil {
+		return "", err
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to read clipboard: %w", err)
+	}
+	return string(out), nil
+}
+
+// WriteClipboard replaces the contents of the host clipboard.
+func (c systemClipboard) WriteClipboard(ctx context.Context, text string) error {
+	cmd, err := c.command(ctx, false)
+	if err != nil {
+		return err
+	}
+	cmd.Stdin = strings.NewReader(text)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to write clipboard: %w: %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// ClipboardOption configures a ClipboardBridge.
+type ClipboardOption func(*ClipboardBridge)
+
+// AllowClipboardRead lets the program read the clipboard with an OSC 52
+// query. Like most terminals, the bridge ignores queries by default, since
+// any program in the session could read what the user copied elsewhere.
+func AllowClipboardRead() ClipboardOption {
+	return func(b *ClipboardBridge) {
+		b.allowRead = true
+	}
+}
+
+// ClipboardBridge connects the OSC 52 clipboard sequences of a session to
+// a Clipboard.
+type ClipboardBridge struct {
+	vt        *VirtualTerminal
+	clipboard Clipboard
+	allowRead bool
+
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu     sync.Mutex
+	copied int
+	err    error
+}
+
+// BridgeClipboard makes the session's clipboard behave like a real
+// terminal's: text a program copies with OSC 52, as tmux, neovim and
+// tools like osc52 do, is written to clipboard, and Paste sends the
+// clipboard contents to the program:
+//
+//	bridge := vt.BridgeClipboard(htlib.SystemClipboard())
+//	defer bridge.Close()
+//
+// The bridge watches the output on its own goroutine. Errors from the
+// clipboard do not stop it; the latest is available from Err.
+func (vt *VirtualTerminal) BridgeClipboard(clipboard Clipboard, opts ...ClipboardOption) *ClipboardBridge {
+	b := &ClipboardBridge{
+		vt:        vt,
+		clipboard: clipboard,
+		done:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	var ctx context.Context
+	ctx, b.cancel = context.WithCancel(vt.current().ctx)
+	sub := vt.subscribeRaw()
+	go b.run(ctx, sub)
+	return b
+}
+
+// Close stops the bridge. It is safe to call Close more than once.
+func (b *ClipboardBridge) Close() {
+	b.cancel()
+	<-b.done
+}
+
+// Copied returns the number of times the program has set the clipboard.
+func (b *ClipboardBridge) Copied() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.copied
+}
+
+// Err returns the most recent error from the clipboard, or nil.
+func (b *ClipboardBridge) Err() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.err
+}
+
+// Paste sends the contents of the clipboard to the program, like pressing
+// the terminal's paste key.
+func (b *ClipboardBridge) Paste(ctx context.Context) error {
+	text, err := b.clipboard.ReadClipboard(ctx)
+	if err != nil {
+		return err
+	}
+	return b.vt.Paste(ctx, text)
+}
+
+// run handles OSC 52 sequences in the output from sub until stopped.
+func (b *ClipboardBridge) run(ctx context.Context, sub chan Event) {
+	defer close(b.done)
+	defer b.vt.Unsubscribe(sub)
+
+	var scanner osc52Scanner
+	for {
+		var event Event
+		select {
+		case e, ok := <-sub:
+			if !ok {
+				return
+			}
+			event = e
+		case <-ctx.Done():
+			return
+		}
+
+		output, ok := event.(OutputEvent)
+		if !ok {
+			continue
+		}
+		for _, seq := range scanner.scan(output.Seq) {
+			b.handle(ctx, seq)
+		}
+	}
+}
+
+// handle performs the clipboard operation of one OSC 52 sequence.
+func (b *ClipboardBridge) handle(ctx context.Context, seq string) {
+	// ESC ] 52 ; selection ; data, terminated by BEL or ST
+	terminator := "\x07"
+	body, ok := strings.CutSuffix(seq, terminator)
+	if !ok {
+		terminator = "\x1b\\"
+		body = strings.TrimSuffix(seq, terminator)
+	}
+	selection, data, _ := strings.Cut(strings.TrimPrefix(body, "\x1b]52;"), ";")
+
+	var err error
+	if data == "?" {
+		if !b.allowRead {
+			return
+		}
+		var text string
+		if text, err = b.clipboard.ReadClipboard(ctx); err == nil {
+			reply := "\x1b]52;" + selection + ";" + base64.StdEncoding.EncodeToString([]byte(text)) + terminator
+			err = b.vt.Input(ctx, reply)
+		}
+	} else {
+		var text []byte
+		if text, err = base64.StdEncoding.DecodeString(data); err != nil {
+			// Terminals ignore data that is not valid base64
+			return
+		}
+		err = b.clipboard.WriteClipboard(ctx, string(text))
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if data != "?" && err == nil {
+		b.copied++
+	}
+	if err != nil && ctx.Err() == nil {
+		b.err = err
+	}
+}
+
+// maxOSC52Size is the longest OSC 52 sequence kept across output events,
+// enough for about 768 KiB of copied text. Longer sequences are dropped up
+// to their terminator instead of being buffered without bound.
+const maxOSC52Size = 1024 * 1024
+
+// osc52Scanner finds OSC 52 sequences in output that arrives in chunks.
+type osc52Scanner struct {
+	// pending is the trailing incomplete escape sequence, to prepend to
+	// the next chunk
+	pending string
+	// discarding is set while the rest of an over-long sequence is skipped
+	discarding bool
+}
+
+// scan returns the complete OSC 52 sequences in s.
+func (sc *osc52Scanner) scan(s string) (seqs []string) {
+	s = sc.pending + s
+	sc.pending = ""
+	if sc.discarding {
+		end := stringTerminator(s)
+		if end < 0 {
+			if strings.HasSuffix(s, "\x1b") {
+				sc.pending = "\x1b"
+			}
+			return nil
+		}
+		sc.discarding = false
+		s = s[end:]
+	}
+
+	for i := 0; i < len(s); {
+		if s[i] != 0x1b {
+			i++
+			continue
+		}
+		end := skipEscape(s, i)
+		if end >= len(s) && !escapeComplete(s[i:]) {
+			if len(s)-i > maxOSC52Size {
+				sc.discarding = isStringSequence(s[i:])
+			} else {
+				sc.pending = s[i:]
+			}
+			return seqs
+		}
+		if strings.HasPrefix(s[i:end], "\x1b]52;") {
+			seqs = append(seqs, s[i:end])
+		}
+		i = end
+	}
+	return seqs
+}
+
+// Paste sends text to the program as a paste. While the program has
+// bracketed paste mode enabled, the text is wrapped in the paste markers
+// so it can tell pasted text from typed text.
+func (vt *VirtualTerminal) Paste(ctx context.Context, text string) error {
+	if vt.Screen().Modes.BracketedPaste {
+		// Strip markers from the text so it cannot end the paste early
+		text = strings.ReplaceAll(text, "\x1b[201~", "")
+		text = "\x1b[200~" + text + "\x1b[201~"
+	}
+	return vt.Input(ctx, text)
+}
@@ -0,0 +1,128 @@
+package htlib
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeClipboard is an in-memory Clipboard.
+type fakeClipboard struct {
+	mu   sync.Mutex
+	text string
+	err  error
+}
+
+func (c *fakeClipboard) ReadClipboard(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.text, c.err
+}
+
+func (c *fakeClipboard) WriteClipboard(ctx context.Context, text string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	c.text = text
+	return nil
+}
+
+func (c *fakeClipboard) get() string {
+	text, _ := c.ReadClipboard(context.Background())
+	return text
+}
+
+func TestScanOSC52(t *testing.T) {
+	var scanner osc52Scanner
+	seqs := scanner.scan("a\x1b]52;c;aGk=\x07b\x1b]2;title\x07\x1b[1m\x1b]52;;eA==\x1b\\\x1b]52;c;cGFy")
+	expected := []string{"\x1b]52;c;aGk=\x07", "\x1b]52;;eA==\x1b\\"}
+	if !reflect.DeepEqual(seqs, expected) || scanner.pending != "\x1b]52;c;cGFy" {
+		t.Errorf("got %q with pending %q", seqs, scanner.pending)
+	}
+
+	// A sequence longer than the limit is dropped up to its terminator
+	scanner = osc52Scanner{}
+	scanner.scan("\x1b]52;c;")
+	for range 8 {
+		if seqs := scanner.scan(strings.Repeat("A", maxOSC52Size/4)); seqs != nil {
+			t.Errorf("unexpected sequences %q", seqs)
+		}
+	}
+	if len(scanner.pending) != 0 || !scanner.discarding {
+		t.Errorf("expected the sequence to be dropped, got %d bytes pending", len(scanner.pending))
+	}
+	seqs = scanner.scan("AAAA\x1b")
+	seqs = append(seqs, scanner.scan("\\\x1b]52;c;aGk=\x07")...)
+	if !reflect.DeepEqual(seqs, []string{"\x1b]52;c;aGk=\x07"}) {
+		t.Errorf("expected the next sequence after the dropped one, got %q", seqs)
+	}
+}
+
+func TestClipboardBridge(t *testing.T) {
+	vt, stdin := newTestTerminal()
+	defer vt.Close()
+
+	clipboard := &fakeClipboard{}
+	bridge := vt.BridgeClipboard(clipboard)
+
+	// A copy split across output events
+	vt.dispatch(OutputEvent{Seq: "copied\x1b]52;c;aGVs"})
+	vt.dispatch(OutputEvent{Seq: "bG8=\x1b\\"})
+	waitUntil(t, func() bool { return bridge.Copied() == 1 })
+	if text := clipboard.get(); text != "hello" {
+		t.Errorf("expected hello on the clipboard, got %q", text)
+	}
+
+	// Queries are ignored unless allowed
+	vt.dispatch(OutputEvent{Seq: "\x1b]52;c;?\x07"})
+	vt.dispatch(OutputEvent{Seq: "\x1b]52;c;d29ybGQ=\x07"})
+	waitUntil(t, func() bool { return bridge.Copied() == 2 })
+	if strings.Contains(stdin.String(), "52;") {
+		t.Errorf("expected the query to be ignored, got %q", stdin.String())
+	}
+
+	clipboard.mu.Lock()
+	clipboard.err = errors.New("no display")
+	clipboard.mu.Unlock()
+	vt.dispatch(OutputEvent{Seq: "\x1b]52;c;eA==\x07"})
+	waitUntil(t, func() bool { return bridge.Err() != nil })
+	bridge.Close()
+	bridge.Close()
+}
+
+func TestClipboardBridgeRead(t *testing.T) {
+	vt, stdin := newTestTerminal()
+	defer vt.Close()
+
+	clipboard := &fakeClipboard{text: "host"}
+	bridge := vt.BridgeClipboard(clipboard, AllowClipboardRead())
+	defer bridge.Close()
+
+	vt.dispatch(OutputEvent{Seq: "\x1b]52;c;?\x07"})
+	waitUntil(t, func() bool { return strings.Contains(stdin.String(), `\u001b]52;c;aG9zdA==\u0007`) })
+
+	if err := bridge.Paste(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stdin.String(), `"payload":"host"`) {
+		t.Errorf("expected the clipboard to be pasted, got %q", stdin.String())
+	}
+}
+
+func TestPasteBracketed(t *testing.T) {
+	vt, stdin := newTestTerminal()
+	defer vt.Close()
+
+	vt.screen.feed("\x1b[?2004h")
+	if err := vt.Paste(context.Background(), "a\x1b[201~b"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stdin.String(), `"payload":"\u001b[200~ab\u001b[201~"`) {
+		t.Errorf("expected a bracketed paste, got %q", stdin.String())
+	}
+}
@@ -0,0 +1,268 @@
+package htlib
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/io41/htlib.go/htproto"
+)
+
+// markerLookback is how far back from the newest output RunCommand looks
+// for a marker, which may be split across output events.
+const markerLookback = 256
+
+// CommandResult is the outcome of RunCommand.
+type CommandResult struct {
+	// Output is what the command wrote, with escape sequences, without the
+	// echoed command line or the prompt that follows it
+	Output string
+	// ExitCode is the exit status of the command as reported by the shell,
+	// or -1 if the shell did not report it
+	ExitCode int
+	// Duration is the time from submitting the command until it finished
+	Duration time.Duration
+}
+
+// Text returns Output with ANSI sequences removed and CRLF and lone CR
+// line endings converted to LF.
+func (r *CommandResult) Text() string {
+	return plainText(r.Output)
+}
+
+// RunCommand runs a command line in the shell, waits for it to finish and
+// returns what it printed and its exit code:
+//
+//	result, err := vt.RunCommand(ctx, "make test")
+//	if err == nil && result.ExitCode != 0 {
+//	    t.Fatalf("make test failed:\n%s", result.Text())
+//	}
+//
+// RunCommand waits for the prompt first. The end of the command is not
+// guessed from the output going quiet: if the shell emits OSC 133 semantic
+// prompt markers, their command start and end markers delimit the output
+// and report the exit code. Otherwise the command is wrapped in printf
+// commands that print unique markers before it and after it with $?, which
+// any POSIX shell understands. command must be a single command line; a
+// trailing ; is dropped, and a command that ends in & or has a comment is
+// rejected with ErrInvalidInput, since it would swallow the end marker.
+// RunCommand returns once the prompt is back. A command that fails is not
+// an error; check ExitCode.
+func (vt *VirtualTerminal) RunCommand(ctx context.Context, command string) (*CommandResult, error) {
+	command, err := commandLine(command)
+	if err != nil {
+		return nil, err
+	}
+	if err := vt.WaitForPrompt(ctx); err != nil {
+		return nil, err
+	}
+
+	line, capture := command, osc133Capture()
+	if !vt.prompt.semantic() {
+		token := strconv.FormatInt(time.Now().UnixNano(), 36)
+		line, capture = markerCommand(command, token), markerCapture(token)
+	}
+
+	// The subscriber is read until the command has finished, and removed
+	// before waiting for the prompt so that it cannot hold up ht
+	events := vt.subscribeRaw(WithEventTypes(EventTypeOutput), WithOverflow(OverflowBlock))
+	defer vt.Unsubscribe(events)
+
+	// The wrapped line is recorded as the command itself
+	cmd := htproto.Input(line + "\n")
+	if line != command {
+		cmd.Display = command + "\n"
+	}
+	start := time.Now()
+	if err := vt.sendCommand(ctx, cmd); err != nil {
+		return nil, err
+	}
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return nil, vt.closedErr()
+			}
+			if !capture.write(event.(OutputEvent).Seq) {
+				continue
+			}
+			result := capture.result()
+			result.Duration = time.Since(start)
+			if result.ExitCode >= 0 {
+				vt.stats.exit(result.ExitCode)
+			}
+			vt.Unsubscribe(events)
+			if err := vt.WaitForPrompt(ctx); err != nil {
+				return nil, err
+			}
+			return result, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-vt.current().ctx.Done():
+			return nil, vt.closedErr()
+		}
+	}
+}
+
+// Output starts a terminal running the configured shell, runs command in
+// it with RunCommand and closes the terminal, like exec.Cmd.CombinedOutput
+// with a real TTY and a shell to interpret the command line:
+//
+//	result, err := htlib.Output(ctx, htlib.DefaultConfig(), "go test ./...")
+//	fmt.Println(result.ExitCode, result.Text())
+//
+// A command that fails is not an error; check ExitCode. If ctx is done
+// first, the terminal is closed, killing the command, and ctx.Err() is
+// returned. Use RunOnce to run a program in place of the shell.
+func Output(ctx context.Context, config Config, command string) (*CommandResult, error) {
+	vt := New(config)
+	defer vt.Close()
+	if err := vt.Start(ctx, WaitReady()); err != nil {
+		return nil, err
+	}
+	return vt.RunCommand(ctx, command)
+}
+
+// commandLine checks that command can be followed by another command on
+// the same line and returns it without surrounding space and trailing ;.
+func commandLine(command string) (string, error) {
+	command = strings.TrimRight(strings.TrimSpace(command), "; \t")
+	switch {
+	case command == "" || strings.ContainsAny(command, "\r\n"):
+		return "", fmt.Errorf("%w: RunCommand needs a single command line", ErrInvalidInput)
+	case strings.HasSuffix(command, "&"):
+		return "", fmt.Errorf("%w: RunCommand cannot run a command line ending in &", ErrInvalidInput)
+	case hasComment(command):
+		return "", fmt.Errorf("%w: RunCommand cannot run a command line with a comment", ErrInvalidInput)
+	}
+	return command, nil
+}
+
+// hasComment reports whether command has a # starting a word outside of
+// quotes, which comments out the rest of the line.
+func hasComment(command string) bool {
+	var quote byte
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			}
+		case c == '\\':
+			i++
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '#' && (i == 0 || strings.IndexByte(" \t;&|()", command[i-1]) >= 0):
+			return true
+		}
+	}
+	return false
+}
+
+// markerCommand wraps command in printf commands that print the begin and
+// end markers matched by markerCapture. The format strings keep the
+// markers themselves out of the echoed command line, and each marker is
+// erased again (ESC 7, marker, ESC 8, erase to end of line). The shell
+// still echoes the whole wrapped line, so the screen shows it; scrollback
+// and transcripts show the command instead, and stripMarkers removes the
+// marker text from them.
+func markerCommand(command, token string) string {
+	begin := `printf '\0337<<%s:%s>>\0338\033[K' htlib-begin ` + token
+	end := `printf '\0337<<%s:%s:%d>>\0338\033[K' htlib-end ` + token + ` "$?"`
+	return begin + "; " + command + "; " + end
+}
+
+// markerText matches the text of the markers of markerCommand, which
+// plain-text output keeps after their escape sequences are removed.
+var markerText = regexp.MustCompile(`<<htlib-(?:begin|end):[0-9a-z]+(?::\d+)?>>`)
+
+// stripMarkers removes the markers of markerCommand from a line of plain
+// text.
+func stripMarkers(line string) string {
+	if !strings.Contains(line, "<<htlib-") {
+		return line
+	}
+	return markerText.ReplaceAllString(line, "")
+}
+
+// markerCapture finds the output between the markers of markerCommand.
+func markerCapture(token string) *commandCapture {
+	return &commandCapture{
+		begin: regexp.MustCompile(`<<htlib-begin:` + token + `>>\x1b8\x1b\[K`),
+		end:   regexp.MustCompile(`\x1b7<<htlib-end:` + token + `:(\d+)>>`),
+		start: -1,
+	}
+}
+
+// osc133Capture finds the output between the OSC 133 command start (C)
+// and command end (D;code) markers. Without a C marker, the output starts
+// on the line after the echoed command line.
+func osc133Capture() *commandCapture {
+	return &commandCapture{
+		begin: regexp.MustCompile(`\x1b\]133;C[^\x07\x1b]*(?:\x07|\x1b\\)`),
+		end:   regexp.MustCompile(`\x1b\]133;D(?:;(-?\d+))?[^\x07\x1b]*(?:\x07|\x1b\\)`),
+		start: -1,
+	}
+}
+
+// commandCapture collects terminal output until the end marker of a
+// command, searching only what is new on every write.
+type commandCapture struct {
+	begin, end *regexp.Regexp
+	output     strings.Builder
+	start      int    // offset of the command's output, or -1
+	finish     int    // offset of the end marker
+	code       string // exit code from the end marker
+	scanned    int    // length of output already searched
+}
+
+// write adds a chunk of output and reports whether the end marker has
+// been seen.
+func (c *commandCapture) write(seq string) bool {
+	c.output.WriteString(seq)
+	s := c.output.String()
+	from := max(c.scanned-markerLookback, 0)
+	c.scanned = len(s)
+
+	if c.start < 0 {
+		if loc := c.begin.FindStringIndex(s[from:]); loc != nil {
+			c.start = from + loc[1]
+		}
+	}
+	from = max(from, c.start)
+	m := c.end.FindStringSubmatchIndex(s[from:])
+	if m == nil {
+		return false
+	}
+	c.finish = from + m[0]
+	if m[2] >= 0 {
+		c.code = s[from+m[2] : from+m[3]]
+	}
+	return true
+}
+
+// result returns the output and exit code once write has returned true.
+func (c *commandCapture) result() *CommandResult {
+	s := c.output.String()
+	start := c.start
+	if start < 0 {
+		// Skip the echoed command line
+		start = c.finish
+		if i := strings.IndexByte(s[:c.finish], '\n'); i >= 0 {
+			start = i + 1
+		}
+	}
+	code, err := strconv.Atoi(c.code)
+	if err != nil {
+		code = -1
+	}
+	return &CommandResult{Output: s[start:c.finish], ExitCode: code}
+}
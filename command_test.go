@@ -0,0 +1,181 @@
+package htlib
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunCommand(t *testing.T) {
+	vt := New(DefaultConfig())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := vt.Start(ctx, WaitReady()); err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+	defer vt.Close()
+	tr := vt.RecordTranscript()
+
+	result, err := vt.RunCommand(ctx, "echo one; echo two; false")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text := result.Text(); text != "one\ntwo\n" {
+		t.Errorf("unexpected output %q", text)
+	}
+	if result.ExitCode != 1 || result.Duration <= 0 {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	// Output without a final newline, and a command right after the last
+	result, err = vt.RunCommand(ctx, "printf 'a\\nb'; (exit 7)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text := result.Text(); text != "a\nb" || result.ExitCode != 7 {
+		t.Errorf("unexpected result %q %d", text, result.ExitCode)
+	}
+	if !vt.AtPrompt() {
+		t.Error("expected the prompt after RunCommand")
+	}
+	if text := vt.Screen().Text(); strings.Contains(text, "<<htlib") {
+		t.Errorf("expected the markers to be erased, got %q", text)
+	}
+
+	// A trailing ; is dropped rather than doubled
+	result, err = vt.RunCommand(ctx, "echo three; ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text := result.Text(); text != "three\n" || result.ExitCode != 0 {
+		t.Errorf("unexpected result %q %d", text, result.ExitCode)
+	}
+
+	if _, err := vt.RunCommand(ctx, "echo a\necho b"); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput for several lines, got %v", err)
+	}
+
+	// The summary, transcript and scrollback show the commands as given
+	commands := vt.Summary().Commands
+	if len(commands) != 3 {
+		t.Fatalf("expected 3 commands, got %+v", commands)
+	}
+	for i, expected := range []struct {
+		command string
+		code    int
+	}{{"echo one; echo two; false", 1}, {"printf 'a\\nb'; (exit 7)", 7}, {"echo three", 0}} {
+		c := commands[i]
+		if c.Command != expected.command || c.ExitCode == nil || *c.ExitCode != expected.code {
+			t.Errorf("command %d: expected %q exiting %d, got %+v", i, expected.command, expected.code, c)
+		}
+	}
+	tr.Close()
+	transcript := tr.Transcript()
+	if len(transcript.Entries) < 2 || transcript.Entries[1].Input != "echo one; echo two; false\n" {
+		t.Errorf("expected the command as input, got %+v", transcript.Entries)
+	}
+	page, err := vt.ReadScrollback(ctx, 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := transcript.String() + strings.Join(page.Lines, "\n")
+	if strings.Contains(text, "htlib") || strings.Contains(text, `"$?"`) {
+		t.Errorf("expected no markers or wrapper, got %q", text)
+	}
+}
+
+func TestCommandLine(t *testing.T) {
+	tests := []struct {
+		command string
+		line    string
+		valid   bool
+	}{
+		{"  make test ", "make test", true},
+		{"echo a; ;", "echo a", true},
+		{"echo $# 'a #b' \"#\" c\\#d e#f", "echo $# 'a #b' \"#\" c\\#d e#f", true},
+		{"true && echo ok", "true && echo ok", true},
+		{"sleep 10 &", "", false},
+		{"make # build it", "", false},
+		{"echo 'it''s' #", "", false},
+		{";", "", false},
+		{"a\nb", "", false},
+	}
+	for _, tt := range tests {
+		line, err := commandLine(tt.command)
+		if tt.valid && (err != nil || line != tt.line) {
+			t.Errorf("%q: expected %q, got %q %v", tt.command, tt.line, line, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("%q: expected ErrInvalidInput, got %q %v", tt.command, line, err)
+		}
+	}
+}
+
+func TestOutput(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := Output(ctx, DefaultConfig(), "echo hello $((6 * 7)); false")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text := result.Text(); text != "hello 42\n" || result.ExitCode != 1 {
+		t.Errorf("unexpected result %q %d", text, result.ExitCode)
+	}
+
+	// A command that outlives ctx is killed
+	short, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	if _, err := Output(short, DefaultConfig(), "sleep 30"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline, got %v", err)
+	}
+}
+
+func TestCommandCapture(t *testing.T) {
+	tests := []struct {
+		name    string
+		capture *commandCapture
+		chunks  []string
+		output  string
+		code    int
+	}{
+		{
+			name:    "markers split across chunks",
+			capture: markerCapture("t1"),
+			chunks:  []string{"$ printf ...\r\n\x1b7<<htlib-beg", "in:t1>>\x1b8\x1b[Khello\r\n\x1b7<<htlib-e", "nd:t1:3>>\x1b8\x1b[K$ "},
+			output:  "hello\r\n",
+			code:    3,
+		},
+		{
+			name:    "OSC 133",
+			capture: osc133Capture(),
+			chunks:  []string{"$ ls\r\n\x1b]133;C\x07a.txt\r\n", "\x1b]133;D;0\x1b\\\x1b]133;A\x07$ "},
+			output:  "a.txt\r\n",
+			code:    0,
+		},
+		{
+			name:    "OSC 133 without C or exit code",
+			capture: osc133Capture(),
+			chunks:  []string{"$ ls\r\na.txt\r\n\x1b]133;D\x07"},
+			output:  "a.txt\r\n",
+			code:    -1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			done := false
+			for _, chunk := range tt.chunks {
+				done = tt.capture.write(chunk)
+			}
+			if !done {
+				t.Fatal("expected the end marker")
+			}
+			if result := tt.capture.result(); result.Output != tt.output || result.ExitCode != tt.code {
+				t.Errorf("expected %q %d, got %q %d", tt.output, tt.code, result.Output, result.ExitCode)
+			}
+		})
+	}
+}
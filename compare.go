@@ -0,0 +1,65 @@
+package htlib
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// ReflowText undoes soft-wrapping in text rendered at the given column width.
+// A row is treated as soft-wrapped when it fills every column and does not end
+// in a space; it is joined with the row that follows it. The result has
+// trailing whitespace trimmed from every logical line and trailing blank lines
+// removed, so the same content rendered at different widths reflows to the
+// same string.
+//
+// A row that wrapped exactly at a space is indistinguishable from a short
+// line padded to the terminal width, so such rows are left unjoined.
+func ReflowText(text string, cols int) string {
+	rows := strings.Split(text, "\n")
+
+	var lines []string
+	var current strings.Builder
+	for i, row := range rows {
+		current.WriteString(row)
+		if i < len(rows)-1 && isSoftWrapped(row, cols) {
+			continue
+		}
+		lines = append(lines, strings.TrimRight(current.String(), " \t"))
+		current.Reset()
+	}
+
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// isSoftWrapped reports whether row fills the full terminal width, meaning
+// the content most likely continues on the next row.
+func isSoftWrapped(row string, cols int) bool {
+	if cols <= 0 || StringWidth(row) < cols {
+		return false
+	}
+	last, _ := utf8.DecodeLastRuneInString(row)
+	return last != ' '
+}
+
+// ReflowedText returns the snapshot text with soft-wrapping undone.
+// See ReflowText for details.
+func (e SnapshotEvent) ReflowedText() string {
+	return ReflowText(e.Text, e.Cols)
+}
+
+// EqualReflowed reports whether two snapshots show the same content once
+// soft-wrapping is undone, ignoring differences in terminal width.
+func EqualReflowed(a, b SnapshotEvent) bool {
+	return a.ReflowedText() == b.ReflowedText()
+}
+
+// ContainsReflowed reports whether the snapshot contains substr once
+// soft-wrapping is undone, so text split across rows by a narrow terminal
+// still matches.
+func (e SnapshotEvent) ContainsReflowed(substr string) bool {
+	return strings.Contains(e.ReflowedText(), substr)
+}
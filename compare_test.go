@@ -0,0 +1,79 @@
+package htlib
+
+import "testing"
+
+func TestReflowText(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		cols     int
+		expected string
+	}{
+		{
+			name:     "no wrapping",
+			text:     "hello   \nworld   ",
+			cols:     8,
+			expected: "hello\nworld",
+		},
+		{
+			name:     "soft wrapped line",
+			text:     "abcdefgh\nij      \n        ",
+			cols:     8,
+			expected: "abcdefghij",
+		},
+		{
+			name:     "full line ending in space is not wrapped",
+			text:     "abcdefg \nhij",
+			cols:     8,
+			expected: "abcdefg\nhij",
+		},
+		{
+			name:     "multiple wraps",
+			text:     "abcd\nefgh\nij",
+			cols:     4,
+			expected: "abcdefghij",
+		},
+		{
+			name:     "unknown width",
+			text:     "abcd\nefgh",
+			cols:     0,
+			expected: "abcd\nefgh",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ReflowText(tt.text, tt.cols)
+			if result != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestEqualReflowed(t *testing.T) {
+	wide := SnapshotEvent{
+		Cols: 20,
+		Rows: 3,
+		Text: "$ echo greetings    \ngreetings           \n$                   ",
+	}
+	narrow := SnapshotEvent{
+		Cols: 6,
+		Rows: 6,
+		Text: "$ echo\n greet\nings  \ngreeti\nngs   \n$     ",
+	}
+
+	if !EqualReflowed(wide, narrow) {
+		t.Errorf("expected snapshots to be equal, got %q and %q",
+			wide.ReflowedText(), narrow.ReflowedText())
+	}
+
+	if !narrow.ContainsReflowed("echo greetings") {
+		t.Error("expected narrow snapshot to contain wrapped text")
+	}
+
+	other := SnapshotEvent{Cols: 12, Rows: 1, Text: "$ echo bye  "}
+	if EqualReflowed(wide, other) {
+		t.Error("expected snapshots to differ")
+	}
+}
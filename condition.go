@@ -0,0 +1,126 @@
+package htlib
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// globalConditions holds the conditions registered with RegisterCondition.
+var globalConditions = struct {
+	sync.RWMutex
+	m map[string]Matcher
+}{m: make(map[string]Matcher)}
+
+// RegisterCondition gives a Matcher a name that every terminal can wait for,
+// so important application states are defined once and referenced by name
+// from input scripts (#until name), agents and tests:
+//
+//	htlib.RegisterCondition("logged-in", htlib.ScreenMatches(regexp.MustCompile(`Welcome, \w+`)))
+//	...
+//	err := vt.WaitForCondition(ctx, "logged-in")
+//
+// Registering a name again replaces the condition. A nil Matcher removes it.
+func RegisterCondition(name string, m Matcher) {
+	globalConditions.Lock()
+	defer globalConditions.Unlock()
+	registerCondition(globalConditions.m, name, m)
+}
+
+// RegisterCondition registers a condition for this terminal only. It takes
+// precedence over a global condition of the same name.
+func (vt *VirtualTerminal) RegisterCondition(name string, m Matcher) {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	if vt.conditions == nil {
+		vt.conditions = make(map[string]Matcher)
+	}
+	registerCondition(vt.conditions, name, m)
+}
+
+// registerCondition adds m to conditions, or removes name if m is nil.
+func registerCondition(conditions map[string]Matcher, name string, m Matcher) {
+	if m == nil {
+		delete(conditions, name)
+		return
+	}
+	conditions[name] = m
+}
+
+// LookupCondition returns the condition registered under name, on the
+// terminal or else globally.
+func (vt *VirtualTerminal) LookupCondition(name string) (Matcher, bool) {
+	vt.mu.RLock()
+	m, ok := vt.conditions[name]
+	vt.mu.RUnlock()
+	if ok {
+		return m, true
+	}
+
+	globalConditions.RLock()
+	defer globalConditions.RUnlock()
+	m, ok = globalConditions.m[name]
+	return m, ok
+}
+
+// Conditions returns the names of the conditions available on the
+// terminal, sorted, for agents that discover the states they can wait for.
+func (vt *VirtualTerminal) Conditions() []string {
+	names := make(map[string]bool)
+	vt.mu.RLock()
+	for name := range vt.conditions {
+		names[name] = true
+	}
+	vt.mu.RUnlock()
+	globalConditions.RLock()
+	for name := range globalConditions.m {
+		names[name] = true
+	}
+	globalConditions.RUnlock()
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// Condition returns a Matcher that evaluates the condition registered
+// under name when it is used, so it can be combined with Expect and
+// triggers. A name that is not registered never matches.
+func Condition(name string) Matcher {
+	return matcher{
+		desc: fmt.Sprintf("condition %q", name),
+		fn: func(mc *MatchContext) []string {
+			if mc.vt == nil {
+				return nil
+			}
+			m, ok := mc.vt.LookupCondition(name)
+			if !ok {
+				return nil
+			}
+			return m.Match(mc)
+		},
+	}
+}
+
+// WaitForCondition waits until the condition registered under name
+// matches and returns the match. Output matchers see the output from the
+// time of the call. It returns an error matching ErrUnknownCondition if no
+// condition has that name.
+func (vt *VirtualTerminal) WaitForCondition(ctx context.Context, name string) ([]string, error) {
+	m, ok := vt.LookupCondition(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownCondition, name)
+	}
+
+	e := vt.NewExpecter()
+	defer e.Close()
+	result, err := e.Expect(ctx, ExpectCase{Matcher: m})
+	if err != nil {
+		return nil, err
+	}
+	return result.Match, nil
+}
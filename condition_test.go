@@ -0,0 +1,94 @@
+package htlib
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegisterCondition(t *testing.T) {
+	RegisterCondition("test-ready", OutputContains("ready"))
+	defer RegisterCondition("test-ready", nil)
+
+	vt, _ := newTestTerminal()
+	defer vt.Close()
+	other, _ := newTestTerminal()
+	defer other.Close()
+
+	// Conditions on a terminal take precedence over global ones
+	vt.RegisterCondition("test-ready", OutputContains("go"))
+	vt.RegisterCondition("test-local", OutputContains("local"))
+
+	if m, ok := other.LookupCondition("test-ready"); !ok || describeMatcher(m) != `output contains "ready"` {
+		t.Errorf("expected the global condition, got %v", m)
+	}
+	if m, ok := vt.LookupCondition("test-ready"); !ok || describeMatcher(m) != `output contains "go"` {
+		t.Errorf("expected the terminal's condition, got %v", m)
+	}
+	if _, ok := other.LookupCondition("test-local"); ok {
+		t.Error("expected the terminal's condition to be private")
+	}
+	if names := vt.Conditions(); !reflect.DeepEqual(names, []string{"test-local", "test-ready"}) {
+		t.Errorf("unexpected names %v", names)
+	}
+
+	vt.RegisterCondition("test-local", nil)
+	if _, ok := vt.LookupCondition("test-local"); ok {
+		t.Error("expected the condition to be removed")
+	}
+}
+
+func TestWaitForCondition(t *testing.T) {
+	vt, _ := newTestTerminal()
+	defer vt.Close()
+	vt.RegisterCondition("logged-in", OutputContains("Welcome"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if _, err := vt.WaitForCondition(ctx, "missing"); !errors.Is(err, ErrUnknownCondition) {
+		t.Errorf("expected ErrUnknownCondition, got %v", err)
+	}
+
+	// The output is repeated until the wait has subscribed to it
+	welcome := func() (stop func()) {
+		done := make(chan struct{})
+		go func() {
+			for {
+				select {
+				case <-done:
+					return
+				case <-time.After(10 * time.Millisecond):
+					vt.dispatch(OutputEvent{Seq: "Welcome, admin\r\n"})
+				}
+			}
+		}()
+		return func() { close(done) }
+	}
+
+	stop := welcome()
+	match, err := vt.WaitForCondition(ctx, "logged-in")
+	stop()
+	if err != nil || match[0] != "Welcome" {
+		t.Fatalf("unexpected result %v, %v", match, err)
+	}
+
+	// Input scripts and Expect refer to conditions by name
+	stop = welcome()
+	err = vt.PlayInputScript(ctx, strings.NewReader("#until logged-in"))
+	stop()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := vt.NewExpecter()
+	defer e.Close()
+	vt.dispatch(OutputEvent{Seq: "Welcome again\r\n"})
+	result, err := e.Expect(ctx, ExpectCase{Matcher: Condition("unknown")}, ExpectCase{Matcher: Condition("logged-in")})
+	if err != nil || result.Index != 1 {
+		t.Errorf("unexpected result %+v, %v", result, err)
+	}
+}
@@ -0,0 +1,127 @@
+package htlib
+
+import (
+	"context"
+	"fmt"
+)
+
+// Cursor is the position, visibility and shape of the terminal cursor. Row
+// and Col are 0-based, with row 0 at the top of the screen.
+type Cursor struct {
+	Row      int
+	Col      int
+	Visible  bool
+	Shape    CursorShape
+	Blinking bool
+}
+
+// CursorShape is the cursor shape selected with DECSCUSR (CSI Ps SP q).
+type CursorShape int
+
+// Cursor shapes.
+const (
+	// CursorDefault is the terminal's default shape, usually a block
+	CursorDefault CursorShape = iota
+	CursorBlock
+	CursorUnderline
+	CursorBar
+)
+
+// String returns the name of the shape.
+func (s CursorShape) String() string {
+	switch s {
+	case CursorBlock:
+		return "block"
+	case CursorUnderline:
+		return "underline"
+	case CursorBar:
+		return "bar"
+	default:
+		return "default"
+	}
+}
+
+// Cursor returns the current cursor state from the local screen model (see
+// Screen), so it is known before sending input without asking ht for a
+// snapshot:
+//
+//	if c := vt.Cursor(); c.Row == fieldRow && c.Col >= fieldCol {
+//	    vt.Input(ctx, "value")
+//	}
+func (vt *VirtualTerminal) Cursor() Cursor {
+	vt.screen.mu.Lock()
+	defer vt.screen.mu.Unlock()
+	return vt.screen.cursor
+}
+
+// WaitForCursorAt waits until the cursor is at the given 0-based row and
+// column and returns the snapshot showing it there. This is useful for
+// checking where navigation keys left the cursor in a TUI.
+func (vt *VirtualTerminal) WaitForCursorAt(ctx context.Context, row, col int, opts ...WaitOption) (*SnapshotEvent, error) {
+	return vt.WaitFor(ctx, func(snapshot *SnapshotEvent) bool {
+		return snapshot.Cursor.Row == row && snapshot.Cursor.Col == col
+	}, opts...)
+}
+
+// Cursor returns the cursor state from the local screen model, which is up
+// to date with the event being evaluated. Unlike Screen it never takes a
+// snapshot.
+func (mc *MatchContext) Cursor() Cursor {
+	if mc.vt == nil {
+		return Cursor{}
+	}
+	return mc.vt.Cursor()
+}
+
+// CursorAt matches when the cursor is at the 0-based row and column. The
+// matched text is empty.
+func CursorAt(row, col int) Matcher {
+	return cursorMatcher(fmt.Sprintf("cursor at %d,%d", row, col), func(c Cursor) bool {
+		return c.Row == row && c.Col == col
+	})
+}
+
+// CursorIn matches when the cursor is inside r, such as the input field of
+// a form:
+//
+//	vt.SendKeys(ctx, htlib.KeyTab)
+//	e.Expect(ctx, htlib.ExpectCase{Matcher: htlib.CursorIn(passwordField)})
+func CursorIn(r Rect) Matcher {
+	return cursorMatcher(fmt.Sprintf("cursor in %+v", r), func(c Cursor) bool {
+		return c.Row >= r.Row && c.Row < r.Row+r.Rows && c.Col >= r.Col && c.Col < r.Col+r.Cols
+	})
+}
+
+// CursorMovedFrom matches once the cursor has left the 0-based row and
+// column. Pass the position from before sending a key to wait for the
+// program to react to it:
+//
+//	from := vt.Cursor()
+//	vt.SendKeys(ctx, htlib.KeyDown)
+//	e.Expect(ctx, htlib.ExpectCase{Matcher: htlib.CursorMovedFrom(from.Row, from.Col)})
+func CursorMovedFrom(row, col int) Matcher {
+	return cursorMatcher(fmt.Sprintf("cursor moved from %d,%d", row, col), func(c Cursor) bool {
+		return c.Row != row || c.Col != col
+	})
+}
+
+// cursorMatcher returns a matcher testing the cursor with fn.
+func cursorMatcher(desc string, fn func(Cursor) bool) Matcher {
+	return matcher{
+		desc: desc,
+		fn: func(mc *MatchContext) []string {
+			if mc.vt != nil && fn(mc.Cursor()) {
+				return []string{""}
+			}
+			return nil
+		},
+	}
+}
+
+// parseCursor replays a screen dump of the given size and returns where
+// the cursor ends up.
+func parseCursor(seq string, cols, rows int) Cursor {
+	m := newScreenModel(cols, rows)
+	m.feed(seq)
+	return m.cursor
+}
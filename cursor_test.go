@@ -0,0 +1,180 @@
+package htlib
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCursor(t *testing.T) {
+	tests := []struct {
+		name     string
+		seq      string
+		expected Cursor
+	}{
+		{"empty", "", Cursor{Row: 0, Col: 0, Visible: true}},
+		{"text", "hello", Cursor{Row: 0, Col: 5, Visible: true}},
+		{"newlines", "a\r\nbc\r\nd", Cursor{Row: 2, Col: 1, Visible: true}},
+		{"absolute", "\x1b[2J\x1b[5;10Hx\x1b[3;7H", Cursor{Row: 2, Col: 6, Visible: true}},
+		{"default params", "abc\x1b[H", Cursor{Row: 0, Col: 0, Visible: true}},
+		{"relative", "\x1b[10;10H\x1b[2A\x1b[3C\x1b[B\x1b[D", Cursor{Row: 8, Col: 11, Visible: true}},
+		{"column and row", "\x1b[4d\x1b[20G", Cursor{Row: 3, Col: 19, Visible: true}},
+		{"hidden", "\x1b[?25l\x1b[2;2H", Cursor{Row: 1, Col: 1, Visible: false}},
+		{"shown again", "\x1b[?25l\x1b[?1049;25h", Cursor{Row: 0, Col: 0, Visible: true}},
+		{"save restore", "\x1b[3;3H\x1b7\x1b[9;9H\x1b8", Cursor{Row: 2, Col: 2, Visible: true}},
+		{"clamped", "\x1b[99;99H", Cursor{Row: 23, Col: 79, Visible: true}},
+		{"pending wrap", strings.Repeat("x", 80), Cursor{Row: 0, Col: 79, Visible: true}},
+		{"wrapped", strings.Repeat("x", 81), Cursor{Row: 1, Col: 1, Visible: true}},
+		{"scroll at bottom", "\x1b[24;1H\n\n", Cursor{Row: 23, Col: 0, Visible: true}},
+		{"tab", "ab\tc", Cursor{Row: 0, Col: 9, Visible: true}},
+		{"backspace", "abc\b\b", Cursor{Row: 0, Col: 1, Visible: true}},
+		{"sgr ignored", "\x1b[1;31mred\x1b[0m", Cursor{Row: 0, Col: 3, Visible: true}},
+		{"multibyte runes count once", "héllo", Cursor{Row: 0, Col: 5, Visible: true}},
+		{"wide runes take two columns", "a中b", Cursor{Row: 0, Col: 4, Visible: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseCursor(tt.seq, 80, 24); got != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestWaitForCursorAt(t *testing.T) {
+	vt := New(DefaultConfig())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := vt.Start(ctx, WaitReady()); err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+	defer vt.Close()
+
+	snapshot, err := vt.WaitForSnapshot(ctx)
+	if err != nil {
+		t.Fatalf("failed to take snapshot: %v", err)
+	}
+	prompt := snapshot.Cursor
+
+	// Typing moves the cursor right without submitting the line
+	if err := vt.Input(ctx, "abc"); err != nil {
+		t.Fatalf("failed to send input: %v", err)
+	}
+	snapshot, err = vt.WaitForCursorAt(ctx, prompt.Row, prompt.Col+3)
+	if err != nil {
+		t.Fatalf("cursor did not move: %v", err)
+	}
+	if !snapshot.Cursor.Visible {
+		t.Error("expected cursor to be visible")
+	}
+}
+
+func TestCursorShape(t *testing.T) {
+	tests := []struct {
+		seq      string
+		shape    CursorShape
+		blinking bool
+	}{
+		{"", CursorDefault, false},
+		{"\x1b[2 q", CursorBlock, false},
+		{"\x1b[1 q", CursorBlock, true},
+		{"\x1b[3 q", CursorUnderline, true},
+		{"\x1b[4 q", CursorUnderline, false},
+		{"\x1b[5 q", CursorBar, true},
+		{"\x1b[6 q", CursorBar, false},
+		{"\x1b[6 q\x1b[?12h", CursorBar, true},
+		{"\x1b[5 q\x1b[0 q", CursorDefault, false},
+		{"\x1b[6 q\x1bc", CursorDefault, false},
+	}
+
+	for _, tt := range tests {
+		c := parseCursor(tt.seq, 80, 24)
+		if c.Shape != tt.shape || c.Blinking != tt.blinking {
+			t.Errorf("%q: expected %v (blinking %v), got %v (blinking %v)", tt.seq, tt.shape, tt.blinking, c.Shape, c.Blinking)
+		}
+	}
+	if CursorBar.String() != "bar" || CursorDefault.String() != "default" {
+		t.Error("unexpected shape names")
+	}
+}
+
+func TestVirtualTerminalCursor(t *testing.T) {
+	vt, _ := newTestTerminal()
+	defer vt.Close()
+
+	vt.trackEvent(InitEvent{Cols: 80, Rows: 24, Seq: "\x1b[2J\x1b[H$ "})
+	if c := vt.Cursor(); c != (Cursor{Row: 0, Col: 2, Visible: true}) {
+		t.Errorf("unexpected cursor after init: %+v", c)
+	}
+
+	// A form moves the cursor into its second field with a bar cursor
+	vt.trackEvent(OutputEvent{Seq: "\x1b[?1049h\x1b[5;12H\x1b[6 q"})
+	expected := Cursor{Row: 4, Col: 11, Visible: true, Shape: CursorBar}
+	if c := vt.Cursor(); c != expected {
+		t.Errorf("expected %+v, got %+v", expected, c)
+	}
+}
+
+func TestCursorMatchers(t *testing.T) {
+	vt, _ := newTestTerminal()
+	defer vt.Close()
+
+	vt.trackEvent(InitEvent{Cols: 20, Rows: 5, Seq: "\x1b[2J\x1b[3;8H"})
+	mc := vt.newMatchContext(context.Background(), nil, "")
+
+	tests := []struct {
+		matcher  Matcher
+		expected bool
+	}{
+		{CursorAt(2, 7), true},
+		{CursorAt(2, 8), false},
+		{CursorIn(Rect{Row: 2, Col: 5, Rows: 1, Cols: 10}), true},
+		{CursorIn(Rect{Row: 2, Col: 8, Rows: 1, Cols: 10}), false},
+		{CursorIn(Rect{Row: 0, Col: 0, Rows: 2, Cols: 20}), false},
+		{CursorMovedFrom(2, 7), false},
+		{CursorMovedFrom(0, 0), true},
+	}
+	for _, tt := range tests {
+		if got := tt.matcher.Match(mc) != nil; got != tt.expected {
+			t.Errorf("%s: expected %v, got %v", describeMatcher(tt.matcher), tt.expected, got)
+		}
+	}
+
+	// Without a terminal there is no cursor to match
+	if CursorAt(0, 0).Match(&MatchContext{}) != nil {
+		t.Error("expected no match without a terminal")
+	}
+}
+
+func TestExpectCursorMove(t *testing.T) {
+	vt, _ := newTestTerminal()
+	defer vt.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	vt.trackEvent(InitEvent{Cols: 40, Rows: 5, Seq: "\x1b[2JUser: \x1b[2;1HPass: \x1b[1;7H"})
+	e := vt.NewExpecter()
+	defer e.Close()
+
+	// Tabbing to the next field moves the cursor without printing anything
+	from := vt.Cursor()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		event := OutputEvent{Seq: "\x1b[2;7H"}
+		vt.trackEvent(event)
+		vt.dispatch(event)
+	}()
+	result, err := e.Expect(ctx,
+		ExpectCase{Matcher: CursorIn(Rect{Row: 1, Col: 6, Rows: 1, Cols: 20})},
+		ExpectCase{Matcher: CursorMovedFrom(from.Row, from.Col)},
+	)
+	if err != nil {
+		t.Fatalf("expect failed: %v", err)
+	}
+	if result.Index != 0 {
+		t.Errorf("expected the cursor in the password field, got case %d", result.Index)
+	}
+}
@@ -97,10 +97,10 @@
 //	    <-vt.Events() // Wait for init
 //
 //	    vt.Input(context.Background(), "my-cli --version\n")
-//	    time.Sleep(100 * time.Millisecond)
 //
-//	    snapshot, _ := vt.WaitForSnapshot(context.Background())
-//	    if !strings.Contains(snapshot.Text, "v1.0.0") {
+//	    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+//	    defer cancel()
+//	    if _, err := vt.WaitForText(ctx, "v1.0.0"); err != nil {
 //	        t.Error("Version not found")
 //	    }
 //	}
@@ -1,6 +1,10 @@
 package htlib
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
 
 var (
 	// ErrNotStarted is returned when attempting to use a VirtualTerminal that hasn't been started.
@@ -12,12 +16,104 @@ var (
 	// ErrClosed is returned when attempting to use a closed VirtualTerminal.
 	ErrClosed = errors.New("virtual terminal closed")
 
+	// ErrTerminalClosed is returned by waits that were still outstanding when
+	// the terminal was closed or its process exited. The error is always a
+	// *TerminalClosedError and also matches ErrClosed.
+	ErrTerminalClosed = errors.New("terminal closed while waiting")
+
+	// ErrBusy is returned by TryInput and TrySendKeys when earlier commands
+	// are still being written to ht.
+	ErrBusy = errors.New("terminal busy writing earlier commands")
+
 	// ErrTimeout is returned when an operation times out.
 	ErrTimeout = errors.New("operation timed out")
 
+	// ErrBudgetExceeded is matched by the *BudgetError returned when
+	// ExpectBatch steps took longer than their timing budget.
+	ErrBudgetExceeded = errors.New("timing budget exceeded")
+
 	// ErrInvalidEvent is returned when an invalid event is received.
 	ErrInvalidEvent = errors.New("invalid event received")
 
 	// ErrProcessExited is returned when the ht process exits unexpectedly.
 	ErrProcessExited = errors.New("ht process exited")
+
+	// ErrInvalidInput is returned when input cannot be encoded for ht.
+	ErrInvalidInput = errors.New("invalid input")
+
+	// ErrUnknownCondition is returned when waiting for a condition name
+	// that has not been registered.
+	ErrUnknownCondition = errors.New("unknown condition")
+
+	// ErrInvalidRecording is returned when a recording file cannot be read.
+	ErrInvalidRecording = errors.New("invalid recording")
+
+	// ErrUnsupported is returned when an operation is not available on the current platform.
+	ErrUnsupported = errors.New("operation not supported on this platform")
 )
+
+// TerminalClosedError reports that a wait was interrupted because the
+// terminal was closed or its process exited. It matches both
+// ErrTerminalClosed and ErrClosed with errors.Is.
+type TerminalClosedError struct {
+	// Screen is the last screen observed before the terminal closed, or nil
+	// if none was seen
+	Screen *SnapshotEvent
+}
+
+func (e *TerminalClosedError) Error() string {
+	if e.Screen == nil {
+		return ErrTerminalClosed.Error()
+	}
+	return fmt.Sprintf("%s (last screen %dx%d)", ErrTerminalClosed, e.Screen.Cols, e.Screen.Rows)
+}
+
+// Is reports whether target is ErrTerminalClosed or ErrClosed.
+func (e *TerminalClosedError) Is(target error) bool {
+	return target == ErrTerminalClosed || target == ErrClosed
+}
+
+// ProcessExitError reports that the ht process ended with a failure, with
+// the end of what it wrote to standard error. It is returned by Err after
+// a non-zero exit and by Start when ht exits before starting the program,
+// and matches ErrProcessExited with errors.Is:
+//
+//	var exitErr *htlib.ProcessExitError
+//	if errors.As(vt.Err(), &exitErr) {
+//	    log.Printf("%s\n%s", exitErr.Status, strings.Join(exitErr.Stderr, "\n"))
+//	}
+type ProcessExitError struct {
+	// Status is how ht ended; ht passes on the exit code or signal of the
+	// program it runs
+	Status ExitStatus
+	// Stderr are the last lines ht wrote to its standard error, up to 100
+	Stderr []string
+	// Started is set if ht had started the program before it exited
+	Started bool
+	// Err is the error from waiting for the process, if any
+	Err error
+}
+
+func (e *ProcessExitError) Error() string {
+	var b strings.Builder
+	b.WriteString(ErrProcessExited.Error())
+	if !e.Started {
+		b.WriteString(" before starting the program")
+	}
+	b.WriteString(": " + e.Status.String())
+	// The last lines usually say what went wrong
+	if tail := e.Stderr[max(len(e.Stderr)-3, 0):]; len(tail) > 0 {
+		b.WriteString(": " + strings.Join(tail, "; "))
+	}
+	return b.String()
+}
+
+// Is reports whether target is ErrProcessExited.
+func (e *ProcessExitError) Is(target error) bool {
+	return target == ErrProcessExited
+}
+
+// Unwrap returns the error from waiting for the process.
+func (e *ProcessExitError) Unwrap() error {
+	return e.Err
+}
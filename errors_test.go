@@ -0,0 +1,105 @@
+package htlib
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTerminalClosedError(t *testing.T) {
+	err := error(&TerminalClosedError{Screen: &SnapshotEvent{Cols: 80, Rows: 24, Text: "$"}})
+
+	if !errors.Is(err, ErrTerminalClosed) {
+		t.Error("expected error to match ErrTerminalClosed")
+	}
+	if !errors.Is(err, ErrClosed) {
+		t.Error("expected error to match ErrClosed")
+	}
+
+	var closedErr *TerminalClosedError
+	if !errors.As(err, &closedErr) || closedErr.Screen.Text != "$" {
+		t.Error("expected final screen to be available via errors.As")
+	}
+}
+
+// htWrapper returns an ht binary that runs script before anything else.
+func htWrapper(t *testing.T, script string) string {
+	path := filepath.Join(t.TempDir(), "ht-wrapper")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestProcessExitErrorBeforeStart(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	config := DefaultConfig()
+	config.HtBinary = htWrapper(t, "echo 'Error: no such binary' >&2\nexit 2\n")
+	vt := New(config)
+	defer vt.Close()
+
+	// Without retries Start returns before ht fails; Err reports it
+	if err := vt.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vt.WaitForExit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	checkBeforeStart(t, vt.Err())
+
+	// With retries Start waits for ht and returns the error
+	vt = New(config)
+	defer vt.Close()
+	checkBeforeStart(t, vt.Start(ctx, WithStartRetry(2, time.Millisecond)))
+}
+
+func checkBeforeStart(t *testing.T, err error) {
+	t.Helper()
+	var exitErr *ProcessExitError
+	if !errors.As(err, &exitErr) || !errors.Is(err, ErrProcessExited) {
+		t.Fatalf("expected a *ProcessExitError, got %v", err)
+	}
+	if exitErr.Started || exitErr.Status.Code != 2 || len(exitErr.Stderr) != 1 {
+		t.Errorf("unexpected error %+v", exitErr)
+	}
+	if want := "ht process exited before starting the program: exit status 2: Error: no such binary"; exitErr.Error() != want {
+		t.Errorf("expected %q, got %q", want, exitErr.Error())
+	}
+	var waitErr *exec.ExitError
+	if !errors.As(err, &waitErr) {
+		t.Error("expected the wait error to be unwrapped")
+	}
+}
+
+func TestProcessExitErrorAfterExit(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	config := DefaultConfig()
+	config.HtBinary = htWrapper(t, "echo 'warning: from ht' >&2\nexec ht \"$@\"\n")
+	vt := New(config)
+	defer vt.Close()
+	if err := vt.Start(ctx); err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+	if err := vt.Input(ctx, "exit 3\r"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vt.WaitForExit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var exitErr *ProcessExitError
+	if !errors.As(vt.Err(), &exitErr) || !errors.Is(vt.Err(), ErrProcessExited) {
+		t.Fatalf("expected a *ProcessExitError, got %v", vt.Err())
+	}
+	if !exitErr.Started || exitErr.Status.Code != 3 || len(exitErr.Stderr) != 1 || exitErr.Stderr[0] != "warning: from ht" {
+		t.Errorf("unexpected error %+v", exitErr)
+	}
+}
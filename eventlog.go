@@ -0,0 +1,177 @@
+package htlib
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+)
+
+// eventLogSize is the number of recent events kept for ChangesSince.
+const eventLogSize = 1024
+
+// Changes is the result of ChangesSince.
+type Changes struct {
+	// Seq is the sequence number of the last event in Events; pass it to
+	// the next ChangesSince call
+	Seq uint64
+	// Events are the events after the requested sequence number, oldest
+	// first, as received from ht before Config.OutputProcessors run
+	Events []Event
+	// Missed is set when some of the events after the requested sequence
+	// number are no longer kept. Screen then holds the screen as of Seq so
+	// the client can start over from it, and Events is empty.
+	Missed bool
+	Screen *Screen
+}
+
+// loggedEvent is an event with its sequence number.
+type loggedEvent struct {
+	seq   uint64
+	event Event
+}
+
+// eventLog keeps the most recent events, numbered from 1, and wakes up
+// readers waiting for new ones.
+type eventLog struct {
+	mu     sync.Mutex
+	seq    uint64
+	events []loggedEvent
+	notify chan struct{}
+
+	// init and snapshot are the last InitEvent and the last SnapshotEvent
+	// after it, kept for WithReplay however old they are
+	init, snapshot loggedEvent
+
+	// screen is the update count of the screen model (see
+	// screenModel.updates) as of the last event appended
+	screen uint64
+}
+
+// append records event and wakes up waiting readers. update is the
+// update count of the screen model after the event, or 0 if the event
+// does not change the screen. It returns the event's sequence number.
+func (l *eventLog) append(event Event, update uint64) uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.seq++
+	if update > 0 {
+		l.screen = update
+	}
+	if len(l.events) == eventLogSize {
+		copy(l.events, l.events[1:])
+		l.events = l.events[:len(l.events)-1]
+	}
+	logged := loggedEvent{seq: l.seq, event: event}
+	l.events = append(l.events, logged)
+	switch event.(type) {
+	case InitEvent:
+		l.init, l.snapshot = logged, loggedEvent{}
+	case SnapshotEvent:
+		l.snapshot = logged
+	}
+	if l.notify != nil {
+		close(l.notify)
+		l.notify = nil
+	}
+	return l.seq
+}
+
+// since returns the events after seq, or the screen from model if some
+// of them are no longer kept. Otherwise it returns a channel that is
+// closed when the next event is appended: if there are no events yet, or
+// if the screen model has been updated with an event that is about to be
+// appended, so that the screen and the sequence number would not match.
+func (l *eventLog) since(seq uint64, model *screenModel) (*Changes, <-chan struct{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	wait := func() (*Changes, <-chan struct{}) {
+		if l.notify == nil {
+			l.notify = make(chan struct{})
+		}
+		return nil, l.notify
+	}
+	if seq == l.seq {
+		return wait()
+	}
+
+	changes := &Changes{Seq: l.seq}
+	// A sequence number from the future comes from another session
+	first := l.seq + 1 - uint64(len(l.events))
+	if seq > l.seq || seq+1 < first {
+		screen, update := model.snapshotUpdate()
+		if update != l.screen {
+			return wait()
+		}
+		changes.Missed, changes.Screen = true, screen
+		return changes, nil
+	}
+	for _, e := range l.events[seq+1-first:] {
+		changes.Events = append(changes.Events, e.event)
+	}
+	return changes, nil
+}
+
+// recent returns the last InitEvent and SnapshotEvent and up to n of the
+// most recent events, oldest first, with the sequence number of the last
+// event appended.
+func (l *eventLog) recent(n int) ([]loggedEvent, uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	n = min(max(n, 0), len(l.events))
+	events := slices.Clone(l.events[len(l.events)-n:])
+	for _, e := range []loggedEvent{l.init, l.snapshot} {
+		if e.event == nil {
+			continue
+		}
+		if i, found := slices.BinarySearchFunc(events, e.seq, func(e loggedEvent, seq uint64) int {
+			return cmp.Compare(e.seq, seq)
+		}); !found {
+			events = slices.Insert(events, i, e)
+		}
+	}
+	return events, l.seq
+}
+
+// ChangesSince returns the events received after sequence number seq,
+// waiting until there is at least one. It is the primitive for clients
+// that poll over HTTP or gRPC rather than keeping a stream open:
+//
+//	var seq uint64
+//	for {
+//	    changes, err := vt.ChangesSince(ctx, seq)
+//	    if err != nil {
+//	        return err
+//	    }
+//	    if changes.Missed {
+//	        redraw(changes.Screen)
+//	    } else {
+//	        forward(changes.Events)
+//	    }
+//	    seq = changes.Seq
+//	}
+//
+// Pass 0 to get all events still kept. The most recent 1024 events are
+// kept; a client that falls further behind gets Missed set and the screen
+// as of Changes.Seq instead of events, so that no output is applied
+// twice. If the terminal closes while waiting, ChangesSince returns a
+// *TerminalClosedError.
+func (vt *VirtualTerminal) ChangesSince(ctx context.Context, seq uint64) (*Changes, error) {
+	for {
+		changes, wait := vt.log.since(seq, vt.screen)
+		if changes != nil {
+			return changes, nil
+		}
+
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-vt.current().ctx.Done():
+			return nil, vt.closedErr()
+		}
+	}
+}
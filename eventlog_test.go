@@ -0,0 +1,119 @@
+package htlib
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestChangesSince(t *testing.T) {
+	vt, _ := newTestTerminal()
+	defer vt.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	send := func(seq string) {
+		event := OutputEvent{Seq: seq}
+		vt.trackEvent(event)
+		vt.dispatch(event)
+		<-vt.Events()
+	}
+	send("a")
+	send("b")
+
+	changes, err := vt.ChangesSince(ctx, 0)
+	if err != nil {
+		t.Fatalf("ChangesSince failed: %v", err)
+	}
+	if changes.Seq != 2 || len(changes.Events) != 2 || changes.Missed {
+		t.Fatalf("expected both events, got %+v", changes)
+	}
+	if changes.Events[1].(OutputEvent).Seq != "b" {
+		t.Errorf("expected events in order, got %+v", changes.Events)
+	}
+
+	// With nothing new the call waits for the next event
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		send("c")
+	}()
+	changes, err = vt.ChangesSince(ctx, changes.Seq)
+	if err != nil {
+		t.Fatalf("ChangesSince failed: %v", err)
+	}
+	if changes.Seq != 3 || len(changes.Events) != 1 || changes.Events[0].(OutputEvent).Seq != "c" {
+		t.Errorf("expected only the new event, got %+v", changes)
+	}
+
+	short, cancelShort := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancelShort()
+	if _, err := vt.ChangesSince(short, changes.Seq); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the context error, got %v", err)
+	}
+}
+
+func TestChangesSinceMissed(t *testing.T) {
+	vt, _ := newTestTerminal()
+	defer vt.Close()
+	ctx := context.Background()
+
+	go func() {
+		for range vt.Events() {
+		}
+	}()
+	send := func(event Event) {
+		vt.trackEvent(event)
+		vt.dispatch(event)
+	}
+	send(InitEvent{Cols: 20, Rows: 2, Seq: "hello"})
+	for range eventLogSize + 10 {
+		send(OutputEvent{Seq: "\x1b[K"})
+	}
+
+	// A client that fell behind starts over from the screen, without
+	// events that are already part of it
+	changes, err := vt.ChangesSince(ctx, 5)
+	if err != nil {
+		t.Fatalf("ChangesSince failed: %v", err)
+	}
+	if !changes.Missed || changes.Screen == nil || changes.Screen.Line(0) != "hello" {
+		t.Fatalf("expected a resync with the screen, got %+v", changes)
+	}
+	if changes.Seq != eventLogSize+11 || len(changes.Events) != 0 {
+		t.Errorf("expected the last sequence number and no events, got seq %d and %d events", changes.Seq, len(changes.Events))
+	}
+
+	// The screen always matches Seq, even while an event is between
+	// updating the screen and being logged
+	event := OutputEvent{Seq: " world"}
+	vt.trackEvent(event)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		vt.dispatch(event)
+	}()
+	changes, err = vt.ChangesSince(ctx, 5)
+	if err != nil {
+		t.Fatalf("ChangesSince failed: %v", err)
+	}
+	if changes.Seq != eventLogSize+12 || changes.Screen.Line(0) != "hello world" {
+		t.Errorf("expected the screen as of seq %d, got %q at %d", eventLogSize+12, changes.Screen.Line(0), changes.Seq)
+	}
+
+	// So does a client from another session
+	changes, err = vt.ChangesSince(ctx, 1<<40)
+	if err != nil || !changes.Missed {
+		t.Errorf("expected a resync for an unknown sequence number, got %+v, %v", changes, err)
+	}
+}
+
+func TestChangesSinceClosed(t *testing.T) {
+	vt, _ := newTestTerminal()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		vt.Close()
+	}()
+	if _, err := vt.ChangesSince(context.Background(), 0); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
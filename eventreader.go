@@ -0,0 +1,91 @@
+package htlib
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// DefaultMaxEventSize is the longest event line accepted from ht when
+// Config.MaxEventSize is zero.
+const DefaultMaxEventSize = 64 << 20
+
+// skippedHeadSize is how much of a line over the limit is kept, enough to
+// tell its event type.
+const skippedHeadSize = 64
+
+// eventReader reads ht's event lines. Unlike bufio.Scanner, whose lines
+// are limited to 64 KiB by default, it grows its buffer as needed, and a
+// line over the limit is skipped rather than ending the session.
+type eventReader struct {
+	r     *bufio.Reader
+	limit int
+	line  []byte
+	err   error
+
+	// skip is called with the size and the first bytes of every line that
+	// is too long; head is only valid during the call
+	skip func(size int, head []byte)
+}
+
+func newEventReader(r io.Reader, limit int, skip func(size int, head []byte)) *eventReader {
+	if limit <= 0 {
+		limit = DefaultMaxEventSize
+	}
+	return &eventReader{r: bufio.NewReaderSize(r, 64*1024), limit: limit, skip: skip}
+}
+
+// next returns the next line without its line ending. It returns false
+// once the input is exhausted or cannot be read; see err.
+func (r *eventReader) next() (string, bool) {
+	for {
+		line, size, err := r.readLine()
+		if err != nil {
+			if err != io.EOF {
+				r.err = err
+			}
+			if line == nil {
+				return "", false
+			}
+		}
+		if line == nil {
+			if r.skip != nil {
+				r.skip(size, r.line)
+			}
+			continue
+		}
+		return string(line), true
+	}
+}
+
+// readLine reads up to the next newline. line is nil if the line was over
+// the limit, in which case it is read to its end but only its first
+// skippedHeadSize bytes are kept in r.line, or if nothing was read before
+// err.
+func (r *eventReader) readLine() (line []byte, size int, err error) {
+	if cap(r.line) > 1<<20 {
+		// Do not hold on to the buffer of an unusually large event
+		r.line = nil
+	}
+	r.line = r.line[:0]
+	tooLong := false
+	for {
+		chunk, err := r.r.ReadSlice('\n')
+		size += len(chunk)
+		if !tooLong {
+			r.line = append(r.line, chunk...)
+			if len(bytes.TrimRight(r.line, "\r\n")) > r.limit {
+				tooLong, r.line = true, r.line[:min(len(r.line), skippedHeadSize)]
+			}
+		}
+		switch {
+		case err == bufio.ErrBufferFull:
+			continue
+		case err != nil && size == 0:
+			return nil, 0, err
+		case tooLong:
+			return nil, size, err
+		}
+		return bytes.TrimRight(r.line, "\r\n"), size, err
+	}
+}
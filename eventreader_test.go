@@ -0,0 +1,76 @@
+package htlib
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestEventReader(t *testing.T) {
+	huge := strings.Repeat("x", 300*1024)
+	input := "first\r\n" + huge + "\n" + strings.Repeat("y", 2000) + "\n\nlast"
+
+	var skipped []int
+	var heads []string
+	r := newEventReader(strings.NewReader(input), 1000, func(size int, head []byte) {
+		skipped = append(skipped, size)
+		heads = append(heads, string(head))
+	})
+	var lines []string
+	for {
+		line, ok := r.next()
+		if !ok {
+			break
+		}
+		lines = append(lines, line)
+	}
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	if len(lines) != 3 || lines[0] != "first" || lines[1] != "" || lines[2] != "last" {
+		t.Errorf("unexpected lines %q", lines)
+	}
+	if len(skipped) != 2 || skipped[0] != len(huge)+1 || skipped[1] != 2001 {
+		t.Errorf("expected both long lines to be skipped, got %v", skipped)
+	}
+	if len(heads) != 2 || heads[0] != huge[:skippedHeadSize] || heads[1] != strings.Repeat("y", skippedHeadSize) {
+		t.Errorf("expected the start of the long lines, got %q", heads)
+	}
+
+	r = newEventReader(strings.NewReader(huge+"\n"), 0, nil)
+	if line, ok := r.next(); !ok || line != huge {
+		t.Errorf("expected a line larger than the read buffer, got %d bytes", len(line))
+	}
+}
+
+func TestEventReaderError(t *testing.T) {
+	failure := errors.New("broken pipe")
+	r := newEventReader(io.MultiReader(strings.NewReader("one\n"), iotest.ErrReader(failure)), 0, nil)
+	if line, ok := r.next(); !ok || line != "one" {
+		t.Fatalf("expected the first line, got %q %v", line, ok)
+	}
+	if _, ok := r.next(); ok || !errors.Is(r.err, failure) {
+		t.Errorf("expected the read error, got %v", r.err)
+	}
+}
+
+func TestLargeSnapshotEvent(t *testing.T) {
+	vt, _ := newTestTerminal()
+	text := strings.Repeat("z", 100*1024)
+	line := `{"type":"snapshot","data":{"cols":80,"rows":24,"seq":"","text":"` + text + `"}}`
+	done := make(chan struct{})
+	vt.wg.Add(1)
+	go vt.readEvents(vt.newEventReader(strings.NewReader(line+"\n")), "", done)
+	<-done
+
+	select {
+	case event := <-vt.Events():
+		if s, ok := event.(SnapshotEvent); !ok || len(s.Text) != len(text) {
+			t.Errorf("expected the large snapshot, got %T", event)
+		}
+	default:
+		t.Error("expected an event")
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"log"
 	"strings"
-	"time"
 
 	"github.com/io41/htlib.go"
 )
@@ -22,9 +21,9 @@ func NewCLITester() *CLITester {
 	}
 }
 
-// Start initializes the terminal
+// Start initializes the terminal and waits for the shell prompt
 func (t *CLITester) Start(ctx context.Context) error {
-	return t.vt.Start(ctx)
+	return t.vt.Start(ctx, htlib.WaitReady())
 }
 
 // Close cleans up the terminal
@@ -32,23 +31,13 @@ func (t *CLITester) Close() error {
 	return t.vt.Close()
 }
 
-// RunCommand runs a command and returns the output
+// RunCommand runs a command, waits for it to finish and returns its output
 func (t *CLITester) RunCommand(ctx context.Context, cmd string) (string, error) {
-	// Send command
-	if err := t.vt.Input(ctx, cmd+"\n"); err != nil {
-		return "", err
-	}
-
-	// Wait for output
-	time.Sleep(300 * time.Millisecond)
-
-	// Get snapshot
-	snapshot, err := t.vt.WaitForSnapshot(ctx)
+	result, err := t.vt.RunCommand(ctx, cmd)
 	if err != nil {
 		return "", err
 	}
-
-	return snapshot.Text, nil
+	return result.Text(), nil
 }
 
 // ExpectOutput checks if the output contains the expected text
@@ -78,9 +67,6 @@ func main() {
 	}
 	defer tester.Close()
 
-	// Wait for init
-	time.Sleep(200 * time.Millisecond)
-
 	// Test 1: Check basic command execution
 	fmt.Println("Test 1: Running 'echo hello'")
 	output, err := tester.RunCommand(ctx, "echo hello")
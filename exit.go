@@ -0,0 +1,114 @@
+package htlib
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+)
+
+// ExitCodeUnknown is the Code of an ExitStatus when the program ended but
+// its exit code is not known.
+const ExitCodeUnknown = -2
+
+// ExitStatus describes how the program running in the terminal ended.
+//
+// It is taken from the exit status of ht, which ends when the program ends.
+// The program is ht's child, not ours, so its own status cannot be
+// collected. ht exits 0 however its program ended, so that is reported as
+// ExitCodeUnknown rather than as a success; a non-zero status or a signal
+// is passed on, as it comes from an ht that exits with the status of its
+// program, from ht failing, or from ht being killed. To check the outcome
+// of commands, run them in a shell with RunCommand, which gets the exit
+// code from the shell.
+type ExitStatus struct {
+	// Code is the exit code, -1 if the program was killed by a signal, or
+	// ExitCodeUnknown
+	Code int
+	// Signal is the signal that killed the program, or 0 if it exited
+	Signal syscall.Signal
+}
+
+// Success reports whether the program exited with code 0. It is false if
+// the code is unknown.
+func (s ExitStatus) Success() bool {
+	return s.Code == 0 && s.Signal == 0
+}
+
+// String returns the status in the style of os.ProcessState, e.g.
+// "exit status 1", "signal: killed" or "exit status unknown".
+func (s ExitStatus) String() string {
+	if s.Signal != 0 {
+		return "signal: " + s.Signal.String()
+	}
+	if s.Code == ExitCodeUnknown {
+		return "exit status unknown"
+	}
+	return fmt.Sprintf("exit status %d", s.Code)
+}
+
+// ExitEvent is the last event of a terminal, sent when the program running
+// in it has ended and before the channel returned by Events is closed.
+// Config.RestartPolicy may follow it with a RestartEvent and a new process.
+type ExitEvent struct {
+	// Code is the exit code, -1 if the program was killed by a signal, or
+	// ExitCodeUnknown; see ExitStatus
+	Code int `json:"code"`
+	// Signal is the signal that killed the program, or 0 if it exited
+	Signal syscall.Signal `json:"signal"`
+	// Err is set if the session ended because of a failure rather than the
+	// program ending: ht exiting before it started the program, its events
+	// becoming unreadable, or a serial connection failing. Code and Signal
+	// then describe how ht itself ended.
+	Err  error `json:"-"`
+	Time time.Time
+}
+
+func (e ExitEvent) Type() EventType { return EventTypeExit }
+
+// Status returns the exit status reported by the event.
+func (e ExitEvent) Status() ExitStatus {
+	return ExitStatus{Code: e.Code, Signal: e.Signal}
+}
+
+// exitStatusOf converts the state of the exited ht process, which stands in
+// for the program's; see ExitStatus.
+func exitStatusOf(state *os.ProcessState) ExitStatus {
+	if state == nil {
+		return ExitStatus{Code: -1}
+	}
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return ExitStatus{Code: -1, Signal: ws.Signal()}
+	}
+	if code := state.ExitCode(); code != 0 {
+		return ExitStatus{Code: code}
+	}
+	return ExitStatus{Code: ExitCodeUnknown}
+}
+
+// WaitForExit blocks until the program running in the terminal exits and
+// returns its exit status:
+//
+//	vt.Input(ctx, "exit 3\n")
+//	status, err := vt.WaitForExit(ctx) // status.Code == 3
+//
+// See ExitStatus for when the code is ExitCodeUnknown.
+// If the terminal is closed first, ht is killed and the status reports
+// SIGKILL.
+func (vt *VirtualTerminal) WaitForExit(ctx context.Context) (ExitStatus, error) {
+	vt.mu.RLock()
+	started := vt.started || vt.starting
+	s := vt.sess
+	vt.mu.RUnlock()
+	if !started {
+		return ExitStatus{}, ErrNotStarted
+	}
+
+	select {
+	case <-s.exited:
+		return s.status, nil
+	case <-ctx.Done():
+		return ExitStatus{}, ctx.Err()
+	}
+}
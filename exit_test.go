@@ -0,0 +1,146 @@
+package htlib
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestExitStatusString(t *testing.T) {
+	tests := []struct {
+		status   ExitStatus
+		expected string
+		success  bool
+	}{
+		{ExitStatus{Code: 0}, "exit status 0", true},
+		{ExitStatus{Code: 3}, "exit status 3", false},
+		{ExitStatus{Code: ExitCodeUnknown}, "exit status unknown", false},
+		{ExitStatus{Code: -1, Signal: syscall.SIGKILL}, "signal: killed", false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.status.String(); got != tt.expected {
+			t.Errorf("expected %q, got %q", tt.expected, got)
+		}
+		if tt.status.Success() != tt.success {
+			t.Errorf("%v: expected Success() = %v", tt.status, tt.success)
+		}
+	}
+}
+
+func TestWaitForExit(t *testing.T) {
+	tests := []struct {
+		name     string
+		script   string
+		expected ExitStatus
+	}{
+		{"exit code", "exit 3", ExitStatus{Code: 3}},
+		// ht exits 0 however its program ended
+		{"success", "true", ExitStatus{Code: ExitCodeUnknown}},
+		{"signal", "kill -TERM $$", ExitStatus{Code: -1, Signal: syscall.SIGTERM}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			config := DefaultConfig()
+			config.Binary = "/bin/sh"
+			config.Args = []string{"-c", tt.script}
+			vt := New(config)
+			defer vt.Close()
+
+			if err := vt.Start(ctx); err != nil {
+				t.Fatalf("failed to start: %v", err)
+			}
+			status, err := vt.WaitForExit(ctx)
+			if err != nil {
+				t.Fatalf("failed to wait for exit: %v", err)
+			}
+			if status != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, status)
+			}
+		})
+	}
+}
+
+func TestWaitForExitErrors(t *testing.T) {
+	vt := New(DefaultConfig())
+	if _, err := vt.WaitForExit(context.Background()); !errors.Is(err, ErrNotStarted) {
+		t.Errorf("expected ErrNotStarted, got %v", err)
+	}
+
+	running, _ := newTestTerminal()
+	defer running.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := running.WaitForExit(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestExitEvent(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	config := DefaultConfig()
+	config.Binary = "/bin/sh"
+	config.Args = []string{"-c", "echo bye; exit 3"}
+	vt := New(config)
+	defer vt.Close()
+
+	sub := vt.Subscribe()
+	if err := vt.Start(ctx); err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+
+	var last Event
+	for event := range vt.Events() {
+		last = event
+	}
+	exit, ok := last.(ExitEvent)
+	if !ok {
+		t.Fatalf("expected the last event to be an ExitEvent, got %#v", last)
+	}
+	if exit.Status() != (ExitStatus{Code: 3}) || exit.Err != nil || exit.Time.IsZero() {
+		t.Errorf("unexpected exit event %+v", exit)
+	}
+
+	waitUntil(t, func() bool {
+		select {
+		case event := <-sub:
+			return event.Type() == EventTypeExit
+		default:
+			return false
+		}
+	})
+}
+
+func TestExitEventReportsFailure(t *testing.T) {
+	vt, _ := newTestTerminal()
+	defer vt.Close()
+
+	proc := &htProcess{serial: &serialBridge{done: make(chan struct{}), err: errors.New("serial port: device gone")}}
+	close(proc.serial.done)
+	vt.proc = proc
+	sub := vt.subscribeRaw()
+
+	readDone := make(chan struct{})
+	close(readDone)
+	vt.wg.Add(1)
+	go vt.waitForExit(vt.sess, readDone, readDone)
+
+	exit := (<-sub).(ExitEvent)
+	if exit.Err == nil || exit.Err.Error() != "serial port: device gone" {
+		t.Errorf("expected the connection error, got %+v", exit)
+	}
+	if event := <-vt.Events(); event.Type() != EventTypeExit {
+		t.Errorf("expected the exit event, got %#v", event)
+	}
+	if _, ok := <-vt.Events(); ok {
+		t.Error("expected the events channel to be closed after the exit event")
+	}
+}
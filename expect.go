@@ -0,0 +1,146 @@
+package htlib
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ExpectCase is one branch of an Expect call: when Matcher matches, Action
+// (if any) runs and Expect returns.
+type ExpectCase struct {
+	Matcher Matcher
+	Action  Action
+}
+
+// ExpectResult reports which case of an Expect call matched.
+type ExpectResult struct {
+	// Index is the position of the matching case in the arguments to Expect
+	Index int
+	// Match is the matched text followed by any regular expression submatches
+	Match []string
+}
+
+// Expecter consumes terminal output in order, in the style of expect(1).
+// Output is recorded from the moment the Expecter is created, and each
+// successful Expect consumes it up to the end of the match, so consecutive
+// calls see consecutive parts of the output and nothing printed between
+// calls is missed.
+type Expecter struct {
+	vt   *VirtualTerminal
+	sub  chan Event
+	done chan struct{}
+
+	mu      sync.Mutex
+	output  *outputBuffer
+	last    Event
+	changed chan struct{}
+}
+
+// NewExpecter returns an Expecter that starts recording output immediately.
+// Call Close when it is no longer needed.
+func (vt *VirtualTerminal) NewExpecter() *Expecter {
+	e := &Expecter{
+		vt:      vt,
+		sub:     vt.subscribeRaw(),
+		done:    make(chan struct{}),
+		output:  newOutputBuffer(),
+		changed: make(chan struct{}, 1),
+	}
+	go e.record()
+	return e
+}
+
+// Close stops recording output. It is safe to call Close more than once.
+func (e *Expecter) Close() {
+	e.vt.Unsubscribe(e.sub)
+}
+
+// record accumulates output until the subscription is closed.
+func (e *Expecter) record() {
+	defer close(e.done)
+
+	for event := range e.sub {
+		switch ev := event.(type) {
+		case OutputEvent:
+			e.mu.Lock()
+			e.output.add(ev)
+			e.last = event
+			e.mu.Unlock()
+		case ResizeEvent:
+			e.mu.Lock()
+			e.last = event
+			e.mu.Unlock()
+		default:
+			// Snapshots taken by screen matchers must not cause another check
+			continue
+		}
+
+		select {
+		case e.changed <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Expect waits until one of the cases matches, runs its action and reports
+// which case it was. Cases are checked in order, so when several match at
+// once the first wins:
+//
+//	result, err := e.Expect(ctx,
+//	    htlib.ExpectCase{Matcher: htlib.OutputContains("password:"), Action: htlib.InputSecret(pw)},
+//	    htlib.ExpectCase{Matcher: htlib.OutputMatches(regexp.MustCompile(`\$ $`))},
+//	)
+//
+// Cases are re-checked whenever output arrives or the terminal is resized,
+// and at least every 250ms for screen matchers. If the action fails, its
+// error is returned together with the result.
+func (e *Expecter) Expect(ctx context.Context, cases ...ExpectCase) (*ExpectResult, error) {
+	if len(cases) == 0 {
+		return nil, fmt.Errorf("expect: no cases given")
+	}
+
+	ticker := time.NewTicker(screenPollInterval)
+	defer ticker.Stop()
+
+	closed := false
+	for {
+		e.mu.Lock()
+		mc := e.vt.newMatchContext(ctx, e.last, e.output.text)
+		e.mu.Unlock()
+
+		for i, c := range cases {
+			match := c.Matcher.Match(mc)
+			if match == nil {
+				continue
+			}
+
+			e.mu.Lock()
+			e.output.consume(match[0])
+			e.mu.Unlock()
+
+			result := &ExpectResult{Index: i, Match: match}
+			if c.Action != nil {
+				if err := c.Action(ctx, e.vt); err != nil {
+					return result, fmt.Errorf("expect case %d (%s): %w", i, describeMatcher(c.Matcher), err)
+				}
+			}
+			return result, nil
+		}
+
+		// Output recorded before the terminal closed has been checked
+		if closed {
+			return nil, e.vt.closedErr()
+		}
+
+		select {
+		case <-e.changed:
+		case <-ticker.C:
+		case <-e.done:
+			closed = true
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package htlib
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpectBranches(t *testing.T) {
+	vt, stdin := newTestTerminal()
+	defer vt.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	e := vt.NewExpecter()
+	defer e.Close()
+
+	cases := []ExpectCase{
+		{Matcher: OutputContains("password:"), Action: InputSecret("s3cret")},
+		{Matcher: OutputMatches(regexp.MustCompile(`(\w+)@host \$ `))},
+	}
+
+	// Output printed before Expect is called is not missed
+	vt.dispatch(OutputEvent{Seq: "login: ok\r\npass"})
+	vt.dispatch(OutputEvent{Seq: "word: "})
+	waitUntil(t, func() bool {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		return strings.Contains(e.output.text, "password:")
+	})
+
+	result, err := e.Expect(ctx, cases...)
+	if err != nil {
+		t.Fatalf("expect failed: %v", err)
+	}
+	if result.Index != 0 {
+		t.Errorf("expected case 0, got %d", result.Index)
+	}
+	if !strings.Contains(stdin.String(), `"payload":"s3cret\n"`) {
+		t.Errorf("expected secret to be sent, got %q", stdin.String())
+	}
+
+	// The password prompt was consumed, so the next call waits for the shell
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		vt.dispatch(OutputEvent{Seq: "\r\nalice@host $ "})
+	}()
+	result, err = e.Expect(ctx, cases...)
+	if err != nil {
+		t.Fatalf("expect failed: %v", err)
+	}
+	if result.Index != 1 || result.Match[1] != "alice" {
+		t.Errorf("expected case 1 matching alice, got %+v", result)
+	}
+}
+
+func TestExpectTimeoutAndActionError(t *testing.T) {
+	vt, _ := newTestTerminal()
+	defer vt.Close()
+
+	e := vt.NewExpecter()
+	defer e.Close()
+
+	shortCtx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := e.Expect(shortCtx, ExpectCase{Matcher: OutputContains("never")}); err != context.DeadlineExceeded {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	if _, err := e.Expect(context.Background()); err == nil {
+		t.Error("expected error without cases")
+	}
+
+	failure := errors.New("boom")
+	vt.dispatch(OutputEvent{Seq: "Continue?"})
+	result, err := e.Expect(context.Background(), ExpectCase{
+		Matcher: OutputContains("Continue?"),
+		Action: func(ctx context.Context, vt *VirtualTerminal) error {
+			return failure
+		},
+	})
+	if !errors.Is(err, failure) {
+		t.Errorf("expected action error, got %v", err)
+	}
+	if result == nil || result.Index != 0 {
+		t.Errorf("expected result for case 0, got %+v", result)
+	}
+}
+
+func TestExpectTerminalClosed(t *testing.T) {
+	vt, _ := newTestTerminal()
+
+	e := vt.NewExpecter()
+	defer e.Close()
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		vt.Close()
+	}()
+
+	_, err := e.Expect(context.Background(), ExpectCase{Matcher: OutputContains("never")})
+	if !errors.Is(err, ErrTerminalClosed) {
+		t.Errorf("expected ErrTerminalClosed, got %v", err)
+	}
+}
@@ -0,0 +1,258 @@
+package htlib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ExpectStep is one step of an ExpectBatch. A step first waits for Matcher
+// (if any) and then runs Action (if any), so send and expect steps are just
+// steps with only one of the two set. The constructors below cover the
+// common cases.
+type ExpectStep struct {
+	// Description names the step in the transcript and in errors; if empty,
+	// the matcher is described instead
+	Description string
+	// Matcher is waited for before Action runs
+	Matcher Matcher
+	// Action runs once Matcher has matched
+	Action Action
+	// Timeout limits the step; zero means only the batch context applies
+	Timeout time.Duration
+	// Budget is how long the step is expected to take at most. Unlike
+	// Timeout it does not stop the step; see ExpectBatch
+	Budget time.Duration
+}
+
+// SendStep returns a step that types text.
+func SendStep(text string) ExpectStep {
+	return ExpectStep{Description: fmt.Sprintf("send %q", text), Action: InputAction(text)}
+}
+
+// SendKeysStep returns a step that sends the named keys.
+func SendKeysStep(keys ...string) ExpectStep {
+	return ExpectStep{Description: "send keys " + strings.Join(keys, " "), Action: KeysAction(keys...)}
+}
+
+// ExpectTextStep returns a step that waits until text appears in the output.
+func ExpectTextStep(text string) ExpectStep {
+	return ExpectStep{Matcher: OutputContains(text)}
+}
+
+// ExpectRegexStep returns a step that waits until re matches the output.
+func ExpectRegexStep(re *regexp.Regexp) ExpectStep {
+	return ExpectStep{Matcher: OutputMatches(re)}
+}
+
+// WithTimeout returns a copy of the step limited to d.
+func (s ExpectStep) WithTimeout(d time.Duration) ExpectStep {
+	s.Timeout = d
+	return s
+}
+
+// WithBudget returns a copy of the step with a timing budget of d.
+func (s ExpectStep) WithBudget(d time.Duration) ExpectStep {
+	s.Budget = d
+	return s
+}
+
+// describe returns the description used for the step.
+func (s ExpectStep) describe() string {
+	switch {
+	case s.Description != "":
+		return s.Description
+	case s.Matcher != nil:
+		return describeMatcher(s.Matcher)
+	default:
+		return "action"
+	}
+}
+
+// ExpectStepResult is the transcript entry for one step of an ExpectBatch.
+type ExpectStepResult struct {
+	// Step is the index of the step in the batch
+	Step int
+	// Description is the step's description
+	Description string
+	// Match is what the step's matcher matched, or nil for a step without one
+	Match []string
+	// Duration is how long the step took
+	Duration time.Duration
+	// Budget is the step's timing budget, or zero if it has none
+	Budget time.Duration
+	// Err is the error that ended the batch at this step, if any
+	Err error
+}
+
+// OverBudget reports whether the step took longer than its budget.
+func (r ExpectStepResult) OverBudget() bool {
+	return r.Budget > 0 && r.Duration > r.Budget
+}
+
+// ExpectTranscript is the list of step results returned by ExpectBatch.
+type ExpectTranscript []ExpectStepResult
+
+// Total returns the time taken by all steps.
+func (t ExpectTranscript) Total() time.Duration {
+	var total time.Duration
+	for _, r := range t {
+		total += r.Duration
+	}
+	return total
+}
+
+// OverBudget returns the steps that took longer than their budget.
+func (t ExpectTranscript) OverBudget() []ExpectStepResult {
+	var over []ExpectStepResult
+	for _, r := range t {
+		if r.OverBudget() {
+			over = append(over, r)
+		}
+	}
+	return over
+}
+
+// String formats the transcript as a report with one line per step:
+//
+//	0  Install location?     12ms  (budget 5s)
+//	1  send "/opt/app\n"      0s
+//	2  Done                  2.3s  (budget 1s, OVER)
+func (t ExpectTranscript) String() string {
+	width := 0
+	for _, r := range t {
+		width = max(width, len(r.Description))
+	}
+
+	var b strings.Builder
+	for _, r := range t {
+		fmt.Fprintf(&b, "%d  %-*s  %s", r.Step, width, r.Description, r.Duration.Round(time.Millisecond))
+		switch {
+		case r.OverBudget():
+			fmt.Fprintf(&b, "  (budget %s, OVER)", r.Budget)
+		case r.Budget > 0:
+			fmt.Fprintf(&b, "  (budget %s)", r.Budget)
+		}
+		if r.Err != nil {
+			fmt.Fprintf(&b, "  error: %v", r.Err)
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// BudgetError is returned by ExpectBatch when every step succeeded but some
+// took longer than their budget. It matches ErrBudgetExceeded.
+type BudgetError struct {
+	// Steps are the results of the steps that were over budget
+	Steps []ExpectStepResult
+}
+
+func (e *BudgetError) Error() string {
+	parts := make([]string, len(e.Steps))
+	for i, r := range e.Steps {
+		parts[i] = fmt.Sprintf("step %d (%s) took %s, budget %s",
+			r.Step, r.Description, r.Duration.Round(time.Millisecond), r.Budget)
+	}
+	return fmt.Sprintf("%s: %s", ErrBudgetExceeded, strings.Join(parts, "; "))
+}
+
+// Is reports whether target is ErrBudgetExceeded.
+func (e *BudgetError) Is(target error) bool {
+	return target == ErrBudgetExceeded
+}
+
+// ExpectBatch runs steps in order, turning a multi-step interactive flow
+// such as an installer or wizard into data:
+//
+//	transcript, err := vt.ExpectBatch(ctx,
+//	    htlib.ExpectTextStep("Install location?").WithTimeout(5*time.Second),
+//	    htlib.SendStep("/opt/app\n"),
+//	    htlib.ExpectRegexStep(regexp.MustCompile(`Continue\? \[y/N\]`)),
+//	    htlib.SendKeysStep("y", "Enter"),
+//	    htlib.ExpectTextStep("Done").WithTimeout(time.Minute),
+//	)
+//
+// Output is recorded from the start of the batch and consumed by each
+// match, as with Expecter. The transcript holds an entry for every step
+// that ran, including the one that failed. A step that exceeds its own
+// Timeout fails with an error matching ErrTimeout.
+//
+// Steps with a Budget let interactive-performance regressions fail
+// ordinary tests. A step over budget does not stop the batch; once all
+// steps succeeded, a *BudgetError lists the slow ones:
+//
+//	transcript, err := vt.ExpectBatch(ctx,
+//	    htlib.SendKeysStep("C-p"),
+//	    htlib.ExpectTextStep("Command palette").WithBudget(200*time.Millisecond),
+//	)
+//	if errors.Is(err, htlib.ErrBudgetExceeded) {
+//	    t.Errorf("palette too slow:\n%s", transcript)
+//	}
+func (vt *VirtualTerminal) ExpectBatch(ctx context.Context, steps ...ExpectStep) (ExpectTranscript, error) {
+	e := vt.NewExpecter()
+	defer e.Close()
+	return e.ExpectBatch(ctx, steps...)
+}
+
+// ExpectBatch runs steps in order against the output recorded by e; see
+// VirtualTerminal.ExpectBatch.
+func (e *Expecter) ExpectBatch(ctx context.Context, steps ...ExpectStep) (ExpectTranscript, error) {
+	transcript := make(ExpectTranscript, 0, len(steps))
+	for i, step := range steps {
+		start := time.Now()
+		match, err := e.runStep(ctx, step)
+		result := ExpectStepResult{
+			Step:        i,
+			Description: step.describe(),
+			Match:       match,
+			Duration:    time.Since(start),
+			Budget:      step.Budget,
+			Err:         err,
+		}
+		transcript = append(transcript, result)
+		if err != nil {
+			return transcript, fmt.Errorf("expect batch step %d (%s): %w", i, result.Description, err)
+		}
+	}
+	if over := transcript.OverBudget(); len(over) > 0 {
+		return transcript, &BudgetError{Steps: over}
+	}
+	return transcript, nil
+}
+
+// runStep waits for the step's matcher and runs its action.
+func (e *Expecter) runStep(ctx context.Context, step ExpectStep) ([]string, error) {
+	stepCtx := ctx
+	if step.Timeout > 0 {
+		var cancel context.CancelFunc
+		stepCtx, cancel = context.WithTimeout(ctx, step.Timeout)
+		defer cancel()
+	}
+
+	// stepErr reports the step's own deadline as ErrTimeout
+	stepErr := func(err error) error {
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			return fmt.Errorf("%w after %s", ErrTimeout, step.Timeout)
+		}
+		return err
+	}
+
+	var match []string
+	if step.Matcher != nil {
+		result, err := e.Expect(stepCtx, ExpectCase{Matcher: step.Matcher})
+		if err != nil {
+			return nil, stepErr(err)
+		}
+		match = result.Match
+	}
+	if step.Action != nil {
+		if err := step.Action(stepCtx, e.vt); err != nil {
+			return match, stepErr(err)
+		}
+	}
+	return match, nil
+}
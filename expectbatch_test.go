@@ -0,0 +1,136 @@
+package htlib
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpectBatch(t *testing.T) {
+	vt, stdin := newTestTerminal()
+	defer vt.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	e := vt.NewExpecter()
+	defer e.Close()
+
+	// Play the installer's side of the conversation as input arrives
+	sent := func(text string) {
+		for !strings.Contains(stdin.String(), text) && ctx.Err() == nil {
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	go func() {
+		vt.dispatch(OutputEvent{Seq: "Install location? "})
+		sent("/opt/app")
+		vt.dispatch(OutputEvent{Seq: "/opt/app\r\nContinue? [y/N] "})
+		sent(`"keys":["y","Enter"]`)
+		vt.dispatch(OutputEvent{Seq: "y\r\nInstalled 42 files\r\n"})
+	}()
+
+	transcript, err := e.ExpectBatch(ctx,
+		ExpectTextStep("Install location?").WithTimeout(time.Second),
+		SendStep("/opt/app\n"),
+		ExpectRegexStep(regexp.MustCompile(`Continue\? \[y/N\]`)),
+		SendKeysStep("y", "Enter"),
+		ExpectRegexStep(regexp.MustCompile(`Installed (\d+) files`)),
+	)
+	if err != nil {
+		t.Fatalf("batch failed: %v", err)
+	}
+	if len(transcript) != 5 {
+		t.Fatalf("expected 5 transcript entries, got %d", len(transcript))
+	}
+	if transcript[1].Description != `send "/opt/app\n"` || transcript[1].Match != nil {
+		t.Errorf("unexpected send entry %+v", transcript[1])
+	}
+	if transcript[3].Description != "send keys y Enter" {
+		t.Errorf("unexpected keys entry %+v", transcript[3])
+	}
+	if last := transcript[4]; last.Match[1] != "42" || last.Description != "output matches /Installed (\\d+) files/" {
+		t.Errorf("unexpected final entry %+v", last)
+	}
+}
+
+func TestExpectBatchStepTimeout(t *testing.T) {
+	vt, stdin := newTestTerminal()
+	defer vt.Close()
+
+	start := time.Now()
+	transcript, err := vt.ExpectBatch(context.Background(),
+		ExpectStep{Description: "wait for banner", Matcher: OutputContains("never")}.WithTimeout(50*time.Millisecond),
+		SendStep("not sent"),
+	)
+	if !errors.Is(err, ErrTimeout) || !strings.Contains(err.Error(), "step 0 (wait for banner)") {
+		t.Errorf("expected step 0 timeout, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("step timeout was not applied")
+	}
+	if len(transcript) != 1 || !errors.Is(transcript[0].Err, ErrTimeout) {
+		t.Errorf("expected failed step in transcript, got %+v", transcript)
+	}
+	if strings.Contains(stdin.String(), "not sent") {
+		t.Error("steps after the failure must not run")
+	}
+
+	// The batch context is reported as is
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := vt.ExpectBatch(ctx, ExpectTextStep("never").WithTimeout(time.Second)); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestExpectBatchBudget(t *testing.T) {
+	vt, _ := newTestTerminal()
+	defer vt.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	e := vt.NewExpecter()
+	defer e.Close()
+
+	go func() {
+		vt.dispatch(OutputEvent{Seq: "menu\r\n"})
+		time.Sleep(100 * time.Millisecond)
+		vt.dispatch(OutputEvent{Seq: "palette\r\n"})
+	}()
+	transcript, err := e.ExpectBatch(ctx,
+		ExpectTextStep("menu").WithBudget(time.Minute),
+		ExpectTextStep("palette").WithBudget(10*time.Millisecond),
+		SendStep("x"),
+	)
+
+	// A slow step does not stop the batch but is reported at the end
+	var budgetErr *BudgetError
+	if !errors.As(err, &budgetErr) || !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected a budget error, got %v", err)
+	}
+	if len(transcript) != 3 {
+		t.Fatalf("expected all steps to run, got %d", len(transcript))
+	}
+	if len(budgetErr.Steps) != 1 || budgetErr.Steps[0].Step != 1 {
+		t.Errorf("expected only step 1 over budget, got %+v", budgetErr.Steps)
+	}
+	if !strings.Contains(err.Error(), `step 1 (output contains "palette")`) {
+		t.Errorf("unexpected error message %q", err)
+	}
+	if transcript[0].OverBudget() || !transcript[1].OverBudget() || transcript[2].Budget != 0 {
+		t.Errorf("unexpected budgets in transcript %+v", transcript)
+	}
+	if transcript.Total() < 100*time.Millisecond {
+		t.Errorf("expected the total to include the slow step, got %s", transcript.Total())
+	}
+
+	report := transcript.String()
+	for _, want := range []string{"(budget 1m0s)\n", "(budget 10ms, OVER)\n", `2  send "x"`} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report does not contain %q:\n%s", want, report)
+		}
+	}
+}
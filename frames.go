@@ -0,0 +1,94 @@
+package htlib
+
+import (
+	"context"
+	"time"
+)
+
+// Frame is the state of the terminal at one tick of SampleFrames.
+type Frame struct {
+	// Index counts the frames of a SampleFrames stream from 0
+	Index  int
+	Time   time.Time
+	Cols   int
+	Rows   int
+	Cursor Cursor
+	// Text is the screen text after Config.Normalization
+	Text string
+	// Changed reports whether Text, Cursor or the size differ from the
+	// previous frame; it is true for the first frame
+	Changed bool
+	// Screen is the full screen with cell attributes
+	Screen *Screen
+}
+
+// SampleFrames emits a Frame of the local screen model every interval,
+// whether the program is printing a lot or nothing at all, so monitors,
+// recorders and ML pipelines get a predictable stream:
+//
+//	for frame := range vt.SampleFrames(ctx, 100*time.Millisecond) {
+//	    if frame.Changed {
+//	        pipeline.Push(frame.Time, frame.Text)
+//	    }
+//	}
+//
+// The first frame is sent immediately. While the receiver is not ready,
+// the pending frame is rebuilt on every tick instead of ticks queueing up,
+// so a frame is never stale by more than one interval. The channel is
+// closed when ctx is done or the terminal is closed. SampleFrames panics if
+// interval is not positive, like time.NewTicker.
+func (vt *VirtualTerminal) SampleFrames(ctx context.Context, interval time.Duration) <-chan Frame {
+	if interval <= 0 {
+		panic("htlib: non-positive interval for SampleFrames")
+	}
+	ch := make(chan Frame)
+	go func() {
+		defer close(ch)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		closed := vt.current().ctx.Done()
+		var last Frame
+		for i := 0; ; i++ {
+		send:
+			for {
+				frame := vt.frame(i, time.Now())
+				frame.Changed = i == 0 || frame.Text != last.Text || frame.Cursor != last.Cursor ||
+					frame.Cols != last.Cols || frame.Rows != last.Rows
+				select {
+				case ch <- frame:
+					last = frame
+					break send
+				case <-ticker.C:
+				case <-ctx.Done():
+					return
+				case <-closed:
+					return
+				}
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			case <-closed:
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// frame returns the current screen as a Frame.
+func (vt *VirtualTerminal) frame(index int, now time.Time) Frame {
+	screen := vt.Screen()
+	return Frame{
+		Index:  index,
+		Time:   now,
+		Cols:   screen.Cols,
+		Rows:   screen.Rows,
+		Cursor: screen.Cursor,
+		Text:   vt.Normalize(screen.Text()),
+		Screen: screen,
+	}
+}
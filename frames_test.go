@@ -0,0 +1,69 @@
+package htlib
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSampleFrames(t *testing.T) {
+	vt, _ := newTestTerminal()
+	defer vt.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	frames := vt.SampleFrames(ctx, 10*time.Millisecond)
+	first := <-frames
+	if first.Index != 0 || !first.Changed || first.Cols != 120 || first.Screen == nil {
+		t.Errorf("unexpected first frame %+v", first)
+	}
+
+	// Frames keep coming without output
+	second := <-frames
+	if second.Index != 1 || second.Changed || second.Time.Sub(first.Time) < 5*time.Millisecond {
+		t.Errorf("unexpected second frame %+v", second)
+	}
+
+	vt.trackEvent(OutputEvent{Seq: "hello   "})
+	var frame Frame
+	for frame = range frames {
+		if frame.Changed {
+			break
+		}
+	}
+	if frame.Text != "hello"+strings.Repeat("\n", 39) || frame.Cursor.Col != 8 {
+		t.Errorf("unexpected frame %+v", frame)
+	}
+
+	cancel()
+	for range frames {
+	}
+}
+
+func TestSampleFramesSlowReceiver(t *testing.T) {
+	vt, _ := newTestTerminal()
+	defer vt.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	frames := vt.SampleFrames(ctx, 10*time.Millisecond)
+	<-frames
+	<-frames
+
+	// The frame waiting for the receiver is rebuilt, so it shows output that
+	// arrived after it was first built
+	time.Sleep(50 * time.Millisecond)
+	vt.trackEvent(OutputEvent{Seq: "late"})
+	time.Sleep(50 * time.Millisecond)
+	if frame := <-frames; !frame.Changed || !strings.HasPrefix(frame.Text, "late") || frame.Index != 2 {
+		t.Errorf("unexpected frame %+v", frame)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for a zero interval")
+		}
+	}()
+	vt.SampleFrames(ctx, 0)
+}
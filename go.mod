@@ -1,3 +1,5 @@
 module github.com/io41/htlib.go
 
 go 1.25.4
+
+require github.com/google/go-cmp v0.7.0
@@ -0,0 +1,237 @@
+package htlib
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Graphemes splits s into grapheme clusters, the units a terminal draws in
+// one cell or, for wide characters, two: a letter with its combining
+// accents, an emoji with its skin tone modifier or variation selector,
+// emoji joined into one with zero-width joiners, a flag made of two
+// regional indicators, or a Hangul syllable made of jamo.
+//
+// The rules are a simplified form of Unicode's extended grapheme
+// clusters (UAX #29) that covers the text terminals show.
+func Graphemes(s string) []string {
+	var clusters []string
+	start := 0
+	for i, r := range s {
+		if i > start && !joinsGrapheme(s[start:i], r) {
+			clusters = append(clusters, s[start:i])
+			start = i
+		}
+	}
+	if start < len(s) {
+		clusters = append(clusters, s[start:])
+	}
+	return clusters
+}
+
+// StringWidth returns the number of terminal columns s occupies: two for
+// each wide character such as CJK ideographs and most emoji, none for
+// combining characters, and one for everything else. Control characters
+// are not counted.
+func StringWidth(s string) int {
+	width := 0
+	for _, g := range Graphemes(s) {
+		width += graphemeWidth(g)
+	}
+	return width
+}
+
+// TruncateWidth returns the longest prefix of s that fits in width
+// columns, cut between grapheme clusters.
+func TruncateWidth(s string, width int) string {
+	clusters := Graphemes(s)
+	used := 0
+	for i, g := range clusters {
+		used += graphemeWidth(g)
+		if used > width {
+			return strings.Join(clusters[:i], "")
+		}
+	}
+	return s
+}
+
+// joinsGrapheme reports whether r continues the grapheme cluster that ends
+// with cluster.
+func joinsGrapheme(cluster string, r rune) bool {
+	last, _ := utf8.DecodeLastRuneInString(cluster)
+	switch {
+	case last == '\r' && r == '\n':
+		return true
+	case isControl(last) || isControl(r):
+		return false
+	case isExtend(r) || r == zwj:
+		return true
+	case last == zwj:
+		// Emoji ZWJ sequences, e.g. 👩‍💻
+		first, _ := utf8.DecodeRuneInString(cluster)
+		return isPictographic(first) && isPictographic(r)
+	case isRegionalIndicator(last) && isRegionalIndicator(r):
+		// Flags are pairs of regional indicators
+		return utf8.RuneCountInString(cluster)%2 == 1
+	default:
+		return joinsHangul(last, r)
+	}
+}
+
+// graphemeWidth returns the number of columns of one grapheme cluster.
+func graphemeWidth(cluster string) int {
+	first, size := utf8.DecodeRuneInString(cluster)
+	switch {
+	case isRegionalIndicator(first) && size < len(cluster):
+		return 2
+	case isPictographic(first) && containsRune(cluster[size:], emojiPresentation):
+		return 2
+	}
+	for _, r := range cluster {
+		if w := runeWidth(r); w > 0 {
+			return w
+		}
+	}
+	return 0
+}
+
+// runeWidth returns the number of columns of a single character.
+func runeWidth(r rune) int {
+	switch {
+	case r == 0 || isControl(r) || isExtend(r) || r == zwj:
+		return 0
+	case unicode.In(r, unicode.Cf) && r != 0xad:
+		return 0
+	case isHangulMedial(r):
+		return 0
+	case unicode.Is(wideRunes, r):
+		return 2
+	default:
+		return 1
+	}
+}
+
+const (
+	zwj               = 0x200d
+	emojiPresentation = 0xfe0f
+)
+
+func containsRune(s string, r rune) bool {
+	for _, c := range s {
+		if c == r {
+			return true
+		}
+	}
+	return false
+}
+
+func isControl(r rune) bool {
+	return r < 0x20 || r >= 0x7f && r < 0xa0
+}
+
+// isExtend reports whether r extends the preceding character: combining
+// marks, variation selectors, emoji modifiers and tags.
+func isExtend(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc) ||
+		r >= 0x1f3fb && r <= 0x1f3ff || // skin tone modifiers
+		r >= 0xe0020 && r <= 0xe007f // tags, as in subdivision flags
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1f1e6 && r <= 0x1f1ff
+}
+
+func isPictographic(r rune) bool {
+	return unicode.Is(pictographicRunes, r) && !isRegionalIndicator(r) && !isExtend(r)
+}
+
+// Hangul syllables are composed of leading consonants (L), vowels (V) and
+// trailing consonants (T), or precomposed LV and LVT syllables.
+func isHangulL(r rune) bool {
+	return r >= 0x1100 && r <= 0x115f || r >= 0xa960 && r <= 0xa97c
+}
+
+func isHangulV(r rune) bool {
+	return r >= 0x1160 && r <= 0x11a7 || r >= 0xd7b0 && r <= 0xd7c6
+}
+
+func isHangulT(r rune) bool {
+	return r >= 0x11a8 && r <= 0x11ff || r >= 0xd7cb && r <= 0xd7fb
+}
+
+func isHangulMedial(r rune) bool {
+	return isHangulV(r) || isHangulT(r)
+}
+
+func joinsHangul(last, r rune) bool {
+	precomposed := last >= 0xac00 && last <= 0xd7a3
+	lv := precomposed && (last-0xac00)%28 == 0
+	switch {
+	case isHangulL(last):
+		return isHangulL(r) || isHangulV(r) || r >= 0xac00 && r <= 0xd7a3
+	case lv || isHangulV(last):
+		return isHangulV(r) || isHangulT(r)
+	case precomposed || isHangulT(last):
+		return isHangulT(r)
+	}
+	return false
+}
+
+// wideRunes are the East Asian Wide and Fullwidth characters, and emoji
+// shown as emoji by default.
+var wideRunes = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{0x1100, 0x115f, 1}, {0x231a, 0x231b, 1}, {0x2329, 0x232a, 1},
+		{0x23e9, 0x23ec, 1}, {0x23f0, 0x23f0, 1}, {0x23f3, 0x23f3, 1},
+		{0x25fd, 0x25fe, 1}, {0x2614, 0x2615, 1}, {0x2648, 0x2653, 1},
+		{0x267f, 0x267f, 1}, {0x2693, 0x2693, 1}, {0x26a1, 0x26a1, 1},
+		{0x26aa, 0x26ab, 1}, {0x26bd, 0x26be, 1}, {0x26c4, 0x26c5, 1},
+		{0x26ce, 0x26ce, 1}, {0x26d4, 0x26d4, 1}, {0x26ea, 0x26ea, 1},
+		{0x26f2, 0x26f3, 1}, {0x26f5, 0x26f5, 1}, {0x26fa, 0x26fa, 1},
+		{0x26fd, 0x26fd, 1}, {0x2705, 0x2705, 1}, {0x270a, 0x270b, 1},
+		{0x2728, 0x2728, 1}, {0x274c, 0x274c, 1}, {0x274e, 0x274e, 1},
+		{0x2753, 0x2755, 1}, {0x2757, 0x2757, 1}, {0x2795, 0x2797, 1},
+		{0x27b0, 0x27b0, 1}, {0x27bf, 0x27bf, 1}, {0x2b1b, 0x2b1c, 1},
+		{0x2b50, 0x2b50, 1}, {0x2b55, 0x2b55, 1}, {0x2e80, 0x303e, 1},
+		{0x3041, 0x33ff, 1}, {0x3400, 0x4dbf, 1}, {0x4e00, 0x9fff, 1},
+		{0xa000, 0xa4cf, 1}, {0xa960, 0xa97f, 1}, {0xac00, 0xd7a3, 1},
+		{0xf900, 0xfaff, 1}, {0xfe10, 0xfe19, 1}, {0xfe30, 0xfe6f, 1},
+		{0xff00, 0xff60, 1}, {0xffe0, 0xffe6, 1},
+	},
+	R32: []unicode.Range32{
+		{0x16fe0, 0x16fe4, 1}, {0x17000, 0x18aff, 1}, {0x1b000, 0x1b2ff, 1},
+		{0x1f004, 0x1f004, 1}, {0x1f0cf, 0x1f0cf, 1}, {0x1f18e, 0x1f18e, 1},
+		{0x1f191, 0x1f19a, 1}, {0x1f1e6, 0x1f1ff, 1}, {0x1f200, 0x1f251, 1},
+		{0x1f300, 0x1f320, 1},
+		{0x1f32d, 0x1f335, 1}, {0x1f337, 0x1f37c, 1}, {0x1f37e, 0x1f393, 1},
+		{0x1f3a0, 0x1f3ca, 1}, {0x1f3cf, 0x1f3d3, 1}, {0x1f3e0, 0x1f3f0, 1},
+		{0x1f3f4, 0x1f3f4, 1}, {0x1f3f8, 0x1f43e, 1}, {0x1f440, 0x1f440, 1},
+		{0x1f442, 0x1f4fc, 1}, {0x1f4ff, 0x1f53d, 1}, {0x1f54b, 0x1f54e, 1},
+		{0x1f550, 0x1f567, 1}, {0x1f57a, 0x1f57a, 1}, {0x1f595, 0x1f596, 1},
+		{0x1f5a4, 0x1f5a4, 1}, {0x1f5fb, 0x1f64f, 1}, {0x1f680, 0x1f6c5, 1},
+		{0x1f6cc, 0x1f6cc, 1}, {0x1f6d0, 0x1f6d2, 1}, {0x1f6d5, 0x1f6d7, 1},
+		{0x1f6dc, 0x1f6df, 1}, {0x1f6eb, 0x1f6ec, 1}, {0x1f6f4, 0x1f6fc, 1},
+		{0x1f7e0, 0x1f7eb, 1}, {0x1f7f0, 0x1f7f0, 1}, {0x1f90c, 0x1f93a, 1},
+		{0x1f93c, 0x1f945, 1}, {0x1f947, 0x1f9ff, 1}, {0x1fa70, 0x1faff, 1},
+		{0x20000, 0x2fffd, 1}, {0x30000, 0x3fffd, 1},
+	},
+}
+
+// pictographicRunes approximates the Extended_Pictographic property.
+var pictographicRunes = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{0x00a9, 0x00a9, 1}, {0x00ae, 0x00ae, 1}, {0x203c, 0x203c, 1},
+		{0x2049, 0x2049, 1}, {0x2122, 0x2122, 1}, {0x2139, 0x2139, 1},
+		{0x2194, 0x2199, 1}, {0x21a9, 0x21aa, 1}, {0x231a, 0x231b, 1},
+		{0x2328, 0x2328, 1}, {0x23cf, 0x23cf, 1}, {0x23e9, 0x23f3, 1},
+		{0x23f8, 0x23fa, 1}, {0x24c2, 0x24c2, 1}, {0x25aa, 0x25ab, 1},
+		{0x25b6, 0x25b6, 1}, {0x25c0, 0x25c0, 1}, {0x25fb, 0x25fe, 1},
+		{0x2600, 0x27bf, 1}, {0x2934, 0x2935, 1}, {0x2b05, 0x2b07, 1},
+		{0x2b1b, 0x2b1c, 1}, {0x2b50, 0x2b50, 1}, {0x2b55, 0x2b55, 1},
+		{0x3030, 0x3030, 1}, {0x303d, 0x303d, 1}, {0x3297, 0x3297, 1},
+		{0x3299, 0x3299, 1},
+	},
+	R32: []unicode.Range32{
+		{0x1f000, 0x1faff, 1}, {0x1fc00, 0x1fffd, 1},
+	},
+}
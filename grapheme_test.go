@@ -0,0 +1,83 @@
+package htlib
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGraphemes(t *testing.T) {
+	tests := []struct {
+		name     string
+		s        string
+		clusters []string
+		width    int
+	}{
+		{"ascii", "abc", []string{"a", "b", "c"}, 3},
+		{"combining mark", "éx", []string{"é", "x"}, 2},
+		{"cjk", "日本", []string{"日", "本"}, 4},
+		{"zwj sequence", "👩‍💻!", []string{"👩‍💻", "!"}, 3},
+		{"skin tone", "👍🏽", []string{"👍🏽"}, 2},
+		{"flags", "🇺🇸🇩🇪", []string{"🇺🇸", "🇩🇪"}, 4},
+		{"emoji presentation", "❤️", []string{"❤️"}, 2},
+		{"text presentation", "❤", []string{"❤"}, 1},
+		{"hangul jamo", "각가", []string{"각", "가"}, 4},
+		{"crlf", "a\r\nb", []string{"a", "\r\n", "b"}, 2},
+		{"empty", "", nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if clusters := Graphemes(tt.s); !reflect.DeepEqual(clusters, tt.clusters) {
+				t.Errorf("expected clusters %q, got %q", tt.clusters, clusters)
+			}
+			if width := StringWidth(tt.s); width != tt.width {
+				t.Errorf("expected width %d, got %d", tt.width, width)
+			}
+		})
+	}
+}
+
+func TestScreenModelGraphemes(t *testing.T) {
+	m := newScreenModel(10, 3)
+	m.feed("日本x\r\n")
+	m.feed("é👩‍💻❤️|\r\n")
+	m.feed("abcdefghi語")
+
+	screen := m.snapshot()
+	if text := screen.Text(); text != "日本x\né👩‍💻❤️|\nabcdefghi\n語" {
+		// The screen scrolled because the wide character wrapped as a whole
+		if text != "é👩‍💻❤️|\nabcdefghi\n語" {
+			t.Fatalf("unexpected text %q", text)
+		}
+	}
+	if screen.Cursor != (Cursor{Row: 2, Col: 2, Visible: true}) {
+		t.Errorf("expected the cursor after the wide character, got %+v", screen.Cursor)
+	}
+
+	first := screen.cells[0]
+	if first[0].String() != "é" || first[1].String() != "👩‍💻" || first[2].String() != "" {
+		t.Errorf("unexpected cells %+v", first[:3])
+	}
+	if first[3].String() != "❤️" || first[4].String() != "" || first[5].Rune != '|' {
+		t.Errorf("expected the emoji presentation to take two cells, got %+v", first[3:6])
+	}
+	if col := screen.Column(5); col != "|f" {
+		t.Errorf("unexpected column %q", col)
+	}
+	if region := screen.Region(Rect{Row: 0, Col: 1, Rows: 1, Cols: 2}); region != "👩‍💻" {
+		t.Errorf("unexpected region %q", region)
+	}
+
+	// Overwriting half of a wide character blanks the other half
+	m.feed("\x1b[3;2Hz")
+	if line := m.snapshot().Line(2); line != " z" {
+		t.Errorf("expected the wide character to be split, got %q", line)
+	}
+}
+
+func TestTruncateWidth(t *testing.T) {
+	for s, expected := range map[string]string{"héllo": "hél", "a日本": "a日", "👩‍💻ok": "👩‍💻o", "ab": "ab"} {
+		if got := TruncateWidth(s, 3); got != expected {
+			t.Errorf("TruncateWidth(%q, 3): expected %q, got %q", s, expected, got)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package htlib
+
+import (
+	"slices"
+	"sync"
+)
+
+// Heatmap counts how often each screen cell changed over a session, which
+// shows where a TUI redraws most: useful for performance work, and for
+// deciding which part of the screen to watch.
+type Heatmap struct {
+	Cols int
+	Rows int
+	// Counts holds the number of changes of each cell, indexed [row][col]
+	Counts [][]int
+	// Updates is the number of screen updates that were compared
+	Updates int
+}
+
+// HeatCell is the change count of one cell.
+type HeatCell struct {
+	Row   int
+	Col   int
+	Count int
+}
+
+// At returns the change count of the cell at the 0-based row and column.
+func (h *Heatmap) At(row, col int) int {
+	if row < 0 || row >= len(h.Counts) || col < 0 || col >= len(h.Counts[row]) {
+		return 0
+	}
+	return h.Counts[row][col]
+}
+
+// Max returns the highest change count of any cell.
+func (h *Heatmap) Max() int {
+	highest := 0
+	for _, row := range h.Counts {
+		for _, count := range row {
+			highest = max(highest, count)
+		}
+	}
+	return highest
+}
+
+// RowTotals returns the number of cell changes in each row.
+func (h *Heatmap) RowTotals() []int {
+	totals := make([]int, len(h.Counts))
+	for row, counts := range h.Counts {
+		for _, count := range counts {
+			totals[row] += count
+		}
+	}
+	return totals
+}
+
+// Hottest returns the n cells that changed most, most changed first. Cells
+// that never changed are not included.
+func (h *Heatmap) Hottest(n int) []HeatCell {
+	var cells []HeatCell
+	for row, counts := range h.Counts {
+		for col, count := range counts {
+			if count > 0 {
+				cells = append(cells, HeatCell{Row: row, Col: col, Count: count})
+			}
+		}
+	}
+	slices.SortStableFunc(cells, func(a, b HeatCell) int {
+		return b.Count - a.Count
+	})
+	return cells[:min(n, len(cells))]
+}
+
+// HeatmapRecorder builds a Heatmap from the screen updates of a terminal.
+type HeatmapRecorder struct {
+	vt   *VirtualTerminal
+	sub  chan Event
+	done chan struct{}
+
+	mu      sync.Mutex
+	heatmap Heatmap
+}
+
+// RecordHeatmap starts counting cell changes. The recorder replays the
+// output on its own copy of the screen model (see Screen), so each output
+// event is compared with the screen before it rather than with whatever the
+// terminal shows by the time the event is processed. It never drops
+// events, which would attribute changes to the wrong cells; like an
+// OverflowBlock subscriber, it holds up the terminal if it falls behind.
+// Call Close to stop recording; the heatmap stays available.
+func (vt *VirtualTerminal) RecordHeatmap() *HeatmapRecorder {
+	sub, model := vt.subscribeScreen()
+	r := &HeatmapRecorder{
+		vt:   vt,
+		sub:  sub,
+		done: make(chan struct{}),
+	}
+	go r.record(model)
+	return r
+}
+
+// Close stops recording. It is safe to call Close more than once.
+func (r *HeatmapRecorder) Close() {
+	r.vt.Unsubscribe(r.sub)
+	<-r.done
+}
+
+// Heatmap returns a copy of the heatmap recorded so far.
+func (r *HeatmapRecorder) Heatmap() *Heatmap {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	h := r.heatmap
+	h.Counts = make([][]int, len(r.heatmap.Counts))
+	for i, row := range r.heatmap.Counts {
+		h.Counts[i] = slices.Clone(row)
+	}
+	return &h
+}
+
+// record replays events on model and compares the screen after every
+// update with the one before.
+func (r *HeatmapRecorder) record(model *screenModel) {
+	defer close(r.done)
+
+	last := model.snapshot()
+	r.mu.Lock()
+	r.resize(last.Cols, last.Rows)
+	r.mu.Unlock()
+
+	for event := range r.sub {
+		switch e := event.(type) {
+		case InitEvent:
+			model.load(e.Cols, e.Rows, e.Seq)
+		case OutputEvent:
+			model.feed(e.Seq)
+		case ResizeEvent:
+			model.setSize(e.Cols, e.Rows)
+		default:
+			continue
+		}
+
+		screen := model.snapshot()
+		r.mu.Lock()
+		r.resize(screen.Cols, screen.Rows)
+		for row := range min(screen.Rows, last.Rows) {
+			for col := range min(screen.Cols, last.Cols) {
+				if screen.Cell(row, col) != last.Cell(row, col) {
+					r.heatmap.Counts[row][col]++
+				}
+			}
+		}
+		r.heatmap.Updates++
+		r.mu.Unlock()
+		last = screen
+	}
+}
+
+// resize adapts the counts to a new screen size, keeping the counts of
+// cells that are still on screen. The caller must hold r.mu.
+func (r *HeatmapRecorder) resize(cols, rows int) {
+	h := &r.heatmap
+	if h.Cols == cols && h.Rows == rows {
+		return
+	}
+	counts := make([][]int, rows)
+	for row := range counts {
+		counts[row] = make([]int, cols)
+		if row < len(h.Counts) {
+			copy(counts[row], h.Counts[row])
+		}
+	}
+	h.Cols, h.Rows, h.Counts = cols, rows, counts
+}
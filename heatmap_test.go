@@ -0,0 +1,58 @@
+package htlib
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHeatmapRecorder(t *testing.T) {
+	vt, _ := newTestTerminal()
+	defer vt.Close()
+
+	r := vt.RecordHeatmap()
+	send := func(event Event) {
+		vt.trackEvent(event)
+		vt.dispatch(event)
+	}
+
+	// A spinner in the top-left corner redraws far more than the rest
+	send(InitEvent{Cols: 10, Rows: 3, Seq: "\x1b[2J"})
+	send(OutputEvent{Seq: "\x1b[3;1Hdone"})
+	for _, frame := range []string{"|", "/", "-", "\\", "|"} {
+		send(OutputEvent{Seq: "\x1b[H" + frame})
+	}
+	waitUntil(t, func() bool { return r.Heatmap().Updates == 7 })
+
+	h := r.Heatmap()
+	if h.Cols != 10 || h.Rows != 3 {
+		t.Fatalf("expected the init size, got %dx%d", h.Cols, h.Rows)
+	}
+	if h.At(0, 0) != 5 || h.Max() != 5 {
+		t.Errorf("expected the spinner cell to change 5 times, got %d (max %d)", h.At(0, 0), h.Max())
+	}
+	if h.At(2, 0) != 1 || h.At(1, 0) != 0 || h.At(-1, 0) != 0 {
+		t.Errorf("unexpected counts %v", h.Counts)
+	}
+	if totals := h.RowTotals(); !reflect.DeepEqual(totals, []int{5, 0, 4}) {
+		t.Errorf("unexpected row totals %v", totals)
+	}
+	expected := []HeatCell{{0, 0, 5}, {2, 0, 1}}
+	if hottest := h.Hottest(2); !reflect.DeepEqual(hottest, expected) {
+		t.Errorf("expected %v, got %v", expected, hottest)
+	}
+	if len(h.Hottest(100)) != 5 {
+		t.Errorf("expected only changed cells, got %v", h.Hottest(100))
+	}
+
+	// Counts survive a resize and recording stops on Close
+	send(ResizeEvent{Cols: 12, Rows: 2})
+	waitUntil(t, func() bool { return r.Heatmap().Updates == 8 })
+	r.Close()
+	r.Close()
+	send(OutputEvent{Seq: "\x1b[Hx"})
+
+	h = r.Heatmap()
+	if h.Cols != 12 || h.Rows != 2 || h.At(0, 0) != 5 || h.Updates != 8 {
+		t.Errorf("unexpected heatmap after resize and close: %+v", h)
+	}
+}
@@ -0,0 +1,118 @@
+// Package htcmp provides go-cmp options for comparing htlib terminal types.
+//
+// The options make cmp.Diff usable directly on events captured in tests:
+//
+//	want := htlib.SnapshotEvent{Cols: 80, Rows: 24, Text: "$ echo hi\nhi\n$"}
+//	if diff := cmp.Diff(want, *snapshot, htcmp.Options(), htcmp.IgnoreSeq()); diff != "" {
+//	    t.Errorf("unexpected screen (-want +got):\n%s", diff)
+//	}
+//
+// This package lives outside the core htlib package so that only users who
+// import it depend on go-cmp.
+package htcmp
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/google/go-cmp/cmp"
+	htlib "github.com/io41/htlib.go"
+)
+
+// htlibPkgPath is the import path of the package whose types these options apply to.
+var htlibPkgPath = reflect.TypeOf(htlib.SnapshotEvent{}).PkgPath()
+
+// Options returns the recommended set of options for comparing htlib values:
+// timestamps are ignored, trailing whitespace in rendered text is trimmed
+// and screens are compared cell by cell.
+func Options() cmp.Options {
+	return cmp.Options{
+		IgnoreTime(),
+		TrimTrailingSpace(),
+		Screens(),
+	}
+}
+
+// screen is the exported state of an htlib.Screen, which cmp cannot
+// compare directly because its cell grid is unexported.
+type screen struct {
+	Cols   int
+	Rows   int
+	Cursor htlib.Cursor
+	Modes  htlib.Modes
+	Title  string
+	// Lines is the text of each row, for a readable diff
+	Lines []string
+	// Cells are the cells of each row, so differences in colors and
+	// attributes are reported too
+	Cells [][]htlib.Cell
+}
+
+// Screens compares htlib.Screen values, and pointers to them, by their
+// size, cursor, modes, title and cells. Without it cmp.Diff panics on the
+// Screen's unexported fields. Cell values need no option: they are
+// compared field by field, including their Style.
+func Screens() cmp.Option {
+	return cmp.Transformer("Screen", func(s htlib.Screen) screen {
+		view := screen{Cols: s.Cols, Rows: s.Rows, Cursor: s.Cursor, Modes: s.Modes, Title: s.Title}
+		for row := 0; row < s.Rows; row++ {
+			cells := make([]htlib.Cell, s.Cols)
+			for col := range cells {
+				cells[col] = s.Cell(row, col)
+			}
+			view.Lines = append(view.Lines, s.Line(row))
+			view.Cells = append(view.Cells, cells)
+		}
+		return view
+	})
+}
+
+// IgnoreTime ignores the Time field of all htlib event types.
+func IgnoreTime() cmp.Option {
+	return cmp.FilterPath(fieldFilter("Time"), cmp.Ignore())
+}
+
+// IgnoreSeq ignores the raw VT100 Seq field of all htlib event types.
+func IgnoreSeq() cmp.Option {
+	return cmp.FilterPath(fieldFilter("Seq"), cmp.Ignore())
+}
+
+// TrimTrailingSpace compares the rendered Text field of htlib types after
+// removing trailing whitespace from every line and dropping trailing blank
+// lines, so padding to the terminal width does not produce differences.
+func TrimTrailingSpace() cmp.Option {
+	return cmp.FilterPath(fieldFilter("Text"), cmp.Transformer("TrimTrailingSpace", trimTrailingSpace))
+}
+
+// StripANSI compares the raw Seq field of htlib types with ANSI escape
+// sequences removed, so only the printed characters are compared.
+func StripANSI() cmp.Option {
+	return cmp.FilterPath(fieldFilter("Seq"), cmp.Transformer("StripANSI", htlib.StripANSI))
+}
+
+// fieldFilter matches paths ending in the named field of a struct defined in htlib.
+func fieldFilter(name string) func(cmp.Path) bool {
+	return func(p cmp.Path) bool {
+		if len(p) < 2 {
+			return false
+		}
+		field, ok := p.Last().(cmp.StructField)
+		if !ok || field.Name() != name {
+			return false
+		}
+		parent := p.Index(-2).Type()
+		return parent != nil && parent.PkgPath() == htlibPkgPath
+	}
+}
+
+// trimTrailingSpace trims each line and removes trailing blank lines.
+func trimTrailingSpace(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
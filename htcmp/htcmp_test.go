@@ -0,0 +1,81 @@
+package htcmp
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	htlib "github.com/io41/htlib.go"
+)
+
+func TestOptions(t *testing.T) {
+	want := htlib.SnapshotEvent{
+		Cols: 10,
+		Rows: 2,
+		Seq:  "$ ls",
+		Text: "$ ls\nfile",
+	}
+	got := htlib.SnapshotEvent{
+		Cols: 10,
+		Rows: 2,
+		Seq:  "$ ls",
+		Text: "$ ls      \nfile      \n          ",
+		Time: time.Now(),
+	}
+
+	if diff := cmp.Diff(want, got, Options()); diff != "" {
+		t.Errorf("unexpected diff (-want +got):\n%s", diff)
+	}
+
+	got.Text = "$ pwd"
+	if cmp.Equal(want, got, Options()) {
+		t.Error("expected different text to be reported")
+	}
+}
+
+func TestIgnoreSeq(t *testing.T) {
+	want := htlib.OutputEvent{Seq: "hello"}
+	got := htlib.OutputEvent{Seq: "world", Time: time.Now()}
+
+	if !cmp.Equal(want, got, IgnoreTime(), IgnoreSeq()) {
+		t.Error("expected Seq to be ignored")
+	}
+}
+
+func TestStripANSI(t *testing.T) {
+	want := htlib.OutputEvent{Seq: "red"}
+	got := htlib.OutputEvent{Seq: "\x1b[31mred\x1b[0m"}
+
+	if diff := cmp.Diff(want, got, StripANSI()); diff != "" {
+		t.Errorf("unexpected diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestScreens(t *testing.T) {
+	want := htlib.SnapshotEvent{Cols: 10, Rows: 2, Seq: "$ ls\r\n\x1b[31mfile"}.Screen()
+	got := htlib.SnapshotEvent{Cols: 10, Rows: 2, Seq: "$ ls\r\n\x1b[31mfile"}.Screen()
+	if diff := cmp.Diff(want, got, Options()); diff != "" {
+		t.Errorf("unexpected diff (-want +got):\n%s", diff)
+	}
+
+	// Same text in another color
+	got = htlib.SnapshotEvent{Cols: 10, Rows: 2, Seq: "$ ls\r\n\x1b[32mfile"}.Screen()
+	diff := cmp.Diff(want, got, Options())
+	if !strings.Contains(diff, "Fg:") {
+		t.Errorf("expected a difference in the colors, got:\n%s", diff)
+	}
+	if cmp.Equal(*want, *got, Options()) {
+		t.Error("expected screen values to differ too")
+	}
+}
+
+func TestOptionsOnlyApplyToHtlibTypes(t *testing.T) {
+	type other struct {
+		Text string
+	}
+
+	if cmp.Equal(other{Text: "a "}, other{Text: "a"}, Options()) {
+		t.Error("expected options to leave non-htlib types alone")
+	}
+}
@@ -0,0 +1,170 @@
+package htlib
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// The colors used for the default foreground and background when
+// rendering, those of xterm.
+const (
+	defaultFgHex = "#e5e5e5"
+	defaultBgHex = "#000000"
+)
+
+// basicColors are xterm's values for the 16 standard colors.
+var basicColors = [16]string{
+	"#000000", "#cd0000", "#00cd00", "#cdcd00", "#0000ee", "#cd00cd", "#00cdcd", "#e5e5e5",
+	"#7f7f7f", "#ff0000", "#00ff00", "#ffff00", "#5c5cff", "#ff00ff", "#00ffff", "#ffffff",
+}
+
+// hex returns the color as #rrggbb, or def for the default color.
+func (c Color) hex(def string) string {
+	if r, g, b, ok := c.RGB(); ok {
+		return fmt.Sprintf("#%02x%02x%02x", r, g, b)
+	}
+	n, ok := c.Index()
+	switch {
+	case !ok:
+		return def
+	case n < 16:
+		return basicColors[n]
+	case n < 232:
+		// 6x6x6 color cube
+		level := func(v uint8) uint8 {
+			if v == 0 {
+				return 0
+			}
+			return 55 + 40*v
+		}
+		n -= 16
+		return fmt.Sprintf("#%02x%02x%02x", level(n/36), level(n/6%6), level(n%6))
+	default:
+		gray := 8 + 10*(n-232)
+		return fmt.Sprintf("#%02x%02x%02x", gray, gray, gray)
+	}
+}
+
+// colors returns the foreground and background of style as #rrggbb, with
+// reverse video applied.
+func (s Style) colors() (fg, bg string) {
+	fg, bg = s.Fg.hex(defaultFgHex), s.Bg.hex(defaultBgHex)
+	if s.Reverse {
+		fg, bg = bg, fg
+	}
+	if s.Invisible {
+		fg = bg
+	}
+	return fg, bg
+}
+
+// css returns the inline CSS of style, leaving out the defaults.
+func (s Style) css() string {
+	var props []string
+	fg, bg := s.colors()
+	if fg != defaultFgHex {
+		props = append(props, "color:"+fg)
+	}
+	if bg != defaultBgHex {
+		props = append(props, "background:"+bg)
+	}
+	if s.Bold {
+		props = append(props, "font-weight:bold")
+	}
+	if s.Faint {
+		props = append(props, "opacity:0.5")
+	}
+	if s.Italic {
+		props = append(props, "font-style:italic")
+	}
+	var decorations []string
+	if s.Underline {
+		decorations = append(decorations, "underline")
+	}
+	if s.Strikethrough {
+		decorations = append(decorations, "line-through")
+	}
+	if len(decorations) > 0 {
+		props = append(props, "text-decoration:"+strings.Join(decorations, " "))
+	}
+	return strings.Join(props, ";")
+}
+
+// HTML renders the screen as a self-contained HTML fragment: a <pre>
+// element with inline styles for colors and attributes, using xterm's
+// palette on a black background. A visible cursor is shown in reverse
+// video. The fragment can be attached to test reports or bug tickets as is:
+//
+//	os.WriteFile("screen.html", []byte(vt.Screen().HTML()), 0o644)
+func (s *Screen) HTML() string {
+	return s.html(nil)
+}
+
+// HTML renders the current screen like Screen.HTML, preceded by <meta>
+// elements describing the session, one per field of Metadata that is set,
+// so that a capture attached to a report says where it came from:
+//
+//	<meta name="htlib:title" content="login flow">
+//	<meta name="htlib:env.LANG" content="C">
+func (vt *VirtualTerminal) HTML() string {
+	meta := vt.Metadata()
+	return vt.Screen().html(&meta)
+}
+
+// html renders the screen, with meta as <meta> elements if it is not nil.
+func (s *Screen) html(meta *Metadata) string {
+	var b strings.Builder
+	if meta != nil {
+		for _, f := range meta.fields() {
+			fmt.Fprintf(&b, "<meta name=\"htlib:%s\" content=\"%s\">\n", html.EscapeString(f.Name), html.EscapeString(f.Value))
+		}
+	}
+	fmt.Fprintf(&b, `<pre class="htlib-screen" style="margin:0;padding:0.5em;font-family:monospace;line-height:1.2;color:%s;background:%s">`, defaultFgHex, defaultBgHex)
+	for row, line := range s.cells {
+		if row > 0 {
+			b.WriteByte('\n')
+		}
+		end := len(line)
+		for end > 0 && line[end-1] == blankCell {
+			end--
+		}
+		if s.Cursor.Visible && row == s.Cursor.Row {
+			end = max(end, min(s.Cursor.Col+1, len(line)))
+		}
+
+		for col := 0; col < end; {
+			style := s.cellStyle(row, col)
+			start := col
+			for col < end && s.cellStyle(row, col) == style {
+				col++
+			}
+			var text strings.Builder
+			for _, cell := range line[start:col] {
+				text.WriteString(cell.String())
+			}
+			if css := style.css(); css != "" {
+				fmt.Fprintf(&b, `<span style="%s">%s</span>`, css, html.EscapeString(text.String()))
+			} else {
+				b.WriteString(html.EscapeString(text.String()))
+			}
+		}
+	}
+	b.WriteString("</pre>")
+	return b.String()
+}
+
+// cellStyle returns the style a cell is rendered with, which is reversed
+// under a visible cursor.
+func (s *Screen) cellStyle(row, col int) Style {
+	style := s.cells[row][col].Style
+	if s.Cursor.Visible && row == s.Cursor.Row && col == s.Cursor.Col {
+		style.Reverse = !style.Reverse
+	}
+	return style
+}
+
+// HTML renders the snapshot like Screen.HTML.
+func (e SnapshotEvent) HTML() string {
+	return e.Screen().HTML()
+}
@@ -0,0 +1,63 @@
+package htlib
+
+import (
+	"html"
+	"strings"
+	"testing"
+)
+
+func TestColorHex(t *testing.T) {
+	tests := []struct {
+		color Color
+		hex   string
+	}{
+		{DefaultColor, "default"},
+		{ColorRed, "#cd0000"},
+		{ColorBrightBlue, "#5c5cff"},
+		{IndexedColor(16), "#000000"},
+		{IndexedColor(208), "#ff8700"},
+		{IndexedColor(244), "#808080"},
+		{RGBColor(1, 2, 3), "#010203"},
+	}
+	for _, tt := range tests {
+		if hex := tt.color.hex("default"); hex != tt.hex {
+			t.Errorf("%v: expected %s, got %s", tt.color, tt.hex, hex)
+		}
+	}
+}
+
+func TestScreenHTML(t *testing.T) {
+	m := newScreenModel(20, 3)
+	m.feed("a<b> \x1b[1;31mFAIL\x1b[0m \x1b[7mrev\x1b[0m\r\n\x1b[4;9mx\x1b[0m\r\n\x1b[?25l")
+	got := m.snapshot().HTML()
+
+	expected := `<pre class="htlib-screen" style="margin:0;padding:0.5em;font-family:monospace;line-height:1.2;color:#e5e5e5;background:#000000">` +
+		`a&lt;b&gt; <span style="color:#cd0000;font-weight:bold">FAIL</span> <span style="color:#000000;background:#e5e5e5">rev</span>` + "\n" +
+		`<span style="text-decoration:underline line-through">x</span>` + "\n" +
+		`</pre>`
+	if got != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, got)
+	}
+
+	// A visible cursor is shown in reverse video
+	m.feed("\x1b[?25h\x1b[3;3H")
+	if got := m.snapshot().HTML(); !strings.HasSuffix(got, "\n"+`  <span style="color:#000000;background:#e5e5e5"> </span></pre>`) {
+		t.Errorf("expected the cursor on the last line, got %s", got)
+	}
+}
+
+func TestTerminalHTML(t *testing.T) {
+	vt, _ := newTestTerminal()
+	defer vt.Close()
+	vt.config.Metadata = Metadata{Title: `"quoted" <title>`, Extra: map[string]string{"run": "7"}}
+	vt.trackEvent(OutputEvent{Seq: "hi"})
+
+	got := vt.HTML()
+	expected := `<meta name="htlib:title" content="&#34;quoted&#34; &lt;title&gt;">` + "\n" +
+		`<meta name="htlib:command" content="` + html.EscapeString(vt.Metadata().Command) + `">` + "\n" +
+		`<meta name="htlib:extra.run" content="7">` + "\n" +
+		`<pre class="htlib-screen"`
+	if !strings.HasPrefix(got, expected) || !strings.HasSuffix(got, vt.Screen().HTML()) {
+		t.Errorf("unexpected HTML\n%s", got)
+	}
+}
@@ -0,0 +1,64 @@
+package htproto
+
+import (
+	"bufio"
+	"io"
+)
+
+// maxLineSize bounds a single event line. Snapshots of large terminals
+// with many attribute changes easily exceed bufio's default of 64 KiB.
+const maxLineSize = 16 << 20
+
+// Encoder writes commands to ht's standard input.
+type Encoder struct {
+	w   io.Writer
+	buf []byte
+}
+
+// NewEncoder returns an Encoder writing to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes the commands in a single write, so that they reach ht
+// together even if other writers share w.
+func (e *Encoder) Encode(cmds ...Command) error {
+	e.buf = e.buf[:0]
+	for _, cmd := range cmds {
+		var err error
+		if e.buf, err = AppendCommand(e.buf, cmd); err != nil {
+			return err
+		}
+	}
+	_, err := e.w.Write(e.buf)
+	return err
+}
+
+// Decoder reads events from ht's standard output.
+type Decoder struct {
+	scanner *bufio.Scanner
+}
+
+// NewDecoder returns a Decoder reading from r.
+func NewDecoder(r io.Reader) *Decoder {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	return &Decoder{scanner: scanner}
+}
+
+// Decode reads the next event, skipping blank lines. It returns io.EOF
+// once r is exhausted. A malformed line is reported as an error, after
+// which decoding can continue with the next line.
+func (d *Decoder) Decode() (Event, error) {
+	for d.scanner.Scan() {
+		line := d.scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		return ParseEvent(line)
+	}
+	if err := d.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
@@ -0,0 +1,87 @@
+package htproto
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Command is a command sent to ht. Only the fields used by its Type are
+// set; the constructors below fill them in.
+type Command struct {
+	Type    string   `json:"type"`
+	Payload string   `json:"payload,omitempty"`
+	Keys    []string `json:"keys,omitempty"`
+	Cols    int      `json:"cols,omitempty"`
+	Rows    int      `json:"rows,omitempty"`
+	Event   string   `json:"event,omitempty"`
+	Button  string   `json:"button,omitempty"`
+	Row     int      `json:"row,omitempty"`
+	Col     int      `json:"col,omitempty"`
+	Shift   bool     `json:"shift,omitempty"`
+	Ctrl    bool     `json:"ctrl,omitempty"`
+	Alt     bool     `json:"alt,omitempty"`
+
+	// Secret marks an input whose payload must not be recorded. It is
+	// not sent to ht.
+	Secret bool `json:"-"`
+	// Display, if set, is recorded in place of the payload of an input
+	// that wraps what the user asked for. It is not sent to ht.
+	Display string `json:"-"`
+}
+
+// Input returns a command that writes text to the terminal as if typed.
+func Input(text string) Command {
+	return Command{Type: CommandInput, Payload: text}
+}
+
+// SendKeys returns a command that sends named keys such as "Enter", "C-c"
+// or "F1".
+func SendKeys(keys ...string) Command {
+	return Command{Type: CommandSendKeys, Keys: keys}
+}
+
+// Resize returns a command that resizes the terminal.
+func Resize(cols, rows int) Command {
+	return Command{Type: CommandResize, Cols: cols, Rows: rows}
+}
+
+// TakeSnapshot returns a command that makes ht send a snapshot event.
+func TakeSnapshot() Command {
+	return Command{Type: CommandTakeSnapshot}
+}
+
+// Mouse returns a mouse command. event is one of "click", "press",
+// "release", "drag" or "scroll"; row and col are 1-based.
+func Mouse(event, button string, row, col int, shift, ctrl, alt bool) Command {
+	return Command{
+		Type:   CommandMouse,
+		Event:  event,
+		Button: button,
+		Row:    row,
+		Col:    col,
+		Shift:  shift,
+		Ctrl:   ctrl,
+		Alt:    alt,
+	}
+}
+
+// AppendCommand appends the encoded command and its terminating newline to
+// dst.
+func AppendCommand(dst []byte, cmd Command) ([]byte, error) {
+	encoded, err := json.Marshal(cmd)
+	if err != nil {
+		return dst, fmt.Errorf("failed to marshal command: %w", err)
+	}
+	dst = append(dst, encoded...)
+	return append(dst, '\n'), nil
+}
+
+// ParseCommand decodes one command line, for code that plays the part of
+// ht.
+func ParseCommand(line []byte) (Command, error) {
+	var cmd Command
+	if err := json.Unmarshal(line, &cmd); err != nil {
+		return Command{}, fmt.Errorf("failed to parse command: %w", err)
+	}
+	return cmd, nil
+}
@@ -0,0 +1,133 @@
+package htproto
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Event is an event sent by ht. The concrete types are InitEvent,
+// OutputEvent, ResizeEvent, SnapshotEvent, MouseEvent and, for types this
+// package does not know, UnknownEvent.
+type Event interface {
+	EventType() string
+}
+
+// InitEvent is the first event ht sends, describing the terminal it started.
+type InitEvent struct {
+	Cols int    `json:"cols"`
+	Rows int    `json:"rows"`
+	PID  int    `json:"pid"`
+	Seq  string `json:"seq"`  // Screen contents as VT sequences
+	Text string `json:"text"` // Screen contents as plain text
+}
+
+// OutputEvent carries output written by the program.
+type OutputEvent struct {
+	Seq string `json:"seq"`
+}
+
+// ResizeEvent reports a change of terminal size.
+type ResizeEvent struct {
+	Cols int `json:"cols"`
+	Rows int `json:"rows"`
+}
+
+// SnapshotEvent answers a takeSnapshot command.
+type SnapshotEvent struct {
+	Cols int    `json:"cols"`
+	Rows int    `json:"rows"`
+	Seq  string `json:"seq"`  // Screen contents as VT sequences
+	Text string `json:"text"` // Screen contents as plain text
+}
+
+// MouseEvent reports a mouse event. Row and Col are 1-based.
+type MouseEvent struct {
+	Event  string `json:"event"`
+	Button string `json:"button"`
+	Row    int    `json:"row"`
+	Col    int    `json:"col"`
+	Shift  bool   `json:"shift"`
+	Ctrl   bool   `json:"ctrl"`
+	Alt    bool   `json:"alt"`
+}
+
+// UnknownEvent is an event of a type this package does not know, kept so
+// that newer versions of ht do not break older clients.
+type UnknownEvent struct {
+	Type string
+	Data json.RawMessage
+}
+
+func (InitEvent) EventType() string      { return EventInit }
+func (OutputEvent) EventType() string    { return EventOutput }
+func (ResizeEvent) EventType() string    { return EventResize }
+func (SnapshotEvent) EventType() string  { return EventSnapshot }
+func (MouseEvent) EventType() string     { return EventMouse }
+func (e UnknownEvent) EventType() string { return e.Type }
+
+// envelope is the outer shape of every event line.
+type envelope struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// ParseEvent decodes one event line.
+func ParseEvent(line []byte) (Event, error) {
+	var env envelope
+	if err := json.Unmarshal(line, &env); err != nil {
+		return nil, fmt.Errorf("failed to parse event: %w", err)
+	}
+
+	var event Event
+	var err error
+	switch env.Type {
+	case EventInit:
+		event, err = decodeData[InitEvent](env.Data)
+	case EventOutput:
+		event, err = decodeData[OutputEvent](env.Data)
+	case EventResize:
+		event, err = decodeData[ResizeEvent](env.Data)
+	case EventSnapshot:
+		event, err = decodeData[SnapshotEvent](env.Data)
+	case EventMouse:
+		event, err = decodeData[MouseEvent](env.Data)
+	default:
+		return UnknownEvent{Type: env.Type, Data: env.Data}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s event: %w", env.Type, err)
+	}
+	return event, nil
+}
+
+func decodeData[T Event](data json.RawMessage) (Event, error) {
+	var event T
+	if len(data) == 0 {
+		return event, nil
+	}
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// AppendEvent appends the encoded event and its terminating newline to
+// dst, for code that plays the part of ht.
+func AppendEvent(dst []byte, event Event) ([]byte, error) {
+	env := envelope{Type: event.EventType()}
+	if unknown, ok := event.(UnknownEvent); ok {
+		env.Data = unknown.Data
+	} else {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return dst, fmt.Errorf("failed to marshal event: %w", err)
+		}
+		env.Data = data
+	}
+	encoded, err := json.Marshal(env)
+	if err != nil {
+		return dst, fmt.Errorf("failed to marshal event: %w", err)
+	}
+	dst = append(dst, encoded...)
+	return append(dst, '\n'), nil
+}
@@ -0,0 +1,68 @@
+// Package htproto implements the wire protocol of ht, the headless
+// terminal that htlib drives.
+//
+// ht reads commands from its standard input and writes events to its
+// standard output, one JSON object per line:
+//
+//	→ {"type":"input","payload":"ls\n"}
+//	← {"type":"output","data":{"seq":"ls\r\nfile.txt\r\n$ "}}
+//
+// Events are only sent for the types named in ht's --subscribe flag; see
+// EventTypes. Most programs should use htlib.VirtualTerminal, which
+// manages the ht process. This package is for code that talks to ht (or
+// something that speaks its protocol) directly, such as proxies, remote
+// backends and test doubles:
+//
+//	enc := htproto.NewEncoder(stdin)
+//	dec := htproto.NewDecoder(stdout)
+//	enc.Encode(htproto.Input("echo hi\n"), htproto.TakeSnapshot())
+//	for {
+//	    event, err := dec.Decode()
+//	    if err != nil {
+//	        break
+//	    }
+//	    if s, ok := event.(htproto.SnapshotEvent); ok {
+//	        fmt.Println(s.Text)
+//	    }
+//	}
+package htproto
+
+import "strings"
+
+// ProtocolVersion identifies the revision of the protocol implemented by
+// this package. It is incremented when commands or events change
+// incompatibly.
+const ProtocolVersion = 1
+
+// MinHtVersion is the oldest ht release that speaks this protocol.
+const MinHtVersion = "0.3.0"
+
+// Command types.
+const (
+	CommandInput        = "input"
+	CommandSendKeys     = "sendKeys"
+	CommandResize       = "resize"
+	CommandTakeSnapshot = "takeSnapshot"
+	CommandMouse        = "mouse"
+)
+
+// Event types.
+const (
+	EventInit     = "init"
+	EventOutput   = "output"
+	EventResize   = "resize"
+	EventSnapshot = "snapshot"
+	EventMouse    = "mouse"
+)
+
+// EventTypes lists all event types, in the order ht documents them.
+var EventTypes = []string{EventInit, EventOutput, EventResize, EventSnapshot, EventMouse}
+
+// SubscribeArg returns the value of ht's --subscribe flag for the given
+// event types, or for all of them if none are given.
+func SubscribeArg(types ...string) string {
+	if len(types) == 0 {
+		types = EventTypes
+	}
+	return strings.Join(types, ",")
+}
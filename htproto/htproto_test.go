@@ -0,0 +1,177 @@
+package htproto
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAppendCommand(t *testing.T) {
+	tests := []struct {
+		cmd      Command
+		expected string
+	}{
+		{Input("ls\n"), `{"type":"input","payload":"ls\n"}`},
+		{SendKeys("Enter", "C-c"), `{"type":"sendKeys","keys":["Enter","C-c"]}`},
+		{Resize(80, 24), `{"type":"resize","cols":80,"rows":24}`},
+		{TakeSnapshot(), `{"type":"takeSnapshot"}`},
+		{
+			Mouse("click", "left", 5, 10, false, true, false),
+			`{"type":"mouse","event":"click","button":"left","row":5,"col":10,"ctrl":true}`,
+		},
+	}
+	for _, tt := range tests {
+		data, err := AppendCommand(nil, tt.cmd)
+		if err != nil {
+			t.Fatalf("AppendCommand(%+v): %v", tt.cmd, err)
+		}
+		if string(data) != tt.expected+"\n" {
+			t.Errorf("expected %s, got %s", tt.expected, data)
+		}
+
+		parsed, err := ParseCommand(data)
+		if err != nil {
+			t.Fatalf("ParseCommand(%s): %v", data, err)
+		}
+		if !reflect.DeepEqual(parsed, tt.cmd) {
+			t.Errorf("round trip: expected %+v, got %+v", tt.cmd, parsed)
+		}
+	}
+
+	if _, err := ParseCommand([]byte("{")); err == nil {
+		t.Error("expected an error for malformed JSON")
+	}
+}
+
+func TestParseEvent(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected Event
+	}{
+		{
+			`{"type":"init","data":{"cols":80,"rows":24,"pid":42,"seq":"\u001b[H$ ","text":"$ "}}`,
+			InitEvent{Cols: 80, Rows: 24, PID: 42, Seq: "\x1b[H$ ", Text: "$ "},
+		},
+		{`{"type":"output","data":{"seq":"hi\r\n"}}`, OutputEvent{Seq: "hi\r\n"}},
+		{`{"type":"resize","data":{"cols":100,"rows":30}}`, ResizeEvent{Cols: 100, Rows: 30}},
+		{
+			`{"type":"snapshot","data":{"cols":2,"rows":1,"seq":"ok","text":"ok"}}`,
+			SnapshotEvent{Cols: 2, Rows: 1, Seq: "ok", Text: "ok"},
+		},
+		{
+			`{"type":"mouse","data":{"event":"press","button":"right","row":1,"col":2,"alt":true}}`,
+			MouseEvent{Event: "press", Button: "right", Row: 1, Col: 2, Alt: true},
+		},
+		{`{"type":"output"}`, OutputEvent{}},
+		{
+			`{"type":"bell","data":{"count":1}}`,
+			UnknownEvent{Type: "bell", Data: json.RawMessage(`{"count":1}`)},
+		},
+	}
+	for _, tt := range tests {
+		event, err := ParseEvent([]byte(tt.line))
+		if err != nil {
+			t.Fatalf("ParseEvent(%s): %v", tt.line, err)
+		}
+		if !reflect.DeepEqual(event, tt.expected) {
+			t.Errorf("ParseEvent(%s): expected %#v, got %#v", tt.line, tt.expected, event)
+		}
+
+		// Encoding the event gives back an equivalent line
+		data, err := AppendEvent(nil, event)
+		if err != nil {
+			t.Fatalf("AppendEvent(%#v): %v", event, err)
+		}
+		again, err := ParseEvent(data)
+		if err != nil || !reflect.DeepEqual(again, event) {
+			t.Errorf("round trip of %s gave %#v (%v)", tt.line, again, err)
+		}
+	}
+
+	for _, line := range []string{"not json", `{"type":"resize","data":{"cols":"wide"}}`} {
+		if _, err := ParseEvent([]byte(line)); err == nil {
+			t.Errorf("ParseEvent(%s): expected an error", line)
+		}
+	}
+}
+
+func TestEncoderDecoder(t *testing.T) {
+	var buf countingWriter
+	enc := NewEncoder(&buf)
+	if err := enc.Encode(Input("echo hi\n"), TakeSnapshot()); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if buf.writes != 1 {
+		t.Errorf("expected commands in a single write, got %d writes", buf.writes)
+	}
+	expected := "{\"type\":\"input\",\"payload\":\"echo hi\\n\"}\n{\"type\":\"takeSnapshot\"}\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+
+	stream := strings.Join([]string{
+		`{"type":"output","data":{"seq":"a"}}`,
+		``,
+		`garbage`,
+		`{"type":"output","data":{"seq":"b"}}`,
+	}, "\n")
+	dec := NewDecoder(strings.NewReader(stream))
+
+	var got []Event
+	var failures int
+	for {
+		event, err := dec.Decode()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			failures++
+			continue
+		}
+		got = append(got, event)
+	}
+	if failures != 1 {
+		t.Errorf("expected the malformed line to fail once, got %d failures", failures)
+	}
+	if !reflect.DeepEqual(got, []Event{OutputEvent{Seq: "a"}, OutputEvent{Seq: "b"}}) {
+		t.Errorf("unexpected events %#v", got)
+	}
+}
+
+func TestDecoderLongLine(t *testing.T) {
+	seq := strings.Repeat("x", 200*1024)
+	line, err := AppendEvent(nil, SnapshotEvent{Cols: 1, Rows: 1, Seq: seq})
+	if err != nil {
+		t.Fatal(err)
+	}
+	event, err := NewDecoder(bytes.NewReader(line)).Decode()
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if s, ok := event.(SnapshotEvent); !ok || s.Seq != seq {
+		t.Errorf("long snapshot was not decoded intact")
+	}
+}
+
+func TestSubscribeArg(t *testing.T) {
+	if got := SubscribeArg(); got != "init,output,resize,snapshot,mouse" {
+		t.Errorf("unexpected default %q", got)
+	}
+	if got := SubscribeArg(EventOutput, EventSnapshot); got != "output,snapshot" {
+		t.Errorf("unexpected subset %q", got)
+	}
+}
+
+type countingWriter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return w.Buffer.Write(p)
+}
@@ -0,0 +1,158 @@
+package htlib
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PlayInputScript reads an input script from r and drives the terminal with
+// it. Scripts are plain text, so input sequences can be written without Go:
+//
+//	# log in and list files
+//	#waitfor "login:"
+//	admin
+//	#key Tab Enter
+//	#sleep 500ms
+//	ls -la
+//	#prompt
+//
+// Every line that is not a directive is typed followed by Enter (an empty
+// line just presses Enter). Directives start with '#':
+//
+//	# comment         ignored ('#' followed by a space, or a lone '#')
+//	##text            types "#text" followed by Enter
+//	#type text        types text without pressing Enter
+//	#key K1 K2 ...    sends named keys, e.g. Enter, C-c, Up
+//	#sleep 500ms      pauses (any time.ParseDuration value)
+//	#waitfor "text"   waits until text is on the screen (Go string syntax, quotes optional)
+//	#stable 200ms     waits until output has been quiet for the duration
+//	#prompt           waits until the shell is at a prompt
+//	#until name       waits for a condition registered with RegisterCondition
+//
+// The whole script is parsed before anything is sent, so a syntax error
+// never leaves the terminal half-driven. Errors name the script line.
+func (vt *VirtualTerminal) PlayInputScript(ctx context.Context, r io.Reader) error {
+	action, err := ParseInputScript(r)
+	if err != nil {
+		return err
+	}
+	return action(ctx, vt)
+}
+
+// ParseInputScript parses an input script (see PlayInputScript) into an
+// Action, which can also be submitted to the work queue.
+func ParseInputScript(r io.Reader) (Action, error) {
+	var steps []Action
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		step, err := parseScriptLine(strings.TrimRight(scanner.Text(), "\r"))
+		if err != nil {
+			return nil, fmt.Errorf("input script line %d: %w", lineNo, err)
+		}
+		if step != nil {
+			steps = append(steps, scriptStep(lineNo, step))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading input script: %w", err)
+	}
+	return Sequence(steps...), nil
+}
+
+// scriptStep wraps action so that its errors name the script line.
+func scriptStep(lineNo int, action Action) Action {
+	return func(ctx context.Context, vt *VirtualTerminal) error {
+		if err := action(ctx, vt); err != nil {
+			return fmt.Errorf("input script line %d: %w", lineNo, err)
+		}
+		return nil
+	}
+}
+
+// parseScriptLine returns the action for one script line, or nil for a
+// comment.
+func parseScriptLine(line string) (Action, error) {
+	if !strings.HasPrefix(line, "#") {
+		return InputAction(line + "\n"), nil
+	}
+	if line == "#" || strings.HasPrefix(line, "# ") {
+		return nil, nil
+	}
+	if literal, ok := strings.CutPrefix(line, "##"); ok {
+		return InputAction("#" + literal + "\n"), nil
+	}
+
+	directive, arg, _ := strings.Cut(line[1:], " ")
+	arg = strings.TrimSpace(arg)
+
+	switch directive {
+	case "type":
+		return InputAction(arg), nil
+
+	case "key":
+		keys := strings.Fields(arg)
+		if len(keys) == 0 {
+			return nil, fmt.Errorf("#key needs at least one key")
+		}
+		return KeysAction(keys...), nil
+
+	case "sleep":
+		d, err := time.ParseDuration(arg)
+		if err != nil {
+			return nil, fmt.Errorf("#sleep: %w", err)
+		}
+		return SleepAction(d), nil
+
+	case "waitfor":
+		text, err := scriptString(arg)
+		if err != nil {
+			return nil, fmt.Errorf("#waitfor: %w", err)
+		}
+		return func(ctx context.Context, vt *VirtualTerminal) error {
+			_, err := vt.WaitForText(ctx, text)
+			return err
+		}, nil
+
+	case "stable":
+		d, err := time.ParseDuration(arg)
+		if err != nil {
+			return nil, fmt.Errorf("#stable: %w", err)
+		}
+		return func(ctx context.Context, vt *VirtualTerminal) error {
+			return vt.WaitForStable(ctx, d)
+		}, nil
+
+	case "prompt":
+		return func(ctx context.Context, vt *VirtualTerminal) error {
+			return vt.WaitForPrompt(ctx)
+		}, nil
+
+	case "until":
+		if arg == "" {
+			return nil, fmt.Errorf("#until needs a condition name")
+		}
+		return func(ctx context.Context, vt *VirtualTerminal) error {
+			_, err := vt.WaitForCondition(ctx, arg)
+			return err
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown directive #%s", directive)
+	}
+}
+
+// scriptString parses a directive argument that may be a quoted Go string.
+func scriptString(arg string) (string, error) {
+	if arg == "" {
+		return "", fmt.Errorf("missing text")
+	}
+	if strings.HasPrefix(arg, `"`) || strings.HasPrefix(arg, "`") {
+		return strconv.Unquote(arg)
+	}
+	return arg, nil
+}
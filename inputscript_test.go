@@ -0,0 +1,93 @@
+package htlib
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPlayInputScriptCommands(t *testing.T) {
+	vt, stdin := newTestTerminal()
+	defer vt.Close()
+
+	script := strings.Join([]string{
+		"# comment",
+		"#",
+		"ls -la",
+		"",
+		"##not a directive",
+		"#type partial",
+		"#key Tab C-c",
+		"#sleep 1ms",
+	}, "\n")
+
+	if err := vt.PlayInputScript(context.Background(), strings.NewReader(script)); err != nil {
+		t.Fatalf("failed to play script: %v", err)
+	}
+
+	expected := strings.Join([]string{
+		`{"type":"input","payload":"ls -la\n"}`,
+		`{"type":"input","payload":"\n"}`,
+		`{"type":"input","payload":"#not a directive\n"}`,
+		`{"type":"input","payload":"partial"}`,
+		`{"type":"sendKeys","keys":["Tab","C-c"]}`,
+	}, "\n") + "\n"
+	if stdin.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, stdin.String())
+	}
+}
+
+func TestParseInputScriptErrors(t *testing.T) {
+	tests := []struct {
+		script string
+		errMsg string
+	}{
+		{"ls\n#bogus", "line 2: unknown directive #bogus"},
+		{"#sleep soon", "line 1: #sleep"},
+		{"#key", "line 1: #key needs at least one key"},
+		{`#waitfor "unterminated`, "line 1: #waitfor"},
+		{"\n\n#stable", "line 3: #stable"},
+		{"#until", "line 1: #until needs a condition name"},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseInputScript(strings.NewReader(tt.script))
+		if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+			t.Errorf("script %q: expected error containing %q, got %v", tt.script, tt.errMsg, err)
+		}
+	}
+}
+
+func TestPlayInputScriptStopsOnError(t *testing.T) {
+	vt, stdin := newTestTerminal()
+	defer vt.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	script := "#waitfor never-shown\necho unreachable"
+	err := vt.PlayInputScript(ctx, strings.NewReader(script))
+	if err == nil || !strings.Contains(err.Error(), "input script line 1") {
+		t.Errorf("expected error for line 1, got %v", err)
+	}
+	if strings.Contains(stdin.String(), "unreachable") {
+		t.Error("script continued after a failed step")
+	}
+}
+
+func TestPlayInputScript(t *testing.T) {
+	vt := New(DefaultConfig())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := vt.Start(ctx, WaitReady()); err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+	defer vt.Close()
+
+	script := "echo script-$((40+2))\n#waitfor \"script-42\"\n#prompt\n"
+	if err := vt.PlayInputScript(ctx, strings.NewReader(script)); err != nil {
+		t.Fatalf("failed to play script: %v", err)
+	}
+}
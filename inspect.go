@@ -0,0 +1,166 @@
+package htlib
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ProcessInfo describes a process running inside the terminal.
+type ProcessInfo struct {
+	PID     int
+	PPID    int
+	Command []string // Command line arguments
+	Cwd     string   // Current working directory
+	// Env is the environment the process was started with. Variables a
+	// shell exports after startup are not reflected here.
+	Env      []string
+	Children []ProcessInfo
+}
+
+// Inspect reports the current working directory, environment and child
+// process tree of the program running inside the terminal. It blocks until
+// the InitEvent has been received so the process ID is known.
+//
+// Inspect reads /proc and returns ErrUnsupported where it is not available.
+func (vt *VirtualTerminal) Inspect(ctx context.Context) (*ProcessInfo, error) {
+	vt.mu.RLock()
+	started := vt.started
+	vt.mu.RUnlock()
+	if !started {
+		return nil, ErrNotStarted
+	}
+
+	if err := vt.waitForInit(ctx); err != nil {
+		return nil, err
+	}
+
+	return inspectProcess(vt.PID())
+}
+
+// inspectProcess builds a ProcessInfo tree rooted at pid from /proc.
+func inspectProcess(pid int) (*ProcessInfo, error) {
+	if _, err := os.Stat("/proc/self"); err != nil {
+		return nil, fmt.Errorf("%w: /proc not available", ErrUnsupported)
+	}
+
+	parents, err := procParents()
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := readProcess(pid, parents)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect process %d: %w", pid, err)
+	}
+	return info, nil
+}
+
+// readProcess reads a single process and, recursively, its children.
+func readProcess(pid int, parents map[int]int) (*ProcessInfo, error) {
+	dir := filepath.Join("/proc", strconv.Itoa(pid))
+
+	cmdline, err := os.ReadFile(filepath.Join(dir, "cmdline"))
+	if err != nil {
+		return nil, err
+	}
+
+	info := &ProcessInfo{
+		PID:     pid,
+		PPID:    parents[pid],
+		Command: splitNul(cmdline),
+	}
+
+	// cwd and environ may be unreadable for processes owned by other users
+	if cwd, err := os.Readlink(filepath.Join(dir, "cwd")); err == nil {
+		info.Cwd = cwd
+	}
+	if environ, err := os.ReadFile(filepath.Join(dir, "environ")); err == nil {
+		info.Env = splitNul(environ)
+	}
+
+	for _, child := range childrenOf(pid, parents) {
+		childInfo, err := readProcess(child, parents)
+		if err != nil {
+			// The child may have exited while we were walking the tree
+			continue
+		}
+		info.Children = append(info.Children, *childInfo)
+	}
+
+	return info, nil
+}
+
+// procParents maps every visible process ID to its parent process ID.
+func procParents() (map[int]int, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read /proc: %w", err)
+	}
+
+	parents := make(map[int]int)
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		stat, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+		if ppid, ok := parseStatPPID(string(stat)); ok {
+			parents[pid] = ppid
+		}
+	}
+	return parents, nil
+}
+
+// childrenOf returns the children of pid in ascending order.
+func childrenOf(pid int, parents map[int]int) []int {
+	var children []int
+	for child, parent := range parents {
+		if parent == pid {
+			children = append(children, child)
+		}
+	}
+	sort.Ints(children)
+	return children
+}
+
+// parseStatPPID extracts the parent process ID from a /proc/<pid>/stat line.
+// The command name may contain spaces and parentheses, so fields are counted
+// from the last closing parenthesis.
+func parseStatPPID(stat string) (int, bool) {
+	end := strings.LastIndexByte(stat, ')')
+	if end < 0 {
+		return 0, false
+	}
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 2 {
+		return 0, false
+	}
+	ppid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return 0, false
+	}
+	return ppid, true
+}
+
+// splitNul splits a NUL-separated /proc file into its entries.
+func splitNul(data []byte) []string {
+	data = bytes.TrimRight(data, "\x00")
+	if len(data) == 0 {
+		return nil
+	}
+	parts := bytes.Split(data, []byte{0})
+	result := make([]string, len(parts))
+	for i, part := range parts {
+		result[i] = string(part)
+	}
+	return result
+}
@@ -0,0 +1,85 @@
+package htlib
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParseStatPPID(t *testing.T) {
+	tests := []struct {
+		stat string
+		ppid int
+		ok   bool
+	}{
+		{"1234 (bash) S 1000 1234 1234 0", 1000, true},
+		{"1234 (my (weird) cmd) R 42 1234", 42, true},
+		{"garbage", 0, false},
+	}
+
+	for _, tt := range tests {
+		ppid, ok := parseStatPPID(tt.stat)
+		if ppid != tt.ppid || ok != tt.ok {
+			t.Errorf("parseStatPPID(%q): expected (%d, %v), got (%d, %v)", tt.stat, tt.ppid, tt.ok, ppid, ok)
+		}
+	}
+}
+
+func TestInspectProcess(t *testing.T) {
+	info, err := inspectProcess(os.Getpid())
+	if errors.Is(err, ErrUnsupported) {
+		t.Skip("/proc not available")
+	}
+	if err != nil {
+		t.Fatalf("failed to inspect: %v", err)
+	}
+
+	wd, _ := os.Getwd()
+	if info.Cwd != wd {
+		t.Errorf("expected cwd %s, got %s", wd, info.Cwd)
+	}
+	if len(info.Command) == 0 {
+		t.Error("expected non-empty command")
+	}
+	if len(info.Env) == 0 {
+		t.Error("expected non-empty environment")
+	}
+}
+
+func TestInspectBeforeStart(t *testing.T) {
+	vt := New(DefaultConfig())
+
+	if _, err := vt.Inspect(context.Background()); err != ErrNotStarted {
+		t.Errorf("expected ErrNotStarted, got %v", err)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	vt := New(DefaultConfig())
+	ctx := context.Background()
+
+	if err := vt.Start(ctx); err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+	defer vt.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	info, err := vt.Inspect(ctx)
+	if errors.Is(err, ErrUnsupported) {
+		t.Skip("/proc not available")
+	}
+	if err != nil {
+		t.Fatalf("failed to inspect: %v", err)
+	}
+
+	if info.PID != vt.PID() {
+		t.Errorf("expected pid %d, got %d", vt.PID(), info.PID)
+	}
+	if info.Cwd == "" {
+		t.Error("expected non-empty cwd")
+	}
+}
@@ -0,0 +1,104 @@
+package htlib
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ShellJob is a job of the shell as listed by the jobs builtin.
+type ShellJob struct {
+	// ID is the job number, as in %1
+	ID int
+	// Current and Previous mark the jobs %+ and %-
+	Current  bool
+	Previous bool
+	// State is the state as printed by the shell, e.g. "Stopped" or
+	// "Running" in bash and "suspended" or "running" in zsh
+	State string
+	// Command is the command line of the job
+	Command string
+}
+
+// Stopped reports whether the job is suspended.
+func (j ShellJob) Stopped() bool {
+	state := strings.ToLower(j.State)
+	return strings.HasPrefix(state, "stopped") || strings.HasPrefix(state, "suspended")
+}
+
+// Running reports whether the job is running in the background.
+func (j ShellJob) Running() bool {
+	return strings.HasPrefix(strings.ToLower(j.State), "running")
+}
+
+// jobLine matches a line of jobs output in bash ("[1]+  Stopped  sleep 9")
+// and zsh ("[1]  + suspended  sleep 9").
+var jobLine = regexp.MustCompile(`^\[(\d+)\]\s*([+-])?\s+(\S+(?: \([^)]*\)| \d+)?)\s+(.*?)\s*$`)
+
+// SuspendForeground suspends the program running in the foreground by
+// typing Ctrl-Z, and waits for the shell to show its prompt again.
+func (vt *VirtualTerminal) SuspendForeground(ctx context.Context) error {
+	if err := vt.Input(ctx, "\x1a"); err != nil {
+		return err
+	}
+	return vt.WaitForPrompt(ctx)
+}
+
+// ResumeJob brings a job back to the foreground with fg. jobSpec is any
+// job specification the shell understands, such as "%1" or "%vim"; an
+// empty one resumes the current job. ResumeJob returns once the command
+// has been sent, since the job then has the terminal.
+func (vt *VirtualTerminal) ResumeJob(ctx context.Context, jobSpec string) error {
+	return vt.Input(ctx, strings.TrimSpace("fg "+jobSpec)+"\n")
+}
+
+// BackgroundJob resumes a stopped job in the background with bg, and waits
+// for the prompt.
+func (vt *VirtualTerminal) BackgroundJob(ctx context.Context, jobSpec string) error {
+	if err := vt.Input(ctx, strings.TrimSpace("bg "+jobSpec)+"\n"); err != nil {
+		return err
+	}
+	return vt.WaitForPrompt(ctx)
+}
+
+// Jobs runs the jobs builtin with RunCommand and returns the jobs it lists,
+// for checking that a program survives being suspended and resumed:
+//
+//	vt.Input(ctx, "my-cli watch\n")
+//	vt.SuspendForeground(ctx)
+//	jobs, _ := vt.Jobs(ctx)
+//	if len(jobs) != 1 || !jobs[0].Stopped() {
+//	    t.Fatalf("expected a stopped job, got %+v", jobs)
+//	}
+//	vt.ResumeJob(ctx, "%1")
+//
+// The output of bash and zsh is understood; lines in other formats are
+// skipped.
+func (vt *VirtualTerminal) Jobs(ctx context.Context) ([]ShellJob, error) {
+	result, err := vt.RunCommand(ctx, "jobs")
+	if err != nil {
+		return nil, err
+	}
+	return parseJobs(strings.Split(result.Text(), "\n")), nil
+}
+
+// parseJobs returns the jobs listed in lines of jobs output.
+func parseJobs(lines []string) []ShellJob {
+	var jobs []ShellJob
+	for _, line := range lines {
+		m := jobLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		id, _ := strconv.Atoi(m[1])
+		jobs = append(jobs, ShellJob{
+			ID:       id,
+			Current:  m[2] == "+",
+			Previous: m[2] == "-",
+			State:    m[3],
+			Command:  m[4],
+		})
+	}
+	return jobs
+}
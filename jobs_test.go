@@ -0,0 +1,85 @@
+package htlib
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseJobs(t *testing.T) {
+	lines := []string{
+		"$ jobs",
+		"[1]   Running                 sleep 5 &",
+		"[2]-  Stopped (tty output)    vim notes.txt",
+		"[3]+  Stopped                 top",
+		"[4]  + suspended  htop",
+		"[5]   Exit 1                  false",
+		"$",
+	}
+	expected := []ShellJob{
+		{ID: 1, State: "Running", Command: "sleep 5 &"},
+		{ID: 2, Previous: true, State: "Stopped (tty output)", Command: "vim notes.txt"},
+		{ID: 3, Current: true, State: "Stopped", Command: "top"},
+		{ID: 4, Current: true, State: "suspended", Command: "htop"},
+		{ID: 5, State: "Exit 1", Command: "false"},
+	}
+	jobs := parseJobs(lines)
+	if !reflect.DeepEqual(jobs, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, jobs)
+	}
+	if !jobs[0].Running() || jobs[0].Stopped() || !jobs[1].Stopped() || !jobs[3].Stopped() {
+		t.Errorf("unexpected states %+v", jobs)
+	}
+}
+
+func TestJobControl(t *testing.T) {
+	vt := New(DefaultConfig())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := vt.Start(ctx, WaitReady()); err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+	defer vt.Close()
+
+	if err := vt.Input(ctx, "sleep 30\n"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err := vt.SuspendForeground(ctx); err != nil {
+		t.Fatalf("failed to suspend: %v", err)
+	}
+	jobs, err := vt.Jobs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 1 || !jobs[0].Stopped() || jobs[0].Command != "sleep 30" {
+		t.Fatalf("expected a stopped sleep, got %+v", jobs)
+	}
+
+	if err := vt.BackgroundJob(ctx, "%1"); err != nil {
+		t.Fatal(err)
+	}
+	jobs, err = vt.Jobs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 1 || !jobs[0].Running() {
+		t.Fatalf("expected a running job, got %+v", jobs)
+	}
+
+	if err := vt.ResumeJob(ctx, "%1"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if vt.AtPrompt() {
+		t.Error("expected the job to be in the foreground")
+	}
+	if err := vt.SendKeys(ctx, "C-c"); err != nil {
+		t.Fatal(err)
+	}
+	if err := vt.WaitForPrompt(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
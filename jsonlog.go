@@ -0,0 +1,269 @@
+package htlib
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/io41/htlib.go/htproto"
+)
+
+// eventLine is one line of an event log: the envelope of ht's protocol with
+// the time the event was received.
+type eventLine struct {
+	Time time.Time `json:"time"`
+	Type string    `json:"type"`
+	Data any       `json:"data"`
+}
+
+// exitData is the data of an ExitEvent in an event log. ht has no exit
+// event; the log adds one so that it records how the session ended.
+type exitData struct {
+	Code   int    `json:"code"`
+	Signal int    `json:"signal,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// EventLogger writes the events of a terminal as JSON lines. Its methods
+// may be called from any goroutine.
+type EventLogger struct {
+	vt *VirtualTerminal
+
+	mu  sync.Mutex
+	w   io.Writer
+	err error
+}
+
+// LogEventsTo starts writing every event received from ht to w, one JSON
+// object per line in ht's own format with the time it was received added:
+//
+//	{"time":"2024-05-01T15:04:05.123Z","type":"output","data":{"seq":"ls\r\n"}}
+//
+// Events are written as they are handled, before they reach subscribers,
+// so none are missed. The log can be loaded with ReadEventLog and replayed
+// with ReplayEvents, for auditing sessions or for running tests against a
+// recorded session in CI without ht installed. Call Close to stop logging.
+func (vt *VirtualTerminal) LogEventsTo(w io.Writer) *EventLogger {
+	l := &EventLogger{vt: vt, w: w}
+	vt.mu.Lock()
+	vt.eventLoggers = append(vt.eventLoggers, l)
+	vt.mu.Unlock()
+	return l
+}
+
+// Close stops logging and returns the first error writing the log, if
+// any. It is safe to call Close more than once.
+func (l *EventLogger) Close() error {
+	l.vt.mu.Lock()
+	l.vt.eventLoggers = without(l.vt.eventLoggers, l)
+	l.vt.mu.Unlock()
+	return l.Err()
+}
+
+// Err returns the first error writing the log. Logging stops after an
+// error.
+func (l *EventLogger) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
+
+// log writes one event.
+func (l *EventLogger) log(event Event) {
+	var line eventLine
+	if exit, ok := event.(ExitEvent); ok {
+		data := exitData{Code: exit.Code, Signal: int(exit.Signal)}
+		if exit.Err != nil {
+			data.Error = exit.Err.Error()
+		}
+		line = eventLine{Time: exit.Time, Type: string(EventTypeExit), Data: data}
+	} else if data, at := protoEvent(event); data != nil {
+		line = eventLine{Time: at, Type: data.EventType(), Data: data}
+	} else {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return
+	}
+	encoded, err := json.Marshal(line)
+	if err == nil {
+		_, err = l.w.Write(append(encoded, '\n'))
+	}
+	if err != nil {
+		l.err = fmt.Errorf("failed to log event: %w", err)
+	}
+}
+
+// ReadEventLog reads an event log written by LogEventsTo. The events carry
+// the times they were originally received. Lines with event types this
+// version does not know are skipped. It returns an error matching
+// ErrInvalidRecording if a line cannot be parsed.
+func ReadEventLog(r io.Reader) ([]Event, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16<<20)
+
+	var events []Event
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var stamp struct {
+			Time time.Time `json:"time"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &stamp); err != nil {
+			return nil, fmt.Errorf("%w: bad event on line %d: %v", ErrInvalidRecording, line, err)
+		}
+		data, err := htproto.ParseEvent(scanner.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("%w: bad event on line %d: %v", ErrInvalidRecording, line, err)
+		}
+		if event := fromProtoEvent(data, stamp.Time); event != nil {
+			events = append(events, event)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// ReplayEvents sends events, as read with ReadEventLog, on the returned
+// channel with the pauses between them as they were recorded, so code that
+// consumes a terminal's events can be run against a logged session:
+//
+//	f, _ := os.Open("session.jsonl")
+//	events, err := htlib.ReadEventLog(f)
+//	for event := range htlib.ReplayEvents(ctx, events, htlib.WithSpeed(10)) {
+//	    // ...
+//	}
+//
+// The events keep their original times. The channel is closed after the
+// last event or when ctx is done.
+func ReplayEvents(ctx context.Context, events []Event, opts ...PlaybackOption) <-chan Event {
+	ch := make(chan Event)
+	go func() {
+		defer close(ch)
+		var start time.Time
+		if len(events) > 0 {
+			start = eventTime(events[0])
+		}
+		replay(ctx, len(events), playbackOptions{}, opts, func(i int) time.Duration {
+			return eventTime(events[i]).Sub(start)
+		}, func(i int) error {
+			select {
+			case ch <- events[i]:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+	return ch
+}
+
+// eventTime returns the time an event was received.
+func eventTime(event Event) time.Time {
+	switch e := event.(type) {
+	case InitEvent:
+		return e.Time
+	case OutputEvent:
+		return e.Time
+	case ResizeEvent:
+		return e.Time
+	case SnapshotEvent:
+		return e.Time
+	case MouseEvent:
+		return e.Time
+	case ExitEvent:
+		return e.Time
+	case ChangeEvent:
+		return e.Time
+	case RestartEvent:
+		return e.Time
+	case UnknownEvent:
+		return e.Time
+	case StderrEvent:
+		return e.Time
+	}
+	return time.Time{}
+}
+
+// protoEvent converts an event to its form in ht's protocol, or returns nil
+// for events ht does not send.
+func protoEvent(event Event) (htproto.Event, time.Time) {
+	switch e := event.(type) {
+	case InitEvent:
+		return htproto.InitEvent{Cols: e.Cols, Rows: e.Rows, PID: e.PID, Seq: e.Seq, Text: e.Text}, e.Time
+	case OutputEvent:
+		return htproto.OutputEvent{Seq: e.Seq}, e.Time
+	case ResizeEvent:
+		return htproto.ResizeEvent{Cols: e.Cols, Rows: e.Rows}, e.Time
+	case SnapshotEvent:
+		return htproto.SnapshotEvent{Cols: e.Cols, Rows: e.Rows, Seq: e.Seq, Text: e.Text}, e.Time
+	case MouseEvent:
+		return htproto.MouseEvent{
+			Event:  e.Event,
+			Button: e.Button,
+			Row:    e.Row,
+			Col:    e.Col,
+			Shift:  e.Shift,
+			Ctrl:   e.Ctrl,
+			Alt:    e.Alt,
+		}, e.Time
+	}
+	return nil, time.Time{}
+}
+
+// fromProtoEvent converts an event of ht's protocol received at t, or the
+// exit event of a log, and returns nil for unknown event types.
+func fromProtoEvent(event htproto.Event, t time.Time) Event {
+	switch e := event.(type) {
+	case htproto.InitEvent:
+		return InitEvent{Cols: e.Cols, Rows: e.Rows, PID: e.PID, Seq: e.Seq, Text: e.Text, Time: t}
+	case htproto.OutputEvent:
+		return OutputEvent{Seq: e.Seq, Time: t}
+	case htproto.ResizeEvent:
+		return ResizeEvent{Cols: e.Cols, Rows: e.Rows, Time: t}
+	case htproto.SnapshotEvent:
+		return SnapshotEvent{
+			Cols:   e.Cols,
+			Rows:   e.Rows,
+			Seq:    e.Seq,
+			Text:   e.Text,
+			Cursor: parseCursor(e.Seq, e.Cols, e.Rows),
+			Time:   t,
+		}
+	case htproto.MouseEvent:
+		return MouseEvent{
+			Event:  e.Event,
+			Button: e.Button,
+			Row:    e.Row,
+			Col:    e.Col,
+			Shift:  e.Shift,
+			Ctrl:   e.Ctrl,
+			Alt:    e.Alt,
+			Time:   t,
+		}
+	case htproto.UnknownEvent:
+		var data exitData
+		if e.Type != string(EventTypeExit) || json.Unmarshal(e.Data, &data) != nil {
+			return nil
+		}
+		exit := ExitEvent{Code: data.Code, Signal: syscall.Signal(data.Signal), Time: t}
+		if data.Error != "" {
+			exit.Err = errors.New(data.Error)
+		}
+		return exit
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package htlib
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestEventLogRoundTrip(t *testing.T) {
+	vt, _ := newTestTerminal()
+	defer vt.Close()
+
+	var buf bytes.Buffer
+	logger := vt.LogEventsTo(&buf)
+
+	start := time.Date(2024, 5, 1, 15, 4, 5, 0, time.UTC)
+	sent := []Event{
+		InitEvent{Cols: 80, Rows: 24, PID: 42, Seq: "\x1bc$ ", Text: "$", Time: start},
+		OutputEvent{Seq: "ls\r\n\x1b[32ma.txt\x1b[0m\r\n", Time: start.Add(100 * time.Millisecond)},
+		ResizeEvent{Cols: 100, Rows: 30, Time: start.Add(200 * time.Millisecond)},
+		MouseEvent{Event: "click", Button: "left", Row: 2, Col: 3, Ctrl: true, Time: start.Add(300 * time.Millisecond)},
+		SnapshotEvent{Cols: 100, Rows: 30, Seq: "\x1bc$ \x1b[1;3H", Text: "$", Time: start.Add(400 * time.Millisecond)},
+		ExitEvent{Code: -1, Signal: syscall.SIGTERM, Time: start.Add(500 * time.Millisecond)},
+	}
+	for _, event := range sent {
+		vt.trackEvent(event)
+	}
+	if err := logger.Close(); err != nil {
+		t.Fatal(err)
+	}
+	logger.Close()
+	vt.trackEvent(OutputEvent{Seq: "not logged", Time: start})
+
+	if n := strings.Count(buf.String(), "\n"); n != len(sent) {
+		t.Fatalf("expected %d lines, got %d:\n%s", len(sent), n, buf.String())
+	}
+	if !strings.Contains(buf.String(), `"type":"exit","data":{"code":-1,"signal":15}}`) {
+		t.Errorf("exit event not logged:\n%s", buf.String())
+	}
+	if !strings.HasPrefix(buf.String(), `{"time":"2024-05-01T15:04:05Z","type":"init","data":{"cols":80,`) {
+		t.Errorf("unexpected first line %q", strings.SplitN(buf.String(), "\n", 2)[0])
+	}
+
+	events, err := ReadEventLog(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != len(sent) {
+		t.Fatalf("expected %d events, got %+v", len(sent), events)
+	}
+	for i, event := range events {
+		if i == 4 {
+			continue // the cursor is derived on reading
+		}
+		if event != sent[i] {
+			t.Errorf("event %d: expected %+v, got %+v", i, sent[i], event)
+		}
+	}
+	snapshot := events[4].(SnapshotEvent)
+	if snapshot.Cursor.Row != 0 || snapshot.Cursor.Col != 2 || !snapshot.Time.Equal(start.Add(400*time.Millisecond)) {
+		t.Errorf("unexpected snapshot %+v", snapshot)
+	}
+}
+
+func TestReadEventLogErrors(t *testing.T) {
+	events, err := ReadEventLog(strings.NewReader(
+		`{"time":"2024-05-01T15:04:05Z","type":"bell","data":{}}` + "\n\n" +
+			`{"time":"2024-05-01T15:04:06Z","type":"output","data":{"seq":"hi"}}` + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].(OutputEvent).Seq != "hi" {
+		t.Errorf("expected the unknown event to be skipped, got %+v", events)
+	}
+
+	for _, log := range []string{"not json\n", `{"time":"yesterday","type":"output","data":{}}`} {
+		if _, err := ReadEventLog(strings.NewReader(log)); !errors.Is(err, ErrInvalidRecording) {
+			t.Errorf("%q: expected ErrInvalidRecording, got %v", log, err)
+		}
+	}
+}
+
+func TestEventLoggerWriteError(t *testing.T) {
+	vt, _ := newTestTerminal()
+	defer vt.Close()
+
+	logger := vt.LogEventsTo(failingWriter{})
+	vt.trackEvent(OutputEvent{Seq: "a"})
+	if logger.Err() == nil {
+		t.Error("expected a write error")
+	}
+	if err := logger.Close(); err == nil {
+		t.Error("expected Close to return the write error")
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestReplayEvents(t *testing.T) {
+	start := time.Now()
+	events := []Event{
+		InitEvent{Cols: 80, Rows: 24, Time: start},
+		OutputEvent{Seq: "a", Time: start.Add(100 * time.Millisecond)},
+		OutputEvent{Seq: "b", Time: start.Add(10 * time.Second)},
+	}
+
+	began := time.Now()
+	var got []Event
+	for event := range ReplayEvents(context.Background(), events, WithSpeed(2), WithMaxWait(20*time.Millisecond)) {
+		got = append(got, event)
+	}
+	if len(got) != 3 || got[2] != events[2] {
+		t.Errorf("expected the events unchanged, got %+v", got)
+	}
+	if elapsed := time.Since(began); elapsed < 40*time.Millisecond || elapsed > time.Second {
+		t.Errorf("unexpected replay time %v", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := ReplayEvents(ctx, events)
+	<-ch
+	cancel()
+	for range ch {
+	}
+}
@@ -0,0 +1,249 @@
+package htlib
+
+import (
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/io41/htlib.go/htproto"
+)
+
+// KeyProfile encodes the named keys passed to SendKeys into the bytes a
+// program receives. Without a profile ht translates the names itself. A
+// profile is given the current terminal modes (see Screen), so it can
+// follow the program, for example sending ESC O A for Up once a full-screen
+// program enables application cursor keys.
+//
+// EncodeKey returns false for keys it does not know; those are left to ht.
+type KeyProfile interface {
+	EncodeKey(key string, modes Modes) (string, bool)
+}
+
+// KeyProfileFunc adapts a function to the KeyProfile interface.
+type KeyProfileFunc func(key string, modes Modes) (string, bool)
+
+// EncodeKey calls f.
+func (f KeyProfileFunc) EncodeKey(key string, modes Modes) (string, bool) {
+	return f(key, modes)
+}
+
+// XtermKeys returns the profile of xterm's legacy encoding. Cursor keys
+// and Home/End switch between CSI and SS3 sequences with the application
+// cursor keys mode (DECCKM), and modifiers on special keys are sent as
+// xterm's CSI 1;m parameter.
+func XtermKeys() KeyProfile {
+	return KeyProfileFunc(encodeXtermKey)
+}
+
+// KittyKeys returns the profile of the kitty keyboard protocol, which
+// programs such as neovim and helix enable to tell keys like Ctrl-I and
+// Tab apart. Modified characters, Enter, Tab, Backspace and Escape are
+// sent as CSI code;m u; other keys use the xterm encoding.
+func KittyKeys() KeyProfile {
+	return KeyProfileFunc(encodeKittyKey)
+}
+
+// AutoKeys returns a profile that uses KittyKeys while the program has
+// enabled the kitty keyboard protocol and XtermKeys otherwise, so the same
+// key names work in a shell and in the editor it starts.
+func AutoKeys() KeyProfile {
+	return KeyProfileFunc(func(key string, modes Modes) (string, bool) {
+		if modes.KittyKeyboard != 0 {
+			return encodeKittyKey(key, modes)
+		}
+		return encodeXtermKey(key, modes)
+	})
+}
+
+// SetKeyProfile changes the profile used by SendKeys. A nil profile leaves
+// key translation to ht.
+func (vt *VirtualTerminal) SetKeyProfile(profile KeyProfile) {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	vt.keyProfile = profile
+}
+
+// keyCommands translates keys with the terminal's key profile. Runs of
+// keys the profile encodes become one input command; the rest are sent to
+// ht by name, in order.
+func (vt *VirtualTerminal) keyCommands(keys []string) []command {
+	vt.mu.RLock()
+	profile := vt.keyProfile
+	vt.mu.RUnlock()
+
+	if profile == nil {
+		return []command{htproto.SendKeys(keys...)}
+	}
+
+	modes := vt.Screen().Modes
+	var cmds []command
+	for _, key := range keys {
+		seq, ok := profile.EncodeKey(key, modes)
+		last := len(cmds) - 1
+		switch {
+		case ok && last >= 0 && cmds[last].Type == htproto.CommandInput:
+			cmds[last].Payload += seq
+		case ok:
+			cmds = append(cmds, htproto.Input(seq))
+		case last >= 0 && cmds[last].Type == htproto.CommandSendKeys:
+			cmds[last].Keys = append(cmds[last].Keys, key)
+		default:
+			cmds = append(cmds, htproto.SendKeys(key))
+		}
+	}
+	return cmds
+}
+
+// Key modifier bits, as in xterm's modifier parameter minus one.
+const (
+	modShift = 1 << iota
+	modAlt
+	modCtrl
+)
+
+// parseKey splits a key name like "C-S-Left" into its modifiers and base
+// key. Caret notation such as "^c" is read as Ctrl.
+func parseKey(key string) (mods int, base string) {
+	if len(key) == 2 && key[0] == '^' {
+		return modCtrl, key[1:]
+	}
+	for len(key) > 2 && key[1] == '-' {
+		switch key[0] {
+		case 'C':
+			mods |= modCtrl
+		case 'A', 'M':
+			mods |= modAlt
+		case 'S':
+			mods |= modShift
+		default:
+			return mods, key
+		}
+		key = key[2:]
+	}
+	return mods, key
+}
+
+// xtermSpecial maps special keys to their CSI final byte or ~ number.
+var xtermSpecial = map[string]struct {
+	final  string // final byte of CSI/SS3 forms, or "~"
+	num    int    // parameter for "~" forms
+	ss3    bool   // SS3 form without modifiers, regardless of DECCKM
+	cursor bool   // SS3 form in application cursor mode
+}{
+	"Up":       {final: "A", cursor: true},
+	"Down":     {final: "B", cursor: true},
+	"Right":    {final: "C", cursor: true},
+	"Left":     {final: "D", cursor: true},
+	"Home":     {final: "H", cursor: true},
+	"End":      {final: "F", cursor: true},
+	"Insert":   {final: "~", num: 2},
+	"Delete":   {final: "~", num: 3},
+	"PageUp":   {final: "~", num: 5},
+	"PageDown": {final: "~", num: 6},
+	"F1":       {final: "P", ss3: true},
+	"F2":       {final: "Q", ss3: true},
+	"F3":       {final: "R", ss3: true},
+	"F4":       {final: "S", ss3: true},
+	"F5":       {final: "~", num: 15},
+	"F6":       {final: "~", num: 17},
+	"F7":       {final: "~", num: 18},
+	"F8":       {final: "~", num: 19},
+	"F9":       {final: "~", num: 20},
+	"F10":      {final: "~", num: 21},
+	"F11":      {final: "~", num: 23},
+	"F12":      {final: "~", num: 24},
+}
+
+// encodeXtermKey encodes key the way xterm does by default.
+func encodeXtermKey(key string, modes Modes) (string, bool) {
+	mods, base := parseKey(key)
+
+	if special, ok := xtermSpecial[base]; ok {
+		switch {
+		case special.final == "~" && mods == 0:
+			return fmt.Sprintf("\x1b[%d~", special.num), true
+		case special.final == "~":
+			return fmt.Sprintf("\x1b[%d;%d~", special.num, mods+1), true
+		case mods != 0:
+			return fmt.Sprintf("\x1b[1;%d%s", mods+1, special.final), true
+		case special.ss3 || special.cursor && modes.ApplicationCursorKeys:
+			return "\x1bO" + special.final, true
+		default:
+			return "\x1b[" + special.final, true
+		}
+	}
+
+	var seq string
+	switch base {
+	case KeyEnter, "Return":
+		seq = "\r"
+	case KeyTab:
+		if mods&modShift != 0 {
+			return "\x1b[Z", true
+		}
+		seq = "\t"
+	case KeySpace:
+		seq = " "
+	case KeyEscape, "Esc":
+		seq = "\x1b"
+	case KeyBackspace:
+		seq = "\x7f"
+	default:
+		r, size := utf8.DecodeRuneInString(base)
+		if size == 0 || size != len(base) {
+			return "", false
+		}
+		if mods&modShift != 0 {
+			r = unicode.ToUpper(r)
+		}
+		seq = string(r)
+		if mods&modCtrl != 0 {
+			b, err := ControlChar(r)
+			if err != nil {
+				return "", false
+			}
+			seq = string(rune(b))
+		}
+	}
+	if mods&modAlt != 0 {
+		seq = "\x1b" + seq
+	}
+	return seq, true
+}
+
+// kittyCodes are the kitty protocol key codes of keys that have a
+// distinct CSI u encoding.
+var kittyCodes = map[string]int{
+	KeyEscape:    27,
+	"Esc":        27,
+	KeyEnter:     13,
+	"Return":     13,
+	KeyTab:       9,
+	KeyBackspace: 127,
+	KeySpace:     32,
+}
+
+// encodeKittyKey encodes key with the disambiguating kitty keyboard
+// protocol.
+func encodeKittyKey(key string, modes Modes) (string, bool) {
+	mods, base := parseKey(key)
+
+	code, ok := kittyCodes[base]
+	if !ok {
+		r, size := utf8.DecodeRuneInString(base)
+		if size == 0 || size != len(base) || mods == 0 {
+			return encodeXtermKey(key, modes)
+		}
+		// Keys are reported by their unshifted lowercase code
+		code = int(unicode.ToLower(r))
+	}
+
+	switch {
+	case code == 27 && mods == 0:
+		return "\x1b[27u", true
+	case mods == 0:
+		return encodeXtermKey(key, modes)
+	default:
+		return fmt.Sprintf("\x1b[%d;%du", code, mods+1), true
+	}
+}
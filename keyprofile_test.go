@@ -0,0 +1,106 @@
+package htlib
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestXtermKeys(t *testing.T) {
+	appCursor := Modes{ApplicationCursorKeys: true}
+	tests := []struct {
+		key      string
+		modes    Modes
+		expected string
+	}{
+		{KeyUp, Modes{}, "\x1b[A"},
+		{KeyUp, appCursor, "\x1bOA"},
+		{KeyHome, appCursor, "\x1bOH"},
+		{"C-Left", appCursor, "\x1b[1;5D"},
+		{"C-S-Right", Modes{}, "\x1b[1;6C"},
+		{KeyPageDown, Modes{}, "\x1b[6~"},
+		{"A-Delete", Modes{}, "\x1b[3;3~"},
+		{KeyF1, Modes{}, "\x1bOP"},
+		{"S-F2", Modes{}, "\x1b[1;2Q"},
+		{KeyF12, Modes{}, "\x1b[24~"},
+		{KeyEnter, Modes{}, "\r"},
+		{"S-Tab", Modes{}, "\x1b[Z"},
+		{KeyBackspace, Modes{}, "\x7f"},
+		{"A-Enter", Modes{}, "\x1b\r"},
+		{"C-c", Modes{}, "\x03"},
+		{"^d", Modes{}, "\x04"},
+		{"A-x", Modes{}, "\x1bx"},
+		{"S-a", Modes{}, "A"},
+		{"é", Modes{}, "é"},
+	}
+	for _, tt := range tests {
+		got, ok := XtermKeys().EncodeKey(tt.key, tt.modes)
+		if !ok || got != tt.expected {
+			t.Errorf("EncodeKey(%q, %+v): expected %q, got %q (%v)", tt.key, tt.modes, tt.expected, got, ok)
+		}
+	}
+
+	for _, key := range []string{"Hyper", "C-é", ""} {
+		if got, ok := XtermKeys().EncodeKey(key, Modes{}); ok {
+			t.Errorf("EncodeKey(%q): expected the key to be left to ht, got %q", key, got)
+		}
+	}
+}
+
+func TestKittyKeys(t *testing.T) {
+	tests := []struct {
+		key      string
+		expected string
+	}{
+		{"C-i", "\x1b[105;5u"},
+		{KeyTab, "\t"},
+		{"C-Tab", "\x1b[9;5u"},
+		{KeyEscape, "\x1b[27u"},
+		{"S-Enter", "\x1b[13;2u"},
+		{"C-S-a", "\x1b[97;6u"},
+		{"a", "a"},
+		{KeyUp, "\x1b[A"},
+		{"C-Up", "\x1b[1;5A"},
+	}
+	for _, tt := range tests {
+		got, ok := KittyKeys().EncodeKey(tt.key, Modes{KittyKeyboard: 1})
+		if !ok || got != tt.expected {
+			t.Errorf("EncodeKey(%q): expected %q, got %q (%v)", tt.key, tt.expected, got, ok)
+		}
+	}
+
+	// AutoKeys follows the mode the program enabled
+	if got, _ := AutoKeys().EncodeKey("C-i", Modes{}); got != "\t" {
+		t.Errorf("expected legacy encoding, got %q", got)
+	}
+	if got, _ := AutoKeys().EncodeKey("C-i", Modes{KittyKeyboard: 1}); got != "\x1b[105;5u" {
+		t.Errorf("expected kitty encoding, got %q", got)
+	}
+}
+
+func TestSendKeysWithProfile(t *testing.T) {
+	vt, stdin := newTestTerminal()
+	defer vt.Close()
+	ctx := context.Background()
+
+	// Without a profile keys go to ht by name
+	if err := vt.SendKeys(ctx, KeyUp); err != nil {
+		t.Fatalf("SendKeys failed: %v", err)
+	}
+	if !strings.Contains(stdin.String(), `{"type":"sendKeys","keys":["Up"]}`) {
+		t.Errorf("expected a sendKeys command, got %q", stdin.String())
+	}
+
+	// Once vim enables application cursor keys, Up is sent as SS3
+	vt.SetKeyProfile(AutoKeys())
+	vt.trackEvent(OutputEvent{Seq: "\x1b[?1h"})
+	if err := vt.SendKeys(ctx, KeyUp, KeyUp, "Hyper", KeyEnter); err != nil {
+		t.Fatalf("SendKeys failed: %v", err)
+	}
+	expected := `{"type":"input","payload":"\u001bOA\u001bOA"}` + "\n" +
+		`{"type":"sendKeys","keys":["Hyper"]}` + "\n" +
+		`{"type":"input","payload":"\r"}` + "\n"
+	if !strings.HasSuffix(stdin.String(), expected) {
+		t.Errorf("expected %q, got %q", expected, stdin.String())
+	}
+}
@@ -0,0 +1,86 @@
+package htlib
+
+import (
+	"strings"
+)
+
+// MarkdownOption configures Markdown export.
+type MarkdownOption func(*markdownOptions)
+
+type markdownOptions struct {
+	width    int
+	language string
+}
+
+// WithMaxWidth cuts lines longer than cols columns, so a wide terminal
+// fits the page. Wide characters are never split.
+func WithMaxWidth(cols int) MarkdownOption {
+	return func(o *markdownOptions) {
+		o.width = cols
+	}
+}
+
+// WithLanguage sets the info string of the code block, such as "console"
+// or "text", which selects the syntax highlighting.
+func WithLanguage(language string) MarkdownOption {
+	return func(o *markdownOptions) {
+		o.language = language
+	}
+}
+
+// Markdown returns text as a fenced Markdown code block for READMEs, issue
+// comments and PR descriptions. Line endings are converted to LF, tabs are
+// expanded to 8-column tab stops, trailing spaces and trailing blank lines
+// are removed. The fence is made longer than any run of backticks in the
+// text, so the block cannot be closed early:
+//
+//	fmt.Println(htlib.Markdown(vt.Screen().Text(), htlib.WithLanguage("console")))
+func Markdown(text string, opts ...MarkdownOption) string {
+	var o markdownOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	text = DefaultNormalization().Apply(text)
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	if o.width > 0 {
+		for i, line := range lines {
+			lines[i] = strings.TrimRight(TruncateWidth(line, o.width), " ")
+		}
+	}
+
+	fence := strings.Repeat("`", max(3, longestRun(text, '`')+1))
+	var b strings.Builder
+	b.WriteString(fence + o.language + "\n")
+	for _, line := range lines {
+		b.WriteString(line + "\n")
+	}
+	b.WriteString(fence + "\n")
+	return b.String()
+}
+
+// longestRun returns the length of the longest run of c in s.
+func longestRun(s string, c byte) int {
+	longest, run := 0, 0
+	for i := 0; i < len(s); i++ {
+		if s[i] != c {
+			run = 0
+			continue
+		}
+		run++
+		longest = max(longest, run)
+	}
+	return longest
+}
+
+// Markdown returns the screen text as a fenced Markdown code block. See
+// Markdown.
+func (s *Screen) Markdown(opts ...MarkdownOption) string {
+	return Markdown(s.Text(), opts...)
+}
+
+// Markdown returns the snapshot text as a fenced Markdown code block. See
+// Markdown.
+func (e SnapshotEvent) Markdown(opts ...MarkdownOption) string {
+	return Markdown(e.Text, opts...)
+}
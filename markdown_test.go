@@ -0,0 +1,48 @@
+package htlib
+
+import "testing"
+
+func TestMarkdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		opts     []MarkdownOption
+		expected string
+	}{
+		{
+			name:     "trailing space and blank lines",
+			text:     "$ ls   \r\nfile\t.txt\n\n\n",
+			expected: "```\n$ ls\nfile    .txt\n```\n",
+		},
+		{
+			name:     "language",
+			text:     "$ echo hi\nhi",
+			opts:     []MarkdownOption{WithLanguage("console")},
+			expected: "```console\n$ echo hi\nhi\n```\n",
+		},
+		{
+			name:     "width clamp keeps wide characters whole",
+			text:     "abcdef\n日本語\nab  cd",
+			opts:     []MarkdownOption{WithMaxWidth(3)},
+			expected: "```\nabc\n日\nab\n```\n",
+		},
+		{
+			name:     "fence longer than backticks in the text",
+			text:     "```go\n````",
+			expected: "`````\n```go\n````\n`````\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if md := Markdown(tt.text, tt.opts...); md != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, md)
+			}
+		})
+	}
+
+	m := newScreenModel(10, 3)
+	m.feed("$ ls\r\n")
+	if md := m.snapshot().Markdown(); md != "```\n$ ls\n```\n" {
+		t.Errorf("unexpected screen markdown %q", md)
+	}
+}
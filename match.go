@@ -0,0 +1,192 @@
+package htlib
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// maxMatchOutput bounds the output text kept for matching; older output is
+// discarded once the limit is reached.
+const maxMatchOutput = 64 * 1024
+
+// Matcher decides whether the terminal has reached some state. Match
+// returns nil if there is no match; otherwise the first element is the
+// matched text and any further elements are regular expression submatches.
+type Matcher interface {
+	Match(mc *MatchContext) []string
+}
+
+// MatchContext is the terminal state a Matcher is evaluated against.
+type MatchContext struct {
+	// Event is the event that caused this evaluation, or nil for the
+	// initial check
+	Event Event
+	// Output is the text received since matching started or the last
+	// match, with ANSI sequences removed and CRLF and lone CR line endings
+	// converted to LF. Config.Normalization is applied to its complete
+	// lines; the last line is left as is while it is being written.
+	Output string
+
+	ctx    context.Context
+	vt     *VirtualTerminal
+	screen *SnapshotEvent
+	err    error
+}
+
+// newMatchContext returns the state for one matcher evaluation.
+func (vt *VirtualTerminal) newMatchContext(ctx context.Context, event Event, output string) *MatchContext {
+	return &MatchContext{
+		Event:  event,
+		Output: vt.config.Normalization.applyLines(output),
+		ctx:    ctx,
+		vt:     vt,
+	}
+}
+
+// Screen returns the current screen, taking a snapshot the first time it is
+// called during an evaluation. It returns nil if no snapshot could be taken.
+func (mc *MatchContext) Screen() *SnapshotEvent {
+	if mc.screen == nil && mc.err == nil && mc.vt != nil {
+		mc.screen, mc.err = mc.vt.WaitForSnapshot(mc.ctx)
+	}
+	return mc.screen
+}
+
+// matcher is a Matcher with a description used in error messages.
+type matcher struct {
+	desc string
+	fn   func(mc *MatchContext) []string
+}
+
+func (m matcher) Match(mc *MatchContext) []string { return m.fn(mc) }
+
+func (m matcher) String() string { return m.desc }
+
+// OutputContains matches when text appears in the output.
+func OutputContains(text string) Matcher {
+	return matcher{
+		desc: fmt.Sprintf("output contains %q", text),
+		fn: func(mc *MatchContext) []string {
+			return containsMatch(mc.Output, text)
+		},
+	}
+}
+
+// OutputMatches matches when re matches the output.
+func OutputMatches(re *regexp.Regexp) Matcher {
+	return matcher{
+		desc: fmt.Sprintf("output matches /%s/", re),
+		fn: func(mc *MatchContext) []string {
+			return re.FindStringSubmatch(mc.Output)
+		},
+	}
+}
+
+// ScreenContains matches when text is visible on the screen.
+func ScreenContains(text string) Matcher {
+	return matcher{
+		desc: fmt.Sprintf("screen contains %q", text),
+		fn: func(mc *MatchContext) []string {
+			if screen := mc.Screen(); screen != nil {
+				return containsMatch(screen.Text, text)
+			}
+			return nil
+		},
+	}
+}
+
+// ScreenMatches matches when re matches the screen text.
+func ScreenMatches(re *regexp.Regexp) Matcher {
+	return matcher{
+		desc: fmt.Sprintf("screen matches /%s/", re),
+		fn: func(mc *MatchContext) []string {
+			if screen := mc.Screen(); screen != nil {
+				return re.FindStringSubmatch(screen.Text)
+			}
+			return nil
+		},
+	}
+}
+
+// MatchFunc adapts a predicate to the Matcher interface. The matched text
+// reported for a successful match is empty.
+func MatchFunc(fn func(mc *MatchContext) bool) Matcher {
+	return matcher{
+		desc: "custom matcher",
+		fn: func(mc *MatchContext) []string {
+			if fn(mc) {
+				return []string{""}
+			}
+			return nil
+		},
+	}
+}
+
+// containsMatch returns a match result if text contains substr.
+func containsMatch(text, substr string) []string {
+	if strings.Contains(text, substr) {
+		return []string{substr}
+	}
+	return nil
+}
+
+// describeMatcher returns a human-readable description of m.
+func describeMatcher(m Matcher) string {
+	if s, ok := m.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("%T", m)
+}
+
+// outputBuffer accumulates plain output text for matching.
+type outputBuffer struct {
+	strip OutputProcessor
+	text  string
+	cr    bool // a CR at the end of the last event, pending a possible LF
+}
+
+// newOutputBuffer returns an empty buffer.
+func newOutputBuffer() *outputBuffer {
+	return &outputBuffer{strip: StripANSIOutput()}
+}
+
+// add appends the printable text of an output event.
+func (b *outputBuffer) add(e OutputEvent) {
+	e, ok := b.strip.Process(e)
+	if !ok {
+		return
+	}
+
+	text := e.Seq
+	if b.cr {
+		text = "\r" + text
+	}
+	b.cr = strings.HasSuffix(text, "\r")
+	if b.cr {
+		text = text[:len(text)-1]
+	}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+
+	b.text += text
+	if len(b.text) > maxMatchOutput {
+		b.text = b.text[len(b.text)-maxMatchOutput:]
+	}
+}
+
+// consume discards text up to and including the first occurrence of match,
+// or all text if match is empty or not found.
+func (b *outputBuffer) consume(match string) {
+	if i := strings.Index(b.text, match); match != "" && i >= 0 {
+		b.text = b.text[i+len(match):]
+		return
+	}
+	b.text = ""
+}
+
+// reset discards the accumulated text.
+func (b *outputBuffer) reset() {
+	b.text = ""
+}
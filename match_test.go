@@ -0,0 +1,74 @@
+package htlib
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestOutputMatchers(t *testing.T) {
+	mc := &MatchContext{Output: "login: admin\r\nPassword: "}
+
+	if got := OutputContains("Password: ").Match(mc); len(got) != 1 || got[0] != "Password: " {
+		t.Errorf("expected OutputContains to match, got %v", got)
+	}
+	if got := OutputContains("denied").Match(mc); got != nil {
+		t.Errorf("expected no match, got %v", got)
+	}
+
+	got := OutputMatches(regexp.MustCompile(`login: (\w+)`)).Match(mc)
+	if len(got) != 2 || got[1] != "admin" {
+		t.Errorf("expected submatch admin, got %v", got)
+	}
+}
+
+func TestScreenMatchers(t *testing.T) {
+	mc := &MatchContext{screen: &SnapshotEvent{Text: "Ready\n> "}}
+
+	if got := ScreenContains("Ready").Match(mc); got == nil {
+		t.Error("expected ScreenContains to match")
+	}
+	if got := ScreenMatches(regexp.MustCompile(`^Error`)).Match(mc); got != nil {
+		t.Errorf("expected no match, got %v", got)
+	}
+
+	// Without a terminal no screen is available
+	if got := ScreenContains("Ready").Match(&MatchContext{}); got != nil {
+		t.Errorf("expected no match without a screen, got %v", got)
+	}
+}
+
+func TestMatchFunc(t *testing.T) {
+	m := MatchFunc(func(mc *MatchContext) bool {
+		return len(mc.Output) > 3
+	})
+	if m.Match(&MatchContext{Output: "ab"}) != nil {
+		t.Error("expected no match for short output")
+	}
+	if m.Match(&MatchContext{Output: "abcd"}) == nil {
+		t.Error("expected match for long output")
+	}
+	if describeMatcher(m) != "custom matcher" {
+		t.Errorf("unexpected description %q", describeMatcher(m))
+	}
+}
+
+func TestOutputBuffer(t *testing.T) {
+	buf := newOutputBuffer()
+	buf.add(OutputEvent{Seq: "\x1b[1mPass"})
+	buf.add(OutputEvent{Seq: "word:\x1b[0m \r"})
+	buf.add(OutputEvent{Seq: "\n\tok\rdone"})
+
+	if buf.text != "Password: \n\tok\ndone" {
+		t.Errorf("unexpected output %q", buf.text)
+	}
+
+	buf.consume("ok")
+	if buf.text != "\ndone" {
+		t.Errorf("expected text after match to remain, got %q", buf.text)
+	}
+
+	buf.reset()
+	if buf.text != "" {
+		t.Errorf("expected empty buffer after reset, got %q", buf.text)
+	}
+}
@@ -0,0 +1,285 @@
+package htlib
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// MenuOption is one entry of a menu read from the screen.
+type MenuOption struct {
+	// Label is the entry's text without its key or marker
+	Label string
+	// Key is the shortcut that selects the entry, e.g. "1" or "b", or empty
+	// for menus that are navigated with the arrow keys
+	Key string
+	// Row is the 0-based screen row of the entry
+	Row int
+	// Selected reports whether the entry carries a selection marker such
+	// as ">" or "❯"
+	Selected bool
+}
+
+// Menu is a menu read from the screen by ParseMenu or ReadMenu.
+type Menu struct {
+	// Options are the entries in screen order
+	Options []MenuOption
+}
+
+// Map returns the options keyed by label.
+func (m *Menu) Map() map[string]MenuOption {
+	options := make(map[string]MenuOption, len(m.Options))
+	for _, option := range m.Options {
+		options[option.Label] = option
+	}
+	return options
+}
+
+// Find returns the option with the given label. An exact match is
+// preferred, then a case-insensitive one, then a unique case-insensitive
+// prefix, so "restore" finds "Restore backup".
+func (m *Menu) Find(label string) (MenuOption, bool) {
+	label = strings.TrimSpace(label)
+	for _, option := range m.Options {
+		if option.Label == label {
+			return option, true
+		}
+	}
+	for _, option := range m.Options {
+		if strings.EqualFold(option.Label, label) {
+			return option, true
+		}
+	}
+
+	var found []MenuOption
+	lower := strings.ToLower(label)
+	for _, option := range m.Options {
+		if strings.HasPrefix(strings.ToLower(option.Label), lower) {
+			found = append(found, option)
+		}
+	}
+	if len(found) == 1 {
+		return found[0], true
+	}
+	return MenuOption{}, false
+}
+
+// Selected returns the option carrying the selection marker.
+func (m *Menu) Selected() (MenuOption, bool) {
+	for _, option := range m.Options {
+		if option.Selected {
+			return option, true
+		}
+	}
+	return MenuOption{}, false
+}
+
+// index returns the position of the option on row in m.Options.
+func (m *Menu) index(row int) int {
+	for i, option := range m.Options {
+		if option.Row == row {
+			return i
+		}
+	}
+	return -1
+}
+
+// keyedEntry matches a numbered or lettered menu entry such as "1) Start",
+// "[b] Back" or "> 2. Stop".
+var keyedEntry = regexp.MustCompile(`^\s*(?:([>*❯▶→])\s*)?[\[(]?([0-9]{1,2}|[A-Za-z])[.)\]:]\s+(\S.*?)\s*$`)
+
+// markedEntry matches the selected entry of an arrow-navigated menu such as
+// "❯ Restore backup".
+var markedEntry = regexp.MustCompile(`^(\s*(?:->|[>*❯▶→])\s+)(\S.*?)\s*$`)
+
+// ParseMenu finds the menu in screen text. Numbered and lettered menus
+// ("1) Restore backup", "[q] Quit") are recognized when at least two
+// entries are present; when a key repeats, the later entries win, so a
+// menu printed again further down the screen replaces the earlier copy.
+//
+// Without such entries, ParseMenu looks for an arrow-navigated menu: the
+// last line starting with a selection marker (">", "->", "*", "❯", "▶"
+// or "→"), together with the adjacent lines whose text is aligned with it.
+// Menus that show the selection only through colors are not recognized.
+//
+// The returned menu has no options if none was found.
+func ParseMenu(text string) *Menu {
+	lines := strings.Split(text, "\n")
+	if menu := parseKeyedMenu(lines); len(menu.Options) >= 2 {
+		return menu
+	}
+	return parseArrowMenu(lines)
+}
+
+// parseKeyedMenu collects numbered and lettered entries.
+func parseKeyedMenu(lines []string) *Menu {
+	menu := &Menu{}
+	seen := make(map[string]bool)
+	for row, line := range lines {
+		m := keyedEntry.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		if seen[m[2]] {
+			// The menu was printed again; start over
+			menu.Options = nil
+			clear(seen)
+		}
+		seen[m[2]] = true
+		menu.Options = append(menu.Options, MenuOption{
+			Label:    m[3],
+			Key:      m[2],
+			Row:      row,
+			Selected: m[1] != "",
+		})
+	}
+	return menu
+}
+
+// parseArrowMenu collects the entries aligned with the last marked line.
+func parseArrowMenu(lines []string) *Menu {
+	menu := &Menu{}
+	selected, indent := -1, 0
+	for row, line := range lines {
+		if m := markedEntry.FindStringSubmatch(line); m != nil {
+			selected, indent = row, utf8.RuneCountInString(m[1])
+		}
+	}
+	if selected < 0 {
+		return menu
+	}
+
+	// entry returns the label of an unmarked entry aligned at indent
+	entry := func(line string) (string, bool) {
+		label := strings.TrimRight(line, " ")
+		if utf8.RuneCountInString(label) <= indent || strings.TrimSpace(string([]rune(label)[:indent])) != "" {
+			return "", false
+		}
+		label = string([]rune(label)[indent:])
+		if label[0] == ' ' {
+			return "", false
+		}
+		return label, true
+	}
+
+	first := selected
+	for first > 0 {
+		if _, ok := entry(lines[first-1]); !ok {
+			break
+		}
+		first--
+	}
+	for row := first; row < len(lines); row++ {
+		if row == selected {
+			label := markedEntry.FindStringSubmatch(lines[row])[2]
+			menu.Options = append(menu.Options, MenuOption{Label: label, Row: row, Selected: true})
+			continue
+		}
+		label, ok := entry(lines[row])
+		if !ok {
+			break
+		}
+		menu.Options = append(menu.Options, MenuOption{Label: label, Row: row})
+	}
+	return menu
+}
+
+// ReadMenu takes a snapshot and returns the menu on the screen (see
+// ParseMenu). The menu has no options if none is shown.
+func (vt *VirtualTerminal) ReadMenu(ctx context.Context) (*Menu, error) {
+	snapshot, err := vt.WaitForSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ParseMenu(snapshot.Text), nil
+}
+
+// ChooseOption configures ChooseMenuOption.
+type ChooseOption func(*chooseOptions)
+
+// chooseOptions holds the settings applied by ChooseOptions.
+type chooseOptions struct {
+	shortcutOnly bool
+}
+
+// MenuShortcutOnly makes ChooseMenuOption press only the shortcut key of a
+// numbered or lettered entry, for menus that react to a single key press.
+// By default the key is followed by Enter, as line-based prompts such as
+// bash's select expect.
+func MenuShortcutOnly() ChooseOption {
+	return func(o *chooseOptions) {
+		o.shortcutOnly = true
+	}
+}
+
+// ChooseMenuOption selects the menu entry with the given label (matched as
+// by Menu.Find), waiting until a menu containing it is on the screen:
+//
+//	err := vt.ChooseMenuOption(ctx, "Restore backup")
+//
+// Entries with a shortcut are chosen by typing it. In arrow-navigated
+// menus the selection is moved with Up or Down, ChooseMenuOption waits
+// until the marker reaches the entry, and then presses Enter.
+func (vt *VirtualTerminal) ChooseMenuOption(ctx context.Context, label string, opts ...ChooseOption) error {
+	var options chooseOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	var menu *Menu
+	_, err := vt.WaitFor(ctx, func(snapshot *SnapshotEvent) bool {
+		menu = ParseMenu(snapshot.Text)
+		_, ok := menu.Find(label)
+		return ok
+	})
+	if err != nil {
+		return fmt.Errorf("waiting for menu option %q: %w", label, err)
+	}
+	target, _ := menu.Find(label)
+
+	if target.Key != "" {
+		if options.shortcutOnly {
+			return vt.SendKeys(ctx, target.Key)
+		}
+		return vt.SendKeys(ctx, target.Key, KeyEnter)
+	}
+
+	keys, err := menu.navigate(target)
+	if err != nil {
+		return err
+	}
+	if len(keys) > 0 {
+		if err := vt.SendKeys(ctx, keys...); err != nil {
+			return err
+		}
+		_, err := vt.WaitFor(ctx, func(snapshot *SnapshotEvent) bool {
+			selected, ok := ParseMenu(snapshot.Text).Selected()
+			return ok && selected.Label == target.Label
+		})
+		if err != nil {
+			return fmt.Errorf("moving selection to %q: %w", target.Label, err)
+		}
+	}
+	return vt.SendKeys(ctx, KeyEnter)
+}
+
+// navigate returns the arrow keys that move the selection to target.
+func (m *Menu) navigate(target MenuOption) ([]string, error) {
+	selected, ok := m.Selected()
+	if !ok {
+		return nil, fmt.Errorf("menu has no selected option to navigate from")
+	}
+
+	key := KeyDown
+	steps := m.index(target.Row) - m.index(selected.Row)
+	if steps < 0 {
+		key, steps = KeyUp, -steps
+	}
+	keys := make([]string, steps)
+	for i := range keys {
+		keys[i] = key
+	}
+	return keys, nil
+}
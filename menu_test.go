@@ -0,0 +1,145 @@
+package htlib
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseMenu(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected []MenuOption
+	}{
+		{
+			name: "numbered",
+			text: "Backup tool\n\n1) Create backup   \n2) Restore backup\n3) Quit\n#? ",
+			expected: []MenuOption{
+				{Label: "Create backup", Key: "1", Row: 2},
+				{Label: "Restore backup", Key: "2", Row: 3},
+				{Label: "Quit", Key: "3", Row: 4},
+			},
+		},
+		{
+			name: "lettered with brackets",
+			text: "  [a] Add\n  [d] Delete\n  (q) Quit",
+			expected: []MenuOption{
+				{Label: "Add", Key: "a", Row: 0},
+				{Label: "Delete", Key: "d", Row: 1},
+				{Label: "Quit", Key: "q", Row: 2},
+			},
+		},
+		{
+			name: "marked keyed entry",
+			text: "> 1. Start\n  2. Stop",
+			expected: []MenuOption{
+				{Label: "Start", Key: "1", Row: 0, Selected: true},
+				{Label: "Stop", Key: "2", Row: 1},
+			},
+		},
+		{
+			name: "reprinted menu wins",
+			text: "1) Yes\n2) No\n#? 7\n1) Yes\n2) No\n#? ",
+			expected: []MenuOption{
+				{Label: "Yes", Key: "1", Row: 3},
+				{Label: "No", Key: "2", Row: 4},
+			},
+		},
+		{
+			name: "arrow navigated",
+			text: "? What do you want to do?\n  Create backup\n❯ Restore backup\n  Quit\n\nUse arrows",
+			expected: []MenuOption{
+				{Label: "Create backup", Row: 1},
+				{Label: "Restore backup", Row: 2, Selected: true},
+				{Label: "Quit", Row: 3},
+			},
+		},
+		{
+			name: "indented arrow menu",
+			text: "Pick one:\n    > red\n      green\n     not aligned",
+			expected: []MenuOption{
+				{Label: "red", Row: 1, Selected: true},
+				{Label: "green", Row: 2},
+			},
+		},
+		{
+			name: "single numbered line is not a menu",
+			text: "1. only one\nuser@host:~$ ",
+		},
+		{
+			name: "plain text",
+			text: "total 1.5 GB\nuser@host:~$ ",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			menu := ParseMenu(tt.text)
+			if !reflect.DeepEqual(menu.Options, tt.expected) {
+				t.Errorf("expected %+v, got %+v", tt.expected, menu.Options)
+			}
+		})
+	}
+}
+
+func TestMenuFindAndNavigate(t *testing.T) {
+	menu := ParseMenu("  Create backup\n  Restore backup\n❯ Restore settings\n  Quit")
+
+	if option, ok := menu.Find("quit"); !ok || option.Row != 3 {
+		t.Errorf("expected case-insensitive match, got %+v, %v", option, ok)
+	}
+	if option, ok := menu.Find("Create"); !ok || option.Label != "Create backup" {
+		t.Errorf("expected prefix match, got %+v, %v", option, ok)
+	}
+	if _, ok := menu.Find("Restore"); ok {
+		t.Error("expected ambiguous prefix not to match")
+	}
+	if _, ok := menu.Map()["Restore settings"]; !ok {
+		t.Error("expected map to contain all labels")
+	}
+
+	target, _ := menu.Find("Create backup")
+	keys, err := menu.navigate(target)
+	if err != nil || !reflect.DeepEqual(keys, []string{KeyUp, KeyUp}) {
+		t.Errorf("expected two Up keys, got %v, %v", keys, err)
+	}
+	target, _ = menu.Find("Quit")
+	if keys, _ := menu.navigate(target); !reflect.DeepEqual(keys, []string{KeyDown}) {
+		t.Errorf("expected one Down key, got %v", keys)
+	}
+
+	keyed := ParseMenu("1) a\n2) b")
+	if _, err := keyed.navigate(keyed.Options[1]); err == nil {
+		t.Error("expected error without a selected option")
+	}
+}
+
+func TestChooseMenuOption(t *testing.T) {
+	vt := New(DefaultConfig())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := vt.Start(ctx, WaitReady()); err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+	defer vt.Close()
+
+	script := `select opt in "Create backup" "Restore backup" Quit; do echo "chose <$opt>"; break; done` + "\n"
+	if err := vt.Input(ctx, script); err != nil {
+		t.Fatalf("failed to send input: %v", err)
+	}
+
+	if err := vt.ChooseMenuOption(ctx, "restore backup"); err != nil {
+		t.Fatalf("failed to choose option: %v", err)
+	}
+	snapshot, err := vt.WaitForText(ctx, "chose <Restore backup>")
+	if err != nil {
+		t.Fatalf("option was not chosen: %v", err)
+	}
+	if strings.Contains(snapshot.Text, "chose <Create") {
+		t.Error("wrong option chosen")
+	}
+}
@@ -0,0 +1,53 @@
+package htlib
+
+import (
+	"context"
+	"sync"
+)
+
+// TerminalEvent is an event from one of the terminals passed to
+// MergeEvents.
+type TerminalEvent struct {
+	// Index is the position of the terminal in the MergeEvents call
+	Index    int
+	Terminal *VirtualTerminal
+	Event    Event
+}
+
+// MergeEvents subscribes to several terminals and delivers their events on
+// a single channel, tagged with the terminal they came from, so that they
+// can be orchestrated from one loop:
+//
+//	for e := range htlib.MergeEvents(ctx, build, server) {
+//	    if _, ok := e.Event.(htlib.ExitEvent); ok {
+//	        log.Printf("terminal %d exited", e.Index)
+//	    }
+//	}
+//
+// Events of each terminal arrive in order. Like other subscribers, a
+// terminal drops events when the reader falls more than its
+// Config.EventBuffer behind. The channel is closed once ctx is done or all
+// the terminals are closed.
+func MergeEvents(ctx context.Context, vts ...*VirtualTerminal) <-chan TerminalEvent {
+	out := make(chan TerminalEvent)
+	var wg sync.WaitGroup
+	for i, vt := range vts {
+		sub := vt.SubscribeContext(ctx)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// The subscription is removed once ctx is done, ending the loop
+			for event := range sub {
+				select {
+				case out <- TerminalEvent{Index: i, Terminal: vt, Event: event}:
+				case <-ctx.Done():
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
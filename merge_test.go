@@ -0,0 +1,47 @@
+package htlib
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMergeEvents(t *testing.T) {
+	a, b := New(DefaultConfig()), New(DefaultConfig())
+	merged := MergeEvents(context.Background(), a, b)
+
+	a.dispatch(OutputEvent{Seq: "from a"})
+	b.dispatch(OutputEvent{Seq: "from b"})
+	got := map[int]string{}
+	for range 2 {
+		e := <-merged
+		got[e.Index] = e.Event.(OutputEvent).Seq
+		if e.Terminal != []*VirtualTerminal{a, b}[e.Index] {
+			t.Errorf("event %d tagged with the wrong terminal", e.Index)
+		}
+	}
+	if got[0] != "from a" || got[1] != "from b" {
+		t.Errorf("unexpected events %q", got)
+	}
+
+	// The channel stays open until every terminal is closed
+	a.Close()
+	b.dispatch(ResizeEvent{})
+	if e := <-merged; e.Index != 1 {
+		t.Errorf("expected events of the open terminal, got %+v", e)
+	}
+	b.Close()
+	if _, ok := <-merged; ok {
+		t.Error("expected the channel to be closed")
+	}
+}
+
+func TestMergeEventsContext(t *testing.T) {
+	vt := New(DefaultConfig())
+	defer vt.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	merged := MergeEvents(ctx, vt)
+	vt.dispatch(OutputEvent{Seq: "unread"})
+	cancel()
+	for range merged {
+	}
+}
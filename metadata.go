@@ -0,0 +1,208 @@
+package htlib
+
+import (
+	"bufio"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Metadata describes a session so that artifacts produced from it
+// (recordings, transcripts, exports) are self-describing.
+type Metadata struct {
+	// Title is a human-readable name for the session
+	Title string `json:"title,omitempty"`
+	// Command is the command line running inside the terminal
+	Command string `json:"command,omitempty"`
+	// Env is a summary of the environment. When it is nil, Metadata fills
+	// it with the names of the variables set in Config.Env, with their
+	// values shown only for those listed in EnvValues
+	Env map[string]string `json:"env,omitempty"`
+	// EnvValues lists the Config.Env variables whose values are safe to
+	// include in Env; the others are recorded as <redacted>, so that tokens
+	// passed in the environment do not end up in shared artifacts
+	EnvValues []string `json:"-"`
+	// GitSHA identifies the revision of the code under test
+	GitSHA string `json:"git_sha,omitempty"`
+	// Extra holds additional caller-defined fields
+	Extra map[string]string `json:"extra,omitempty"`
+}
+
+// Metadata returns the session metadata: Config.Metadata with Command and
+// Env filled in from the configuration when they are empty. Env then has
+// every Config.Env variable, with the value <redacted> unless it is listed
+// in EnvValues. The returned value is a copy and may be modified freely.
+func (vt *VirtualTerminal) Metadata() Metadata {
+	m := vt.config.Metadata
+	m.Env = copyStringMap(m.Env)
+	m.Extra = copyStringMap(m.Extra)
+	m.EnvValues = slices.Clone(m.EnvValues)
+
+	if m.Command == "" {
+		parts := []string{shellWord(vt.config.Binary)}
+		for _, arg := range vt.config.Args {
+			parts = append(parts, shellWord(arg))
+		}
+		m.Command = strings.Join(parts, " ")
+	}
+
+	if m.Env == nil && len(vt.config.Env) > 0 {
+		m.Env = make(map[string]string, len(vt.config.Env))
+		for _, kv := range vt.config.Env {
+			key, value, _ := strings.Cut(kv, "=")
+			if !slices.Contains(m.EnvValues, key) {
+				value = redacted
+			}
+			m.Env[key] = value
+		}
+	}
+
+	return m
+}
+
+// metadataField is a name and value of Metadata, as listed by fields.
+type metadataField struct {
+	Name, Value string
+}
+
+// fields lists the fields of m that are set, named as in JSON, with the
+// entries of Env and Extra as env.KEY and extra.KEY in key order, for
+// formats without a structure of their own.
+func (m Metadata) fields() []metadataField {
+	var fields []metadataField
+	for _, f := range []metadataField{
+		{"title", m.Title},
+		{"command", m.Command},
+		{"git_sha", m.GitSHA},
+	} {
+		if f.Value != "" {
+			fields = append(fields, f)
+		}
+	}
+	for _, group := range []struct {
+		prefix string
+		values map[string]string
+	}{{"env.", m.Env}, {"extra.", m.Extra}} {
+		for _, key := range slices.Sorted(maps.Keys(group.values)) {
+			fields = append(fields, metadataField{group.prefix + key, group.values[key]})
+		}
+	}
+	return fields
+}
+
+// shellWord returns s unchanged if it is safe as a shell word, and quoted
+// otherwise.
+func shellWord(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./=:,+@%") == "" {
+		return s
+	}
+	return shellQuote(s)
+}
+
+// copyStringMap returns a copy of m, or nil if m is nil.
+func copyStringMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// GitSHA returns the commit checked out in the git repository containing
+// dir, for use in Metadata.GitSHA. It reads the repository files directly
+// and does not need the git binary.
+func GitSHA(dir string) (string, error) {
+	gitDir, err := findGitDir(dir)
+	if err != nil {
+		return "", err
+	}
+
+	head, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return "", err
+	}
+	ref, isRef := strings.CutPrefix(strings.TrimSpace(string(head)), "ref: ")
+	if !isRef {
+		return ref, nil // detached HEAD
+	}
+
+	// Branches may live in the common directory of a linked worktree
+	dirs := []string{gitDir}
+	if common, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		path := strings.TrimSpace(string(common))
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(gitDir, path)
+		}
+		dirs = append(dirs, path)
+	}
+
+	for _, d := range dirs {
+		if sha, err := os.ReadFile(filepath.Join(d, filepath.FromSlash(ref))); err == nil {
+			return strings.TrimSpace(string(sha)), nil
+		}
+		if sha, ok := packedRef(filepath.Join(d, "packed-refs"), ref); ok {
+			return sha, nil
+		}
+	}
+	return "", fmt.Errorf("git ref %s not found", ref)
+}
+
+// findGitDir returns the .git directory for the repository containing dir.
+func findGitDir(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		path := filepath.Join(dir, ".git")
+		if info, err := os.Stat(path); err == nil {
+			if info.IsDir() {
+				return path, nil
+			}
+			// Worktrees and submodules use a file pointing at the git directory
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return "", err
+			}
+			target, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir: ")
+			if !ok {
+				return "", fmt.Errorf("invalid .git file in %s", dir)
+			}
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(dir, target)
+			}
+			return target, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("not a git repository: %s", dir)
+		}
+		dir = parent
+	}
+}
+
+// packedRef looks up ref in a packed-refs file.
+func packedRef(path, ref string) (string, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		sha, name, ok := strings.Cut(scanner.Text(), " ")
+		if ok && name == ref {
+			return sha, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,96 @@
+package htlib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMetadataDefaults(t *testing.T) {
+	config := DefaultConfig()
+	config.Args = []string{"-c", "echo hello world"}
+	config.Env = []string{"TERM=xterm-256color", "LANG=C.UTF-8", "API_TOKEN=s3cret"}
+	config.Metadata = Metadata{Title: "demo", Extra: map[string]string{"suite": "smoke"}, EnvValues: []string{"TERM", "LANG"}}
+
+	vt := New(config)
+	defer vt.Close()
+
+	m := vt.Metadata()
+	if m.Title != "demo" {
+		t.Errorf("expected title demo, got %q", m.Title)
+	}
+	if m.Command != `/bin/bash -c 'echo hello world'` {
+		t.Errorf("unexpected command %q", m.Command)
+	}
+	if m.Env["TERM"] != "xterm-256color" || m.Env["LANG"] != "C.UTF-8" || m.Env["API_TOKEN"] != "<redacted>" {
+		t.Errorf("unexpected env %v", m.Env)
+	}
+
+	// The result is a copy
+	m.Extra["suite"] = "changed"
+	if vt.Metadata().Extra["suite"] != "smoke" {
+		t.Error("modifying returned metadata changed the terminal's metadata")
+	}
+}
+
+func TestMetadataExplicitFields(t *testing.T) {
+	config := DefaultConfig()
+	config.Env = []string{"SECRET=x"}
+	config.Metadata = Metadata{Command: "my-app", Env: map[string]string{"MODE": "test"}}
+
+	vt := New(config)
+	defer vt.Close()
+
+	m := vt.Metadata()
+	if m.Command != "my-app" {
+		t.Errorf("expected explicit command, got %q", m.Command)
+	}
+	if len(m.Env) != 1 || m.Env["MODE"] != "test" {
+		t.Errorf("expected explicit env only, got %v", m.Env)
+	}
+}
+
+func TestGitSHA(t *testing.T) {
+	const sha = "0123456789abcdef0123456789abcdef01234567"
+	root := t.TempDir()
+	gitDir := filepath.Join(root, ".git")
+	sub := filepath.Join(root, "a", "b")
+	for _, dir := range []string{filepath.Join(gitDir, "refs", "heads"), sub} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(gitDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Loose branch ref, looked up from a subdirectory
+	write("HEAD", "ref: refs/heads/main\n")
+	write(filepath.Join("refs", "heads", "main"), sha+"\n")
+	if got, err := GitSHA(sub); err != nil || got != sha {
+		t.Errorf("loose ref: got %q, %v", got, err)
+	}
+
+	// Packed ref
+	write("HEAD", "ref: refs/heads/release\n")
+	write("packed-refs", "# pack-refs with: peeled\n"+sha+" refs/heads/release\n")
+	if got, err := GitSHA(root); err != nil || got != sha {
+		t.Errorf("packed ref: got %q, %v", got, err)
+	}
+
+	// Detached HEAD
+	write("HEAD", sha+"\n")
+	if got, err := GitSHA(root); err != nil || got != sha {
+		t.Errorf("detached HEAD: got %q, %v", got, err)
+	}
+
+	// Missing ref
+	write("HEAD", "ref: refs/heads/missing\n")
+	if _, err := GitSHA(root); err == nil {
+		t.Error("expected error for missing ref")
+	}
+}
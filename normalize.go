@@ -0,0 +1,101 @@
+package htlib
+
+import "strings"
+
+// Normalization controls how text is cleaned up: the rendered Text of
+// InitEvents, SnapshotEvents and Frames, which ScreenContains and
+// ScreenMatches compare, the output seen by OutputContains and
+// OutputMatches, and the output of transcript entries. Output is normalized
+// a line at a time once the line is complete; a line that is still being
+// written is left as is, so that a prompt like "Password: " keeps its
+// trailing space. The zero value leaves text byte-exact.
+type Normalization struct {
+	// NormalizeNewlines converts CRLF and lone CR line endings to LF
+	NormalizeNewlines bool
+	// TabWidth expands tabs to spaces using tab stops of this width (0 keeps tabs)
+	TabWidth int
+	// TrimTrailingSpace removes trailing spaces and tabs from every line
+	TrimTrailingSpace bool
+}
+
+// DefaultNormalization returns the normalization used by DefaultConfig:
+// LF line endings, 8-column tab stops and trimmed trailing whitespace.
+func DefaultNormalization() Normalization {
+	return Normalization{
+		NormalizeNewlines: true,
+		TabWidth:          8,
+		TrimTrailingSpace: true,
+	}
+}
+
+// IsZero reports whether n leaves text unchanged.
+func (n Normalization) IsZero() bool {
+	return n == Normalization{}
+}
+
+// Apply returns text normalized according to n.
+func (n Normalization) Apply(text string) string {
+	if n.IsZero() {
+		return text
+	}
+
+	if n.NormalizeNewlines {
+		text = strings.ReplaceAll(text, "\r\n", "\n")
+		text = strings.ReplaceAll(text, "\r", "\n")
+	}
+
+	if n.TabWidth <= 0 && !n.TrimTrailingSpace {
+		return text
+	}
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if n.TabWidth > 0 {
+			line = expandTabs(line, n.TabWidth)
+		}
+		if n.TrimTrailingSpace {
+			line = strings.TrimRight(line, " \t")
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// applyLines applies n to the complete lines of text, those followed by a
+// newline, and leaves the last line as is while it is still being written.
+func (n Normalization) applyLines(text string) string {
+	end := strings.LastIndexByte(text, '\n')
+	if n.IsZero() || end < 0 {
+		return text
+	}
+	return n.Apply(text[:end+1]) + text[end+1:]
+}
+
+// expandTabs replaces tabs in a single line with spaces up to the next tab stop.
+func expandTabs(line string, width int) string {
+	if !strings.Contains(line, "\t") {
+		return line
+	}
+
+	var b strings.Builder
+	col := 0
+	for _, g := range Graphemes(line) {
+		if g == "\t" {
+			spaces := width - col%width
+			b.WriteString(strings.Repeat(" ", spaces))
+			col += spaces
+			continue
+		}
+		b.WriteString(g)
+		col += graphemeWidth(g)
+	}
+	return b.String()
+}
+
+// Normalize applies the terminal's configured Normalization to text. It is
+// the same normalization used for the Text field of snapshots and for
+// output matchers, so matchers and logs built on top of the terminal see
+// consistent text.
+func (vt *VirtualTerminal) Normalize(text string) string {
+	return vt.config.Normalization.Apply(text)
+}
@@ -0,0 +1,106 @@
+package htlib
+
+import (
+	"context"
+	"testing"
+)
+
+func TestNormalizationApply(t *testing.T) {
+	tests := []struct {
+		name     string
+		norm     Normalization
+		input    string
+		expected string
+	}{
+		{
+			name:     "zero value is byte-exact",
+			norm:     Normalization{},
+			input:    "a\r\nb\t \n",
+			expected: "a\r\nb\t \n",
+		},
+		{
+			name:     "newlines",
+			norm:     Normalization{NormalizeNewlines: true},
+			input:    "a\r\nb\rc\n",
+			expected: "a\nb\nc\n",
+		},
+		{
+			name:     "tab expansion",
+			norm:     Normalization{TabWidth: 4},
+			input:    "a\tb\n\tc",
+			expected: "a   b\n    c",
+		},
+		{
+			name:     "trim trailing space",
+			norm:     Normalization{TrimTrailingSpace: true},
+			input:    "a  \nb\t\n  c",
+			expected: "a\nb\n  c",
+		},
+		{
+			name:     "default",
+			norm:     DefaultNormalization(),
+			input:    "$ ls   \r\nfoo\tbar  \r\n",
+			expected: "$ ls\nfoo     bar\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := tt.norm.Apply(tt.input)
+			if result != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestParseEventNormalizesText(t *testing.T) {
+	raw := `{"type":"snapshot","data":{"cols":6,"rows":2,"seq":"","text":"$ ls  \nfoo   "}}`
+
+	event, err := New(DefaultConfig()).parseEvent(raw)
+	if err != nil {
+		t.Fatalf("failed to parse event: %v", err)
+	}
+	if text := event.(SnapshotEvent).Text; text != "$ ls\nfoo" {
+		t.Errorf("expected normalized text, got %q", text)
+	}
+
+	event, err = New(Config{}).parseEvent(raw)
+	if err != nil {
+		t.Fatalf("failed to parse event: %v", err)
+	}
+	if text := event.(SnapshotEvent).Text; text != "$ ls  \nfoo   " {
+		t.Errorf("expected byte-exact text, got %q", text)
+	}
+}
+
+func TestNormalizeOutput(t *testing.T) {
+	vt, _ := newTestTerminal()
+	vt.config.Normalization = Normalization{TabWidth: 4, TrimTrailingSpace: true}
+	output := "a\tb  \nPassword: "
+
+	if got := vt.newMatchContext(context.Background(), nil, output).Output; got != "a   b\nPassword: " {
+		t.Errorf("expected complete lines normalized, got %q", got)
+	}
+	if OutputContains("a   b\n").Match(vt.newMatchContext(context.Background(), nil, output)) == nil {
+		t.Error("expected OutputContains to see the normalized line")
+	}
+
+	tr := vt.RecordTranscript()
+	vt.trackEvent(OutputEvent{Seq: output})
+	if got := tr.Transcript().Entries[0].Output; got != "a   b\nPassword: " {
+		t.Errorf("expected the last line as is while recording, got %q", got)
+	}
+	tr.Close()
+	if got := tr.Transcript().Entries[0].Output; got != "a   b\nPassword:" {
+		t.Errorf("expected the whole entry normalized once closed, got %q", got)
+	}
+
+	vt.config.Normalization = Normalization{}
+	tr = vt.RecordTranscript()
+	defer tr.Close()
+	vt.trackEvent(OutputEvent{Seq: "\r\n" + output + "\r\n"})
+	if got := tr.Transcript().Entries[0].Output; got != "\na\tb  \nPassword: " {
+		t.Errorf("expected byte-exact lines, got %q", got)
+	}
+}
@@ -0,0 +1,132 @@
+package htlib
+
+import (
+	"regexp"
+	"strings"
+)
+
+// OutputProcessor transforms OutputEvents before they are delivered to
+// consumers. Process returns the event to deliver, or false to drop it.
+// Processors may keep state between events (for example, partial lines),
+// so an instance must only be used for a single event stream.
+type OutputProcessor interface {
+	Process(e OutputEvent) (OutputEvent, bool)
+}
+
+// OutputProcessorFunc adapts a function to the OutputProcessor interface.
+type OutputProcessorFunc func(e OutputEvent) (OutputEvent, bool)
+
+// Process calls f(e).
+func (f OutputProcessorFunc) Process(e OutputEvent) (OutputEvent, bool) {
+	return f(e)
+}
+
+// ChainOutputProcessors returns a processor that runs processors in order,
+// stopping as soon as one drops the event. It returns nil if no processors
+// are given.
+func ChainOutputProcessors(processors ...OutputProcessor) OutputProcessor {
+	if len(processors) == 0 {
+		return nil
+	}
+	return OutputProcessorFunc(func(e OutputEvent) (OutputEvent, bool) {
+		for _, p := range processors {
+			var ok bool
+			if e, ok = p.Process(e); !ok {
+				return e, false
+			}
+		}
+		return e, true
+	})
+}
+
+// StripANSIOutput returns a processor that removes ANSI escape sequences
+// from output, including sequences split across events.
+func StripANSIOutput() OutputProcessor {
+	var pending string
+	return OutputProcessorFunc(func(e OutputEvent) (OutputEvent, bool) {
+		seq := pending + e.Seq
+		pending = ""
+
+		// Hold back an escape sequence cut off at the end of the event
+		if i := strings.LastIndexByte(seq, 0x1b); i >= 0 {
+			if end := skipEscape(seq, i); end >= len(seq) && !escapeComplete(seq[i:]) {
+				pending = seq[i:]
+				seq = seq[:i]
+			}
+		}
+
+		e.Seq = StripANSI(seq)
+		return e, e.Seq != ""
+	})
+}
+
+// PrefixTimestamps returns a processor that prefixes every output line with
+// the time its first character arrived, formatted with layout (see
+// time.Format) and followed by a space.
+func PrefixTimestamps(layout string) OutputProcessor {
+	atLineStart := true
+	return OutputProcessorFunc(func(e OutputEvent) (OutputEvent, bool) {
+		prefix := e.Time.Format(layout) + " "
+
+		var b strings.Builder
+		for _, r := range e.Seq {
+			if atLineStart {
+				b.WriteString(prefix)
+				atLineStart = false
+			}
+			b.WriteRune(r)
+			if r == '\n' {
+				atLineStart = true
+			}
+		}
+
+		e.Seq = b.String()
+		return e, true
+	})
+}
+
+// FilterLines returns a processor that only passes complete output lines
+// whose text (with ANSI sequences removed) matches re. Partial lines are
+// buffered until their newline arrives.
+func FilterLines(re *regexp.Regexp) OutputProcessor {
+	return lineFilter(func(line string) bool {
+		return re.MatchString(line)
+	})
+}
+
+// ExcludeLines returns a processor that drops complete output lines whose
+// text (with ANSI sequences removed) matches re. Partial lines are buffered
+// until their newline arrives.
+func ExcludeLines(re *regexp.Regexp) OutputProcessor {
+	return lineFilter(func(line string) bool {
+		return !re.MatchString(line)
+	})
+}
+
+// lineFilter buffers output into lines and passes those accepted by keep.
+func lineFilter(keep func(line string) bool) OutputProcessor {
+	var partial string
+	return OutputProcessorFunc(func(e OutputEvent) (OutputEvent, bool) {
+		data := partial + e.Seq
+		end := strings.LastIndexByte(data, '\n')
+		if end < 0 {
+			partial = data
+			return e, false
+		}
+		partial = data[end+1:]
+
+		var b strings.Builder
+		for _, line := range strings.SplitAfter(data[:end+1], "\n") {
+			if line == "" {
+				continue
+			}
+			text := strings.TrimRight(StripANSI(line), "\r\n")
+			if keep(text) {
+				b.WriteString(line)
+			}
+		}
+
+		e.Seq = b.String()
+		return e, e.Seq != ""
+	})
+}
@@ -0,0 +1,95 @@
+package htlib
+
+import (
+	"regexp"
+	"testing"
+	"time"
+)
+
+// runProcessor feeds chunks through p and concatenates the delivered output.
+func runProcessor(p OutputProcessor, chunks ...string) string {
+	var result string
+	for _, chunk := range chunks {
+		if e, ok := p.Process(OutputEvent{Seq: chunk}); ok {
+			result += e.Seq
+		}
+	}
+	return result
+}
+
+func TestStripANSIOutput(t *testing.T) {
+	result := runProcessor(StripANSIOutput(), "\x1b[31mred", "\x1b[", "0m plain\x1b]0;ti", "tle\x07!")
+	if result != "red plain!" {
+		t.Errorf("expected %q, got %q", "red plain!", result)
+	}
+}
+
+func TestPrefixTimestamps(t *testing.T) {
+	p := PrefixTimestamps("15:04:05")
+	at := time.Date(2024, 1, 1, 12, 30, 0, 0, time.UTC)
+
+	var result string
+	for _, chunk := range []string{"one\ntw", "o\n"} {
+		e, _ := p.Process(OutputEvent{Seq: chunk, Time: at})
+		result += e.Seq
+	}
+
+	expected := "12:30:00 one\n12:30:00 two\n"
+	if result != expected {
+		t.Errorf("expected %q, got %q", expected, result)
+	}
+}
+
+func TestFilterLines(t *testing.T) {
+	result := runProcessor(FilterLines(regexp.MustCompile(`^ERROR`)),
+		"INFO start\r\nERR", "OR \x1b[1mboom\x1b[0m\r\n", "INFO done\r\n")
+
+	expected := "ERROR \x1b[1mboom\x1b[0m\r\n"
+	if result != expected {
+		t.Errorf("expected %q, got %q", expected, result)
+	}
+}
+
+func TestExcludeLines(t *testing.T) {
+	result := runProcessor(ExcludeLines(regexp.MustCompile(`^DEBUG`)), "DEBUG x\nkeep\n")
+	if result != "keep\n" {
+		t.Errorf("expected %q, got %q", "keep\n", result)
+	}
+}
+
+func TestChainOutputProcessors(t *testing.T) {
+	if ChainOutputProcessors() != nil {
+		t.Error("expected nil processor for empty chain")
+	}
+
+	p := ChainOutputProcessors(StripANSIOutput(), FilterLines(regexp.MustCompile(`keep`)))
+	result := runProcessor(p, "\x1b[1mkeep\x1b[0m\nskip\n")
+	if result != "keep\n" {
+		t.Errorf("expected %q, got %q", "keep\n", result)
+	}
+}
+
+func TestDispatchOutputProcessors(t *testing.T) {
+	vt := New(Config{OutputProcessors: []OutputProcessor{StripANSIOutput()}})
+
+	raw := vt.subscribeRaw()
+	processed := vt.Subscribe()
+	filtered := vt.Subscribe(WithOutputProcessors(ExcludeLines(regexp.MustCompile(`.`))))
+
+	vt.dispatch(OutputEvent{Seq: "\x1b[1mbold\x1b[0m\n"})
+
+	if e := (<-vt.Events()).(OutputEvent); e.Seq != "bold\n" {
+		t.Errorf("expected processed output on Events(), got %q", e.Seq)
+	}
+	if e := (<-processed).(OutputEvent); e.Seq != "bold\n" {
+		t.Errorf("expected processed output on subscriber, got %q", e.Seq)
+	}
+	if e := (<-raw).(OutputEvent); e.Seq != "\x1b[1mbold\x1b[0m\n" {
+		t.Errorf("expected raw output on raw subscriber, got %q", e.Seq)
+	}
+	select {
+	case e := <-filtered:
+		t.Errorf("expected filtered subscriber to receive nothing, got %v", e)
+	default:
+	}
+}
@@ -0,0 +1,217 @@
+package htlib
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProcessStats is the resource usage of a process running inside the
+// terminal.
+type ProcessStats struct {
+	PID int
+	// Command is the name of the executable
+	Command string
+	// CPUTime is the user and system CPU time used so far
+	CPUTime time.Duration
+	// RSS is the resident memory in bytes
+	RSS int64
+	// OpenFiles is the number of open file descriptors, or -1 where it
+	// cannot be determined
+	OpenFiles int
+	// Children are the processes started by this one, such as the commands
+	// run by a shell
+	Children []ProcessStats
+}
+
+// Total returns the usage of the process and all of its descendants
+// combined, e.g. to check the memory of a program started by the shell.
+// OpenFiles is -1 if it is unknown for any of them.
+func (s ProcessStats) Total() ProcessStats {
+	total := s
+	total.Children = nil
+	for _, child := range s.Children {
+		c := child.Total()
+		total.CPUTime += c.CPUTime
+		total.RSS += c.RSS
+		if total.OpenFiles < 0 || c.OpenFiles < 0 {
+			total.OpenFiles = -1
+		} else {
+			total.OpenFiles += c.OpenFiles
+		}
+	}
+	return total
+}
+
+// ProcessStats reports the CPU time, memory and open files of the program
+// running in the terminal and of its child processes, for load tests that
+// check a program does not grow while it is driven:
+//
+//	stats, err := vt.ProcessStats()
+//	if total := stats.Total(); total.RSS > 200<<20 {
+//	    t.Errorf("using %d MiB", total.RSS>>20)
+//	}
+//
+// Figures are read from /proc on Linux and from ps(1) elsewhere, where the
+// number of open files is not available. ProcessStats returns an error
+// matching ErrNotStarted until the InitEvent has been received, and
+// ErrUnsupported for Config.SerialPort.
+func (vt *VirtualTerminal) ProcessStats() (*ProcessStats, error) {
+	pid, err := vt.livePID()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat("/proc/self/stat"); err == nil {
+		parents, err := procParents()
+		if err != nil {
+			return nil, err
+		}
+		stats, err := readProcStats(pid, parents)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read stats of process %d: %w", pid, err)
+		}
+		return stats, nil
+	}
+
+	out, err := exec.Command("ps", "-A", "-o", "pid=,ppid=,rss=,time=,comm=").Output()
+	if err != nil {
+		return nil, fmt.Errorf("%w: neither /proc nor ps available: %v", ErrUnsupported, err)
+	}
+	return psStats(pid, string(out))
+}
+
+// readProcStats reads the stats of pid and, recursively, its children from
+// /proc.
+func readProcStats(pid int, parents map[int]int) (*ProcessStats, error) {
+	dir := filepath.Join("/proc", strconv.Itoa(pid))
+	stat, err := os.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		return nil, err
+	}
+	stats, ok := parseStatUsage(string(stat))
+	if !ok {
+		return nil, fmt.Errorf("unexpected format of %s/stat", dir)
+	}
+	stats.PID = pid
+
+	stats.OpenFiles = -1
+	if fds, err := os.ReadDir(filepath.Join(dir, "fd")); err == nil {
+		stats.OpenFiles = len(fds)
+	}
+
+	for _, child := range childrenOf(pid, parents) {
+		childStats, err := readProcStats(child, parents)
+		if err != nil {
+			// The child may have exited while we were walking the tree
+			continue
+		}
+		stats.Children = append(stats.Children, *childStats)
+	}
+	return stats, nil
+}
+
+// parseStatUsage extracts the command name, CPU time and resident memory
+// from a /proc/<pid>/stat line.
+func parseStatUsage(stat string) (*ProcessStats, bool) {
+	start := strings.IndexByte(stat, '(')
+	end := strings.LastIndexByte(stat, ')')
+	if start < 0 || end < start {
+		return nil, false
+	}
+	// Fields after the command name start at field 3 (state); utime is 14,
+	// stime 15 and rss, in pages, 24
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 22 {
+		return nil, false
+	}
+	utime, errU := strconv.ParseInt(fields[11], 10, 64)
+	stime, errS := strconv.ParseInt(fields[12], 10, 64)
+	rss, errR := strconv.ParseInt(fields[21], 10, 64)
+	if errU != nil || errS != nil || errR != nil {
+		return nil, false
+	}
+	return &ProcessStats{
+		Command: stat[start+1 : end],
+		CPUTime: time.Duration(utime+stime) * time.Second / clockTicks,
+		RSS:     rss * int64(os.Getpagesize()),
+	}, true
+}
+
+// psStats builds the stats of pid and its descendants from the output of
+// ps -o pid=,ppid=,rss=,time=,comm=.
+func psStats(pid int, out string) (*ProcessStats, error) {
+	parents := make(map[int]int)
+	procs := make(map[int]ProcessStats)
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 {
+			continue
+		}
+		p, errP := strconv.Atoi(fields[0])
+		ppid, errPP := strconv.Atoi(fields[1])
+		rss, errR := strconv.ParseInt(fields[2], 10, 64)
+		cpu, ok := parsePSTime(fields[3])
+		if errP != nil || errPP != nil || errR != nil || !ok {
+			continue
+		}
+		parents[p] = ppid
+		procs[p] = ProcessStats{
+			PID:       p,
+			Command:   filepath.Base(strings.Join(fields[4:], " ")),
+			CPUTime:   cpu,
+			RSS:       rss * 1024,
+			OpenFiles: -1,
+		}
+	}
+
+	var build func(pid int) ProcessStats
+	build = func(pid int) ProcessStats {
+		stats := procs[pid]
+		for _, child := range childrenOf(pid, parents) {
+			stats.Children = append(stats.Children, build(child))
+		}
+		return stats
+	}
+	if _, ok := procs[pid]; !ok {
+		return nil, fmt.Errorf("process %d not found", pid)
+	}
+	stats := build(pid)
+	return &stats, nil
+}
+
+// parsePSTime parses a CPU time printed by ps: [[dd-]hh:]mm:ss[.cc].
+func parsePSTime(s string) (time.Duration, bool) {
+	var days int64
+	if d, rest, found := strings.Cut(s, "-"); found {
+		n, err := strconv.ParseInt(d, 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		days, s = n, rest
+	}
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, false
+	}
+	seconds, err := strconv.ParseFloat(parts[len(parts)-1], 64)
+	if err != nil {
+		return 0, false
+	}
+	total := time.Duration(seconds * float64(time.Second))
+	for i, unit := range []time.Duration{time.Minute, time.Hour} {
+		if i >= len(parts)-1 {
+			break
+		}
+		n, err := strconv.ParseInt(parts[len(parts)-2-i], 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		total += time.Duration(n) * unit
+	}
+	return total + time.Duration(days)*24*time.Hour, true
+}
@@ -0,0 +1,99 @@
+package htlib
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestProcessStats(t *testing.T) {
+	if _, err := os.Stat("/proc/self/stat"); err != nil {
+		t.Skip("/proc not available")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	vt := New(DefaultConfig())
+	defer vt.Close()
+	if err := vt.Start(ctx, WaitReady()); err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+	if err := vt.Input(ctx, "sleep 30 < /dev/null\r"); err != nil {
+		t.Fatal(err)
+	}
+
+	var stats *ProcessStats
+	waitUntil(t, func() bool {
+		var err error
+		stats, err = vt.ProcessStats()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(stats.Children) == 1 && stats.Children[0].Command == "sleep"
+	})
+	if stats.PID != vt.PID() || stats.Command != "bash" {
+		t.Errorf("unexpected process %d %q", stats.PID, stats.Command)
+	}
+	if stats.RSS <= 0 || stats.OpenFiles < 3 {
+		t.Errorf("expected memory and at least stdin, stdout and stderr, got %+v", stats)
+	}
+
+	total := stats.Total()
+	if total.RSS != stats.RSS+stats.Children[0].RSS || total.Children != nil {
+		t.Errorf("unexpected total %+v", total)
+	}
+	if total.OpenFiles != stats.OpenFiles+stats.Children[0].OpenFiles {
+		t.Errorf("unexpected total of open files %d", total.OpenFiles)
+	}
+}
+
+func TestProcessStatsNotStarted(t *testing.T) {
+	vt := New(DefaultConfig())
+	if _, err := vt.ProcessStats(); !errors.Is(err, ErrNotStarted) {
+		t.Errorf("expected ErrNotStarted, got %v", err)
+	}
+}
+
+func TestParseStatUsage(t *testing.T) {
+	stat := "42 (my (odd) cmd) S 1 42 42 0 -1 4194560 100 0 0 0 250 50 0 0 20 0 1 0 100 1000000 300 18446744073709551615"
+	stats, ok := parseStatUsage(stat)
+	if !ok {
+		t.Fatal("failed to parse")
+	}
+	if stats.Command != "my (odd) cmd" || stats.CPUTime != 3*time.Second || stats.RSS != 300*int64(os.Getpagesize()) {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if _, ok := parseStatUsage("42 (cmd) S 1"); ok {
+		t.Error("expected short lines to be rejected")
+	}
+}
+
+func TestPSStats(t *testing.T) {
+	out := `    1     0  1024   0:01.50 /sbin/launchd
+  100     1  2048   1:02.25 -bash
+  200   100  4096 01:00:00 /usr/local/bin/my app
+  300     1   512   0:00.00 other
+`
+	stats, err := psStats(100, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Command != "-bash" || stats.RSS != 2048*1024 || stats.CPUTime != 62250*time.Millisecond || stats.OpenFiles != -1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if len(stats.Children) != 1 || stats.Children[0].Command != "my app" || stats.Children[0].CPUTime != time.Hour {
+		t.Errorf("unexpected children %+v", stats.Children)
+	}
+	if total := stats.Total(); total.RSS != 6144*1024 || total.OpenFiles != -1 {
+		t.Errorf("unexpected total %+v", total)
+	}
+
+	if _, err := psStats(999, out); err == nil {
+		t.Error("expected an error for a missing process")
+	}
+	if d, ok := parsePSTime("2-03:04:05"); !ok || d != 51*time.Hour+4*time.Minute+5*time.Second {
+		t.Errorf("unexpected duration %v %v", d, ok)
+	}
+}
@@ -0,0 +1,220 @@
+package htlib
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/io41/htlib.go/htproto"
+)
+
+// maxPromptLine bounds the current output line kept for prompt detection.
+const maxPromptLine = 1024
+
+// WaitForPrompt waits until the shell is showing a prompt and ready for the
+// next command:
+//
+//	vt.Input(ctx, "make test\n")
+//	err := vt.WaitForPrompt(ctx)
+//
+// Submitting a line with Input, SendKeys or Batch marks the shell as busy
+// before the command is sent, so WaitForPrompt called right after Input
+// waits for the command to finish rather than matching the previous prompt.
+//
+// Shells that emit OSC 133 semantic prompt markers (enabled by most shell
+// integrations for iTerm2, VS Code, WezTerm and others) are tracked through
+// those markers. Otherwise the current line of output is matched against
+// Config.PromptPatterns, which default to common bash, zsh and fish prompts.
+func (vt *VirtualTerminal) WaitForPrompt(ctx context.Context) error {
+	for {
+		ready, changed := vt.prompt.state()
+		if ready {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-vt.current().ctx.Done():
+			return vt.closedErr()
+		}
+	}
+}
+
+// AtPrompt reports whether the shell is currently showing a prompt, as
+// described for WaitForPrompt.
+func (vt *VirtualTerminal) AtPrompt() bool {
+	ready, _ := vt.prompt.state()
+	return ready
+}
+
+// promptPatterns returns the configured prompt patterns or the default.
+func (vt *VirtualTerminal) promptPatterns() []*regexp.Regexp {
+	if len(vt.config.PromptPatterns) > 0 {
+		return vt.config.PromptPatterns
+	}
+	return []*regexp.Regexp{defaultPromptPattern}
+}
+
+// submitsLine reports whether cmd ends a line of input, which makes the
+// shell start running a command.
+func submitsLine(cmd command) bool {
+	switch cmd.Type {
+	case htproto.CommandInput:
+		return strings.ContainsAny(cmd.Payload, "\r\n")
+	case htproto.CommandSendKeys:
+		for _, key := range cmd.Keys {
+			switch key {
+			case KeyEnter, "Return", "C-m", "C-j", "^M", "^J":
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// promptTracker follows whether the shell is at a prompt by watching
+// terminal output for prompts and OSC 133 markers.
+type promptTracker struct {
+	mu      sync.Mutex
+	ready   bool
+	changed chan struct{} // closed and replaced whenever ready changes
+
+	osc133  bool   // the shell has emitted OSC 133 markers
+	exit    int    // exit code of the last command from OSC 133;D
+	exitSet bool   // exit is known for the last command
+	pending string // escape sequence split across output events
+	line    []byte // plain text of the current output line
+}
+
+// state returns whether a prompt is showing and a channel that is closed
+// when that changes.
+func (p *promptTracker) state() (bool, <-chan struct{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.changed == nil {
+		p.changed = make(chan struct{})
+	}
+	return p.ready, p.changed
+}
+
+// setReady updates the ready state and wakes waiters. The caller must hold p.mu.
+func (p *promptTracker) setReady(ready bool) {
+	if p.ready == ready {
+		return
+	}
+	p.ready = ready
+	if p.changed != nil {
+		close(p.changed)
+	}
+	p.changed = make(chan struct{})
+}
+
+// reset forgets the state of a shell that has been replaced.
+func (p *promptTracker) reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.osc133, p.exit, p.exitSet = false, 0, false
+	p.pending, p.line = "", p.line[:0]
+	p.setReady(false)
+}
+
+// busy records that a command line has been submitted.
+func (p *promptTracker) busy() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.line = p.line[:0]
+	p.exitSet = false
+	p.setReady(false)
+}
+
+// write processes a chunk of terminal output.
+func (p *promptTracker) write(seq string, patterns []*regexp.Regexp) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	seq = p.pending + seq
+	p.pending = ""
+
+	for i := 0; i < len(seq); {
+		if seq[i] == 0x1b {
+			end := skipEscape(seq, i)
+			if end >= len(seq) && !escapeComplete(seq[i:]) {
+				p.pending = seq[i:]
+				break
+			}
+			p.escape(seq[i:end])
+			i = end
+			continue
+		}
+
+		switch c := seq[i]; c {
+		case '\n', '\r':
+			p.line = p.line[:0]
+		default:
+			if c >= 0x20 || c == '\t' {
+				p.line = append(p.line, c)
+			}
+		}
+		i++
+	}
+
+	if excess := len(p.line) - maxPromptLine; excess > 0 {
+		p.line = append(p.line[:0], p.line[excess:]...)
+	}
+
+	// Shells with OSC 133 support report prompts explicitly
+	if p.osc133 {
+		return
+	}
+	for _, re := range patterns {
+		if re.Match(p.line) {
+			p.setReady(true)
+			return
+		}
+	}
+	p.setReady(false)
+}
+
+// escape handles OSC 133 semantic prompt markers: A (prompt start) and B
+// (prompt end) mean the shell is waiting for input, C (command output
+// start) means a command is running, and D;code reports its exit code.
+func (p *promptTracker) escape(esc string) {
+	body, ok := strings.CutPrefix(esc, "\x1b]133;")
+	if !ok || body == "" {
+		return
+	}
+	body = strings.TrimSuffix(strings.TrimSuffix(body, "\x07"), "\x1b\\")
+
+	p.osc133 = true
+	switch body[0] {
+	case 'A', 'B':
+		p.setReady(true)
+	case 'C':
+		p.exitSet = false
+		p.setReady(false)
+	case 'D':
+		code, _, _ := strings.Cut(strings.TrimPrefix(body[1:], ";"), ";")
+		if n, err := strconv.Atoi(code); err == nil {
+			p.exit, p.exitSet = n, true
+		}
+	}
+}
+
+// semantic reports whether the shell emits OSC 133 markers.
+func (p *promptTracker) semantic() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.osc133
+}
+
+// lastExit returns the exit code of the last command, if the shell
+// reported it with OSC 133;D.
+func (p *promptTracker) lastExit() (int, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.exit, p.exitSet
+}
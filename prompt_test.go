@@ -0,0 +1,130 @@
+package htlib
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestPromptTrackerPatterns(t *testing.T) {
+	var p promptTracker
+	patterns := []*regexp.Regexp{defaultPromptPattern}
+
+	steps := []struct {
+		output string
+		ready  bool
+	}{
+		{"Welcome\r\n", false},
+		{"\x1b[32muser@host\x1b[0m:~", false},
+		{"$ ", true},
+		{"l", false},
+		{"s\r\nfile.txt\r\n", false},
+		{"❯ ", true},
+	}
+	for _, step := range steps {
+		p.write(step.output, patterns)
+		if ready, _ := p.state(); ready != step.ready {
+			t.Errorf("after %q: expected ready=%v", step.output, step.ready)
+		}
+	}
+
+	// Submitting a line marks the shell busy until the next prompt
+	p.busy()
+	if ready, _ := p.state(); ready {
+		t.Error("expected busy after submitting a line")
+	}
+}
+
+func TestPromptTrackerOSC133(t *testing.T) {
+	var p promptTracker
+	patterns := []*regexp.Regexp{defaultPromptPattern}
+
+	// The marker is split across events
+	p.write("\x1b]13", patterns)
+	p.write("3;A\x07prompt> \x1b]133;B\x07", patterns)
+	ready, changed := p.state()
+	if !ready {
+		t.Fatal("expected ready after OSC 133;B")
+	}
+
+	p.write("\x1b]133;C\x07", patterns)
+	select {
+	case <-changed:
+	default:
+		t.Error("expected change notification")
+	}
+
+	// Once markers are seen, prompt-like output no longer counts
+	p.write("progress 50% ", patterns)
+	if ready, _ := p.state(); ready {
+		t.Error("expected busy while a command runs")
+	}
+
+	p.write("\x1b]133;D;0\x1b\\\x1b]133;A\x1b\\", patterns)
+	if ready, _ := p.state(); !ready {
+		t.Error("expected ready after OSC 133;A")
+	}
+}
+
+func TestSubmitsLine(t *testing.T) {
+	tests := []struct {
+		cmd      Command
+		expected bool
+	}{
+		{InputCommand("ls\n"), true},
+		{InputCommand("ls"), false},
+		{KeysCommand("ls", KeyEnter), true},
+		{KeysCommand(Ctrl('m')), true},
+		{KeysCommand("ls", KeyTab), false},
+		{ResizeCommand(80, 24), false},
+	}
+	for _, tt := range tests {
+		if got := submitsLine(tt.cmd.cmd); got != tt.expected {
+			t.Errorf("%+v: expected %v, got %v", tt.cmd.cmd, tt.expected, got)
+		}
+	}
+}
+
+func TestWaitForPrompt(t *testing.T) {
+	vt := New(DefaultConfig())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := vt.Start(ctx, WaitReady()); err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+	defer vt.Close()
+
+	if !vt.AtPrompt() {
+		t.Error("expected shell to be at a prompt after WaitReady")
+	}
+
+	start := time.Now()
+	if err := vt.Input(ctx, "sleep 0.3\n"); err != nil {
+		t.Fatalf("failed to send input: %v", err)
+	}
+	if err := vt.WaitForPrompt(ctx); err != nil {
+		t.Fatalf("failed waiting for prompt: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Errorf("returned after %v, before the command finished", elapsed)
+	}
+}
+
+func TestWaitForPromptCustomPattern(t *testing.T) {
+	config := DefaultConfig()
+	config.PromptPatterns = []*regexp.Regexp{regexp.MustCompile(`READY> $`)}
+	vt := New(config)
+	vt.started = true
+	defer vt.Close()
+
+	vt.trackEvent(OutputEvent{Seq: "$ "})
+	if vt.AtPrompt() {
+		t.Error("default prompt should not match a custom pattern")
+	}
+	vt.trackEvent(OutputEvent{Seq: "\r\nREADY> "})
+	if !vt.AtPrompt() {
+		t.Error("expected custom prompt to match")
+	}
+}
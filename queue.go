@@ -0,0 +1,371 @@
+package htlib
+
+import (
+	"container/heap"
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/io41/htlib.go/htproto"
+)
+
+// Action is a unit of work performed against a terminal, such as sending
+// input or waiting for a condition.
+type Action func(ctx context.Context, vt *VirtualTerminal) error
+
+// InputAction returns an Action that sends raw input.
+func InputAction(text string) Action {
+	return func(ctx context.Context, vt *VirtualTerminal) error {
+		return vt.Input(ctx, text)
+	}
+}
+
+// InputSecret returns an Action that types secret followed by a newline, as
+// when answering a password prompt. The secret is sent like Input, but
+// transcripts and Summary record it as <redacted>, and errors returned by
+// the action never include it.
+func InputSecret(secret string) Action {
+	return func(ctx context.Context, vt *VirtualTerminal) error {
+		cmd := htproto.Input(secret + "\n")
+		cmd.Secret = true
+		return vt.sendCommand(ctx, cmd)
+	}
+}
+
+// redacted is recorded in place of secret input.
+const redacted = "<redacted>"
+
+// redactedPayload returns what is recorded for the payload of a secret
+// input: the placeholder, followed by the line ending that submitted it.
+func redactedPayload(payload string) string {
+	return redacted + payload[len(strings.TrimRight(payload, "\r\n")):]
+}
+
+// recordedPayload returns the payload of an input command as transcripts
+// and Summary record it: redacted if it is secret, and what the user asked
+// for if it was wrapped, as by RunCommand.
+func recordedPayload(cmd command) string {
+	switch {
+	case cmd.Secret:
+		return redactedPayload(cmd.Payload)
+	case cmd.Display != "":
+		return cmd.Display
+	}
+	return cmd.Payload
+}
+
+// KeysAction returns an Action that sends named keys.
+func KeysAction(keys ...string) Action {
+	return func(ctx context.Context, vt *VirtualTerminal) error {
+		return vt.SendKeys(ctx, keys...)
+	}
+}
+
+// SleepAction returns an Action that pauses for d, or until ctx is done.
+func SleepAction(d time.Duration) Action {
+	return func(ctx context.Context, vt *VirtualTerminal) error {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Sequence returns an Action that runs actions in order, stopping at the
+// first error.
+func Sequence(actions ...Action) Action {
+	return func(ctx context.Context, vt *VirtualTerminal) error {
+		for _, action := range actions {
+			if err := action(ctx, vt); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// Job is a handle to an Action submitted with Enqueue.
+type Job struct {
+	action   Action
+	priority int
+	seq      uint64
+	index    int // position in the queue heap, -1 once dequeued
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	stop   func() bool
+	done   chan struct{}
+	err    error
+	queue  *workQueue
+}
+
+// EnqueueOption configures a Job submitted with Enqueue.
+type EnqueueOption func(*Job)
+
+// WithPriority sets the priority of a queued job. Jobs with higher priority
+// run first; jobs with equal priority run in submission order. The default
+// priority is 0.
+func WithPriority(priority int) EnqueueOption {
+	return func(j *Job) {
+		j.priority = priority
+	}
+}
+
+// Done returns a channel that is closed when the job has finished, failed
+// or been cancelled.
+func (j *Job) Done() <-chan struct{} {
+	return j.done
+}
+
+// Err returns the job's result once Done is closed, and nil before that.
+func (j *Job) Err() error {
+	select {
+	case <-j.done:
+		return j.err
+	default:
+		return nil
+	}
+}
+
+// Wait blocks until the job has finished and returns its result.
+func (j *Job) Wait(ctx context.Context) error {
+	select {
+	case <-j.done:
+		return j.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Cancel removes the job from the queue if it has not started, or cancels
+// its context if it is running. It reports whether the job was still
+// pending.
+func (j *Job) Cancel() bool {
+	q := j.queue
+	q.mu.Lock()
+	pending := j.index >= 0
+	if pending {
+		heap.Remove(&q.jobs, j.index)
+	}
+	q.mu.Unlock()
+
+	if pending {
+		j.finish(context.Canceled)
+	} else {
+		j.cancel()
+	}
+	return pending
+}
+
+// finish records the job's result and releases waiters.
+func (j *Job) finish(err error) {
+	j.err = err
+	j.stop()
+	j.cancel()
+	close(j.done)
+}
+
+// Enqueue submits an action to the terminal's work queue. Queued actions run
+// one at a time in priority order, so several goroutines can share a
+// terminal without interleaving their keystrokes. The action runs with a
+// context derived from ctx that is cancelled by Job.Cancel or Close.
+func (vt *VirtualTerminal) Enqueue(ctx context.Context, action Action, opts ...EnqueueOption) *Job {
+	job := &Job{
+		action: action,
+		index:  -1,
+		done:   make(chan struct{}),
+		queue:  &vt.queue,
+	}
+	for _, opt := range opts {
+		opt(job)
+	}
+	s := vt.current()
+	job.ctx, job.cancel = context.WithCancel(ctx)
+	job.stop = context.AfterFunc(s.ctx, job.cancel)
+
+	if s.ctx.Err() != nil || !vt.queue.push(job) {
+		job.finish(ErrClosed)
+		return job
+	}
+
+	if stopped, ok := vt.queue.startConsumer(); ok {
+		go vt.runQueue(s.ctx, stopped)
+	}
+	return job
+}
+
+// Do enqueues an action and waits for it to complete.
+func (vt *VirtualTerminal) Do(ctx context.Context, action Action, opts ...EnqueueOption) error {
+	return vt.Enqueue(ctx, action, opts...).Wait(ctx)
+}
+
+// CancelPending removes all jobs that have not started yet from the work
+// queue and returns how many were cancelled.
+func (vt *VirtualTerminal) CancelPending() int {
+	jobs := vt.queue.drain()
+	for _, job := range jobs {
+		job.finish(context.Canceled)
+	}
+	return len(jobs)
+}
+
+// runQueue executes queued jobs until ctx, the context of the session it
+// was started in, is done. It closes stopped when it returns.
+func (vt *VirtualTerminal) runQueue(ctx context.Context, stopped chan<- struct{}) {
+	defer close(stopped)
+	for {
+		job := vt.queue.pop()
+		if job == nil {
+			select {
+			case <-vt.queue.wake:
+				continue
+			case <-ctx.Done():
+				vt.queue.close()
+				for _, job := range vt.queue.drain() {
+					job.finish(ErrClosed)
+				}
+				return
+			}
+		}
+
+		if ctx.Err() != nil {
+			job.finish(ErrClosed)
+			continue
+		}
+		if err := job.ctx.Err(); err != nil {
+			job.finish(err)
+			continue
+		}
+		job.finish(job.action(job.ctx, vt))
+	}
+}
+
+// workQueue is a priority queue of jobs with a single consumer.
+type workQueue struct {
+	mu     sync.Mutex
+	jobs   jobHeap
+	seq    uint64
+	wake   chan struct{}
+	closed bool
+	// consumer is closed when the goroutine running the jobs stops, and
+	// nil before it has started
+	consumer chan struct{}
+}
+
+// startConsumer reports whether the caller should start the goroutine
+// running the jobs, and returns the channel it closes when it stops.
+func (q *workQueue) startConsumer() (chan struct{}, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.consumer != nil {
+		return nil, false
+	}
+	q.consumer = make(chan struct{})
+	return q.consumer, true
+}
+
+// reopen waits for the consumer of a closed queue to stop and lets the
+// queue accept jobs again.
+func (q *workQueue) reopen() {
+	q.mu.Lock()
+	consumer := q.consumer
+	q.mu.Unlock()
+	if consumer != nil {
+		<-consumer
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = false
+	q.consumer = nil
+}
+
+// push adds a job and wakes the consumer. It reports false if the queue
+// has been closed.
+func (q *workQueue) push(job *Job) bool {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return false
+	}
+	if q.wake == nil {
+		q.wake = make(chan struct{}, 1)
+	}
+	q.seq++
+	job.seq = q.seq
+	heap.Push(&q.jobs, job)
+	q.mu.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// close stops the queue from accepting new jobs.
+func (q *workQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+}
+
+// pop removes the highest-priority job, or returns nil if the queue is empty.
+func (q *workQueue) pop() *Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.jobs) == 0 {
+		return nil
+	}
+	return heap.Pop(&q.jobs).(*Job)
+}
+
+// drain removes and returns all pending jobs.
+func (q *workQueue) drain() []*Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	jobs := make([]*Job, 0, len(q.jobs))
+	for len(q.jobs) > 0 {
+		jobs = append(jobs, heap.Pop(&q.jobs).(*Job))
+	}
+	return jobs
+}
+
+// jobHeap orders jobs by descending priority, then by submission order.
+type jobHeap []*Job
+
+func (h jobHeap) Len() int { return len(h) }
+
+func (h jobHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h jobHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *jobHeap) Push(x interface{}) {
+	job := x.(*Job)
+	job.index = len(*h)
+	*h = append(*h, job)
+}
+
+func (h *jobHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	job := old[n-1]
+	old[n-1] = nil
+	job.index = -1
+	*h = old[:n-1]
+	return job
+}
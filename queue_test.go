@@ -0,0 +1,124 @@
+package htlib
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEnqueuePriorityOrder(t *testing.T) {
+	vt := New(DefaultConfig())
+	defer vt.Close()
+	ctx := context.Background()
+
+	// Block the worker so the remaining jobs queue up
+	release := make(chan struct{})
+	blocker := vt.Enqueue(ctx, func(ctx context.Context, vt *VirtualTerminal) error {
+		<-release
+		return nil
+	})
+
+	var mu sync.Mutex
+	var order []string
+	record := func(name string) Action {
+		return func(ctx context.Context, vt *VirtualTerminal) error {
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+			return nil
+		}
+	}
+
+	jobs := []*Job{
+		vt.Enqueue(ctx, record("low-1")),
+		vt.Enqueue(ctx, record("high"), WithPriority(10)),
+		vt.Enqueue(ctx, record("low-2")),
+		vt.Enqueue(ctx, record("urgent"), WithPriority(20)),
+	}
+
+	close(release)
+	if err := blocker.Wait(ctx); err != nil {
+		t.Fatalf("blocker failed: %v", err)
+	}
+	for _, job := range jobs {
+		if err := job.Wait(ctx); err != nil {
+			t.Fatalf("job failed: %v", err)
+		}
+	}
+
+	expected := []string{"urgent", "high", "low-1", "low-2"}
+	if len(order) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, order)
+	}
+	for i := range expected {
+		if order[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, order)
+		}
+	}
+}
+
+func TestJobCancel(t *testing.T) {
+	vt := New(DefaultConfig())
+	defer vt.Close()
+	ctx := context.Background()
+
+	started := make(chan struct{})
+	running := vt.Enqueue(ctx, func(ctx context.Context, vt *VirtualTerminal) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	pending := vt.Enqueue(ctx, SleepAction(time.Hour))
+	<-started
+
+	if !pending.Cancel() {
+		t.Error("expected pending job to be cancelled before starting")
+	}
+	if err := pending.Wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+
+	if running.Cancel() {
+		t.Error("expected running job not to be reported as pending")
+	}
+	if err := running.Wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestCancelPending(t *testing.T) {
+	vt := New(DefaultConfig())
+	defer vt.Close()
+	ctx := context.Background()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	blocker := vt.Enqueue(ctx, func(ctx context.Context, vt *VirtualTerminal) error {
+		close(started)
+		<-release
+		return nil
+	})
+	<-started
+	vt.Enqueue(ctx, SleepAction(time.Hour))
+	vt.Enqueue(ctx, SleepAction(time.Hour))
+
+	if n := vt.CancelPending(); n != 2 {
+		t.Errorf("expected 2 cancelled jobs, got %d", n)
+	}
+	close(release)
+	if err := blocker.Wait(ctx); err != nil {
+		t.Errorf("expected blocker to complete, got %v", err)
+	}
+}
+
+func TestEnqueueAfterClose(t *testing.T) {
+	vt := New(DefaultConfig())
+	vt.Close()
+
+	err := vt.Do(context.Background(), SleepAction(time.Millisecond))
+	if err != ErrClosed {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
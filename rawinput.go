@@ -0,0 +1,142 @@
+package htlib
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+)
+
+// InputBytes sends raw bytes to the terminal. Unlike Input it validates the
+// data up front: ht carries input as a JSON string, so data must be valid
+// UTF-8 and ErrInvalidInput is returned otherwise rather than letting the
+// encoder silently replace bytes.
+func (vt *VirtualTerminal) InputBytes(ctx context.Context, data []byte) error {
+	if !utf8.Valid(data) {
+		return fmt.Errorf("%w: data is not valid UTF-8", ErrInvalidInput)
+	}
+	return vt.Input(ctx, string(data))
+}
+
+// InputNotation decodes s with ParseInputNotation and sends the result.
+// Example: vt.InputNotation(ctx, `printf 'a\tb'^M`)
+func (vt *VirtualTerminal) InputNotation(ctx context.Context, s string) error {
+	data, err := ParseInputNotation(s)
+	if err != nil {
+		return err
+	}
+	return vt.InputBytes(ctx, data)
+}
+
+// InputControl sends the control character for c, e.g. InputControl(ctx, 'c')
+// sends 0x03 (ETX) directly instead of going through ht's key names.
+func (vt *VirtualTerminal) InputControl(ctx context.Context, c rune) error {
+	b, err := ControlChar(c)
+	if err != nil {
+		return err
+	}
+	return vt.InputBytes(ctx, []byte{b})
+}
+
+// InputEscape sends an escape sequence, prefixing seq with ESC.
+// Example: InputEscape(ctx, "[A") sends the cursor-up sequence.
+func (vt *VirtualTerminal) InputEscape(ctx context.Context, seq string) error {
+	return vt.InputBytes(ctx, []byte("\x1b"+seq))
+}
+
+// ControlChar returns the control byte produced by pressing Ctrl with c.
+// Letters are case-insensitive; '@', '[', '\\', ']', '^', '_' and '?' map to
+// NUL, ESC, FS, GS, RS, US and DEL respectively.
+func ControlChar(c rune) (byte, error) {
+	switch {
+	case c >= 'a' && c <= 'z':
+		return byte(c-'a') + 1, nil
+	case c >= '@' && c <= '_':
+		return byte(c) & 0x1f, nil
+	case c == '?':
+		return 0x7f, nil
+	default:
+		return 0, fmt.Errorf("%w: no control character for %q", ErrInvalidInput, c)
+	}
+}
+
+// ParseInputNotation decodes a string written in caret and backslash
+// notation into raw bytes:
+//
+//	^C, ^[, ^?   caret notation for control characters
+//	\e, \E       ESC
+//	\xHH         a byte from \x00 to \x7f in hexadecimal
+//	\0           NUL
+//	\a \b \f \n \r \t \v
+//	\\, \^       a literal backslash or caret
+//
+// All other characters are passed through unchanged. Bytes from \x80 up
+// are rejected, since on their own they are not valid UTF-8, which is all
+// ht can carry; write such characters literally instead.
+func ParseInputNotation(s string) ([]byte, error) {
+	out := make([]byte, 0, len(s))
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '^':
+			if i+1 >= len(s) {
+				return nil, fmt.Errorf("%w: trailing ^ at offset %d", ErrInvalidInput, i)
+			}
+			b, err := ControlChar(rune(s[i+1]))
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid caret sequence ^%c at offset %d", ErrInvalidInput, s[i+1], i)
+			}
+			out = append(out, b)
+			i++
+
+		case '\\':
+			if i+1 >= len(s) {
+				return nil, fmt.Errorf("%w: trailing backslash at offset %d", ErrInvalidInput, i)
+			}
+			i++
+			switch s[i] {
+			case 'e', 'E':
+				out = append(out, 0x1b)
+			case '0':
+				out = append(out, 0x00)
+			case 'a':
+				out = append(out, '\a')
+			case 'b':
+				out = append(out, '\b')
+			case 'f':
+				out = append(out, '\f')
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'v':
+				out = append(out, '\v')
+			case '\\', '^':
+				out = append(out, s[i])
+			case 'x':
+				if i+2 >= len(s) {
+					return nil, fmt.Errorf("%w: short \\x escape at offset %d", ErrInvalidInput, i-1)
+				}
+				v, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+				if err != nil {
+					return nil, fmt.Errorf("%w: invalid \\x escape at offset %d", ErrInvalidInput, i-1)
+				}
+				if v >= utf8.RuneSelf {
+					return nil, fmt.Errorf("%w: \\x%s at offset %d is not ASCII; write the character itself", ErrInvalidInput, s[i+1:i+3], i-1)
+				}
+				out = append(out, byte(v))
+				i += 2
+			default:
+				return nil, fmt.Errorf("%w: unknown escape \\%c at offset %d", ErrInvalidInput, s[i], i-1)
+			}
+
+		default:
+			out = append(out, c)
+		}
+	}
+
+	return out, nil
+}
@@ -0,0 +1,80 @@
+package htlib
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestControlChar(t *testing.T) {
+	tests := []struct {
+		input    rune
+		expected byte
+	}{
+		{'c', 0x03},
+		{'C', 0x03},
+		{'@', 0x00},
+		{'[', 0x1b},
+		{'?', 0x7f},
+		{'_', 0x1f},
+	}
+
+	for _, tt := range tests {
+		b, err := ControlChar(tt.input)
+		if err != nil {
+			t.Errorf("ControlChar(%q): unexpected error %v", tt.input, err)
+			continue
+		}
+		if b != tt.expected {
+			t.Errorf("ControlChar(%q): expected %#x, got %#x", tt.input, tt.expected, b)
+		}
+	}
+
+	if _, err := ControlChar('1'); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput, got %v", err)
+	}
+}
+
+func TestParseInputNotation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []byte
+	}{
+		{"plain", []byte("plain")},
+		{"^C", []byte{0x03}},
+		{"a^[b", []byte{'a', 0x1b, 'b'}},
+		{`\e[A`, []byte("\x1b[A")},
+		{`\x00\x7f`, []byte{0x00, 0x7f}},
+		{`é\x41`, []byte("éA")},
+		{`line\r\n`, []byte("line\r\n")},
+		{`\\\^`, []byte(`\^`)},
+		{"^?", []byte{0x7f}},
+	}
+
+	for _, tt := range tests {
+		result, err := ParseInputNotation(tt.input)
+		if err != nil {
+			t.Errorf("ParseInputNotation(%q): unexpected error %v", tt.input, err)
+			continue
+		}
+		if !bytes.Equal(result, tt.expected) {
+			t.Errorf("ParseInputNotation(%q): expected %q, got %q", tt.input, tt.expected, result)
+		}
+	}
+
+	for _, input := range []string{"^", `\`, `\q`, `\x4`, `\xzz`, "^1", `\xff`, `\xc3\xa9`} {
+		if _, err := ParseInputNotation(input); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("ParseInputNotation(%q): expected ErrInvalidInput, got %v", input, err)
+		}
+	}
+}
+
+func TestInputBytesInvalidUTF8(t *testing.T) {
+	vt := New(DefaultConfig())
+
+	err := vt.InputBytes(context.Background(), []byte{0xff})
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput, got %v", err)
+	}
+}
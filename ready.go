@@ -0,0 +1,58 @@
+package htlib
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// StartOption configures the behavior of Start.
+type StartOption func(*startOptions)
+
+// startOptions holds the settings applied by StartOptions.
+type startOptions struct {
+	waitReady     bool
+	retryAttempts int
+	retryBackoff  time.Duration
+}
+
+// WaitReady makes Start block until the InitEvent has been received and the
+// shell has printed its first prompt (see WaitForPrompt), so the terminal is
+// ready for input when Start returns. The InitEvent is still delivered on
+// Events().
+//
+// Only use WaitReady when the terminal runs a shell; a program that never
+// prints a prompt makes Start wait until ctx is done.
+func WaitReady() StartOption {
+	return func(o *startOptions) {
+		o.waitReady = true
+	}
+}
+
+// defaultPromptPattern matches the end of common bash, zsh, fish and root
+// prompts, e.g. "user@host:~$ ", "% ", "# " or "❯ ".
+var defaultPromptPattern = regexp.MustCompile(`[$#%>❯➜]\s*$`)
+
+// waitReady blocks until the InitEvent and the first prompt are observed.
+func (vt *VirtualTerminal) waitReady(ctx context.Context) error {
+	if err := vt.waitForInit(ctx); err != nil {
+		return err
+	}
+
+	return vt.WaitForPrompt(ctx)
+}
+
+// runOnReady runs the Config.OnReady hooks in order, waiting for the prompt
+// to return after each one so the next starts from a settled shell.
+func (vt *VirtualTerminal) runOnReady(ctx context.Context) error {
+	for i, action := range vt.config.OnReady {
+		if err := action(ctx, vt); err != nil {
+			return fmt.Errorf("OnReady hook %d: %w", i, err)
+		}
+		if err := vt.WaitForPrompt(ctx); err != nil {
+			return fmt.Errorf("OnReady hook %d: waiting for prompt: %w", i, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package htlib
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefaultPromptPattern(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected bool
+	}{
+		{"user@host:~$ ", true},
+		{"[root@box /]# ", true},
+		{"host% ", true},
+		{"~/src ❯ ", true},
+		{"Loading...\n", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		result := defaultPromptPattern.MatchString(tt.text)
+		if result != tt.expected {
+			t.Errorf("prompt match for %q: expected %v, got %v", tt.text, tt.expected, result)
+		}
+	}
+}
+
+func TestOnReadyHooks(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	config := DefaultConfig()
+	config.OnReady = []Action{
+		InputAction("export HOOKED=yes\n"),
+		InputAction("cd /tmp\n"),
+	}
+	vt := New(config)
+	defer vt.Close()
+
+	if err := vt.Start(ctx); err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+	if !vt.AtPrompt() {
+		t.Error("expected the shell to be at a prompt after the hooks")
+	}
+
+	vt.Input(ctx, "echo \"$HOOKED:$PWD\" | tr a-z A-Z\n")
+	if _, err := vt.WaitForText(ctx, "YES:/TMP"); err != nil {
+		t.Errorf("hooks did not run: %v", err)
+	}
+}
+
+func TestOnReadyHookError(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	failure := errors.New("boom")
+	config := DefaultConfig()
+	config.OnReady = []Action{
+		InputAction("true\n"),
+		func(ctx context.Context, vt *VirtualTerminal) error { return failure },
+	}
+	vt := New(config)
+	defer vt.Close()
+
+	err := vt.Start(ctx)
+	if !errors.Is(err, failure) || !strings.Contains(err.Error(), "OnReady hook 1") {
+		t.Errorf("expected hook 1 error, got %v", err)
+	}
+
+	// ht is stopped again, so Start can be retried
+	if pid := vt.PID(); pid != 0 {
+		t.Errorf("expected no process after the failed Start, got PID %d", pid)
+	}
+	vt.config.OnReady = nil
+	if err := vt.Start(ctx, WaitReady()); err != nil {
+		t.Errorf("expected Start to succeed again, got %v", err)
+	}
+}
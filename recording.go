@@ -0,0 +1,244 @@
+package htlib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultKeyframeInterval is how often a Recorder stores a keyframe unless
+// WithKeyframeInterval says otherwise.
+const defaultKeyframeInterval = 5 * time.Second
+
+// Recording is a recorded session: the output and resize events with their
+// time offsets, plus keyframes that capture the whole screen every few
+// seconds. A player can seek to any time by loading the last keyframe
+// before it and replaying only the events that follow.
+type Recording struct {
+	// Cols and Rows are the size of the terminal when recording started
+	Cols     int             `json:"cols"`
+	Rows     int             `json:"rows"`
+	Metadata Metadata        `json:"metadata"`
+	Events   []RecordedEvent `json:"events"`
+	Frames   []Keyframe      `json:"keyframes"`
+	Duration time.Duration   `json:"duration"`
+}
+
+// RecordedEvent is an output or resize event in a Recording.
+type RecordedEvent struct {
+	// Offset is the time since recording started
+	Offset time.Duration `json:"offset"`
+	// Seq is the output, empty for a resize
+	Seq string `json:"seq,omitempty"`
+	// Cols and Rows are the new size of a resize, zero for output
+	Cols int `json:"cols,omitempty"`
+	Rows int `json:"rows,omitempty"`
+}
+
+// Keyframe is the full screen at one point of a Recording.
+type Keyframe struct {
+	Offset time.Duration `json:"offset"`
+	// Event is the number of events that happened before the keyframe,
+	// i.e. the index of the first event to replay after loading it
+	Event int `json:"event"`
+	Cols  int `json:"cols"`
+	Rows  int `json:"rows"`
+	// Seq recreates the screen, including its colors, cursor and modes,
+	// when written to a freshly reset terminal of size Cols x Rows
+	Seq string `json:"seq"`
+}
+
+// Seek returns the keyframe to load for time offset and the events to
+// replay after it to reach offset. A web player seeks by writing the
+// keyframe's Seq to a terminal of its size and then each event.
+func (r *Recording) Seek(offset time.Duration) (Keyframe, []RecordedEvent) {
+	if len(r.Frames) == 0 {
+		return Keyframe{Cols: r.Cols, Rows: r.Rows}, nil
+	}
+	i := sort.Search(len(r.Frames), func(i int) bool { return r.Frames[i].Offset > offset })
+	frame := r.Frames[max(i-1, 0)]
+
+	end := frame.Event
+	for end < len(r.Events) && r.Events[end].Offset <= offset {
+		end++
+	}
+	return frame, r.Events[frame.Event:end]
+}
+
+// ScreenAt returns the screen as it was at time offset.
+func (r *Recording) ScreenAt(offset time.Duration) *Screen {
+	frame, events := r.Seek(offset)
+	m := newScreenModel(frame.Cols, frame.Rows)
+	m.feed(frame.Seq)
+	for _, e := range events {
+		if e.Cols > 0 && e.Rows > 0 {
+			m.setSize(e.Cols, e.Rows)
+		} else {
+			m.feed(e.Seq)
+		}
+	}
+	return m.snapshot()
+}
+
+// Save writes the recording as JSON to storage.
+func (r *Recording) Save(ctx context.Context, storage Storage, name string) error {
+	w, err := storage.Create(ctx, name)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(w).Encode(r); err != nil {
+		w.Close()
+		return fmt.Errorf("failed to write recording: %w", err)
+	}
+	return w.Close()
+}
+
+// LoadRecording reads a recording written by Recording.Save.
+func LoadRecording(ctx context.Context, storage Storage, name string) (*Recording, error) {
+	rc, err := storage.Open(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var r Recording
+	if err := json.NewDecoder(rc).Decode(&r); err != nil {
+		return nil, fmt.Errorf("failed to read recording %s: %w", name, err)
+	}
+	return &r, nil
+}
+
+// RecordOption configures a Recorder.
+type RecordOption func(*Recorder)
+
+// WithKeyframeInterval sets how often the Recorder stores a keyframe
+// (default 5s). Shorter intervals make seeking faster and recordings
+// larger.
+func WithKeyframeInterval(d time.Duration) RecordOption {
+	return func(r *Recorder) {
+		r.interval = d
+	}
+}
+
+// Recorder records a terminal session into a Recording.
+type Recorder struct {
+	vt       *VirtualTerminal
+	sub      chan Event
+	done     chan struct{}
+	interval time.Duration
+	start    time.Time
+
+	mu        sync.Mutex
+	recording Recording
+}
+
+// Record starts recording the session. The recorder replays the output on
+// its own copy of the screen model (see Screen) to take keyframes, the
+// first one immediately. It never drops events, since one lost output
+// event would corrupt every later keyframe; like an OverflowBlock
+// subscriber, it holds up the terminal if it falls behind. Call Close to
+// stop recording.
+func (vt *VirtualTerminal) Record(opts ...RecordOption) *Recorder {
+	r := &Recorder{
+		vt:       vt,
+		done:     make(chan struct{}),
+		interval: defaultKeyframeInterval,
+		start:    time.Now(),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	sub, model := vt.subscribeScreen()
+	r.sub = sub
+	r.recording.Metadata = vt.Metadata()
+	r.recording.Cols, r.recording.Rows = model.cols, model.rows
+	r.keyframe(model, 0)
+	go r.record(model)
+	return r
+}
+
+// Close stops recording. It is safe to call Close more than once.
+func (r *Recorder) Close() {
+	r.vt.Unsubscribe(r.sub)
+	<-r.done
+}
+
+// Recording returns a copy of what has been recorded so far.
+func (r *Recorder) Recording() *Recording {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rec := r.recording
+	rec.Events = append([]RecordedEvent(nil), r.recording.Events...)
+	rec.Frames = append([]Keyframe(nil), r.recording.Frames...)
+	return &rec
+}
+
+// record replays events on model, storing them and a keyframe whenever
+// the interval has passed since the last one.
+func (r *Recorder) record(model *screenModel) {
+	defer close(r.done)
+
+	for event := range r.sub {
+		var recorded RecordedEvent
+		var now time.Time
+		switch e := event.(type) {
+		case InitEvent:
+			// A new screen: start over from a keyframe
+			model.load(e.Cols, e.Rows, e.Seq)
+			r.keyframe(model, r.offset(e.Time))
+			continue
+		case OutputEvent:
+			model.feed(e.Seq)
+			recorded.Seq, now = e.Seq, e.Time
+		case ResizeEvent:
+			model.setSize(e.Cols, e.Rows)
+			recorded.Cols, recorded.Rows, now = e.Cols, e.Rows, e.Time
+		default:
+			continue
+		}
+		recorded.Offset = r.offset(now)
+
+		r.mu.Lock()
+		r.recording.Events = append(r.recording.Events, recorded)
+		r.recording.Duration = recorded.Offset
+		last := r.recording.Frames[len(r.recording.Frames)-1]
+		r.mu.Unlock()
+
+		if recorded.Offset-last.Offset >= r.interval {
+			r.keyframe(model, recorded.Offset)
+		}
+	}
+}
+
+// offset returns the time of an event relative to the start of recording.
+// Events without a time are taken to happen now.
+func (r *Recorder) offset(t time.Time) time.Duration {
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return max(t.Sub(r.start)-r.vt.suspension.between(r.start, t), 0)
+}
+
+// keyframe stores the state of model as a keyframe at offset.
+func (r *Recorder) keyframe(model *screenModel, offset time.Duration) {
+	seq := model.dump()
+	model.mu.Lock()
+	cols, rows := model.cols, model.rows
+	model.mu.Unlock()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.recording.Frames = append(r.recording.Frames, Keyframe{
+		Offset: offset,
+		Event:  len(r.recording.Events),
+		Cols:   cols,
+		Rows:   rows,
+		Seq:    seq,
+	})
+	r.recording.Duration = max(r.recording.Duration, offset)
+}
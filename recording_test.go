@@ -0,0 +1,114 @@
+package htlib
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestScreenModelDump(t *testing.T) {
+	tests := []struct {
+		name string
+		seq  string
+	}{
+		{"text", "hello\r\nworld"},
+		{"styles", "\x1b[1;31mred\x1b[0m plain \x1b[38;5;200;48;2;1;2;3mext\x1b[4m"},
+		{"modes", "\x1b[?1h\x1b=\x1b[?7l\x1b[4h\x1b[?2004h\x1b[?1000h\x1b[?25l\x1b[5 q\x1b[>3u"},
+		{"scroll region and origin", "\x1b[2;4r\x1b[?6h\x1b[2;3Hx"},
+		{"title", "\x1b]2;my title\x07"},
+		{"saved cursor", "\x1b[3;5H\x1b[7m\x1b7\x1b[0m\x1b[H"},
+		{"alternate screen", "main\x1b[2;2H\x1b[?1049h\x1b[1;32malt\x1b[3;1H"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newScreenModel(10, 4)
+			m.feed(tt.seq)
+
+			restored := newScreenModel(10, 4)
+			restored.feed(m.dump())
+			if got, want := restored.snapshot(), m.snapshot(); !reflect.DeepEqual(got, want) {
+				t.Errorf("dump did not restore the screen:\ngot  %+v\nwant %+v", got, want)
+			}
+			if restored.style != m.style || restored.saved != m.saved || restored.top != m.top || restored.bottom != m.bottom {
+				t.Errorf("dump did not restore the cursor state")
+			}
+
+			// Leaving the alternate screen restores the main one
+			m.feed("\x1b[?1049l\x1b8")
+			restored.feed("\x1b[?1049l\x1b8")
+			if !reflect.DeepEqual(restored.snapshot(), m.snapshot()) {
+				t.Errorf("dump did not restore the main screen:\n%q", restored.snapshot().Text())
+			}
+		})
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	vt, _ := newTestTerminal()
+	defer vt.Close()
+
+	r := vt.Record(WithKeyframeInterval(time.Second))
+	at := func(d time.Duration) time.Time { return r.start.Add(d) }
+	send := func(event Event) {
+		vt.trackEvent(event)
+		vt.dispatch(event)
+	}
+
+	send(InitEvent{Cols: 10, Rows: 3, Seq: "\x1b[2J", Time: at(0)})
+	send(OutputEvent{Seq: "one", Time: at(500 * time.Millisecond)})
+	send(OutputEvent{Seq: "\r\ntwo", Time: at(1500 * time.Millisecond)})
+	send(ResizeEvent{Cols: 8, Rows: 2, Time: at(2 * time.Second)})
+	send(OutputEvent{Seq: "\x1b[2J\x1b[Hthree", Time: at(2600 * time.Millisecond)})
+	waitUntil(t, func() bool { return len(r.Recording().Events) == 4 })
+	r.Close()
+	r.Close()
+	send(OutputEvent{Seq: "ignored", Time: at(3 * time.Second)})
+
+	rec := r.Recording()
+	if len(rec.Events) != 4 || rec.Duration != 2600*time.Millisecond {
+		t.Fatalf("unexpected recording: %+v", rec)
+	}
+	// Initial, init, after 1.5s and after 2.6s
+	offsets := []time.Duration{}
+	for _, f := range rec.Frames {
+		offsets = append(offsets, f.Offset)
+	}
+	if want := []time.Duration{0, 0, 1500 * time.Millisecond, 2600 * time.Millisecond}; !reflect.DeepEqual(offsets, want) {
+		t.Fatalf("expected keyframes at %v, got %v", want, offsets)
+	}
+
+	frame, events := rec.Seek(2200 * time.Millisecond)
+	if frame.Offset != 1500*time.Millisecond || len(events) != 1 || events[0].Cols != 8 {
+		t.Errorf("unexpected seek result %+v %+v", frame, events)
+	}
+
+	tests := []struct {
+		offset time.Duration
+		text   string
+	}{
+		{0, "\n\n"},
+		{time.Second, "one\n\n"},
+		{2 * time.Second, "one\ntwo"},
+		{time.Hour, "three\n"},
+	}
+	for _, tt := range tests {
+		if text := rec.ScreenAt(tt.offset).Text(); text != tt.text {
+			t.Errorf("at %v: expected %q, got %q", tt.offset, tt.text, text)
+		}
+	}
+
+	// Recordings round-trip through storage
+	storage := NewMemoryStorage()
+	if err := rec.Save(context.Background(), storage, "session.json"); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadRecording(context.Background(), storage, "session.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.Frames, rec.Frames) || !reflect.DeepEqual(loaded.Events, rec.Events) {
+		t.Errorf("recording changed after save and load")
+	}
+}
@@ -0,0 +1,139 @@
+package htlib
+
+import "strings"
+
+// Rect is a rectangular area of the screen. Row and Col are the 0-based
+// position of its top-left cell; Rows and Cols its height and width.
+type Rect struct {
+	Row  int
+	Col  int
+	Rows int
+	Cols int
+}
+
+// Line returns the text of the 0-based row with trailing spaces removed,
+// or "" if the row is off the screen. Negative rows count from the bottom,
+// so Line(-1) is the last row, where status bars usually are.
+func (s *Screen) Line(row int) string {
+	return lineOf(s.lines(), row)
+}
+
+// Region returns the text inside r, one line per row with trailing spaces
+// removed. Parts of r that are off the screen are ignored:
+//
+//	status := screen.Region(htlib.Rect{Row: 0, Col: 60, Rows: 1, Cols: 20})
+func (s *Screen) Region(r Rect) string {
+	return regionOf(s.lines(), r)
+}
+
+// Column returns the characters of the 0-based column from top to bottom,
+// with trailing spaces removed. This suits one-character markers such as
+// a selection gutter or test result glyphs.
+func (s *Screen) Column(col int) string {
+	return columnOf(s.lines(), col)
+}
+
+// lines returns the text of every row.
+func (s *Screen) lines() []string {
+	lines := make([]string, len(s.cells))
+	for row := range s.cells {
+		lines[row] = s.line(row)
+	}
+	return lines
+}
+
+// Line returns the text of the 0-based row of the snapshot. See
+// Screen.Line.
+func (e SnapshotEvent) Line(row int) string {
+	return lineOf(strings.Split(e.Text, "\n"), row)
+}
+
+// Region returns the text of the snapshot inside r. See Screen.Region.
+func (e SnapshotEvent) Region(r Rect) string {
+	return regionOf(strings.Split(e.Text, "\n"), r)
+}
+
+// Column returns the characters of the 0-based column of the snapshot.
+// See Screen.Column.
+func (e SnapshotEvent) Column(col int) string {
+	return columnOf(strings.Split(e.Text, "\n"), col)
+}
+
+// Line returns the text of the 0-based row of the local screen model. See
+// Screen.Line.
+func (vt *VirtualTerminal) Line(row int) string {
+	return vt.Screen().Line(row)
+}
+
+// Region returns the text of the local screen model inside r. See
+// Screen.Region.
+func (vt *VirtualTerminal) Region(r Rect) string {
+	return vt.Screen().Region(r)
+}
+
+// Column returns the characters of the 0-based column of the local screen
+// model. See Screen.Column.
+func (vt *VirtualTerminal) Column(col int) string {
+	return vt.Screen().Column(col)
+}
+
+func lineOf(lines []string, row int) string {
+	if row < 0 {
+		row += len(lines)
+	}
+	if row < 0 || row >= len(lines) {
+		return ""
+	}
+	return strings.TrimRight(lines[row], " ")
+}
+
+func regionOf(lines []string, r Rect) string {
+	first := max(r.Row, 0)
+	last := min(r.Row+r.Rows, len(lines))
+	if first >= last || r.Cols <= 0 {
+		return ""
+	}
+
+	out := make([]string, 0, last-first)
+	for _, line := range lines[first:last] {
+		cols := splitColumns(line)
+		from := min(max(r.Col, 0), len(cols))
+		to := min(max(r.Col+r.Cols, 0), len(cols))
+		out = append(out, strings.TrimRight(strings.Join(cols[from:to], ""), " "))
+	}
+	return strings.Join(out, "\n")
+}
+
+func columnOf(lines []string, col int) string {
+	if col < 0 {
+		return ""
+	}
+	var b strings.Builder
+	for _, line := range lines {
+		if cols := splitColumns(line); col < len(cols) && cols[col] != "" {
+			b.WriteString(cols[col])
+		} else {
+			b.WriteByte(' ')
+		}
+	}
+	return strings.TrimRight(b.String(), " ")
+}
+
+// splitColumns splits a line into the text of each column: one grapheme
+// cluster per column, followed by "" for the column covered by the right
+// half of a wide character.
+func splitColumns(line string) []string {
+	var cols []string
+	for _, g := range Graphemes(line) {
+		width := graphemeWidth(g)
+		if width == 0 && len(cols) > 0 {
+			cols[len(cols)-1] += g
+			continue
+		}
+		cols = append(cols, g)
+		for range width - 1 {
+			cols = append(cols, "")
+		}
+	}
+	return cols
+}
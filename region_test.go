@@ -0,0 +1,89 @@
+package htlib
+
+import "testing"
+
+func TestRegionHelpers(t *testing.T) {
+	text := "Name     Status\n" +
+		"> build  ok    \n" +
+		"  test   FAILED\n" +
+		"\n" +
+		"-- INSERT --   "
+	snapshot := SnapshotEvent{Cols: 15, Rows: 5, Text: text}
+
+	m := newScreenModel(15, 5)
+	m.feed("Name     Status\r\n> build  ok\r\n  test   FAILED\r\n\r\n-- INSERT --")
+	screen := m.snapshot()
+
+	sources := map[string]interface {
+		Line(int) string
+		Region(Rect) string
+		Column(int) string
+	}{
+		"snapshot": snapshot,
+		"screen":   screen,
+	}
+	for name, s := range sources {
+		lines := []struct {
+			row      int
+			expected string
+		}{
+			{0, "Name     Status"},
+			{1, "> build  ok"},
+			{3, ""},
+			{-1, "-- INSERT --"},
+			{-5, "Name     Status"},
+			{5, ""},
+			{-6, ""},
+		}
+		for _, tt := range lines {
+			if got := s.Line(tt.row); got != tt.expected {
+				t.Errorf("%s: Line(%d): expected %q, got %q", name, tt.row, tt.expected, got)
+			}
+		}
+
+		regions := []struct {
+			rect     Rect
+			expected string
+		}{
+			// The status column
+			{Rect{Row: 1, Col: 9, Rows: 2, Cols: 6}, "ok\nFAILED"},
+			// Clipped to the screen
+			{Rect{Row: -1, Col: 2, Rows: 3, Cols: 100}, "me     Status\nbuild  ok"},
+			{Rect{Row: 1, Col: 20, Rows: 1, Cols: 5}, ""},
+			{Rect{Row: 7, Col: 0, Rows: 1, Cols: 5}, ""},
+			{Rect{Row: 0, Col: 0, Rows: 1, Cols: 0}, ""},
+		}
+		for _, tt := range regions {
+			if got := s.Region(tt.rect); got != tt.expected {
+				t.Errorf("%s: Region(%+v): expected %q, got %q", name, tt.rect, tt.expected, got)
+			}
+		}
+
+		if got := s.Column(0); got != "N>  -" {
+			t.Errorf("%s: Column(0): expected %q, got %q", name, "N>  -", got)
+		}
+		if got := s.Column(14); got != "s D" {
+			t.Errorf("%s: Column(14): expected %q, got %q", name, "s D", got)
+		}
+		if got := s.Column(-1); got != "" {
+			t.Errorf("%s: Column(-1): expected \"\", got %q", name, got)
+		}
+	}
+}
+
+func TestVirtualTerminalRegion(t *testing.T) {
+	vt, _ := newTestTerminal()
+	defer vt.Close()
+
+	vt.trackEvent(InitEvent{Cols: 20, Rows: 3, Seq: "\x1b[2J\x1b[Hleft      right\r\n\x1b[3;1Hstatus: ready"})
+
+	if got := vt.Line(-1); got != "status: ready" {
+		t.Errorf("unexpected last line %q", got)
+	}
+	if got := vt.Region(Rect{Row: 0, Col: 10, Rows: 1, Cols: 10}); got != "right" {
+		t.Errorf("unexpected region %q", got)
+	}
+	if got := vt.Column(0); got != "l s" {
+		t.Errorf("unexpected column %q", got)
+	}
+}
@@ -0,0 +1,73 @@
+package htlib
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ResizeSequence walks the terminal through each of sizes in turn, which is
+// useful for checking how a TUI lays out across breakpoints. After each
+// resize it waits for ht to report the new size and for output to settle for
+// the given quiet period, then captures a snapshot. The snapshots are
+// returned in the same order as sizes.
+func (vt *VirtualTerminal) ResizeSequence(ctx context.Context, settle time.Duration, sizes ...Size) ([]SnapshotEvent, error) {
+	sub := vt.subscribeRaw()
+	defer vt.Unsubscribe(sub)
+
+	snapshots := make([]SnapshotEvent, 0, len(sizes))
+	for _, size := range sizes {
+		if err := vt.Resize(ctx, size.Cols, size.Rows); err != nil {
+			return snapshots, err
+		}
+
+		_, err := vt.waitForEvent(ctx, sub, func(event Event) bool {
+			resize, ok := event.(ResizeEvent)
+			return ok && resize.Cols == size.Cols && resize.Rows == size.Rows
+		})
+		if err != nil {
+			return snapshots, fmt.Errorf("waiting for resize to %s: %w", size, err)
+		}
+
+		if err := vt.waitQuiet(ctx, sub, settle); err != nil {
+			return snapshots, fmt.Errorf("waiting for output to settle at %s: %w", size, err)
+		}
+
+		snapshot, err := vt.WaitForSnapshot(ctx)
+		if err != nil {
+			return snapshots, fmt.Errorf("taking snapshot at %s: %w", size, err)
+		}
+		snapshots = append(snapshots, *snapshot)
+	}
+
+	return snapshots, nil
+}
+
+// OnResize calls fn with the new size every time ht reports that the
+// terminal was resized, by Resize or otherwise, until stop is called or
+// the terminal is closed:
+//
+//	stop := vt.OnResize(func(size htlib.Size) {
+//	    renderer.SetSize(size.Cols, size.Rows)
+//	})
+//	defer stop()
+//
+// fn runs on its own goroutine, one call at a time. If it falls behind,
+// only the latest size is kept for the next call. A call may still be in
+// progress when stop returns.
+func (vt *VirtualTerminal) OnResize(fn func(Size)) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sub := vt.SubscribeContext(ctx,
+		WithEventTypes(EventTypeResize),
+		WithBuffer(1),
+		WithOverflow(OverflowDropOldest),
+	)
+	go func() {
+		for event := range sub {
+			if e, ok := event.(ResizeEvent); ok {
+				fn(Size{Cols: e.Cols, Rows: e.Rows})
+			}
+		}
+	}()
+	return cancel
+}
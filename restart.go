@@ -0,0 +1,149 @@
+package htlib
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// session is the state that belongs to one ht process: Start creates it
+// and Restart replaces it.
+type session struct {
+	// ctx is cancelled when the process exits or the terminal is closed
+	ctx    context.Context
+	cancel context.CancelFunc
+	// events is the channel returned by Events, closed after the ExitEvent
+	events chan Event
+	// initDone is closed on the InitEvent, exited once status is known
+	initDone chan struct{}
+	exited   chan struct{}
+	status   ExitStatus
+
+	// eventsDone is closed before events, and sendMu is held while sending
+	// to events, so that an event dispatched late is discarded rather than
+	// sent on the closed channel
+	eventsDone chan struct{}
+	sendMu     sync.Mutex
+	closeOnce  sync.Once
+}
+
+// newSession creates a session whose events channel holds buffer events.
+func newSession(buffer int) *session {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &session{
+		ctx:        ctx,
+		cancel:     cancel,
+		events:     make(chan Event, buffer),
+		initDone:   make(chan struct{}),
+		exited:     make(chan struct{}),
+		eventsDone: make(chan struct{}),
+	}
+}
+
+// closeEvents closes the events channel, waiting for an event being sent
+// to it to be delivered or given up. It is safe to call more than once.
+func (s *session) closeEvents() {
+	s.closeOnce.Do(func() {
+		close(s.eventsDone)
+		s.sendMu.Lock()
+		defer s.sendMu.Unlock()
+		close(s.events)
+	})
+}
+
+// current returns the session of the current ht process.
+func (vt *VirtualTerminal) current() *session {
+	vt.mu.RLock()
+	defer vt.mu.RUnlock()
+	return vt.sess
+}
+
+// Restart ends the program running in the terminal, if it is still
+// running, and starts a new ht process with the same Config, for
+// recovering from a crashed or wedged shell without setting everything up
+// again:
+//
+//	if status, _ := vt.WaitForExit(ctx); !status.Success() {
+//	    err = vt.Restart(ctx, htlib.WaitReady())
+//	}
+//
+// Subscribers, event loggers, output tees, transcripts and recorders stay
+// attached and receive the ExitEvent of the old process followed by the
+// InitEvent of the new one. Events returns a new channel, as the old one
+// is closed after its ExitEvent. The screen, Size, prompt state, PID, exit
+// status, Err and Suspended are reset; the scrollback and the statistics
+// for Summary are kept.
+//
+// Triggers, watchdogs and clipboard bridges stop with the old process and
+// have to be added again, except for Config.Watchdogs, which are added as
+// by Start. Restart takes the same options as Start. To restart
+// automatically when the program fails, see Config.RestartPolicy.
+func (vt *VirtualTerminal) Restart(ctx context.Context, opts ...StartOption) error {
+	vt.restartMu.Lock()
+	defer vt.restartMu.Unlock()
+
+	if err := vt.replaceSession(false); err != nil {
+		return err
+	}
+
+	var options startOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return vt.launch(ctx, options)
+}
+
+// replaceSession ends the current ht process as Close does and resets the
+// terminal for a new one. The terminal must have been started, unless
+// retry is set because the previous process failed to spawn. The caller
+// must hold restartMu.
+func (vt *VirtualTerminal) replaceSession(retry bool) error {
+	vt.mu.Lock()
+	if vt.closed {
+		vt.mu.Unlock()
+		return ErrClosed
+	}
+	if !vt.started && !retry {
+		vt.mu.Unlock()
+		return ErrNotStarted
+	}
+	old, stdin, started := vt.sess, vt.stdin, vt.started
+	vt.mu.Unlock()
+
+	// End the old process, and wait until its last events have been
+	// delivered
+	old.cancel()
+	if stdin != nil {
+		stdin.Close()
+	}
+	vt.wg.Wait()
+	if !started {
+		// No process ever ran to close the channel after its ExitEvent
+		old.closeEvents()
+	}
+	vt.queue.reopen()
+
+	vt.mu.Lock()
+	if vt.closed {
+		vt.mu.Unlock()
+		return ErrClosed
+	}
+	vt.sess = newSession(vt.config.eventBuffer())
+	vt.started = false
+	vt.proc, vt.stdin, vt.stdout, vt.stderr = nil, nil, nil, nil
+	vt.pid, vt.lastScreen, vt.err = 0, nil, nil
+	vt.cols, vt.rows = 0, 0
+	vt.charsets = charsetTranslator{}
+	vt.changes = changeTracker{}
+	vt.mu.Unlock()
+
+	cols, rows := vt.configSize()
+	vt.screen.load(cols, rows, "")
+	vt.prompt.reset()
+	vt.stderrTail.reset()
+	vt.snapshots.reset()
+	vt.suspension.mu.Lock()
+	vt.suspension.end(time.Now())
+	vt.suspension.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,101 @@
+package htlib
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRestartAfterExit(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	vt := New(DefaultConfig())
+	defer vt.Close()
+	sub := vt.Subscribe()
+	if err := vt.Start(ctx, WaitReady()); err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+	firstPID := vt.PID()
+
+	// The work queue stops when the process exits, and Restart reopens it
+	if err := vt.Do(ctx, InputAction("exit 3\r")); err != nil {
+		t.Fatal(err)
+	}
+	if status, err := vt.WaitForExit(ctx); err != nil || status.Code != 3 {
+		t.Fatalf("expected exit status 3, got %v %v", status, err)
+	}
+
+	if err := vt.Restart(ctx, WaitReady()); err != nil {
+		t.Fatalf("failed to restart: %v", err)
+	}
+	if vt.PID() == 0 || vt.PID() == firstPID {
+		t.Errorf("expected a new process, got PID %d", vt.PID())
+	}
+	if err := vt.Do(ctx, InputAction("echo back $((40+2))\r")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vt.WaitForText(ctx, "back 42"); err != nil {
+		t.Fatal(err)
+	}
+
+	waiting, stop := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer stop()
+	if _, err := vt.WaitForExit(waiting); err != context.DeadlineExceeded {
+		t.Errorf("expected the new process to be running, got %v", err)
+	}
+
+	// The subscriber saw the old process end and the new one start
+	var types []EventType
+	for len(sub) > 0 {
+		event := <-sub
+		if event.Type() == EventTypeInit || event.Type() == EventTypeExit {
+			types = append(types, event.Type())
+		}
+	}
+	if len(types) != 3 || types[0] != EventTypeInit || types[1] != EventTypeExit || types[2] != EventTypeInit {
+		t.Errorf("expected init, exit, init, got %v", types)
+	}
+}
+
+func TestRestartRunning(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	vt := New(DefaultConfig())
+	defer vt.Close()
+	if err := vt.Start(ctx, WaitReady()); err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+	events := vt.Events()
+
+	if err := vt.Restart(ctx); err != nil {
+		t.Fatalf("failed to restart: %v", err)
+	}
+
+	var last Event
+	for event := range events {
+		last = event
+	}
+	if exit, ok := last.(ExitEvent); !ok || exit.Status().Success() {
+		t.Errorf("expected the old events to end with the process being killed, got %#v", last)
+	}
+	if vt.Events() == events {
+		t.Error("expected a new events channel")
+	}
+	if _, err := vt.WaitFor(ctx, func(s *SnapshotEvent) bool { return vt.AtPrompt() }); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRestartErrors(t *testing.T) {
+	vt := New(DefaultConfig())
+	if err := vt.Restart(context.Background()); !errors.Is(err, ErrNotStarted) {
+		t.Errorf("expected ErrNotStarted, got %v", err)
+	}
+	vt.Close()
+	if err := vt.Restart(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
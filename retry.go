@@ -0,0 +1,126 @@
+package htlib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// WithStartRetry makes Start retry transient spawn failures, such as a
+// busy ht binary, PTY exhaustion or EAGAIN from fork, up to attempts times
+// in total. The first retry waits for backoff and each further retry waits
+// twice as long as the previous one. Permanent failures such as a missing
+// ht binary are returned immediately.
+//
+// With retries enabled Start also waits for ht to send its InitEvent, so
+// that an ht process that fails to allocate a PTY and exits is retried
+// rather than reported as a started terminal.
+func WithStartRetry(attempts int, backoff time.Duration) StartOption {
+	return func(o *startOptions) {
+		o.retryAttempts = attempts
+		o.retryBackoff = backoff
+	}
+}
+
+// IsTransientStartError reports whether err from Start is a spawn failure
+// that may succeed if retried, as opposed to a permanent one like a missing
+// binary.
+func IsTransientStartError(err error) bool {
+	switch {
+	case err == nil,
+		errors.Is(err, exec.ErrNotFound),
+		errors.Is(err, fs.ErrNotExist),
+		errors.Is(err, fs.ErrPermission):
+		return false
+	case errors.Is(err, ErrProcessExited):
+		// ht could not set up the terminal, typically because no PTY was available
+		return true
+	}
+
+	for _, errno := range []syscall.Errno{
+		syscall.EAGAIN, syscall.ETXTBSY, syscall.EBUSY,
+		syscall.ENOMEM, syscall.EMFILE, syscall.ENFILE, syscall.EINTR,
+	} {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	return false
+}
+
+// spawnWithRetry spawns ht, retrying transient failures as configured.
+func (vt *VirtualTerminal) spawnWithRetry(ctx context.Context, options startOptions) (*htProcess, error) {
+	if options.retryAttempts <= 1 {
+		return vt.spawn()
+	}
+
+	backoff := options.retryBackoff
+	for attempt := 1; ; attempt++ {
+		proc, err := vt.spawn()
+		if err == nil {
+			err = vt.confirmStart(ctx, proc)
+		}
+		if err == nil {
+			return proc, nil
+		}
+		if attempt >= options.retryAttempts || !IsTransientStartError(err) {
+			if attempt > 1 {
+				err = fmt.Errorf("after %d attempts: %w", attempt, err)
+			}
+			return nil, err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-vt.current().ctx.Done():
+			timer.Stop()
+			return nil, ErrClosed
+		}
+		backoff *= 2
+	}
+}
+
+// confirmStart waits for ht's first event line, which it only sends once
+// the PTY and child process are set up. The line is kept for readEvents.
+func (vt *VirtualTerminal) confirmStart(ctx context.Context, proc *htProcess) error {
+	scanned := make(chan bool, 1)
+	go func() {
+		line, ok := proc.events.next()
+		proc.first = line
+		scanned <- ok
+	}()
+
+	select {
+	case ok := <-scanned:
+		if !ok {
+			// ht has exited, so its stderr ends too, unless something it
+			// started holds on to it
+			tail := make(chan []string, 1)
+			go func() { tail <- readTail(proc.stderr, stderrTailLines) }()
+			var stderr []string
+			select {
+			case stderr = <-tail:
+			case <-time.After(time.Second):
+			}
+			status, waitErr := proc.wait()
+			return &ProcessExitError{Status: status, Stderr: stderr, Err: waitErr}
+		}
+		return nil
+	case <-ctx.Done():
+		proc.kill()
+		<-scanned
+		return ctx.Err()
+	case <-vt.current().ctx.Done():
+		proc.kill()
+		<-scanned
+		return ErrClosed
+	}
+}
@@ -0,0 +1,125 @@
+package htlib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestIsTransientStartError(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected bool
+	}{
+		{nil, false},
+		{fmt.Errorf("failed to start ht process: %w", exec.ErrNotFound), false},
+		{&os.PathError{Op: "fork/exec", Path: "/x/ht", Err: syscall.ENOENT}, false},
+		{&os.PathError{Op: "fork/exec", Path: "/x/ht", Err: syscall.EACCES}, false},
+		{&os.PathError{Op: "fork/exec", Path: "/x/ht", Err: syscall.ETXTBSY}, true},
+		{fmt.Errorf("failed to start ht process: %w", syscall.EAGAIN), true},
+		{fmt.Errorf("%w before init: exit status 1", ErrProcessExited), true},
+		{errors.New("something else"), false},
+	}
+
+	for _, tt := range tests {
+		if got := IsTransientStartError(tt.err); got != tt.expected {
+			t.Errorf("IsTransientStartError(%v) = %v, expected %v", tt.err, got, tt.expected)
+		}
+	}
+}
+
+// flakyHT writes a wrapper around ht that exits immediately for the first
+// failures invocations, like an ht that cannot allocate a PTY.
+func flakyHT(t *testing.T, failures int) (binary, counter string) {
+	t.Helper()
+	if _, err := exec.LookPath("ht"); err != nil {
+		t.Skip("ht not available")
+	}
+
+	dir := t.TempDir()
+	counter = filepath.Join(dir, "count")
+	binary = filepath.Join(dir, "flaky-ht")
+	script := fmt.Sprintf(`#!/bin/sh
+n=$(cat %[1]q 2>/dev/null || echo 0)
+n=$((n+1))
+echo $n > %[1]q
+if [ $n -le %[2]d ]; then
+  echo "openpty failed" >&2
+  exit 1
+fi
+exec ht "$@"
+`, counter, failures)
+	if err := os.WriteFile(binary, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return binary, counter
+}
+
+func TestStartRetry(t *testing.T) {
+	binary, counter := flakyHT(t, 2)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	config := DefaultConfig()
+	config.HtBinary = binary
+	vt := New(config)
+	defer vt.Close()
+
+	if err := vt.Start(ctx, WithStartRetry(5, 10*time.Millisecond)); err != nil {
+		t.Fatalf("expected start to succeed after retries: %v", err)
+	}
+
+	data, _ := os.ReadFile(counter)
+	if strings.TrimSpace(string(data)) != "3" {
+		t.Errorf("expected 3 attempts, got %q", data)
+	}
+
+	// The init line read while confirming the start is still delivered
+	select {
+	case event := <-vt.Events():
+		if _, ok := event.(InitEvent); !ok {
+			t.Errorf("expected InitEvent first, got %T", event)
+		}
+	case <-ctx.Done():
+		t.Fatal("no init event")
+	}
+}
+
+func TestStartRetryExhausted(t *testing.T) {
+	binary, _ := flakyHT(t, 10)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	config := DefaultConfig()
+	config.HtBinary = binary
+	vt := New(config)
+	defer vt.Close()
+
+	err := vt.Start(ctx, WithStartRetry(3, time.Millisecond))
+	if !errors.Is(err, ErrProcessExited) || !strings.Contains(err.Error(), "after 3 attempts") {
+		t.Errorf("expected ErrProcessExited after 3 attempts, got %v", err)
+	}
+}
+
+func TestStartRetryPermanentError(t *testing.T) {
+	config := DefaultConfig()
+	config.HtBinary = filepath.Join(t.TempDir(), "missing-ht")
+	vt := New(config)
+	defer vt.Close()
+
+	start := time.Now()
+	err := vt.Start(context.Background(), WithStartRetry(5, time.Second))
+	if err == nil || IsTransientStartError(err) {
+		t.Errorf("expected permanent error, got %v", err)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Error("permanent error was retried")
+	}
+}
@@ -0,0 +1,127 @@
+package htlib
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RunResult is the outcome of RunOnce and RunUntilExit.
+type RunResult struct {
+	// Screen is the terminal as the program left it
+	Screen *Screen
+	// Snapshot is the final screen in the form of a SnapshotEvent. ht has
+	// exited by then, so it is rendered from the local screen model.
+	Snapshot *SnapshotEvent
+	// Output is everything the program wrote, with escape sequences, as
+	// delivered on Events() after Config.OutputProcessors
+	Output string
+	// Duration is the time from starting ht until the program exited
+	Duration time.Duration
+	// ExitStatus is how the program ended; its Code is ExitCodeUnknown if
+	// ht exited 0, see ExitStatus
+	ExitStatus ExitStatus
+}
+
+// Text returns Output with ANSI sequences removed and CRLF and lone CR
+// line endings converted to LF.
+func (r *RunResult) Text() string {
+	return plainText(r.Output)
+}
+
+// plainText removes ANSI sequences from terminal output and converts its
+// line endings to LF.
+func plainText(output string) string {
+	text := StripANSI(output)
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.ReplaceAll(text, "\r", "\n")
+}
+
+// RunOnce runs argv in a new terminal in place of the interactive shell,
+// waits for it to exit and returns what it did, like exec with a real TTY:
+//
+//	result, err := htlib.RunOnce(ctx, htlib.DefaultConfig(), "ls", "--color=auto")
+//	fmt.Println(result.ExitStatus, result.Screen.Text())
+//
+// config.Binary and config.Args are replaced by argv, which is passed to
+// ht as its command exactly like them, without quoting; use Output to have
+// a shell interpret a command line. config.OnReady is ignored since there
+// is no shell prompt to wait for. A program that
+// exits with a non-zero status is not an error; check ExitStatus. If ctx
+// is done first, the program is killed and ctx.Err() is returned.
+func RunOnce(ctx context.Context, config Config, argv ...string) (*RunResult, error) {
+	if len(argv) == 0 {
+		return nil, fmt.Errorf("%w: no command given", ErrInvalidInput)
+	}
+	config.Binary = argv[0]
+	config.Args = argv[1:]
+	config.OnReady = nil
+
+	vt := New(config)
+	defer vt.Close()
+	return vt.RunUntilExit(ctx, nil)
+}
+
+// RunUntilExit starts the terminal, passes every event on Events() to
+// handle and returns once the program has exited, for one-shot programs
+// such as installers and dialogs that would otherwise be watched for the
+// events channel to close:
+//
+//	vt.AddTrigger(htlib.OutputContains("Install now?"), htlib.InputAction("y\n"))
+//	result, err := vt.RunUntilExit(ctx, func(e htlib.Event) { log.Println(e.Type()) })
+//	fmt.Println(result.ExitStatus, result.Snapshot.Text)
+//
+// handle may be nil. It is called in order from a single goroutine, up to
+// and including the ExitEvent, and must not block on the terminal. opts
+// are passed to Start. A program that exits with a non-zero status is not
+// an error; check ExitStatus. If ctx is done first, the terminal is closed,
+// killing the program, and ctx.Err() is returned.
+func (vt *VirtualTerminal) RunUntilExit(ctx context.Context, handle func(Event), opts ...StartOption) (*RunResult, error) {
+	start := time.Now()
+	if err := vt.Start(ctx, opts...); err != nil {
+		return nil, err
+	}
+
+	// Events() is drained so that ht is never held up, and closed once
+	// all output has been read
+	var output strings.Builder
+	events := vt.Events()
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		for event := range events {
+			if e, ok := event.(OutputEvent); ok {
+				output.WriteString(e.Seq)
+			}
+			if handle != nil {
+				handle(event)
+			}
+		}
+	}()
+
+	status, err := vt.WaitForExit(ctx)
+	if err != nil {
+		vt.Close()
+		<-drained
+		return nil, err
+	}
+	duration := time.Since(start)
+	<-drained
+
+	screen := vt.Screen()
+	return &RunResult{
+		Screen: screen,
+		Snapshot: &SnapshotEvent{
+			Cols:   screen.Cols,
+			Rows:   screen.Rows,
+			Seq:    vt.screen.dump(),
+			Text:   vt.Normalize(screen.Text()),
+			Cursor: screen.Cursor,
+			Time:   time.Now(),
+		},
+		Output:     output.String(),
+		Duration:   duration,
+		ExitStatus: status,
+	}, nil
+}
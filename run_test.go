@@ -0,0 +1,80 @@
+package htlib
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunOnce(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := RunOnce(ctx, DefaultConfig(), "sh", "-c", `printf '\033[1mbuilt\033[0m\n'; [ -t 1 ] && echo tty; exit 3`)
+	if err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	if result.ExitStatus.Code != 3 {
+		t.Errorf("expected exit code 3, got %s", result.ExitStatus)
+	}
+	if !strings.Contains(result.Output, "\x1b[1mbuilt") {
+		t.Errorf("expected raw output with escape sequences, got %q", result.Output)
+	}
+	if text := result.Text(); !strings.Contains(text, "built\ntty\n") {
+		t.Errorf("expected plain text output on a tty, got %q", text)
+	}
+	if !strings.Contains(result.Screen.Text(), "tty") {
+		t.Errorf("expected the final screen, got %q", result.Screen.Text())
+	}
+	if result.Duration <= 0 {
+		t.Errorf("expected a duration, got %s", result.Duration)
+	}
+}
+
+func TestRunOnceErrors(t *testing.T) {
+	if _, err := RunOnce(context.Background(), DefaultConfig()); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput without a command, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := RunOnce(ctx, DefaultConfig(), "sleep", "10"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the context error, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Error("the program was not stopped")
+	}
+}
+
+func TestRunUntilExit(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	config := DefaultConfig()
+	config.Binary = "/bin/sh"
+	config.Args = []string{"-c", `printf 'Install now? '; read answer; echo "answer: $answer"; exit 4`}
+	vt := New(config)
+	defer vt.Close()
+	vt.AddTrigger(OutputContains("Install now?"), InputAction("y\n"), TriggerOnce())
+
+	var types []EventType
+	result, err := vt.RunUntilExit(ctx, func(e Event) { types = append(types, e.Type()) })
+	if err != nil {
+		t.Fatalf("RunUntilExit failed: %v", err)
+	}
+	if result.ExitStatus.Code != 4 {
+		t.Errorf("expected exit code 4, got %s", result.ExitStatus)
+	}
+	if !strings.Contains(result.Snapshot.Text, "answer: y") || !strings.Contains(result.Screen.Text(), "answer: y") {
+		t.Errorf("expected the final screen, got %q", result.Snapshot.Text)
+	}
+	if screen := result.Snapshot.Screen(); screen.Text() != result.Screen.Text() {
+		t.Errorf("expected the snapshot to render the final screen, got %q", screen.Text())
+	}
+	if len(types) < 2 || types[0] != EventTypeInit || types[len(types)-1] != EventTypeExit {
+		t.Errorf("expected every event from init to exit, got %v", types)
+	}
+}
@@ -0,0 +1,314 @@
+// Package s3storage provides an htlib.Storage backed by an S3-compatible
+// object store:
+//
+//	store := &s3storage.Storage{
+//	    Endpoint:        "https://s3.us-east-1.amazonaws.com",
+//	    Bucket:          "ci-artifacts",
+//	    AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+//	    SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+//	}
+//	err := recording.Save(ctx, store, "run-42/session.cast")
+//
+// It lives outside the core htlib package so that only users who import it
+// carry an S3 client.
+package s3storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	htlib "github.com/io41/htlib.go"
+)
+
+// Storage stores objects in an S3-compatible object store (AWS S3, MinIO,
+// Ceph and others) using path-style requests signed with AWS Signature
+// Version 4. Objects are buffered in memory and uploaded with a single PUT
+// when the writer returned by Create is closed.
+type Storage struct {
+	// Endpoint is the base URL of the service, e.g. "https://s3.us-east-1.amazonaws.com"
+	Endpoint string
+	// Region is the signing region (default: "us-east-1")
+	Region string
+	// Bucket is the bucket holding the objects
+	Bucket string
+	// Prefix is prepended to every object name, e.g. "sessions/"
+	Prefix string
+	// AccessKeyID and SecretAccessKey are the credentials used to sign requests
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is the optional token for temporary credentials
+	SessionToken string
+	// Client is the HTTP client to use (default: http.DefaultClient)
+	Client *http.Client
+
+	// now returns the signing time; tests override it
+	now func() time.Time
+}
+
+// validateName checks that name is usable as a storage object name, as
+// htlib.Storage requires.
+func validateName(name string) error {
+	if name == "." || !fs.ValidPath(name) {
+		return fmt.Errorf("%w: invalid storage name %q", htlib.ErrInvalidInput, name)
+	}
+	return nil
+}
+
+// Create buffers writes and uploads the object on Close.
+func (s *Storage) Create(ctx context.Context, name string) (io.WriteCloser, error) {
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return &writer{ctx: ctx, storage: s, name: name}, nil
+}
+
+// writer uploads its buffer on Close.
+type writer struct {
+	bytes.Buffer
+	ctx     context.Context
+	storage *Storage
+	name    string
+}
+
+func (w *writer) Close() error {
+	resp, err := w.storage.do(w.ctx, http.MethodPut, w.storage.Prefix+w.name, nil, w.Bytes())
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Open downloads the object.
+func (s *Storage) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+	resp, err := s.do(ctx, http.MethodGet, s.Prefix+name, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// List pages through ListObjectsV2 results for the prefix.
+func (s *Storage) List(ctx context.Context, prefix string) ([]string, error) {
+	var result struct {
+		Contents []struct {
+			Key string
+		}
+		IsTruncated           bool
+		NextContinuationToken string
+	}
+
+	var names []string
+	query := url.Values{"list-type": {"2"}, "prefix": {s.Prefix + prefix}}
+	for {
+		resp, err := s.do(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode object list: %w", err)
+		}
+
+		for _, object := range result.Contents {
+			names = append(names, strings.TrimPrefix(object.Key, s.Prefix))
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+		result.Contents = nil
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+// Remove deletes the object.
+func (s *Storage) Remove(ctx context.Context, name string) error {
+	if err := validateName(name); err != nil {
+		return err
+	}
+	resp, err := s.do(ctx, http.MethodDelete, s.Prefix+name, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// do sends a signed request for key (or the bucket itself if key is empty)
+// and converts error responses to errors. A 404 for an object matches
+// fs.ErrNotExist.
+func (s *Storage) do(ctx context.Context, method, key string, query url.Values, body []byte) (*http.Response, error) {
+	endpoint, err := url.Parse(strings.TrimSuffix(s.Endpoint, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3 endpoint: %w", err)
+	}
+	endpoint.Path += "/" + s.Bucket
+	if key != "" {
+		endpoint.Path += "/" + key
+	}
+	endpoint.RawPath = s3EscapePath(endpoint.Path)
+	endpoint.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+	s.sign(req, body)
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = fmt.Errorf("S3 %s %s: %s: %s", method, key, resp.Status, bytes.TrimSpace(detail))
+	if resp.StatusCode == http.StatusNotFound && key != "" {
+		if method == http.MethodDelete {
+			return resp, nil
+		}
+		err = &fs.PathError{Op: strings.ToLower(method), Path: key, Err: fs.ErrNotExist}
+	}
+	return nil, err
+}
+
+// sign adds AWS Signature Version 4 headers to req. All headers already set
+// on the request are signed, together with the host.
+func (s *Storage) sign(req *http.Request, body []byte) {
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	t := now().UTC()
+	date := t.Format("20060102")
+	region := s.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	payloadHash := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Date", t.Format("20060102T150405Z"))
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+
+	// Canonical headers: lowercase names, sorted, with trimmed values
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		s3CanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + t.Format("20060102T150405Z") + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with key.
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Escape percent-encodes s as required by Signature Version 4, leaving
+// only unreserved characters (and slashes, if keepSlash is set) unescaped.
+func s3Escape(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', keepSlash && c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// s3EscapePath returns the canonical URI for path.
+func s3EscapePath(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return s3Escape(path, true)
+}
+
+// s3CanonicalQuery returns the canonical query string for query.
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, s3Escape(key, false)+"="+s3Escape(value, false))
+		}
+	}
+	return strings.Join(parts, "&")
+}
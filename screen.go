@@ -0,0 +1,122 @@
+package htlib
+
+import "strings"
+
+// Cell is one character cell of the screen: the character and the colors
+// and attributes it was printed with.
+type Cell struct {
+	// Rune is the character shown in the cell, ' ' if it is empty. For a
+	// grapheme cluster of several characters it is the first one, and it is
+	// 0 in the cell covered by the right half of a wide character.
+	Rune rune
+	// Grapheme is the whole cluster when it is more than one character,
+	// such as a letter with combining accents or an emoji ZWJ sequence
+	Grapheme string
+	Style
+}
+
+// String returns the text shown in the cell, which is empty for the right
+// half of a wide character.
+func (c Cell) String() string {
+	switch {
+	case c.Grapheme != "":
+		return c.Grapheme
+	case c.Rune == 0:
+		return ""
+	default:
+		return string(c.Rune)
+	}
+}
+
+// blankCell is the content of erased cells.
+var blankCell = Cell{Rune: ' '}
+
+// Modes are the terminal modes set by the program running in the terminal.
+type Modes struct {
+	// AltScreen is set while the alternate screen buffer is shown, as in
+	// full-screen programs like vim or less
+	AltScreen bool
+	// ApplicationCursorKeys is set when cursor keys send SS3 sequences (DECCKM)
+	ApplicationCursorKeys bool
+	// ApplicationKeypad is set when the keypad sends application sequences (DECKPAM)
+	ApplicationKeypad bool
+	// AutoWrap is set when printing past the last column wraps to the next line (DECAWM)
+	AutoWrap bool
+	// Insert is set when printed characters shift the rest of the line right (IRM)
+	Insert bool
+	// LineFeedNewLine is set when line feeds also return the carriage (LNM)
+	LineFeedNewLine bool
+	// Origin is set when cursor positions are relative to the scroll region (DECOM)
+	Origin bool
+	// BracketedPaste is set when the program wants pasted text bracketed
+	BracketedPaste bool
+	// MouseTracking is set when the program has asked for mouse events
+	MouseTracking bool
+	// KittyKeyboard holds the kitty keyboard protocol flags the program
+	// enabled with CSI > flags u, or 0 for legacy key encoding
+	KittyKeyboard int
+}
+
+// Screen is the state of the terminal as maintained locally by parsing the
+// output stream, without asking ht for a snapshot.
+type Screen struct {
+	Cols   int
+	Rows   int
+	Cursor Cursor
+	Modes  Modes
+	// Title is the window title last set with OSC 0 or OSC 2
+	Title string
+
+	cells [][]Cell
+}
+
+// Cell returns the cell at the 0-based row and column, or an empty cell if
+// the position is off the screen:
+//
+//	if cell := screen.Cell(row, col); cell.Fg == htlib.ColorRed && cell.Bold {
+//	    // a failure marker
+//	}
+func (s *Screen) Cell(row, col int) Cell {
+	if row < 0 || row >= len(s.cells) || col < 0 || col >= len(s.cells[row]) {
+		return blankCell
+	}
+	return s.cells[row][col]
+}
+
+// Text returns the screen contents as lines separated by "\n", with
+// trailing spaces removed from each line.
+func (s *Screen) Text() string {
+	var b strings.Builder
+	for row := range s.cells {
+		if row > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(s.line(row))
+	}
+	return b.String()
+}
+
+// line returns the text of row without trailing spaces.
+func (s *Screen) line(row int) string {
+	var b strings.Builder
+	for _, cell := range s.cells[row] {
+		b.WriteString(cell.String())
+	}
+	return strings.TrimRight(b.String(), " ")
+}
+
+// Screen returns the current state of the terminal. It is maintained
+// locally from the output stream, so it is available instantly and without
+// the round trip of WaitForSnapshot. The returned Screen is a copy and is
+// not updated afterwards.
+func (vt *VirtualTerminal) Screen() *Screen {
+	return vt.screen.snapshot()
+}
+
+// Screen parses the snapshot's raw sequence into a Screen, which gives
+// access to the colors and attributes of each cell.
+func (e SnapshotEvent) Screen() *Screen {
+	m := newScreenModel(e.Cols, e.Rows)
+	m.feed(e.Seq)
+	return m.snapshot()
+}
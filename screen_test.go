@@ -0,0 +1,113 @@
+package htlib
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScreenCell(t *testing.T) {
+	m := newScreenModel(4, 2)
+	m.feed("ab\r\n  é")
+	screen := m.snapshot()
+
+	tests := []struct {
+		row, col int
+		expected rune
+	}{
+		{0, 0, 'a'},
+		{0, 1, 'b'},
+		{0, 2, ' '},
+		{1, 2, 'é'},
+		{-1, 0, ' '},
+		{2, 0, ' '},
+		{0, 4, ' '},
+	}
+	for _, tt := range tests {
+		if got := screen.Cell(tt.row, tt.col).Rune; got != tt.expected {
+			t.Errorf("Cell(%d, %d): expected %q, got %q", tt.row, tt.col, tt.expected, got)
+		}
+	}
+
+	// The returned screen is a copy
+	m.feed("\x1b[Hz")
+	if screen.Cell(0, 0).Rune != 'a' {
+		t.Error("screen changed after it was returned")
+	}
+}
+
+func TestVirtualTerminalScreen(t *testing.T) {
+	vt, _ := newTestTerminal()
+	defer vt.Close()
+
+	if screen := vt.Screen(); screen.Cols != 120 || screen.Rows != 40 {
+		t.Errorf("expected the configured size before init, got %dx%d", screen.Cols, screen.Rows)
+	}
+
+	vt.trackEvent(InitEvent{Cols: 20, Rows: 5, Seq: "\x1b[2J\x1b[Hwelcome"})
+	vt.trackEvent(OutputEvent{Seq: "\r\n$ ls\r\nfile.txt\r\n$ "})
+	vt.trackEvent(ResizeEvent{Cols: 30, Rows: 5})
+
+	screen := vt.Screen()
+	if screen.Cols != 30 || screen.Rows != 5 {
+		t.Errorf("expected 30x5, got %dx%d", screen.Cols, screen.Rows)
+	}
+	if !strings.HasPrefix(screen.Text(), "welcome\n$ ls\nfile.txt\n$") {
+		t.Errorf("unexpected text %q", screen.Text())
+	}
+	if screen.Cursor != (Cursor{Row: 3, Col: 2, Visible: true}) {
+		t.Errorf("unexpected cursor %+v", screen.Cursor)
+	}
+}
+
+func TestScreenFollowsShell(t *testing.T) {
+	vt := New(DefaultConfig())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := vt.Start(ctx, WaitReady()); err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+	defer vt.Close()
+
+	if err := vt.Input(ctx, "echo screen-$((6*7))\n"); err != nil {
+		t.Fatalf("failed to send input: %v", err)
+	}
+	if _, err := vt.WaitForText(ctx, "screen-42"); err != nil {
+		t.Fatalf("output did not appear: %v", err)
+	}
+	// Output events are processed before the snapshot showing them
+	if !strings.Contains(vt.Screen().Text(), "screen-42") {
+		t.Errorf("local screen does not show the output:\n%s", vt.Screen().Text())
+	}
+}
+
+func TestScreenCellStyle(t *testing.T) {
+	snapshot := SnapshotEvent{
+		Cols: 20,
+		Rows: 2,
+		Seq:  "\x1b[32mPASS\x1b[0m ok\r\n\x1b[1;31mFAIL\x1b[7m!\x1b[m\x1b[44m\x1b[K",
+	}
+	screen := snapshot.Screen()
+
+	tests := []struct {
+		row, col int
+		expected Cell
+	}{
+		{0, 0, Cell{Rune: 'P', Style: Style{Fg: ColorGreen}}},
+		{0, 5, Cell{Rune: 'o'}},
+		{1, 0, Cell{Rune: 'F', Style: Style{Fg: ColorRed, Bold: true}}},
+		{1, 4, Cell{Rune: '!', Style: Style{Fg: ColorRed, Bold: true, Reverse: true}}},
+		// Erasing keeps the current background color
+		{1, 10, Cell{Rune: ' ', Style: Style{Bg: ColorBlue}}},
+	}
+	for _, tt := range tests {
+		if got := screen.Cell(tt.row, tt.col); got != tt.expected {
+			t.Errorf("Cell(%d, %d): expected %+v, got %+v", tt.row, tt.col, tt.expected, got)
+		}
+	}
+	if screen.Text() != "PASS ok\nFAIL!" {
+		t.Errorf("unexpected text %q", screen.Text())
+	}
+}
@@ -0,0 +1,234 @@
+package htlib
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// DefaultScrollbackLines is the number of output lines retained when
+// Config.ScrollbackLines is zero.
+const DefaultScrollbackLines = 10000
+
+// ScrollbackPage is one fixed-size page of retained output history.
+type ScrollbackPage struct {
+	Page     int      // Page index, starting at 0 for the first line ever written
+	PageSize int      // Number of lines per page
+	First    int      // Absolute index of the first line in Lines
+	Lines    []string // Lines on this page, ANSI sequences removed
+	Total    int      // Total number of lines written so far
+	Oldest   int      // Absolute index of the oldest line still retained
+	Limit    int      // Number of complete lines retained at most
+}
+
+// ReadScrollback returns a page of the terminal's output history. Lines are
+// numbered from 0 in the order they were written and keep their index for
+// the life of the session, so page p always covers lines
+// [p*pageSize, (p+1)*pageSize). Lines that have been evicted because of the
+// Config.ScrollbackLines limit are omitted; the line currently being written
+// is included once it has content.
+//
+// History is rebuilt locally from OutputEvents: escape sequences are
+// removed and carriage returns overwrite the current line, so the result
+// matches what a plain text log of the session would show. ht only reports
+// the visible screen, so its own scrollback options, which can be set with
+// Config.HtArgs, do not change what is kept here.
+func (vt *VirtualTerminal) ReadScrollback(ctx context.Context, page, pageSize int) (*ScrollbackPage, error) {
+	if page < 0 || pageSize <= 0 {
+		return nil, fmt.Errorf("invalid page %d with size %d", page, pageSize)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return vt.history.page(page, pageSize), nil
+}
+
+// lineHistory accumulates plain-text output lines with a bounded capacity.
+type lineHistory struct {
+	mu      sync.Mutex
+	limit   int
+	lines   []string
+	evicted int // number of lines dropped from the front
+	current []rune
+	col     int
+
+	// escape holds an escape sequence split across output events
+	escape string
+	// raw keeps tabs and trailing spaces, for a transcript to apply
+	// Config.Normalization to
+	raw bool
+	// echo is the text of an input whose echoes are shown as display once
+	// their line is complete, see expectEcho
+	echo, display string
+}
+
+// expectEcho makes lines containing echo, as the shell echoes a typed
+// input, show display in its place until the next call. echo may be echoed
+// more than once: by the terminal while the shell is busy, and again by
+// the shell's line editor, so it must be unique, as RunCommand's markers
+// make it.
+func (h *lineHistory) expectEcho(echo, display string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.echo, h.display = echo, display
+}
+
+// finish returns a complete line as it is kept: cleaned as by clean, and
+// with an expected echo replaced.
+func (h *lineHistory) finish(line string) string {
+	if h.echo != "" {
+		line = strings.ReplaceAll(line, h.echo, h.display)
+	}
+	return h.clean(line)
+}
+
+// clean removes RunCommand markers and, unless raw is set, trailing spaces.
+func (h *lineHistory) clean(line string) string {
+	line = stripMarkers(line)
+	if h.raw {
+		return line
+	}
+	return strings.TrimRight(line, " ")
+}
+
+// pending reports whether a line has been started but not finished.
+func (h *lineHistory) pending() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.current) > 0
+}
+
+// write appends a chunk of raw terminal output.
+func (h *lineHistory) write(seq string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	seq = h.escape + seq
+	h.escape = ""
+
+	for i := 0; i < len(seq); {
+		if seq[i] == 0x1b {
+			end := skipEscape(seq, i)
+			if end >= len(seq) && !escapeComplete(seq[i:]) {
+				h.escape = seq[i:]
+				return
+			}
+			i = end
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(seq[i:])
+		i += size
+
+		switch r {
+		case '\n':
+			h.newline()
+		case '\r':
+			h.col = 0
+		case '\b':
+			if h.col > 0 {
+				h.col--
+			}
+		case '\t':
+			if h.raw {
+				h.put('\t')
+				break
+			}
+			for {
+				h.put(' ')
+				if h.col%8 == 0 {
+					break
+				}
+			}
+		default:
+			if r >= 0x20 && r != 0x7f {
+				h.put(r)
+			}
+		}
+	}
+}
+
+// put writes r at the current column, overwriting existing characters.
+func (h *lineHistory) put(r rune) {
+	if h.col < len(h.current) {
+		h.current[h.col] = r
+	} else {
+		for len(h.current) < h.col {
+			h.current = append(h.current, ' ')
+		}
+		h.current = append(h.current, r)
+	}
+	h.col++
+}
+
+// newline finishes the current line and enforces the retention limit.
+func (h *lineHistory) newline() {
+	h.lines = append(h.lines, h.finish(string(h.current)))
+	h.current = h.current[:0]
+	h.col = 0
+
+	// Compact only once the buffer has grown to twice the limit, so that
+	// eviction does not copy the whole history on every line
+	if limit := h.capacity(); len(h.lines) >= 2*limit {
+		excess := len(h.lines) - limit
+		h.lines = append([]string(nil), h.lines[excess:]...)
+		h.evicted += excess
+	}
+}
+
+// capacity returns the number of complete lines to retain.
+func (h *lineHistory) capacity() int {
+	if h.limit <= 0 {
+		return DefaultScrollbackLines
+	}
+	return h.limit
+}
+
+// snapshot returns the retained lines (including a non-empty current line)
+// and the absolute index of the first one.
+func (h *lineHistory) snapshot() ([]string, int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	lines, evicted := h.lines, h.evicted
+	if excess := len(lines) - h.capacity(); excess > 0 {
+		lines = lines[excess:]
+		evicted += excess
+	}
+
+	lines = append([]string(nil), lines...)
+	if len(h.current) > 0 {
+		lines = append(lines, h.clean(string(h.current)))
+	}
+	return lines, evicted
+}
+
+// page returns the lines of one page.
+func (h *lineHistory) page(page, pageSize int) *ScrollbackPage {
+	lines, oldest := h.snapshot()
+	total := oldest + len(lines)
+
+	result := &ScrollbackPage{
+		Page:     page,
+		PageSize: pageSize,
+		Total:    total,
+		Oldest:   oldest,
+		Limit:    h.capacity(),
+	}
+
+	start := page * pageSize
+	end := start + pageSize
+	if start < oldest {
+		start = oldest
+	}
+	if end > total {
+		end = total
+	}
+	result.First = start
+	if start < end {
+		result.Lines = lines[start-oldest : end-oldest]
+	}
+	return result
+}
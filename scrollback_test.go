@@ -0,0 +1,82 @@
+package htlib
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestLineHistory(t *testing.T) {
+	var h lineHistory
+	h.write("$ echo hi\r\nhi\r\n")
+	h.write("progress 10%\rprogress 99%\rdone        \r\n")
+	h.write("\x1b[1mbo")
+	h.write("ld\x1b[0m\r\n$ ")
+
+	lines, oldest := h.snapshot()
+	expected := []string{"$ echo hi", "hi", "done", "bold", "$"}
+	if oldest != 0 || !reflect.DeepEqual(lines, expected) {
+		t.Errorf("expected %q from 0, got %q from %d", expected, lines, oldest)
+	}
+}
+
+func TestLineHistoryEcho(t *testing.T) {
+	var h lineHistory
+	h.expectEcho("printf x; true; printf y", "true")
+	h.write("$ printf x; true; printf y\r\n")
+	h.write("<<htlib-begin:ab12>>\x1b8\x1b[Kok\r\n<<htlib-end:ab12:0>>\r\n$ ")
+
+	lines, _ := h.snapshot()
+	expected := []string{"$ true", "ok", "", "$"}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("expected %q, got %q", expected, lines)
+	}
+}
+
+func TestLineHistoryLimit(t *testing.T) {
+	h := lineHistory{limit: 3}
+	for _, line := range []string{"a", "b", "c", "d", "e"} {
+		h.write(line + "\n")
+	}
+
+	lines, oldest := h.snapshot()
+	if oldest != 2 || !reflect.DeepEqual(lines, []string{"c", "d", "e"}) {
+		t.Errorf("expected [c d e] from 2, got %q from %d", lines, oldest)
+	}
+}
+
+func TestReadScrollback(t *testing.T) {
+	vt := New(Config{ScrollbackLines: 5})
+	for _, line := range []string{"0", "1", "2", "3", "4", "5", "6"} {
+		vt.history.write(line + "\n")
+	}
+	ctx := context.Background()
+
+	tests := []struct {
+		page     int
+		first    int
+		expected []string
+	}{
+		{page: 0, first: 2, expected: []string{"2"}},
+		{page: 1, first: 3, expected: []string{"3", "4", "5"}},
+		{page: 2, first: 6, expected: []string{"6"}},
+		{page: 3, first: 9, expected: nil},
+	}
+
+	for _, tt := range tests {
+		page, err := vt.ReadScrollback(ctx, tt.page, 3)
+		if err != nil {
+			t.Fatalf("page %d: unexpected error %v", tt.page, err)
+		}
+		if page.First != tt.first || !reflect.DeepEqual(page.Lines, tt.expected) {
+			t.Errorf("page %d: expected %q from %d, got %q from %d", tt.page, tt.expected, tt.first, page.Lines, page.First)
+		}
+		if page.Total != 7 || page.Oldest != 2 || page.Limit != 5 {
+			t.Errorf("page %d: expected total 7, oldest 2 and limit 5, got %d, %d and %d", tt.page, page.Total, page.Oldest, page.Limit)
+		}
+	}
+
+	if _, err := vt.ReadScrollback(ctx, 0, 0); err == nil {
+		t.Error("expected error for zero page size")
+	}
+}
@@ -0,0 +1,62 @@
+package htlib
+
+import (
+	"regexp"
+)
+
+// SearchMatch is a match found by Search.
+type SearchMatch struct {
+	// Scrollback is set for matches in the output history and unset for
+	// matches on the visible screen
+	Scrollback bool
+	// Row is the 0-based screen row, or for scrollback matches the line
+	// index as used by ReadScrollback
+	Row int
+	// Col is the 0-based column where the match starts
+	Col int
+	// Text is the matched text and Line the whole line containing it
+	Text string
+	Line string
+}
+
+// Search returns all matches of pattern in the retained scrollback, oldest
+// first, followed by those on the visible screen, top to bottom. Use
+// regexp.QuoteMeta to search for literal text:
+//
+//	matches := vt.Search(regexp.MustCompile(`(?i)warning`))
+//	if len(matches) > 0 {
+//	    t.Errorf("warning at line %d: %s", matches[0].Row, matches[0].Line)
+//	}
+//
+// Lines are matched one at a time, without escape sequences and trailing
+// spaces. Output still on screen is usually also in the scrollback, so the
+// same text can be reported twice. Lines evicted because of
+// Config.ScrollbackLines are not searched.
+func (vt *VirtualTerminal) Search(pattern *regexp.Regexp) []SearchMatch {
+	var matches []SearchMatch
+
+	lines, oldest := vt.history.snapshot()
+	for i, line := range lines {
+		matches = appendMatches(matches, pattern, line, oldest+i, true)
+	}
+
+	screen := vt.Screen()
+	for row := range screen.cells {
+		matches = appendMatches(matches, pattern, screen.line(row), row, false)
+	}
+	return matches
+}
+
+// appendMatches appends the matches of pattern in line to matches.
+func appendMatches(matches []SearchMatch, pattern *regexp.Regexp, line string, row int, scrollback bool) []SearchMatch {
+	for _, loc := range pattern.FindAllStringIndex(line, -1) {
+		matches = append(matches, SearchMatch{
+			Scrollback: scrollback,
+			Row:        row,
+			Col:        StringWidth(line[:loc[0]]),
+			Text:       line[loc[0]:loc[1]],
+			Line:       line,
+		})
+	}
+	return matches
+}
@@ -0,0 +1,43 @@
+package htlib
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	vt := New(Config{ScrollbackLines: 3})
+	defer vt.Close()
+
+	// The warning has scrolled off the screen but is still in scrollback
+	output := "build\r\nwarning: old\r\nstep 1\r\nstep 2\r\n€ warning: new"
+	vt.history.write(output)
+	vt.screen.load(20, 2, "")
+	vt.screen.feed(output)
+
+	expected := []SearchMatch{
+		{Scrollback: true, Row: 1, Col: 0, Text: "warning", Line: "warning: old"},
+		{Scrollback: true, Row: 4, Col: 2, Text: "warning", Line: "€ warning: new"},
+		{Scrollback: false, Row: 1, Col: 2, Text: "warning", Line: "€ warning: new"},
+	}
+	if matches := vt.Search(regexp.MustCompile("warning")); !reflect.DeepEqual(matches, expected) {
+		t.Errorf("expected %+v, got %+v", expected, matches)
+	}
+
+	matches := vt.Search(regexp.MustCompile(`step \d`))
+	if len(matches) != 3 || matches[2].Scrollback || matches[2].Text != "step 2" || matches[2].Row != 0 {
+		t.Errorf("unexpected matches %+v", matches)
+	}
+
+	// Columns count screen cells, so wide characters count twice
+	vt.screen.feed("\r\n日本 warning")
+	if matches := vt.Search(regexp.MustCompile("warning")); matches[len(matches)-1].Col != 5 {
+		t.Errorf("expected the match after two wide characters at column 5, got %+v", matches)
+	}
+
+	// The oldest line has been evicted
+	if matches := vt.Search(regexp.MustCompile("build")); len(matches) != 0 {
+		t.Errorf("expected no matches in evicted lines, got %+v", matches)
+	}
+}
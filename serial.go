@@ -0,0 +1,223 @@
+package htlib
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"unicode/utf8"
+
+	"github.com/io41/htlib.go/htproto"
+)
+
+// defaultBaudRate is the speed of Config.SerialPort if BaudRate is unset,
+// the usual speed of U-Boot and Linux serial consoles.
+const defaultBaudRate = 115200
+
+// openSerialPort opens a serial device and configures its line. Tests
+// replace it to attach the terminal to an in-memory connection.
+var openSerialPort = func(name string, baud int) (io.ReadWriteCloser, error) {
+	port, err := os.OpenFile(name, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open serial port: %w", err)
+	}
+
+	// stty configures the terminal on its standard input, which works the
+	// same on Linux, macOS and the BSDs, whose stty flags for naming a
+	// device differ
+	stty := exec.Command("stty", strconv.Itoa(baud), "raw", "-echo", "clocal", "cs8", "-parenb", "-cstopb")
+	stty.Stdin = port
+	if out, err := stty.CombinedOutput(); err != nil {
+		port.Close()
+		return nil, fmt.Errorf("failed to configure serial port: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return port, nil
+}
+
+// spawnSerial connects to Config.SerialPort. The device takes the place of
+// the ht process: a serialBridge speaks ht's protocol on its behalf, so
+// events, the screen model and every helper built on them work unchanged.
+func (vt *VirtualTerminal) spawnSerial() (*htProcess, error) {
+	baud := vt.config.BaudRate
+	if baud == 0 {
+		baud = defaultBaudRate
+	}
+	port, err := openSerialPort(vt.config.SerialPort, baud)
+	if err != nil {
+		return nil, err
+	}
+
+	commands, stdin := io.Pipe()
+	stdout, events := io.Pipe()
+	bridge := &serialBridge{
+		port:     port,
+		commands: commands,
+		events:   events,
+		screen:   newScreenModel(vt.configSize()),
+		done:     make(chan struct{}),
+	}
+
+	go bridge.serve()
+	go bridge.read()
+
+	return &htProcess{
+		serial: bridge,
+		stdin:  stdin,
+		stdout: stdout,
+		events: vt.newEventReader(stdout),
+	}, nil
+}
+
+// serialBridge plays the part of ht for a serial device. It turns what the
+// device sends into output events and the commands written to the terminal
+// into bytes for the device. Serial lines have no window size, so resizes
+// only change the local screen, and mouse commands are ignored.
+type serialBridge struct {
+	port     io.ReadWriteCloser
+	commands *io.PipeReader
+
+	// mu orders event lines, and output with the snapshots taken of it
+	mu     sync.Mutex
+	events *io.PipeWriter
+	screen *screenModel
+
+	// done is closed once the connection has ended, err tells why
+	done chan struct{}
+	err  error
+}
+
+// emit writes one event line.
+func (b *serialBridge) emit(event htproto.Event) error {
+	line, err := htproto.AppendEvent(nil, event)
+	if err != nil {
+		return err
+	}
+	_, err = b.events.Write(line)
+	return err
+}
+
+// read sends the init event and forwards the output of the device until
+// the port is closed or fails, then ends the event stream.
+func (b *serialBridge) read() {
+	defer close(b.done)
+	defer b.events.Close()
+	defer b.port.Close()
+
+	screen := b.screen.snapshot()
+	if err := b.emit(htproto.InitEvent{Cols: screen.Cols, Rows: screen.Rows}); err != nil {
+		return
+	}
+
+	var pending []byte
+	buf := make([]byte, 4096)
+	for {
+		n, err := b.port.Read(buf)
+		if n > 0 {
+			var seq []byte
+			seq, pending = splitUTF8(append(pending, buf[:n]...))
+			b.mu.Lock()
+			b.screen.feed(string(seq))
+			emitErr := b.emit(htproto.OutputEvent{Seq: string(seq)})
+			b.mu.Unlock()
+			if emitErr != nil {
+				err = emitErr
+			}
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, os.ErrClosed) && !errors.Is(err, io.ErrClosedPipe) {
+				b.err = fmt.Errorf("serial port: %w", err)
+			}
+			return
+		}
+	}
+}
+
+// serve executes the commands written to the terminal. Once they end,
+// because the terminal was closed, it closes the port and the event stream,
+// which ends read even if nobody reads the events any more.
+func (b *serialBridge) serve() {
+	defer b.events.Close()
+	defer b.port.Close()
+	defer b.commands.Close()
+
+	scanner := bufio.NewScanner(b.commands)
+	for scanner.Scan() {
+		cmd, err := htproto.ParseCommand(scanner.Bytes())
+		if err != nil {
+			continue
+		}
+		if err := b.execute(cmd); err != nil {
+			return
+		}
+	}
+}
+
+// execute performs one command.
+func (b *serialBridge) execute(cmd command) error {
+	switch cmd.Type {
+	case htproto.CommandInput:
+		_, err := io.WriteString(b.port, cmd.Payload)
+		return err
+	case htproto.CommandSendKeys:
+		modes := b.screen.snapshot().Modes
+		var seq strings.Builder
+		for _, key := range cmd.Keys {
+			if s, ok := AutoKeys().EncodeKey(key, modes); ok {
+				seq.WriteString(s)
+			}
+		}
+		_, err := io.WriteString(b.port, seq.String())
+		return err
+	case htproto.CommandResize:
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.screen.setSize(cmd.Cols, cmd.Rows)
+		return b.emit(htproto.ResizeEvent{Cols: cmd.Cols, Rows: cmd.Rows})
+	case htproto.CommandTakeSnapshot:
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		screen := b.screen.snapshot()
+		return b.emit(htproto.SnapshotEvent{
+			Cols: screen.Cols,
+			Rows: screen.Rows,
+			Seq:  b.screen.dump(),
+			Text: screen.Text(),
+		})
+	}
+	return nil
+}
+
+// close ends the connection.
+func (b *serialBridge) close() {
+	b.commands.Close()
+	b.port.Close()
+	b.events.Close()
+	<-b.done
+}
+
+// wait blocks until the connection has ended and returns the error that
+// ended it, if it was not closed deliberately.
+func (b *serialBridge) wait() error {
+	<-b.done
+	return b.err
+}
+
+// splitUTF8 splits data before a character that is cut off at its end, so
+// that a character split across reads is not sent as two broken halves.
+func splitUTF8(data []byte) (complete, rest []byte) {
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				return data[:i], append([]byte(nil), data[i:]...)
+			}
+			break
+		}
+	}
+	return data, nil
+}
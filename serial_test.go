@@ -0,0 +1,119 @@
+package htlib
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSerialPort replaces the serial device with an in-memory connection
+// for the duration of the test and returns the device's end.
+func fakeSerialPort(t *testing.T) net.Conn {
+	t.Helper()
+	host, device := net.Pipe()
+	saved := openSerialPort
+	openSerialPort = func(name string, baud int) (io.ReadWriteCloser, error) {
+		if name != "/dev/ttyUSB0" || baud != defaultBaudRate {
+			t.Errorf("unexpected port %s at %d baud", name, baud)
+		}
+		return host, nil
+	}
+	t.Cleanup(func() {
+		openSerialPort = saved
+		device.Close()
+	})
+	return device
+}
+
+func TestSerialPort(t *testing.T) {
+	device := fakeSerialPort(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	vt := New(Config{SerialPort: "/dev/ttyUSB0", Cols: 40, Rows: 5})
+	defer vt.Close()
+	if err := vt.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// A character split across reads arrives whole
+	go func() {
+		io.WriteString(device, "U-Boot 2024.01 \xe2\x82")
+		io.WriteString(device, "\xac\r\n=> ")
+	}()
+	if _, err := vt.WaitForText(ctx, "U-Boot 2024.01 €"); err != nil {
+		t.Fatal(err)
+	}
+	if line := vt.Screen().Line(1); line != "=>" {
+		t.Errorf("unexpected prompt line %q", line)
+	}
+
+	reader := bufio.NewReader(device)
+	if err := vt.Input(ctx, "printenv\n"); err != nil {
+		t.Fatal(err)
+	}
+	if line, err := reader.ReadString('\n'); err != nil || line != "printenv\n" {
+		t.Errorf("expected the input on the device, got %q, %v", line, err)
+	}
+	if err := vt.SendKeys(ctx, "C-c", KeyUp); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 4)
+	if n, err := io.ReadAtLeast(reader, buf, 4); err != nil || string(buf[:n]) != "\x03\x1b[A" {
+		t.Errorf("expected encoded keys on the device, got %q, %v", buf[:n], err)
+	}
+
+	snapshot, err := vt.WaitForSnapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(snapshot.Text, "U-Boot 2024.01 €\n=>") || snapshot.Cols != 40 {
+		t.Errorf("unexpected snapshot %+v", snapshot)
+	}
+
+	// Unplugging the device ends the session without an error
+	device.Close()
+	status, err := vt.WaitForExit(ctx)
+	if err != nil || status.Code != 0 || vt.Err() != nil {
+		t.Errorf("unexpected exit %+v, %v, %v", status, err, vt.Err())
+	}
+}
+
+func TestSerialPortClose(t *testing.T) {
+	device := fakeSerialPort(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	vt := New(Config{SerialPort: "/dev/ttyUSB0"})
+	if err := vt.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// Output nobody reads must not keep Close from returning
+	go io.WriteString(device, strings.Repeat("boot log\r\n", 1000))
+	if err := vt.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := device.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("expected the port to be closed, got %v", err)
+	}
+}
+
+func TestSplitUTF8(t *testing.T) {
+	tests := []struct{ data, complete, rest string }{
+		{"abc", "abc", ""},
+		{"ab\xe2\x82", "ab", "\xe2\x82"},
+		{"ab\xe2\x82\xac", "ab\xe2\x82\xac", ""},
+		{"\xf0\x9f", "", "\xf0\x9f"},
+		{"ab\x82", "ab\x82", ""},
+	}
+	for _, tt := range tests {
+		complete, rest := splitUTF8([]byte(tt.data))
+		if string(complete) != tt.complete || string(rest) != tt.rest {
+			t.Errorf("splitUTF8(%q): got %q, %q", tt.data, complete, rest)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package htlib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Signal sends sig to the program ht started in the terminal, using the
+// process ID from the InitEvent:
+//
+//	err := vt.Signal(syscall.SIGHUP)
+//
+// Unlike typing Ctrl-C with SendKeys, signals arrive even when the program
+// reads the terminal in raw mode or ignores its input. To reach a command
+// a shell is running instead of the shell itself, use SignalForeground.
+//
+// Signal returns an error matching ErrNotStarted until the InitEvent has
+// been received, ErrProcessExited once the program has ended, and
+// ErrUnsupported for Config.SerialPort, where there is no local process.
+func (vt *VirtualTerminal) Signal(sig os.Signal) error {
+	pid, err := vt.livePID()
+	if err != nil {
+		return err
+	}
+	proc, err := os.FindProcess(pid)
+	if err == nil {
+		err = proc.Signal(sig)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to signal process %d: %w", pid, err)
+	}
+	return nil
+}
+
+// SignalForeground sends sig to the terminal's foreground process group,
+// the processes a key such as Ctrl-C would signal: the command a shell is
+// running, or the shell itself at its prompt. Where the foreground group
+// cannot be read from /proc, sig is sent to the program as with Signal.
+func (vt *VirtualTerminal) SignalForeground(sig os.Signal) error {
+	pid, err := vt.livePID()
+	if err != nil {
+		return err
+	}
+	s, ok := sig.(syscall.Signal)
+	pgid, found := foregroundGroup(pid)
+	if !ok || !found {
+		return vt.Signal(sig)
+	}
+	if err := signalGroup(pgid, s); err != nil {
+		return fmt.Errorf("failed to signal process group %d: %w", pgid, err)
+	}
+	return nil
+}
+
+// Interrupt sends SIGINT to the terminal's foreground process group, like
+// Ctrl-C but independent of the terminal's settings.
+func (vt *VirtualTerminal) Interrupt() error {
+	return vt.SignalForeground(syscall.SIGINT)
+}
+
+// Terminate sends SIGTERM to the program ht started in the terminal.
+func (vt *VirtualTerminal) Terminate() error {
+	return vt.Signal(syscall.SIGTERM)
+}
+
+// livePID returns the process ID of the program running in the terminal,
+// or why there is none.
+func (vt *VirtualTerminal) livePID() (int, error) {
+	vt.mu.RLock()
+	defer vt.mu.RUnlock()
+
+	switch {
+	case !vt.started:
+		return 0, ErrNotStarted
+	case vt.closed:
+		return 0, ErrClosed
+	case vt.proc != nil && vt.proc.serial != nil:
+		return 0, fmt.Errorf("%w: serial devices have no process to signal", ErrUnsupported)
+	}
+	select {
+	case <-vt.sess.exited:
+		return 0, ErrProcessExited
+	default:
+	}
+	if vt.pid == 0 {
+		return 0, fmt.Errorf("%w: process ID not known before the InitEvent", ErrNotStarted)
+	}
+	return vt.pid, nil
+}
+
+// foregroundGroup returns the foreground process group of the terminal
+// that pid is attached to.
+func foregroundGroup(pid int) (int, bool) {
+	stat, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return 0, false
+	}
+	return parseStatTPGID(string(stat))
+}
+
+// parseStatTPGID extracts the foreground process group of the process's
+// terminal from a /proc/<pid>/stat line.
+func parseStatTPGID(stat string) (int, bool) {
+	end := strings.LastIndexByte(stat, ')')
+	if end < 0 {
+		return 0, false
+	}
+	// Fields after the command name start at field 3 (state); tpgid is 8
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 6 {
+		return 0, false
+	}
+	tpgid, err := strconv.Atoi(fields[5])
+	if err != nil || tpgid <= 0 {
+		return 0, false
+	}
+	return tpgid, true
+}
@@ -0,0 +1,36 @@
+package htlib
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+)
+
+func TestSignalErrors(t *testing.T) {
+	vt := New(DefaultConfig())
+	if err := vt.Interrupt(); !errors.Is(err, ErrNotStarted) {
+		t.Errorf("expected ErrNotStarted, got %v", err)
+	}
+
+	running, _ := newTestTerminal()
+	defer running.Close()
+	if err := running.Signal(syscall.SIGTERM); !errors.Is(err, ErrNotStarted) {
+		t.Errorf("expected ErrNotStarted before the InitEvent, got %v", err)
+	}
+
+	running.proc = &htProcess{serial: &serialBridge{}}
+	running.trackEvent(InitEvent{Cols: 80, Rows: 24, PID: 1})
+	if err := running.Signal(syscall.SIGTERM); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported for serial ports, got %v", err)
+	}
+}
+
+func TestParseStatTPGID(t *testing.T) {
+	stat := "1234 (my (odd) cmd) S 1 1234 1234 34816 5678 4194560 0 0"
+	if tpgid, ok := parseStatTPGID(stat); !ok || tpgid != 5678 {
+		t.Errorf("expected 5678, got %d %v", tpgid, ok)
+	}
+	if _, ok := parseStatTPGID("1 (init) S 0 1 1 0 -1 4194560"); ok {
+		t.Error("expected no foreground group for a process without a terminal")
+	}
+}
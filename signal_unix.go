@@ -0,0 +1,10 @@
+//go:build unix
+
+package htlib
+
+import "syscall"
+
+// signalGroup sends sig to every process in the process group pgid.
+func signalGroup(pgid int, sig syscall.Signal) error {
+	return syscall.Kill(-pgid, sig)
+}
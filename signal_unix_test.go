@@ -0,0 +1,82 @@
+//go:build unix
+
+package htlib
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestSignal(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	config := DefaultConfig()
+	config.Binary = "/bin/sh"
+	config.Args = []string{"-c", "trap 'exit 7' USR1; echo ready; while :; do sleep 0.05; done"}
+	vt := New(config)
+	defer vt.Close()
+
+	if err := vt.Start(ctx); err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+	if _, err := vt.WaitForText(ctx, "ready"); err != nil {
+		t.Fatal(err)
+	}
+	if err := vt.Signal(syscall.SIGUSR1); err != nil {
+		t.Fatal(err)
+	}
+	status, err := vt.WaitForExit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != (ExitStatus{Code: 7}) {
+		t.Errorf("expected the trap to exit 7, got %v", status)
+	}
+	if err := vt.Terminate(); !errors.Is(err, ErrProcessExited) {
+		t.Errorf("expected ErrProcessExited after exit, got %v", err)
+	}
+}
+
+func TestInterruptForeground(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	vt := New(DefaultConfig())
+	defer vt.Close()
+	if err := vt.Start(ctx, WaitReady()); err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+
+	// Run a job that ignores its input, so Ctrl-C typed on the terminal
+	// would be read as data rather than interrupt it
+	if err := vt.Input(ctx, "stty -isig; sleep 30; stty isig\r"); err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(t, func() bool {
+		pgid, ok := foregroundGroup(vt.PID())
+		cmdline, _ := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pgid), "cmdline"))
+		return ok && strings.HasPrefix(string(cmdline), "sleep")
+	})
+	if err := vt.Interrupt(); err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(t, func() bool {
+		pgid, ok := foregroundGroup(vt.PID())
+		return ok && pgid == vt.PID()
+	})
+
+	// The shell itself must have survived the interrupt
+	select {
+	case <-vt.current().exited:
+		t.Fatal("the shell exited")
+	default:
+	}
+}
@@ -0,0 +1,13 @@
+package htlib
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// signalGroup is not available on Windows, which has no process groups to
+// signal. SignalForeground falls back to Signal before it gets here, since
+// there is no /proc to read the foreground group from.
+func signalGroup(pgid int, sig syscall.Signal) error {
+	return fmt.Errorf("%w: process groups cannot be signalled on Windows", ErrUnsupported)
+}
@@ -0,0 +1,124 @@
+package htlib
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/io41/htlib.go/htproto"
+)
+
+// snapshotRequests matches SnapshotEvents with the takeSnapshot commands
+// they answer. ht does not tag its snapshots, but it answers commands in
+// the order it reads them, so requests and snapshots are numbered alike.
+type snapshotRequests struct {
+	mu        sync.Mutex
+	requested uint64
+	received  uint64
+	// lost holds the numbers of snapshots that were too large or could not
+	// be parsed, and lostc is closed and replaced whenever one is added
+	lost  map[uint64]bool
+	lostc chan struct{}
+}
+
+// snapshotLine matches the start of ht's snapshot event lines.
+var snapshotLine = regexp.MustCompile(`^\s*\{\s*"type"\s*:\s*"snapshot"`)
+
+// isSnapshotLine reports whether an event line that was dropped or could
+// not be parsed was a snapshot.
+func isSnapshotLine(line []byte) bool {
+	return snapshotLine.Match(line)
+}
+
+// countSnapshots returns the number of takeSnapshot commands in cmds.
+func countSnapshots(cmds []command) uint64 {
+	var n uint64
+	for _, cmd := range cmds {
+		if cmd.Type == htproto.CommandTakeSnapshot {
+			n++
+		}
+	}
+	return n
+}
+
+// receive returns the request number of the next snapshot from ht.
+func (r *snapshotRequests) receive() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.received++
+	return r.received
+}
+
+// lose counts a snapshot from ht that cannot be delivered, so that the
+// snapshots after it keep their request numbers, and wakes the waiters.
+func (r *snapshotRequests) lose() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.received++
+	if r.lost == nil {
+		r.lost = make(map[uint64]bool)
+	}
+	r.lost[r.received] = true
+	if r.lostc != nil {
+		close(r.lostc)
+		r.lostc = nil
+	}
+}
+
+// lostChan returns a channel that is closed when the next snapshot is lost.
+func (r *snapshotRequests) lostChan() <-chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.lostc == nil {
+		r.lostc = make(chan struct{})
+	}
+	return r.lostc
+}
+
+// isLost reports whether the snapshot answering request was lost.
+func (r *snapshotRequests) isLost(request uint64) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lost[request]
+}
+
+// reset starts numbering afresh for a new process.
+func (r *snapshotRequests) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requested, r.received = 0, 0
+	r.lost = nil
+}
+
+// requestSnapshot sends cmds followed by a takeSnapshot command and waits
+// for the snapshot that answers it.
+func (vt *VirtualTerminal) requestSnapshot(ctx context.Context, cmds ...command) (*SnapshotEvent, error) {
+	// Subscribe to events temporarily
+	eventChan := vt.subscribeRaw(WithEventTypes(EventTypeSnapshot))
+	defer vt.Unsubscribe(eventChan)
+
+	lost := vt.snapshots.lostChan()
+	request, err := vt.writeCommands(ctx, append(cmds, htproto.TakeSnapshot())...)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		select {
+		case event := <-eventChan:
+			if snapshot, ok := event.(SnapshotEvent); ok && snapshot.Request >= request {
+				return &snapshot, nil
+			}
+		case <-lost:
+			if vt.snapshots.isLost(request) {
+				return nil, fmt.Errorf("%w: snapshot %d was too large or could not be parsed", ErrInvalidEvent, request)
+			}
+			lost = vt.snapshots.lostChan()
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-vt.current().ctx.Done():
+			return nil, vt.closedErr()
+		}
+	}
+}
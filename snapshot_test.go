@@ -0,0 +1,85 @@
+package htlib
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWaitForSnapshotCorrelation(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	vt, stdin := newTestTerminal()
+	defer vt.Close()
+
+	// A snapshot requested earlier is not mistaken for the answer
+	if err := vt.TakeSnapshot(ctx); err != nil {
+		t.Fatal(err)
+	}
+	results := make(chan *SnapshotEvent, 2)
+	for range 2 {
+		go func() {
+			snapshot, err := vt.WaitForSnapshot(ctx)
+			if err != nil {
+				t.Error(err)
+			}
+			results <- snapshot
+		}()
+	}
+	waitUntil(t, func() bool { return strings.Count(stdin.String(), "takeSnapshot") == 3 })
+
+	for _, text := range []string{"first", "second", "third"} {
+		vt.handleLine(`{"type":"snapshot","data":{"cols":80,"rows":24,"seq":"","text":"` + text + `"}}`)
+	}
+	a, b := <-results, <-results
+	if a.Request == b.Request || a.Request < 2 || b.Request < 2 {
+		t.Fatalf("expected each waiter to get its own snapshot, got %d and %d", a.Request, b.Request)
+	}
+	for _, s := range []*SnapshotEvent{a, b} {
+		if want := []string{"", "first", "second", "third"}[s.Request]; s.Text != want {
+			t.Errorf("snapshot %d: expected %q, got %q", s.Request, want, s.Text)
+		}
+	}
+}
+
+func TestWaitForSnapshotLost(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	vt, stdin := newTestTerminal()
+	defer vt.Close()
+	vt.config.MaxEventSize = 1000
+
+	type result struct {
+		snapshot *SnapshotEvent
+		err      error
+	}
+	var results [3]chan result
+	for i := range results {
+		results[i] = make(chan result, 1)
+		go func() {
+			snapshot, err := vt.WaitForSnapshot(ctx)
+			results[i] <- result{snapshot, err}
+		}()
+		waitUntil(t, func() bool { return strings.Count(stdin.String(), "takeSnapshot") == i+1 })
+	}
+
+	// The first answer is over the limit and the second is not valid
+	huge := `{"type":"snapshot","data":{"cols":80,"rows":24,"seq":"","text":"` + strings.Repeat("z", 2000) + `"}}`
+	done := make(chan struct{})
+	vt.wg.Add(1)
+	go vt.readEvents(vt.newEventReader(strings.NewReader(huge+"\n")), "", done)
+	<-done
+	vt.handleLine(`{"type":"snapshot","data":{"cols":"wide"}}`)
+	vt.handleLine(`{"type":"snapshot","data":{"cols":80,"rows":24,"seq":"","text":"third"}}`)
+
+	for _, r := range results[:2] {
+		if res := <-r; !errors.Is(res.err, ErrInvalidEvent) {
+			t.Errorf("expected ErrInvalidEvent for a lost snapshot, got %v", res.err)
+		}
+	}
+	if res := <-results[2]; res.err != nil || res.snapshot.Request != 3 || res.snapshot.Text != "third" {
+		t.Errorf("expected the third snapshot, got %+v %v", res.snapshot, res.err)
+	}
+}
@@ -0,0 +1,98 @@
+package htlib
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// stderrTailLines is the number of lines of ht's standard error kept for
+// Stderr.
+const stderrTailLines = 100
+
+// StderrEvent is a line that ht wrote to its standard error, such as a
+// warning or the reason it failed. It is only sent if Config.StderrEvents
+// is set.
+type StderrEvent struct {
+	Line string
+	Time time.Time
+}
+
+func (e StderrEvent) Type() EventType { return EventTypeStderr }
+
+// Stderr returns the last lines ht wrote to its standard error, oldest
+// first, to explain a failed start or an unexpected exit:
+//
+//	if status, _ := vt.WaitForExit(ctx); !status.Success() {
+//	    log.Printf("ht: %s: %s", status, strings.Join(vt.Stderr(), "\n"))
+//	}
+//
+// Up to 100 lines are kept. Restart starts afresh with the new process.
+func (vt *VirtualTerminal) Stderr() []string {
+	return vt.stderrTail.lines()
+}
+
+// readStderr reads ht's standard error until it is closed, keeping its
+// tail and sending StderrEvents. It closes done when it stops reading.
+func (vt *VirtualTerminal) readStderr(r io.Reader, done chan<- struct{}) {
+	defer vt.wg.Done()
+	defer close(done)
+
+	lines := newEventReader(r, 64*1024, nil)
+	for {
+		line, ok := lines.next()
+		if !ok {
+			return
+		}
+		vt.stderrTail.add(line)
+		if vt.config.StderrEvents {
+			// Keep reading after Close so that ht never blocks on a full pipe
+			vt.dispatch(StderrEvent{Line: line, Time: time.Now()})
+		}
+	}
+}
+
+// readTail reads r to its end and returns its last lines, e.g. what ht
+// wrote to its standard error before it failed to start. r may be nil.
+func readTail(r io.Reader, limit int) []string {
+	if r == nil {
+		return nil
+	}
+	tail := lineTail{limit: limit}
+	lines := newEventReader(r, 64*1024, nil)
+	for {
+		line, ok := lines.next()
+		if !ok {
+			return tail.lines()
+		}
+		tail.add(line)
+	}
+}
+
+// lineTail keeps the last lines of a stream.
+type lineTail struct {
+	mu    sync.Mutex
+	limit int
+	tail  []string
+}
+
+func (t *lineTail) add(line string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tail = append(t.tail, line)
+	if len(t.tail) > t.limit {
+		t.tail = append(t.tail[:0], t.tail[len(t.tail)-t.limit:]...)
+	}
+}
+
+func (t *lineTail) lines() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]string(nil), t.tail...)
+}
+
+func (t *lineTail) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tail = nil
+}
@@ -0,0 +1,65 @@
+package htlib
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestStderr(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Wrap ht in a script that writes to stderr before starting it
+	wrapper := filepath.Join(t.TempDir(), "ht-wrapper")
+	script := "#!/bin/sh\necho 'warning: from ht' >&2\nexec ht \"$@\"\n"
+	if err := os.WriteFile(wrapper, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	config := DefaultConfig()
+	config.HtBinary = wrapper
+	config.StderrEvents = true
+	vt := New(config)
+	defer vt.Close()
+	sub := vt.Subscribe()
+	if err := vt.Start(ctx); err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+
+	if event := nextEvent[StderrEvent](ctx, t, sub); event.Line != "warning: from ht" || event.Time.IsZero() {
+		t.Errorf("unexpected event %+v", event)
+	}
+	if lines := vt.Stderr(); len(lines) != 1 || lines[0] != "warning: from ht" {
+		t.Errorf("unexpected stderr %q", lines)
+	}
+
+	// The exit event still comes last
+	if err := vt.Input(ctx, "exit\r"); err != nil {
+		t.Fatal(err)
+	}
+	var last Event
+	for event := range vt.Events() {
+		last = event
+	}
+	if _, ok := last.(ExitEvent); !ok {
+		t.Errorf("expected the exit event last, got %#v", last)
+	}
+}
+
+func TestLineTail(t *testing.T) {
+	tail := lineTail{limit: 3}
+	for i := range 5 {
+		tail.add(strconv.Itoa(i))
+	}
+	if lines := tail.lines(); len(lines) != 3 || lines[0] != "2" || lines[2] != "4" {
+		t.Errorf("expected the last 3 lines, got %q", lines)
+	}
+	tail.reset()
+	if lines := tail.lines(); len(lines) != 0 {
+		t.Errorf("expected no lines after reset, got %q", lines)
+	}
+}
@@ -0,0 +1,238 @@
+package htlib
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Storage persists named objects such as session artifacts. Recording.Save
+// and LoadRecording use it directly; anything else that writes to an
+// io.Writer, like LogEventsTo or an encoder passed to RecordTo, can write to
+// the writer returned by Create, which must then be closed once it is done.
+// Names are slash-separated paths like "runs/42/session.cast"; they must be
+// valid according to io/fs.ValidPath. Implementations must be safe for
+// concurrent use.
+type Storage interface {
+	// Create returns a writer for name. The object becomes visible, replacing
+	// any existing one, only when the writer is closed without error.
+	Create(ctx context.Context, name string) (io.WriteCloser, error)
+	// Open returns a reader for name. A missing object is reported with an
+	// error matching fs.ErrNotExist.
+	Open(ctx context.Context, name string) (io.ReadCloser, error)
+	// List returns the sorted names of all objects starting with prefix.
+	List(ctx context.Context, prefix string) ([]string, error)
+	// Remove deletes name. Removing a missing object is not an error.
+	Remove(ctx context.Context, name string) error
+}
+
+// validateName checks that name is usable as a storage object name.
+func validateName(name string) error {
+	if name == "." || !fs.ValidPath(name) {
+		return fmt.Errorf("%w: invalid storage name %q", ErrInvalidInput, name)
+	}
+	return nil
+}
+
+// DirStorage stores objects as files below a local directory.
+type DirStorage struct {
+	// Root is the directory holding the objects; it is created on demand
+	Root string
+}
+
+// NewDirStorage returns a Storage backed by the directory root.
+func NewDirStorage(root string) *DirStorage {
+	return &DirStorage{Root: root}
+}
+
+// Create writes to a temporary file that is renamed into place on Close.
+func (s *DirStorage) Create(ctx context.Context, name string) (io.WriteCloser, error) {
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	target := filepath.Join(s.Root, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp(filepath.Dir(target), ".tmp-"+filepath.Base(target)+"-*")
+	if err != nil {
+		return nil, err
+	}
+	return &dirWriter{File: f, target: target}, nil
+}
+
+// dirWriter renames its temporary file to the target on Close.
+type dirWriter struct {
+	*os.File
+	target string
+}
+
+func (w *dirWriter) Close() error {
+	if err := w.File.Close(); err != nil {
+		os.Remove(w.Name())
+		return err
+	}
+	if err := os.Rename(w.Name(), w.target); err != nil {
+		os.Remove(w.Name())
+		return err
+	}
+	return nil
+}
+
+// Open opens the file for name.
+func (s *DirStorage) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return os.Open(filepath.Join(s.Root, filepath.FromSlash(name)))
+}
+
+// List walks the directory for files whose names start with prefix.
+func (s *DirStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	var names []string
+	err := filepath.WalkDir(s.Root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == s.Root && os.IsNotExist(err) {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".tmp-") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.Root, p)
+		if err != nil {
+			return err
+		}
+		if name := filepath.ToSlash(rel); strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Remove deletes the file for name.
+func (s *DirStorage) Remove(ctx context.Context, name string) error {
+	if err := validateName(name); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	err := os.Remove(filepath.Join(s.Root, filepath.FromSlash(name)))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// MemoryStorage keeps objects in memory, which is useful in tests.
+type MemoryStorage struct {
+	mu      sync.RWMutex
+	objects map[string][]byte
+}
+
+// NewMemoryStorage returns an empty in-memory Storage.
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{objects: make(map[string][]byte)}
+}
+
+// Create buffers writes and stores the object on Close.
+func (s *MemoryStorage) Create(ctx context.Context, name string) (io.WriteCloser, error) {
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return &memoryWriter{storage: s, name: name}, nil
+}
+
+// memoryWriter stores its buffer in a MemoryStorage on Close.
+type memoryWriter struct {
+	bytes.Buffer
+	storage *MemoryStorage
+	name    string
+}
+
+func (w *memoryWriter) Close() error {
+	w.storage.mu.Lock()
+	defer w.storage.mu.Unlock()
+	w.storage.objects[w.name] = bytes.Clone(w.Bytes())
+	return nil
+}
+
+// Open returns a reader over the stored object.
+func (s *MemoryStorage) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	data, ok := s.objects[name]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// List returns the stored names starting with prefix.
+func (s *MemoryStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var names []string
+	for name := range s.objects {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Remove deletes the object.
+func (s *MemoryStorage) Remove(ctx context.Context, name string) error {
+	if err := validateName(name); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.objects, name)
+	return nil
+}
@@ -0,0 +1,115 @@
+package htlib
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testStorage exercises the Storage contract against s.
+func testStorage(t *testing.T, s Storage) {
+	t.Helper()
+	ctx := context.Background()
+
+	put := func(name, data string) {
+		t.Helper()
+		w, err := s.Create(ctx, name)
+		if err != nil {
+			t.Fatalf("create %s: %v", name, err)
+		}
+		if _, err := io.WriteString(w, data); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("close %s: %v", name, err)
+		}
+	}
+	get := func(name string) (string, error) {
+		r, err := s.Open(ctx, name)
+		if err != nil {
+			return "", err
+		}
+		defer r.Close()
+		data, err := io.ReadAll(r)
+		return string(data), err
+	}
+
+	put("runs/1/session.cast", "first")
+	put("runs/2/session.cast", "second")
+	put("other.txt", "other")
+	put("runs/1/session.cast", "replaced")
+
+	if data, err := get("runs/1/session.cast"); err != nil || data != "replaced" {
+		t.Errorf("expected replaced content, got %q, %v", data, err)
+	}
+	if _, err := get("missing.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	}
+
+	names, err := s.List(ctx, "runs/")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if expected := []string{"runs/1/session.cast", "runs/2/session.cast"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %v, got %v", expected, names)
+	}
+
+	if err := s.Remove(ctx, "runs/2/session.cast"); err != nil {
+		t.Errorf("remove: %v", err)
+	}
+	if err := s.Remove(ctx, "runs/2/session.cast"); err != nil {
+		t.Errorf("removing a missing object should succeed, got %v", err)
+	}
+	if names, _ := s.List(ctx, ""); len(names) != 2 {
+		t.Errorf("expected 2 objects after remove, got %v", names)
+	}
+
+	if _, err := s.Create(ctx, "../escape"); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput for invalid name, got %v", err)
+	}
+}
+
+func TestDirStorage(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "artifacts")
+	s := NewDirStorage(root)
+
+	// Listing a directory that does not exist yet is empty
+	if names, err := s.List(context.Background(), ""); err != nil || len(names) != 0 {
+		t.Errorf("expected empty list, got %v, %v", names, err)
+	}
+
+	testStorage(t, s)
+
+	if _, err := os.Stat(filepath.Join(root, "runs", "1", "session.cast")); err != nil {
+		t.Errorf("expected file on disk: %v", err)
+	}
+}
+
+func TestDirStorageUncommittedWrite(t *testing.T) {
+	s := NewDirStorage(t.TempDir())
+	ctx := context.Background()
+
+	w, err := s.Create(ctx, "partial.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(w, "data")
+
+	// Nothing is visible until the writer is closed
+	if names, _ := s.List(ctx, ""); len(names) != 0 {
+		t.Errorf("expected no objects before Close, got %v", names)
+	}
+	w.Close()
+	if names, _ := s.List(ctx, ""); len(names) != 1 {
+		t.Errorf("expected 1 object after Close, got %v", names)
+	}
+}
+
+func TestMemoryStorage(t *testing.T) {
+	testStorage(t, NewMemoryStorage())
+}
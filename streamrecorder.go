@@ -0,0 +1,176 @@
+package htlib
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RecordingEncoder writes a session in a recording file format for
+// RecordTo. Encoders for asciicast and ttyrec are provided by
+// NewCastEncoder and NewTTYRecEncoder.
+type RecordingEncoder interface {
+	// Begin is called once, before any other method, with the size of the
+	// terminal, the time recording started and the session metadata
+	Begin(cols, rows int, start time.Time, metadata Metadata) error
+	// Output writes output at offset, the time since the start
+	Output(offset time.Duration, seq string) error
+	// Resize writes a change of terminal size at offset
+	Resize(offset time.Duration, cols, rows int) error
+}
+
+// StreamRecorder writes a terminal session with a RecordingEncoder as it
+// happens. Its methods may be called from any goroutine.
+type StreamRecorder struct {
+	vt    *VirtualTerminal
+	sub   chan Event
+	done  chan struct{}
+	enc   RecordingEncoder
+	start time.Time
+
+	// mu guards the fields below and orders calls to enc
+	mu       sync.Mutex
+	model    *screenModel
+	last     time.Duration // offset of the last event written
+	paused   bool
+	pausedAt time.Time
+	skipped  time.Duration // total time spent paused
+	missed   bool          // output arrived while paused
+	err      error
+}
+
+// RecordTo starts recording the session with enc. After Begin the current
+// screen is written as output, so that a recording started mid-session
+// plays back correctly; output and resizes follow as they arrive. The
+// recorder never drops events, since one lost output event would corrupt
+// the rest of the recording; like an OverflowBlock subscriber, it holds up
+// the terminal if the encoder falls behind.
+func (vt *VirtualTerminal) RecordTo(enc RecordingEncoder) (*StreamRecorder, error) {
+	sub, model := vt.subscribeScreen()
+	r := &StreamRecorder{
+		vt:    vt,
+		sub:   sub,
+		done:  make(chan struct{}),
+		enc:   enc,
+		start: time.Now(),
+		model: model,
+	}
+	if err := enc.Begin(model.cols, model.rows, r.start, vt.Metadata()); err != nil {
+		vt.Unsubscribe(sub)
+		return nil, fmt.Errorf("failed to start recording: %w", err)
+	}
+	r.write(0, model.dump())
+	if r.err != nil {
+		vt.Unsubscribe(sub)
+		return nil, r.err
+	}
+
+	go r.record()
+	return r, nil
+}
+
+// Pause stops recording until Resume is called. The paused time is cut
+// from the recording, so playback continues without a gap.
+func (r *StreamRecorder) Pause() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.paused {
+		r.paused = true
+		r.pausedAt = time.Now()
+	}
+}
+
+// Resume continues recording after Pause. If the screen changed while
+// paused, the recording jumps to the current screen.
+func (r *StreamRecorder) Resume() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.paused {
+		return
+	}
+	now := time.Now()
+	r.skipped += now.Sub(r.pausedAt)
+	r.paused = false
+	if r.missed {
+		r.missed = false
+		r.write(r.offset(now), r.model.dump())
+	}
+}
+
+// Close stops recording and returns the first error of the encoder. It is
+// safe to call Close more than once. The underlying writer is not closed.
+func (r *StreamRecorder) Close() error {
+	r.vt.Unsubscribe(r.sub)
+	<-r.done
+	return r.Err()
+}
+
+// Err returns the first error of the encoder.
+func (r *StreamRecorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// record writes events until the recorder is closed.
+func (r *StreamRecorder) record() {
+	defer close(r.done)
+
+	for event := range r.sub {
+		r.mu.Lock()
+		switch e := event.(type) {
+		case InitEvent:
+			r.model.load(e.Cols, e.Rows, e.Seq)
+			r.output(e.Time, r.model.dump())
+		case OutputEvent:
+			r.model.feed(e.Seq)
+			r.output(e.Time, e.Seq)
+		case ResizeEvent:
+			r.model.setSize(e.Cols, e.Rows)
+			if r.paused {
+				r.missed = true
+			} else if r.err == nil {
+				offset := r.offset(e.Time)
+				r.last = offset
+				r.setErr(r.enc.Resize(offset, e.Cols, e.Rows))
+			}
+		}
+		r.mu.Unlock()
+	}
+}
+
+// output writes output unless the recorder is paused. The caller must
+// hold r.mu.
+func (r *StreamRecorder) output(t time.Time, seq string) {
+	if r.paused {
+		r.missed = true
+		return
+	}
+	r.write(r.offset(t), seq)
+}
+
+// offset returns the time of an event in the recording, never earlier
+// than the last event written. The caller must hold r.mu.
+func (r *StreamRecorder) offset(t time.Time) time.Duration {
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return max(t.Sub(r.start)-r.skipped-r.vt.suspension.between(r.start, t), r.last)
+}
+
+// write writes output at offset. After an error nothing more is written.
+// The caller must hold r.mu.
+func (r *StreamRecorder) write(offset time.Duration, seq string) {
+	if r.err != nil {
+		return
+	}
+	r.last = offset
+	r.setErr(r.enc.Output(offset, seq))
+}
+
+// setErr records the first error of the encoder. The caller must hold r.mu.
+func (r *StreamRecorder) setErr(err error) {
+	if err != nil && r.err == nil {
+		r.err = fmt.Errorf("failed to write recording: %w", err)
+	}
+}
@@ -0,0 +1,53 @@
+package htlib
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// failingEncoder fails once it has written a number of outputs.
+type failingEncoder struct {
+	outputs int
+	begin   error
+}
+
+func (e *failingEncoder) Begin(cols, rows int, start time.Time, metadata Metadata) error {
+	return e.begin
+}
+
+func (e *failingEncoder) Output(offset time.Duration, seq string) error {
+	if e.outputs == 0 {
+		return errors.New("disk full")
+	}
+	e.outputs--
+	return nil
+}
+
+func (e *failingEncoder) Resize(offset time.Duration, cols, rows int) error {
+	return nil
+}
+
+func TestStreamRecorderErrors(t *testing.T) {
+	vt, _ := newTestTerminal()
+	defer vt.Close()
+
+	if _, err := vt.RecordTo(&failingEncoder{begin: errors.New("no header")}); err == nil {
+		t.Error("expected the error of Begin")
+	}
+	if _, err := vt.RecordTo(&failingEncoder{}); err == nil {
+		t.Error("expected the error writing the initial screen")
+	}
+
+	r, err := vt.RecordTo(&failingEncoder{outputs: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := OutputEvent{Seq: "x", Time: time.Now()}
+	vt.trackEvent(event)
+	vt.dispatch(event)
+	waitUntil(t, func() bool { return r.Err() != nil })
+	if err := r.Close(); err == nil || err.Error() != "failed to write recording: disk full" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,249 @@
+package htlib
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Color is a terminal color: the default color, one of the 256 indexed
+// colors, or a 24-bit RGB color. The zero value is the default color.
+type Color uint32
+
+// Color kinds, stored in the top byte of a Color.
+const (
+	indexedColor Color = 1 << 24
+	rgbColor     Color = 2 << 24
+	colorKind    Color = 0xff << 24
+)
+
+// The eight standard colors and their bright variants.
+const (
+	DefaultColor Color = 0
+
+	ColorBlack   = indexedColor | 0
+	ColorRed     = indexedColor | 1
+	ColorGreen   = indexedColor | 2
+	ColorYellow  = indexedColor | 3
+	ColorBlue    = indexedColor | 4
+	ColorMagenta = indexedColor | 5
+	ColorCyan    = indexedColor | 6
+	ColorWhite   = indexedColor | 7
+
+	ColorBrightBlack   = indexedColor | 8
+	ColorBrightRed     = indexedColor | 9
+	ColorBrightGreen   = indexedColor | 10
+	ColorBrightYellow  = indexedColor | 11
+	ColorBrightBlue    = indexedColor | 12
+	ColorBrightMagenta = indexedColor | 13
+	ColorBrightCyan    = indexedColor | 14
+	ColorBrightWhite   = indexedColor | 15
+)
+
+// IndexedColor returns color n of the 256-color palette.
+func IndexedColor(n uint8) Color {
+	return indexedColor | Color(n)
+}
+
+// RGBColor returns a 24-bit color.
+func RGBColor(r, g, b uint8) Color {
+	return rgbColor | Color(r)<<16 | Color(g)<<8 | Color(b)
+}
+
+// IsDefault reports whether c is the terminal's default color.
+func (c Color) IsDefault() bool {
+	return c == DefaultColor
+}
+
+// Index returns the palette index of an indexed color.
+func (c Color) Index() (uint8, bool) {
+	return uint8(c), c&colorKind == indexedColor
+}
+
+// RGB returns the components of a 24-bit color.
+func (c Color) RGB() (r, g, b uint8, ok bool) {
+	return uint8(c >> 16), uint8(c >> 8), uint8(c), c&colorKind == rgbColor
+}
+
+// String returns "default", the palette index such as "1", or the RGB
+// value such as "#ff8800".
+func (c Color) String() string {
+	if n, ok := c.Index(); ok {
+		return strconv.Itoa(int(n))
+	}
+	if r, g, b, ok := c.RGB(); ok {
+		return fmt.Sprintf("#%02x%02x%02x", r, g, b)
+	}
+	return "default"
+}
+
+// Style is the set of colors and attributes a character was printed with.
+// The zero value is the terminal's default style.
+type Style struct {
+	Fg            Color
+	Bg            Color
+	Bold          bool
+	Faint         bool
+	Italic        bool
+	Underline     bool
+	Blink         bool
+	Reverse       bool
+	Invisible     bool
+	Strikethrough bool
+}
+
+// applySGR updates the style with the parameters of an SGR sequence
+// (CSI ... m). Both the ';' and the ':' forms of extended colors are
+// understood, e.g. "38;5;208" and "38:2::255:136:0".
+func (s *Style) applySGR(params string) {
+	fields := strings.Split(params, ";")
+	for i := 0; i < len(fields); i++ {
+		sub := strings.Split(fields[i], ":")
+		code, _ := strconv.Atoi(sub[0])
+
+		switch {
+		case code == 0:
+			*s = Style{}
+		case code == 1:
+			s.Bold = true
+		case code == 2:
+			s.Faint = true
+		case code == 3:
+			s.Italic = true
+		case code == 4:
+			// 4:0 turns underlining off, 4:1 to 4:5 select its shape
+			s.Underline = len(sub) < 2 || sub[1] != "0"
+		case code == 5 || code == 6:
+			s.Blink = true
+		case code == 7:
+			s.Reverse = true
+		case code == 8:
+			s.Invisible = true
+		case code == 9:
+			s.Strikethrough = true
+		case code == 21:
+			s.Underline = true
+		case code == 22:
+			s.Bold, s.Faint = false, false
+		case code == 23:
+			s.Italic = false
+		case code == 24:
+			s.Underline = false
+		case code == 25:
+			s.Blink = false
+		case code == 27:
+			s.Reverse = false
+		case code == 28:
+			s.Invisible = false
+		case code == 29:
+			s.Strikethrough = false
+		case code >= 30 && code <= 37:
+			s.Fg = IndexedColor(uint8(code - 30))
+		case code == 38:
+			var c Color
+			c, i = extendedColor(fields, sub, i)
+			s.Fg = c
+		case code == 39:
+			s.Fg = DefaultColor
+		case code >= 40 && code <= 47:
+			s.Bg = IndexedColor(uint8(code - 40))
+		case code == 48:
+			var c Color
+			c, i = extendedColor(fields, sub, i)
+			s.Bg = c
+		case code == 49:
+			s.Bg = DefaultColor
+		case code >= 90 && code <= 97:
+			s.Fg = IndexedColor(uint8(code - 90 + 8))
+		case code >= 100 && code <= 107:
+			s.Bg = IndexedColor(uint8(code - 100 + 8))
+		}
+	}
+}
+
+// extendedColor parses the color of SGR 38 or 48 at fields[i], whose
+// ':'-separated parts are sub. It returns the color and the index of the
+// last field it used.
+func extendedColor(fields, sub []string, i int) (Color, int) {
+	args := sub[1:]
+	if len(sub) == 1 {
+		// The ';' form takes its arguments from the following fields
+		args = fields[i+1:]
+	}
+	num := func(n int) uint8 {
+		if n < len(args) {
+			v, _ := strconv.Atoi(args[n])
+			return uint8(max(0, min(v, 255)))
+		}
+		return 0
+	}
+	used := func(n int) int {
+		if len(sub) == 1 {
+			return min(i+n, len(fields)-1)
+		}
+		return i
+	}
+
+	if len(args) == 0 {
+		return DefaultColor, i
+	}
+	switch args[0] {
+	case "5":
+		return IndexedColor(num(1)), used(2)
+	case "2":
+		// The ':' form may carry a color space id before the components
+		if len(sub) > 1 && len(args) >= 5 {
+			return RGBColor(num(2), num(3), num(4)), i
+		}
+		return RGBColor(num(1), num(2), num(3)), used(4)
+	}
+	return DefaultColor, used(1)
+}
+
+// sgr returns the SGR sequence that selects the style from any other.
+func (s Style) sgr() string {
+	params := []string{"0"}
+	for _, attr := range []struct {
+		on   bool
+		code string
+	}{
+		{s.Bold, "1"},
+		{s.Faint, "2"},
+		{s.Italic, "3"},
+		{s.Underline, "4"},
+		{s.Blink, "5"},
+		{s.Reverse, "7"},
+		{s.Invisible, "8"},
+		{s.Strikethrough, "9"},
+	} {
+		if attr.on {
+			params = append(params, attr.code)
+		}
+	}
+	if p := s.Fg.sgrParams(30); p != "" {
+		params = append(params, p)
+	}
+	if p := s.Bg.sgrParams(40); p != "" {
+		params = append(params, p)
+	}
+	return "\x1b[" + strings.Join(params, ";") + "m"
+}
+
+// sgrParams returns the SGR parameters selecting c as the foreground (base
+// 30) or background (base 40) color, or "" for the default color.
+func (c Color) sgrParams(base int) string {
+	if n, ok := c.Index(); ok {
+		switch {
+		case n < 8:
+			return strconv.Itoa(base + int(n))
+		case n < 16:
+			return strconv.Itoa(base + 60 + int(n) - 8)
+		default:
+			return fmt.Sprintf("%d;5;%d", base+8, n)
+		}
+	}
+	if r, g, b, ok := c.RGB(); ok {
+		return fmt.Sprintf("%d;2;%d;%d;%d", base+8, r, g, b)
+	}
+	return ""
+}
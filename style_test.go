@@ -0,0 +1,57 @@
+package htlib
+
+import "testing"
+
+func TestApplySGR(t *testing.T) {
+	tests := []struct {
+		name     string
+		start    Style
+		params   string
+		expected Style
+	}{
+		{"reset", Style{Bold: true, Fg: ColorRed}, "", Style{}},
+		{"explicit reset", Style{Bold: true}, "0", Style{}},
+		{"attributes", Style{}, "1;3;4;5;7;8;9", Style{Bold: true, Italic: true, Underline: true, Blink: true, Reverse: true, Invisible: true, Strikethrough: true}},
+		{"attributes off", Style{Bold: true, Faint: true, Underline: true, Reverse: true}, "22;24;27", Style{}},
+		{"underline off subparam", Style{Underline: true}, "4:0", Style{}},
+		{"curly underline", Style{}, "4:3", Style{Underline: true}},
+		{"basic colors", Style{}, "31;42", Style{Fg: ColorRed, Bg: ColorGreen}},
+		{"bright colors", Style{}, "91;104", Style{Fg: ColorBrightRed, Bg: ColorBrightBlue}},
+		{"default colors", Style{Fg: ColorRed, Bg: ColorGreen}, "39;49", Style{}},
+		{"256 colors", Style{}, "38;5;208;48;5;17;1", Style{Fg: IndexedColor(208), Bg: IndexedColor(17), Bold: true}},
+		{"rgb colors", Style{}, "38;2;255;136;0;4", Style{Fg: RGBColor(255, 136, 0), Underline: true}},
+		{"colon rgb with color space", Style{}, "38:2::1:2:3", Style{Fg: RGBColor(1, 2, 3)}},
+		{"colon rgb", Style{}, "48:2:1:2:3", Style{Bg: RGBColor(1, 2, 3)}},
+		{"colon indexed", Style{}, "38:5:99;1", Style{Fg: IndexedColor(99), Bold: true}},
+		{"truncated", Style{}, "38;5", Style{Fg: IndexedColor(0)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			style := tt.start
+			style.applySGR(tt.params)
+			if style != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, style)
+			}
+		})
+	}
+}
+
+func TestColor(t *testing.T) {
+	if !DefaultColor.IsDefault() || DefaultColor.String() != "default" {
+		t.Error("unexpected default color")
+	}
+	if n, ok := ColorBrightCyan.Index(); !ok || n != 14 || ColorBrightCyan.String() != "14" {
+		t.Errorf("unexpected indexed color %d, %v", n, ok)
+	}
+	if _, _, _, ok := ColorRed.RGB(); ok {
+		t.Error("indexed color reported as RGB")
+	}
+	c := RGBColor(255, 136, 0)
+	if r, g, b, ok := c.RGB(); !ok || r != 255 || g != 136 || b != 0 || c.String() != "#ff8800" {
+		t.Errorf("unexpected RGB color %v", c)
+	}
+	if _, ok := c.Index(); ok {
+		t.Error("RGB color reported as indexed")
+	}
+}
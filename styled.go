@@ -0,0 +1,158 @@
+package htlib
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Span is a run of characters on one line printed with the same style.
+type Span struct {
+	// Col is the 0-based column of the first character
+	Col  int    `json:"col"`
+	Text string `json:"text"`
+	Style
+}
+
+// StyledSnapshot is the screen as lines of styled spans, for tests that
+// check colors and attributes and for tools that render or store screens
+// with their styling. It encodes to JSON with colors written as in
+// Color.String.
+type StyledSnapshot struct {
+	Cols   int      `json:"cols"`
+	Rows   int      `json:"rows"`
+	Cursor Cursor   `json:"cursor"`
+	Lines  [][]Span `json:"lines"`
+	Time   time.Time
+}
+
+// Text returns the text of the snapshot like Screen.Text.
+func (s *StyledSnapshot) Text() string {
+	lines := make([]string, len(s.Lines))
+	for row, spans := range s.Lines {
+		var b strings.Builder
+		col := 0
+		for _, span := range spans {
+			b.WriteString(strings.Repeat(" ", span.Col-col))
+			b.WriteString(span.Text)
+			col = span.Col + StringWidth(span.Text)
+		}
+		lines[row] = strings.TrimRight(b.String(), " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Spans returns the 0-based row as styled spans, left to right. Runs of
+// blank cells in the default style are left out, so a plain line of text
+// is a single span. Negative rows count from the bottom, as in Line.
+func (s *Screen) Spans(row int) []Span {
+	if row < 0 {
+		row += len(s.cells)
+	}
+	if row < 0 || row >= len(s.cells) {
+		return nil
+	}
+
+	var spans []Span
+	var text strings.Builder
+	start := 0
+	flush := func() {
+		if text.Len() > 0 {
+			spans = append(spans, Span{Col: start, Text: text.String(), Style: s.cells[row][start].Style})
+			text.Reset()
+		}
+	}
+
+	line := s.cells[row]
+	for col, cell := range line {
+		// Blanks are kept only between words of default-style text
+		if cell == blankCell && (text.Len() == 0 || line[start].Style != (Style{}) || blankFrom(line, col)) {
+			flush()
+			continue
+		}
+		if text.Len() > 0 && cell.Style != line[start].Style {
+			flush()
+		}
+		if text.Len() == 0 {
+			start = col
+		}
+		text.WriteString(cell.String())
+	}
+	flush()
+	return spans
+}
+
+// blankFrom reports whether the run of blank cells at col ends the line or
+// is followed by styled text.
+func blankFrom(line []Cell, col int) bool {
+	for ; col < len(line); col++ {
+		if line[col] != blankCell {
+			return line[col].Style != (Style{})
+		}
+	}
+	return true
+}
+
+// Styled returns the screen as a StyledSnapshot.
+func (s *Screen) Styled() *StyledSnapshot {
+	styled := &StyledSnapshot{
+		Cols:   s.Cols,
+		Rows:   s.Rows,
+		Cursor: s.Cursor,
+		Lines:  make([][]Span, len(s.cells)),
+	}
+	for row := range s.cells {
+		styled.Lines[row] = s.Spans(row)
+	}
+	return styled
+}
+
+// TakeStyledSnapshot requests a snapshot from ht and returns it with the
+// colors and attributes of the text:
+//
+//	snapshot, err := vt.TakeStyledSnapshot(ctx)
+//	for _, span := range snapshot.Lines[0] {
+//	    if span.Fg == htlib.ColorRed {
+//	        t.Errorf("error shown: %s", span.Text)
+//	    }
+//	}
+//
+// Use Screen for the same information from the local model without a
+// round trip to ht.
+func (vt *VirtualTerminal) TakeStyledSnapshot(ctx context.Context) (*StyledSnapshot, error) {
+	snapshot, err := vt.WaitForSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	styled := snapshot.Screen().Styled()
+	styled.Time = snapshot.Time
+	return styled, nil
+}
+
+// MarshalText encodes the color as in String.
+func (c Color) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText decodes a color written by MarshalText.
+func (c *Color) UnmarshalText(text []byte) error {
+	s := string(text)
+	if s == "default" {
+		*c = DefaultColor
+		return nil
+	}
+	if hex, ok := strings.CutPrefix(s, "#"); ok && len(hex) == 6 {
+		v, err := strconv.ParseUint(hex, 16, 32)
+		if err == nil {
+			*c = RGBColor(uint8(v>>16), uint8(v>>8), uint8(v))
+			return nil
+		}
+	}
+	if n, err := strconv.ParseUint(s, 10, 8); err == nil {
+		*c = IndexedColor(uint8(n))
+		return nil
+	}
+	return fmt.Errorf("invalid color %q", s)
+}
@@ -0,0 +1,63 @@
+package htlib
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScreenSpans(t *testing.T) {
+	m := newScreenModel(30, 2)
+	m.feed("  ok \x1b[1;31mFAIL\x1b[0m   3 tests \x1b[42m  \x1b[0m")
+	screen := m.snapshot()
+
+	red := Style{Fg: ColorRed, Bold: true}
+	expected := []Span{
+		{Col: 2, Text: "ok"},
+		{Col: 5, Text: "FAIL", Style: red},
+		{Col: 12, Text: "3 tests"},
+		{Col: 20, Text: "  ", Style: Style{Bg: ColorGreen}},
+	}
+	if spans := screen.Spans(0); !reflect.DeepEqual(spans, expected) {
+		t.Errorf("expected %+v, got %+v", expected, spans)
+	}
+	if spans := screen.Spans(-1); spans != nil {
+		t.Errorf("expected no spans on an empty line, got %+v", spans)
+	}
+	if spans := screen.Spans(5); spans != nil {
+		t.Errorf("expected no spans off the screen, got %+v", spans)
+	}
+
+	styled := screen.Styled()
+	if text := styled.Text(); text != screen.Text() {
+		t.Errorf("expected the screen text, got %q", text)
+	}
+}
+
+func TestStyledSnapshotJSON(t *testing.T) {
+	m := newScreenModel(20, 1)
+	m.feed("\x1b[38;2;255;136;0mwarn\x1b[0m \x1b[38;5;200;4mlink")
+	styled := m.snapshot().Styled()
+
+	data, err := json.Marshal(styled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"Fg":"#ff8800"`) || !strings.Contains(string(data), `"Fg":"200"`) {
+		t.Errorf("expected readable colors, got %s", data)
+	}
+
+	var decoded StyledSnapshot
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded.Lines, styled.Lines) {
+		t.Errorf("expected %+v, got %+v", styled.Lines, decoded.Lines)
+	}
+
+	var c Color
+	if err := c.UnmarshalText([]byte("purple")); err == nil {
+		t.Error("expected an error for an unknown color")
+	}
+}
@@ -0,0 +1,430 @@
+package htlib
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// subscriber is a registered event consumer.
+type subscriber struct {
+	ch chan Event
+
+	// raw subscribers receive events before Config.OutputProcessors run
+	raw bool
+	// output is applied to OutputEvents for this subscriber only
+	output OutputProcessor
+	// types are the event types delivered, or all if empty
+	types []EventType
+	// buffer is the capacity of ch
+	buffer int
+	// overflow decides what happens to events when ch is full
+	overflow OverflowPolicy
+	// dropped counts the events this subscriber missed
+	dropped atomic.Uint64
+	// sequenced subscribers receive SequencedEvents
+	sequenced bool
+	// gap counts the events missed since the last one delivered; it is
+	// guarded by sendMu
+	gap uint64
+	// replay subscribers start with the InitEvent, the last SnapshotEvent
+	// and up to history recent events. Later events up to sequence number
+	// after were replayed and are not delivered again.
+	replay  bool
+	history int
+	after   uint64
+	// synced subscribers start from model, a copy of the screen model
+	// taken as they are added, and do not receive the screen updates it
+	// already includes: those up to update number screenAfter
+	synced      bool
+	model       *screenModel
+	screenAfter uint64
+
+	// sendMu is held while delivering an event, so that ch is not closed
+	// during a send
+	sendMu sync.Mutex
+	// done is closed when the subscriber is removed, before ch
+	done chan struct{}
+}
+
+// close closes the channels of a removed subscriber, waiting for an event
+// being delivered to it to be given up. The caller must hold vt.mu.
+func (s *subscriber) close() {
+	close(s.done)
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	close(s.ch)
+}
+
+// OverflowPolicy decides what happens to an event when the Events channel
+// or a subscriber channel is full, e.g. while a program prints a large file
+// faster than it is read.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock waits until there is room, holding up all other
+	// subscribers and the reading of ht's output
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest discards the oldest unread event to make room
+	OverflowDropOldest
+	// OverflowDropNewest discards the new event. The ExitEvent is always
+	// delivered, replacing the oldest event if needed.
+	OverflowDropNewest
+)
+
+// SubscribeOption configures a subscriber created with Subscribe.
+type SubscribeOption func(*subscriber)
+
+// WithOutputProcessors applies processors to OutputEvents delivered to this
+// subscriber, after any processors configured on the terminal. Processors
+// keep per-stream state, so pass fresh instances to every subscriber.
+func WithOutputProcessors(processors ...OutputProcessor) SubscribeOption {
+	return func(s *subscriber) {
+		s.output = ChainOutputProcessors(processors...)
+	}
+}
+
+// WithOverflow sets what happens to events when the subscriber falls
+// behind (default: OverflowDropNewest). With OverflowBlock nothing is lost,
+// but the terminal waits for the subscriber, holding up the Events channel,
+// other subscribers and the reading of ht's output. DroppedEventsFor
+// reports the events lost with the other policies.
+func WithOverflow(policy OverflowPolicy) SubscribeOption {
+	return func(s *subscriber) {
+		s.overflow = policy
+	}
+}
+
+// SequencedEvent wraps an event delivered to a subscriber created with
+// WithSequence.
+type SequencedEvent struct {
+	Event
+	// Seq is the event's sequence number, as used by ChangesSince. Events
+	// are numbered from 1 in the order they were received; numbers of
+	// events filtered out by type or by an output processor are skipped.
+	Seq uint64
+	// Dropped is the number of events the subscriber missed since the
+	// previous event it received because it fell behind
+	Dropped uint64
+}
+
+// WithSequence delivers every event wrapped in a SequencedEvent, so that
+// the subscriber can tell when it missed events and catch up with
+// ChangesSince:
+//
+//	for event := range vt.Subscribe(htlib.WithSequence()) {
+//	    e := event.(htlib.SequencedEvent)
+//	    if e.Dropped > 0 {
+//	        changes, err := vt.ChangesSince(ctx, lastSeq)
+//	        ...
+//	    }
+//	    lastSeq = e.Seq
+//	}
+//
+// Type switches must look at the Event field of the wrapper.
+func WithSequence() SubscribeOption {
+	return func(s *subscriber) {
+		s.sequenced = true
+	}
+}
+
+// WithReplay fills the subscriber's channel with the events it missed
+// before subscribing: the InitEvent of the current process, the last
+// SnapshotEvent after it and up to n of the most recent events, oldest
+// first. A subscriber created after startup can then learn the state of
+// the terminal without taking a snapshot:
+//
+//	sub := vt.Subscribe(htlib.WithReplay(0)) // the InitEvent and last snapshot
+//
+// Up to 1024 recent events are kept. Replayed events are as received from
+// ht, before Config.OutputProcessors run; the subscriber's own processors,
+// event types and WithSequence apply to them. The channel has room for
+// them in addition to its buffer.
+func WithReplay(n int) SubscribeOption {
+	return func(s *subscriber) {
+		s.replay, s.history = true, n
+	}
+}
+
+// replayed returns the events to fill the channel of a new subscriber
+// with.
+func (vt *VirtualTerminal) replayed(sub *subscriber, logged []loggedEvent) []Event {
+	var events []Event
+	for _, e := range logged {
+		if len(sub.types) > 0 && !slices.Contains(sub.types, e.event.Type()) {
+			continue
+		}
+		event, ok := vt.processOutput(sub.output, e.event)
+		if !ok {
+			continue
+		}
+		if sub.sequenced {
+			event = SequencedEvent{Event: event, Seq: e.seq}
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// WithBuffer sets the capacity of the subscriber's channel instead of
+// Config.EventBuffer: a large one for a consumer that handles events in
+// bursts, or a small one for a consumer that only cares about recent
+// events, often combined with OverflowDropOldest. If n is not positive,
+// Config.EventBuffer is used.
+func WithBuffer(n int) SubscribeOption {
+	return func(s *subscriber) {
+		s.buffer = n
+	}
+}
+
+// WithEventTypes delivers only events of the given types to the
+// subscriber, see SubscribeTypes.
+func WithEventTypes(types ...EventType) SubscribeOption {
+	return func(s *subscriber) {
+		s.types = append(s.types, types...)
+	}
+}
+
+// SubscribeTypes creates a subscriber that receives only events of the
+// given types, so that one waiting for resizes is not flooded with output:
+//
+//	resizes := vt.SubscribeTypes(htlib.EventTypeResize, htlib.EventTypeSnapshot)
+//	defer vt.Unsubscribe(resizes)
+//
+// Other options can be combined with WithEventTypes and Subscribe.
+func (vt *VirtualTerminal) SubscribeTypes(types ...EventType) chan Event {
+	return vt.Subscribe(WithEventTypes(types...))
+}
+
+// SubscribeContext creates a subscriber like Subscribe that is removed,
+// closing its channel, once ctx is done, so that a goroutine or a function
+// with early returns cannot leak it:
+//
+//	ctx, cancel := context.WithCancel(ctx)
+//	defer cancel()
+//	for event := range vt.SubscribeContext(ctx) {
+//	    ...
+//	}
+//
+// Unsubscribe may still be called to remove it earlier.
+func (vt *VirtualTerminal) SubscribeContext(ctx context.Context, opts ...SubscribeOption) chan Event {
+	sub := vt.subscribe(opts...)
+	go func() {
+		select {
+		case <-ctx.Done():
+			vt.Unsubscribe(sub.ch)
+		case <-sub.done:
+		}
+	}()
+	return sub.ch
+}
+
+// dispatch delivers an event to the Events() channel and all subscribers.
+// It returns false if the terminal was closed while delivering.
+func (vt *VirtualTerminal) dispatch(event Event) bool {
+	// The screen model was updated with this event by trackEvent just
+	// before, so its update count numbers the event for the log and for
+	// synced subscribers
+	var update uint64
+	switch event.(type) {
+	case InitEvent, OutputEvent, ResizeEvent:
+		update = vt.screen.updateCount()
+	}
+	seq := vt.log.append(event, update)
+	processed, keep := vt.processOutput(vt.output, event)
+	s := vt.current()
+
+	// Send to main events channel
+	if keep && !vt.send(s, processed) {
+		return false
+	}
+
+	// Send to subscribers. The list is copied so that a subscriber that
+	// blocks can still be removed.
+	vt.mu.RLock()
+	subscribers := slices.Clone(vt.subscribers)
+	vt.mu.RUnlock()
+
+	for _, sub := range subscribers {
+		if len(sub.types) > 0 && !slices.Contains(sub.types, event.Type()) {
+			continue
+		}
+		if sub.synced && update > 0 && update <= sub.screenAfter {
+			continue
+		}
+		e := event
+		if !sub.raw {
+			if !keep {
+				continue
+			}
+			e = processed
+		}
+		if !vt.deliver(s, sub, e, seq) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// deliver sends an event to a subscriber according to its overflow policy.
+// It returns false if the terminal was closed while waiting for room.
+func (vt *VirtualTerminal) deliver(s *session, sub *subscriber, event Event, seq uint64) bool {
+	sub.sendMu.Lock()
+	defer sub.sendMu.Unlock()
+
+	select {
+	case <-sub.done:
+		return true
+	default:
+	}
+	if seq <= sub.after {
+		// Already replayed
+		return true
+	}
+	event, ok := vt.processOutput(sub.output, event)
+	if !ok {
+		return true
+	}
+	// wrap stamps the event with the number of events missed before it
+	wrap := func() Event {
+		if !sub.sequenced {
+			return event
+		}
+		return SequencedEvent{Event: event, Seq: seq, Dropped: sub.gap}
+	}
+	lose := func() {
+		sub.gap++
+		sub.dropped.Add(1)
+		vt.dropped.Add(1)
+	}
+
+	select {
+	case sub.ch <- wrap():
+		sub.gap = 0
+		return true
+	default:
+	}
+	switch sub.overflow {
+	case OverflowDropNewest:
+		lose()
+		return true
+	case OverflowDropOldest:
+		for {
+			select {
+			case <-sub.done:
+				return true
+			case old := <-sub.ch:
+				if e, ok := old.(SequencedEvent); ok {
+					// Carry over the count of the discarded event
+					sub.gap += e.Dropped
+				}
+				lose()
+			default:
+			}
+			select {
+			case sub.ch <- wrap():
+				sub.gap = 0
+				return true
+			default:
+			}
+		}
+	}
+
+	// Wait for room, unless the subscriber is removed or the terminal is
+	// being closed
+	select {
+	case sub.ch <- wrap():
+		sub.gap = 0
+		return true
+	case <-sub.done:
+		return true
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
+// send delivers an event to the Events() channel of s according to
+// Config.Overflow. It returns false if the terminal was closed while
+// waiting for room.
+func (vt *VirtualTerminal) send(s *session, event Event) bool {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+
+	select {
+	case <-s.eventsDone:
+		// The session has ended; nobody reads its channel any more
+		return true
+	default:
+	}
+	select {
+	case s.events <- event:
+		return true
+	default:
+	}
+
+	policy := vt.config.Overflow
+	if _, ok := event.(ExitEvent); ok && policy == OverflowDropNewest {
+		// The last event must arrive; without a reader to wait for, make
+		// room for it instead
+		policy = OverflowDropOldest
+	}
+	switch policy {
+	case OverflowDropNewest:
+		vt.dropped.Add(1)
+		return true
+	case OverflowDropOldest:
+		for {
+			select {
+			case <-s.events:
+				vt.dropped.Add(1)
+			default:
+			}
+			select {
+			case s.events <- event:
+				return true
+			default:
+			}
+		}
+	}
+
+	// Wait for room, unless the terminal is being closed
+	select {
+	case s.events <- event:
+		return true
+	case <-s.eventsDone:
+		return true
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
+// DroppedEvents returns the number of events that were not delivered
+// because the Events channel or a subscriber channel was full, or because
+// they were longer than Config.MaxEventSize.
+func (vt *VirtualTerminal) DroppedEvents() uint64 {
+	return vt.dropped.Load()
+}
+
+// DroppedEventsFor returns the number of events the subscriber ch missed
+// because it fell behind, or 0 if ch is not subscribed.
+func (vt *VirtualTerminal) DroppedEventsFor(ch <-chan Event) uint64 {
+	vt.mu.RLock()
+	defer vt.mu.RUnlock()
+	for _, sub := range vt.subscribers {
+		if sub.ch == ch {
+			return sub.dropped.Load()
+		}
+	}
+	return 0
+}
+
+// processOutput runs p over event if it is an OutputEvent.
+func (vt *VirtualTerminal) processOutput(p OutputProcessor, event Event) (Event, bool) {
+	output, isOutput := event.(OutputEvent)
+	if p == nil || !isOutput {
+		return event, true
+	}
+	return p.Process(output)
+}
@@ -0,0 +1,392 @@
+package htlib
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// drain returns the Seq of the output events waiting on ch and the types
+// of the other events.
+func drain(ch <-chan Event) []string {
+	var got []string
+	for len(ch) > 0 {
+		switch e := (<-ch).(type) {
+		case OutputEvent:
+			got = append(got, e.Seq)
+		default:
+			got = append(got, string(e.Type()))
+		}
+	}
+	return got
+}
+
+func TestOverflowDropOldest(t *testing.T) {
+	vt := New(Config{EventBuffer: 2, Overflow: OverflowDropOldest})
+	for _, seq := range []string{"a", "b", "c"} {
+		vt.dispatch(OutputEvent{Seq: seq})
+	}
+	if got := drain(vt.Events()); len(got) != 2 || got[0] != "b" || got[1] != "c" {
+		t.Errorf("expected the newest events, got %q", got)
+	}
+	if n := vt.DroppedEvents(); n != 1 {
+		t.Errorf("expected 1 dropped event, got %d", n)
+	}
+}
+
+func TestOverflowDropNewest(t *testing.T) {
+	vt := New(Config{EventBuffer: 2, Overflow: OverflowDropNewest})
+	for _, seq := range []string{"a", "b", "c"} {
+		vt.dispatch(OutputEvent{Seq: seq})
+	}
+	vt.dispatch(ExitEvent{})
+	if got := drain(vt.Events()); len(got) != 2 || got[0] != "b" || got[1] != "exit" {
+		t.Errorf("expected the oldest events and the exit, got %q", got)
+	}
+	if n := vt.DroppedEvents(); n != 2 {
+		t.Errorf("expected 2 dropped events, got %d", n)
+	}
+}
+
+func TestOverflowBlock(t *testing.T) {
+	vt := New(Config{EventBuffer: 1})
+	vt.dispatch(OutputEvent{Seq: "a"})
+
+	done := make(chan bool)
+	go func() { done <- vt.dispatch(OutputEvent{Seq: "b"}) }()
+	select {
+	case <-done:
+		t.Fatal("expected dispatch to wait for room")
+	case <-time.After(50 * time.Millisecond):
+	}
+	<-vt.Events()
+	if ok := <-done; !ok {
+		t.Error("expected the event to be delivered")
+	}
+
+	go func() { done <- vt.dispatch(OutputEvent{Seq: "c"}) }()
+	vt.current().cancel()
+	if ok := <-done; ok {
+		t.Error("expected dispatch to give up once the terminal is closing")
+	}
+	if n := vt.DroppedEvents(); n != 0 {
+		t.Errorf("expected no dropped events, got %d", n)
+	}
+}
+
+func TestSubscriberOverflow(t *testing.T) {
+	vt := New(Config{EventBuffer: 2, Overflow: OverflowDropNewest})
+	sub := vt.Subscribe()
+	for _, seq := range []string{"a", "b", "c"} {
+		vt.dispatch(OutputEvent{Seq: seq})
+	}
+	if got := drain(sub); len(got) != 2 || got[0] != "a" || cap(sub) != 2 {
+		t.Errorf("expected the first 2 events in a channel of 2, got %q", got)
+	}
+	// One event dropped by Events and one by the subscriber
+	if n := vt.DroppedEvents(); n != 2 {
+		t.Errorf("expected 2 dropped events, got %d", n)
+	}
+}
+
+func TestSubscribeTypes(t *testing.T) {
+	vt := New(DefaultConfig())
+	resizes := vt.SubscribeTypes(EventTypeResize, EventTypeExit)
+	all := vt.Subscribe()
+	vt.dispatch(OutputEvent{Seq: "a"})
+	vt.dispatch(ResizeEvent{Cols: 80, Rows: 24})
+	vt.dispatch(OutputEvent{Seq: "b"})
+	vt.dispatch(ExitEvent{})
+
+	if got := drain(resizes); len(got) != 2 || got[0] != "resize" || got[1] != "exit" {
+		t.Errorf("expected only the resize and exit events, got %q", got)
+	}
+	if got := drain(all); len(got) != 4 {
+		t.Errorf("expected every event on an unfiltered subscriber, got %q", got)
+	}
+	if n := vt.DroppedEvents(); n != 0 {
+		t.Errorf("expected filtered events not to count as dropped, got %d", n)
+	}
+}
+
+func TestSubscribeContext(t *testing.T) {
+	vt := New(DefaultConfig())
+	ctx, cancel := context.WithCancel(context.Background())
+	sub := vt.SubscribeContext(ctx, WithEventTypes(EventTypeOutput))
+	vt.dispatch(ResizeEvent{})
+	vt.dispatch(OutputEvent{Seq: "a"})
+
+	cancel()
+	var got []string
+	for event := range sub {
+		got = append(got, event.(OutputEvent).Seq)
+	}
+	if len(got) != 1 || got[0] != "a" {
+		t.Errorf("expected the output event before the channel closed, got %q", got)
+	}
+	waitUntil(t, func() bool {
+		vt.mu.RLock()
+		defer vt.mu.RUnlock()
+		return len(vt.subscribers) == 0
+	})
+
+	// Removing the subscriber first stops watching ctx; Close closes the rest
+	early := vt.SubscribeContext(context.Background())
+	vt.Unsubscribe(early)
+	if _, ok := <-early; ok {
+		t.Error("expected the unsubscribed channel to be closed")
+	}
+	last := vt.SubscribeContext(context.Background())
+	vt.Close()
+	if _, ok := <-last; ok {
+		t.Error("expected Close to close the channel")
+	}
+}
+
+func TestSubscriberOverflowPolicies(t *testing.T) {
+	vt := New(Config{EventBuffer: 2, Overflow: OverflowDropOldest})
+	oldest := vt.Subscribe(WithOverflow(OverflowDropOldest))
+	newest := vt.Subscribe()
+	for _, seq := range []string{"a", "b", "c", "d"} {
+		vt.dispatch(OutputEvent{Seq: seq})
+	}
+	if n := vt.DroppedEventsFor(oldest); n != 2 {
+		t.Errorf("expected 2 events dropped for the ring buffer, got %d", n)
+	}
+	if got := drain(oldest); len(got) != 2 || got[0] != "c" || got[1] != "d" {
+		t.Errorf("expected the newest events, got %q", got)
+	}
+	if got := drain(newest); len(got) != 2 || got[0] != "a" || got[1] != "b" {
+		t.Errorf("expected the oldest events, got %q", got)
+	}
+	if n := vt.DroppedEventsFor(newest); n != 2 {
+		t.Errorf("expected 2 events dropped for the default policy, got %d", n)
+	}
+	if n := vt.DroppedEvents(); n != 6 {
+		t.Errorf("expected 6 dropped in total, including Events, got %d", n)
+	}
+}
+
+func TestSubscriberOverflowBlock(t *testing.T) {
+	vt := New(Config{EventBuffer: 1, Overflow: OverflowDropNewest})
+	sub := vt.Subscribe(WithOverflow(OverflowBlock))
+	vt.dispatch(OutputEvent{Seq: "a"})
+
+	done := make(chan bool)
+	go func() { done <- vt.dispatch(OutputEvent{Seq: "b"}) }()
+	select {
+	case <-done:
+		t.Fatal("expected dispatch to wait for the subscriber")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if e := <-sub; e.(OutputEvent).Seq != "a" {
+		t.Errorf("unexpected event %#v", e)
+	}
+	if !<-done {
+		t.Error("expected the event to be delivered")
+	}
+	if e := <-sub; e.(OutputEvent).Seq != "b" {
+		t.Errorf("expected no event to be lost, got %#v", e)
+	}
+
+	// A blocked delivery is given up when the subscriber is removed
+	vt.dispatch(OutputEvent{Seq: "c"})
+	go func() { done <- vt.dispatch(OutputEvent{Seq: "d"}) }()
+	time.Sleep(20 * time.Millisecond)
+	vt.Unsubscribe(sub)
+	if !<-done {
+		t.Error("expected dispatch to carry on")
+	}
+	if got := drain(sub); len(got) != 1 || got[0] != "c" {
+		t.Errorf("expected the buffered event, got %q", got)
+	}
+}
+
+func TestSubscribeSequence(t *testing.T) {
+	vt := New(Config{EventBuffer: 2, Overflow: OverflowDropNewest})
+	newest := vt.Subscribe(WithSequence(), WithEventTypes(EventTypeOutput))
+	oldest := vt.Subscribe(WithSequence(), WithOverflow(OverflowDropOldest))
+
+	vt.dispatch(OutputEvent{Seq: "a"})
+	vt.dispatch(ResizeEvent{})
+	vt.dispatch(OutputEvent{Seq: "b"})
+	vt.dispatch(OutputEvent{Seq: "c"})
+
+	// The resize is numbered but filtered out, and "c" is dropped
+	a, b := (<-newest).(SequencedEvent), (<-newest).(SequencedEvent)
+	if a.Seq != 1 || a.Dropped != 0 || b.Seq != 3 || b.Event.(OutputEvent).Seq != "b" {
+		t.Errorf("unexpected events %+v %+v", a, b)
+	}
+	vt.dispatch(OutputEvent{Seq: "d"})
+	if d := (<-newest).(SequencedEvent); d.Seq != 5 || d.Dropped != 1 {
+		t.Errorf("expected the next event to report the dropped one, got %+v", d)
+	}
+
+	// Three events were discarded to make room; the counts add up even
+	// though discarded events carried some of them
+	c, d := (<-oldest).(SequencedEvent), (<-oldest).(SequencedEvent)
+	if c.Seq != 4 || d.Seq != 5 || c.Dropped+d.Dropped != 3 {
+		t.Errorf("unexpected events %+v %+v", c, d)
+	}
+	if c.Type() != EventTypeOutput {
+		t.Errorf("expected the wrapped event's type, got %q", c.Type())
+	}
+}
+
+func TestSubscribeReplay(t *testing.T) {
+	vt := New(DefaultConfig())
+	vt.dispatch(SnapshotEvent{Text: "old"})
+	vt.dispatch(InitEvent{Cols: 80, Rows: 24})
+	for _, seq := range []string{"a", "b", "c"} {
+		vt.dispatch(OutputEvent{Seq: seq})
+	}
+	vt.dispatch(SnapshotEvent{Text: "new"})
+	vt.dispatch(OutputEvent{Seq: "d"})
+
+	// The snapshot from before the InitEvent is not replayed
+	state := vt.Subscribe(WithReplay(0))
+	if got := drain(state); len(got) != 2 || got[0] != "init" || got[1] != "snapshot" {
+		t.Errorf("expected the init and the last snapshot, got %q", got)
+	}
+	recent := vt.Subscribe(WithReplay(2), WithSequence())
+	vt.dispatch(OutputEvent{Seq: "e"})
+	var seqs []uint64
+	for len(recent) > 0 {
+		seqs = append(seqs, (<-recent).(SequencedEvent).Seq)
+	}
+	if len(seqs) != 4 || seqs[0] != 2 || seqs[1] != 6 || seqs[2] != 7 || seqs[3] != 8 {
+		t.Errorf("expected the init, the last 2 events and the new one, got %v", seqs)
+	}
+	outputs := vt.Subscribe(WithReplay(10), WithEventTypes(EventTypeOutput))
+	if got := drain(outputs); len(got) != 5 || got[0] != "a" || got[4] != "e" {
+		t.Errorf("expected the replayed output events, got %q", got)
+	}
+	if got := drain(vt.Subscribe()); len(got) != 0 {
+		t.Errorf("expected nothing without WithReplay, got %q", got)
+	}
+}
+
+func TestSubscribeBuffer(t *testing.T) {
+	vt := New(Config{EventBuffer: 4, Overflow: OverflowDropNewest})
+	small := vt.Subscribe(WithBuffer(1), WithOverflow(OverflowDropOldest))
+	large := vt.Subscribe(WithBuffer(1000))
+	if cap(small) != 1 || cap(large) != 1000 || cap(vt.Subscribe()) != 4 {
+		t.Fatalf("unexpected capacities %d %d", cap(small), cap(large))
+	}
+	for _, seq := range []string{"a", "b", "c"} {
+		vt.dispatch(OutputEvent{Seq: seq})
+	}
+	if got := drain(small); len(got) != 1 || got[0] != "c" {
+		t.Errorf("expected only the latest event, got %q", got)
+	}
+	if got := drain(large); len(got) != 3 {
+		t.Errorf("expected every event, got %q", got)
+	}
+}
+
+func TestSubscribeZeroBuffer(t *testing.T) {
+	vt, _ := newTestTerminal()
+	defer vt.Close()
+	vt.config.EventBuffer = 4
+
+	// A zero buffer falls back to Config.EventBuffer rather than leaving
+	// drop-oldest nothing to drop
+	sub := vt.Subscribe(WithBuffer(0), WithOverflow(OverflowDropOldest))
+	if cap(sub) != 4 {
+		t.Errorf("expected the configured capacity, got %d", cap(sub))
+	}
+	for range 10 {
+		vt.dispatch(OutputEvent{Seq: "x"})
+	}
+
+	done := make(chan struct{})
+	go func() {
+		vt.Unsubscribe(sub)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Unsubscribe hung")
+	}
+}
+
+func TestDispatchCloseStress(t *testing.T) {
+	for range 20 {
+		vt := New(Config{EventBuffer: 1})
+		stop := make(chan struct{})
+		var wg sync.WaitGroup
+		for range 4 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+					}
+					vt.dispatch(OutputEvent{Seq: "x"})
+				}
+			}()
+		}
+		for _, policy := range []OverflowPolicy{OverflowBlock, OverflowDropOldest, OverflowDropNewest} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				// Nobody reads these subscribers
+				for range 100 {
+					ctx, cancel := context.WithCancel(context.Background())
+					vt.SubscribeContext(ctx, WithOverflow(policy), WithBuffer(1))
+					vt.Unsubscribe(vt.Subscribe(WithOverflow(policy)))
+					cancel()
+				}
+			}()
+		}
+
+		// Events is never read, yet Close completes while events are sent
+		time.Sleep(time.Millisecond)
+		if err := vt.CloseWithTimeout(5 * time.Second); err != nil {
+			t.Fatalf("Close did not complete: %v", err)
+		}
+		close(stop)
+		wg.Wait()
+
+		// Late events are discarded rather than sent on closed channels
+		vt.dispatch(ExitEvent{})
+		for range vt.Events() {
+		}
+		for range vt.Subscribe() {
+		}
+	}
+}
+
+func TestSubscribeScreen(t *testing.T) {
+	vt, _ := newTestTerminal()
+	go func() {
+		for range vt.Events() {
+		}
+	}()
+	send := func(event Event) {
+		vt.trackEvent(event)
+		vt.dispatch(event)
+	}
+	send(OutputEvent{Seq: "a"})
+
+	// The copy is taken after the screen model has been updated with "b"
+	// but before "b" is dispatched: it must not be delivered again
+	event := OutputEvent{Seq: "b"}
+	vt.trackEvent(event)
+	sub, model := vt.subscribeScreen()
+	defer vt.Unsubscribe(sub)
+	vt.dispatch(event)
+	send(OutputEvent{Seq: "c"})
+
+	if text := model.snapshot().Line(0); text != "ab" {
+		t.Errorf("expected the copy to include a and b, got %q", text)
+	}
+	if got := drain(sub); len(got) != 1 || got[0] != "c" {
+		t.Errorf("expected only c to be delivered, got %q", got)
+	}
+}
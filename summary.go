@@ -0,0 +1,233 @@
+package htlib
+
+import (
+	"sync"
+	"time"
+
+	"github.com/io41/htlib.go/htproto"
+)
+
+// SessionSummary is a concise record of a session for logs and reports.
+// It encodes to JSON.
+type SessionSummary struct {
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+	// Commands are the command lines submitted at the shell prompt, in order
+	Commands []CommandSummary `json:"commands"`
+	// OutputBytes and OutputEvents count the output received from ht
+	OutputBytes  int64 `json:"outputBytes"`
+	OutputEvents int   `json:"outputEvents"`
+	// InputBytes counts the raw input sent; named keys are not counted
+	InputBytes int64           `json:"inputBytes"`
+	Resizes    []ResizeSummary `json:"resizes"`
+	// Errors are failed writes to ht and the error that ended the session
+	Errors []string `json:"errors"`
+	// Exit is how the program ended, or nil while it is running
+	Exit *ExitStatus `json:"exit,omitempty"`
+	// Cols, Rows and Screen are the final screen as text
+	Cols   int    `json:"cols"`
+	Rows   int    `json:"rows"`
+	Screen string `json:"screen"`
+}
+
+// CommandSummary is a command line submitted at the shell prompt.
+type CommandSummary struct {
+	// Command is the line as typed; editing by the shell, such as
+	// completion or history expansion, is not reflected
+	Command string    `json:"command"`
+	Start   time.Time `json:"start"`
+	// Duration is the time until the shell showed its prompt again, or
+	// until the summary was taken if it has not finished
+	Duration time.Duration `json:"duration"`
+	Finished bool          `json:"finished"`
+	// ExitCode is the exit code reported by shells with OSC 133 support,
+	// or nil if unknown
+	ExitCode *int `json:"exitCode,omitempty"`
+}
+
+// ResizeSummary is a resize of the terminal.
+type ResizeSummary struct {
+	Cols int       `json:"cols"`
+	Rows int       `json:"rows"`
+	Time time.Time `json:"time"`
+}
+
+// Summary returns a report of the session so far: the commands run with
+// their durations and exit codes, the amount of output, resizes, errors
+// and the final screen. Orchestration layers can log it as one record per
+// session instead of processing the event stream:
+//
+//	defer func() {
+//	    data, _ := json.Marshal(vt.Summary())
+//	    log.Printf("session: %s", data)
+//	}()
+//
+// Commands are recognized as lines submitted with Input, SendKeys or Batch
+// while the shell is at its prompt (see WaitForPrompt).
+func (vt *VirtualTerminal) Summary() *SessionSummary {
+	now := time.Now()
+	summary := vt.stats.summary(now)
+
+	if err := vt.Err(); err != nil {
+		summary.Errors = append(summary.Errors, err.Error())
+	}
+	s := vt.current()
+	select {
+	case <-s.exited:
+		status := s.status
+		summary.Exit = &status
+	default:
+	}
+
+	screen := vt.Screen()
+	summary.Cols, summary.Rows, summary.Screen = screen.Cols, screen.Rows, screen.Text()
+	return summary
+}
+
+// sessionStats collects the data for Summary as commands are sent and
+// events arrive.
+type sessionStats struct {
+	mu           sync.Mutex
+	start        time.Time
+	line         []rune // the command line being typed
+	commands     []CommandSummary
+	running      bool // the last command has not finished
+	outputBytes  int64
+	outputEvents int
+	inputBytes   int64
+	resizes      []ResizeSummary
+	errors       []string
+}
+
+// begin records the start of the session.
+func (s *sessionStats) begin(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.start = now
+}
+
+// input records a command sent to ht. atPrompt tells whether the shell was
+// at its prompt, so that a submitted line is a new command.
+func (s *sessionStats) input(cmd command, atPrompt bool, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch cmd.Type {
+	case htproto.CommandInput:
+		s.inputBytes += int64(len(cmd.Payload))
+		for _, r := range recordedPayload(cmd) {
+			atPrompt = s.typeRune(r, atPrompt, now)
+		}
+	case htproto.CommandSendKeys:
+		for _, key := range cmd.Keys {
+			switch key {
+			case KeyEnter, "Return", "C-m", "C-j", "^M", "^J":
+				atPrompt = s.typeRune('\r', atPrompt, now)
+			case KeySpace:
+				atPrompt = s.typeRune(' ', atPrompt, now)
+			case KeyBackspace:
+				atPrompt = s.typeRune(0x7f, atPrompt, now)
+			case "C-c", "^c", "^C":
+				atPrompt = s.typeRune(0x03, atPrompt, now)
+			default:
+				if r := []rune(key); len(r) == 1 {
+					atPrompt = s.typeRune(r[0], atPrompt, now)
+				}
+			}
+		}
+	}
+}
+
+// typeRune applies one typed character to the command line. It returns
+// whether the shell is still at its prompt afterwards. The caller must
+// hold s.mu.
+func (s *sessionStats) typeRune(r rune, atPrompt bool, now time.Time) bool {
+	switch {
+	case r == '\r' || r == '\n':
+		if atPrompt && !s.running {
+			s.commands = append(s.commands, CommandSummary{Command: string(s.line), Start: now})
+			s.running = true
+		}
+		s.line = s.line[:0]
+		return false
+	case r == 0x7f || r == '\b':
+		if len(s.line) > 0 {
+			s.line = s.line[:len(s.line)-1]
+		}
+	case r == 0x03:
+		s.line = s.line[:0]
+	case r >= 0x20:
+		s.line = append(s.line, r)
+	}
+	return atPrompt
+}
+
+// output records an output event and finishes the running command once
+// the shell is back at its prompt.
+func (s *sessionStats) output(n int, prompt *promptTracker, now time.Time) {
+	ready, _ := prompt.state()
+	code, codeSet := prompt.lastExit()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.outputBytes += int64(n)
+	s.outputEvents++
+
+	if s.running && ready {
+		c := &s.commands[len(s.commands)-1]
+		c.Duration = now.Sub(c.Start)
+		c.Finished = true
+		if codeSet {
+			c.ExitCode = &code
+		}
+		s.running = false
+	}
+}
+
+// exit records the exit code of the last command, as found by RunCommand
+// when the shell does not report it.
+func (s *sessionStats) exit(code int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n := len(s.commands); n > 0 && s.commands[n-1].ExitCode == nil {
+		s.commands[n-1].ExitCode = &code
+	}
+}
+
+// resize records a resize event.
+func (s *sessionStats) resize(cols, rows int, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resizes = append(s.resizes, ResizeSummary{Cols: cols, Rows: rows, Time: now})
+}
+
+// error records a failure.
+func (s *sessionStats) error(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errors = append(s.errors, err.Error())
+}
+
+// summary returns a copy of the statistics.
+func (s *sessionStats) summary(now time.Time) *SessionSummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	summary := &SessionSummary{
+		Start:        s.start,
+		Commands:     append([]CommandSummary(nil), s.commands...),
+		OutputBytes:  s.outputBytes,
+		OutputEvents: s.outputEvents,
+		InputBytes:   s.inputBytes,
+		Resizes:      append([]ResizeSummary(nil), s.resizes...),
+		Errors:       append([]string(nil), s.errors...),
+	}
+	if !s.start.IsZero() {
+		summary.Duration = now.Sub(s.start)
+	}
+	if s.running {
+		c := &summary.Commands[len(summary.Commands)-1]
+		c.Duration = now.Sub(c.Start)
+	}
+	return summary
+}
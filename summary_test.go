@@ -0,0 +1,57 @@
+package htlib
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestSummary(t *testing.T) {
+	vt, _ := newTestTerminal()
+	defer vt.Close()
+	ctx := context.Background()
+
+	vt.trackEvent(OutputEvent{Seq: "\x1b]133;A\x07$ \x1b]133;B\x07"})
+	vt.Input(ctx, "ls -l\n")
+	vt.trackEvent(OutputEvent{Seq: "\x1b]133;C\x07ls: cannot access\r\n\x1b]133;D;2\x07"})
+	vt.trackEvent(OutputEvent{Seq: "\x1b]133;A\x07$ \x1b]133;B\x07"})
+
+	// Input to a running command is not a new command
+	vt.SendKeys(ctx, "c", "a", "t", KeySpace, "x", KeyBackspace, "y", KeyEnter)
+	vt.Input(ctx, "answer\n")
+	vt.trackEvent(ResizeEvent{Cols: 100, Rows: 30})
+
+	summary := vt.Summary()
+	if len(summary.Commands) != 2 {
+		t.Fatalf("expected 2 commands, got %+v", summary.Commands)
+	}
+	first, second := summary.Commands[0], summary.Commands[1]
+	if first.Command != "ls -l" || !first.Finished || first.ExitCode == nil || *first.ExitCode != 2 {
+		t.Errorf("unexpected first command %+v", first)
+	}
+	if second.Command != "cat y" || second.Finished || second.ExitCode != nil || second.Duration <= 0 {
+		t.Errorf("unexpected second command %+v", second)
+	}
+	if summary.OutputEvents != 3 || summary.InputBytes != int64(len("ls -l\nanswer\n")) {
+		t.Errorf("unexpected counts %+v", summary)
+	}
+	if len(summary.Resizes) != 1 || summary.Resizes[0].Cols != 100 || summary.Cols != 100 || summary.Rows != 30 {
+		t.Errorf("unexpected resizes %+v", summary.Resizes)
+	}
+	if summary.Exit != nil || len(summary.Errors) != 0 {
+		t.Errorf("unexpected exit or errors %+v", summary)
+	}
+
+	data, err := json.Marshal(summary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded SessionSummary
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded.Commands[0].ExitCode, first.ExitCode) || decoded.Screen != summary.Screen {
+		t.Errorf("summary changed in JSON: %s", data)
+	}
+}
@@ -0,0 +1,180 @@
+package htlib
+
+import (
+	"time"
+)
+
+// RestartPolicy makes a terminal relaunch ht when the program running in
+// it exits unexpectedly, so that a long-lived session survives its shell
+// crashing or being killed:
+//
+//	config.RestartPolicy = &htlib.RestartPolicy{MaxRestarts: 5}
+//
+// A RestartEvent is sent before every restart, after the ExitEvent of the
+// old process. The terminal is restarted as by Restart, including
+// Config.Watchdogs and Config.OnReady.
+type RestartPolicy struct {
+	// MaxRestarts is the number of consecutive restarts before giving up,
+	// leaving the terminal exited (default: no limit)
+	MaxRestarts int
+	// Backoff is the delay before the first restart, doubled for every
+	// consecutive restart (default: 1s)
+	Backoff time.Duration
+	// MaxBackoff caps the delay between restarts (default: 30s)
+	MaxBackoff time.Duration
+	// ResetAfter starts counting consecutive restarts afresh once a
+	// process has run this long (default: 1m)
+	ResetAfter time.Duration
+	// OnSuccess also restarts the program when it exits with status 0,
+	// e.g. after typing exit in the shell, or with ExitCodeUnknown, as it
+	// may have succeeded. Set it to restart after every exit of an ht that
+	// does not report its program's status.
+	OnSuccess bool
+}
+
+// delay returns the delay before the given restart, counting from 1.
+func (p RestartPolicy) delay(attempt int) time.Duration {
+	delay, limit := p.Backoff, p.MaxBackoff
+	if delay <= 0 {
+		delay = time.Second
+	}
+	if limit <= 0 {
+		limit = 30 * time.Second
+	}
+	for i := 1; i < attempt && delay < limit; i++ {
+		delay *= 2
+	}
+	return min(delay, limit)
+}
+
+func (p RestartPolicy) resetAfter() time.Duration {
+	if p.ResetAfter <= 0 {
+		return time.Minute
+	}
+	return p.ResetAfter
+}
+
+// RestartEvent is sent when Config.RestartPolicy restarts the terminal,
+// after the ExitEvent of the old process and before the InitEvent of the
+// new one. It is the first event on the new channel returned by Events.
+type RestartEvent struct {
+	// Attempt counts consecutive restarts, starting at 1
+	Attempt int
+	// Status is how the old process ended
+	Status ExitStatus
+	// Delay is the time waited before starting the new process
+	Delay time.Duration
+	Time  time.Time
+}
+
+func (e RestartEvent) Type() EventType { return EventTypeRestart }
+
+// supervision is the state of Config.RestartPolicy, guarded by restartMu.
+type supervision struct {
+	// restarts is the number of consecutive restarts
+	restarts int
+	// launched is when the current process was started
+	launched time.Time
+}
+
+// supervise waits for the process of s to end and restarts the terminal
+// if policy asks for it. It does nothing if the terminal is closed or
+// restarted by Restart first.
+func (vt *VirtualTerminal) supervise(s *session, policy RestartPolicy) {
+	<-s.ctx.Done()
+
+	vt.restartMu.Lock()
+	defer vt.restartMu.Unlock()
+
+	vt.mu.RLock()
+	replaced := vt.closed || vt.sess != s
+	vt.mu.RUnlock()
+	if replaced {
+		return
+	}
+	select {
+	case <-s.exited:
+	default:
+		return
+	}
+	status := s.status
+	if (status.Success() || status.Code == ExitCodeUnknown) && !policy.OnSuccess {
+		return
+	}
+
+	if time.Since(vt.supervision.launched) >= policy.resetAfter() {
+		vt.supervision.restarts = 0
+	}
+	for {
+		if policy.MaxRestarts > 0 && vt.supervision.restarts >= policy.MaxRestarts {
+			return
+		}
+		vt.supervision.restarts++
+		attempt := vt.supervision.restarts
+
+		retry := vt.current() != s
+		if err := vt.replaceSession(retry); err != nil {
+			return
+		}
+		next := vt.current()
+		event := RestartEvent{Attempt: attempt, Status: status, Delay: policy.delay(attempt), Time: time.Now()}
+		vt.trackEvent(event)
+		vt.dispatch(event)
+
+		select {
+		case <-time.After(event.Delay):
+		case <-next.ctx.Done():
+			// Closed while waiting
+			next.closeEvents()
+			return
+		}
+
+		err := vt.launch(next.ctx, startOptions{})
+		vt.mu.RLock()
+		started, closed := vt.started, vt.closed
+		vt.mu.RUnlock()
+		switch {
+		case started:
+			// The new process is supervised on its own, see launch
+			return
+		case closed:
+			next.closeEvents()
+			return
+		}
+
+		// ht could not be started at all: try again, or end the session
+		// as if the process had exited
+		if policy.MaxRestarts > 0 && vt.supervision.restarts >= policy.MaxRestarts {
+			vt.abandon(next, err)
+			return
+		}
+	}
+}
+
+// abandon ends a session whose process failed to start, sending the
+// ExitEvent and closing the events channel as waitForExit would.
+func (vt *VirtualTerminal) abandon(s *session, err error) {
+	status := ExitStatus{Code: -1}
+	vt.mu.Lock()
+	vt.err = err
+	s.status = status
+	vt.mu.Unlock()
+	close(s.exited)
+
+	exit := ExitEvent{Code: status.Code, Err: err, Time: time.Now()}
+	vt.trackEvent(exit)
+	vt.dispatch(exit)
+	s.cancel()
+	s.closeEvents()
+}
+
+// startSupervising watches the process just started by launch if
+// Config.RestartPolicy is set. The caller must hold restartMu.
+func (vt *VirtualTerminal) startSupervising() {
+	policy := vt.config.RestartPolicy
+	if policy == nil {
+		return
+	}
+	vt.supervision.launched = time.Now()
+	go vt.supervise(vt.current(), *policy)
+}
@@ -0,0 +1,143 @@
+package htlib
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// nextEvent returns the next event of type T received on sub.
+func nextEvent[T Event](ctx context.Context, t *testing.T, sub <-chan Event) T {
+	t.Helper()
+	for {
+		select {
+		case event := <-sub:
+			if e, ok := event.(T); ok {
+				return e
+			}
+		case <-ctx.Done():
+			var e T
+			t.Fatalf("timed out waiting for a %s event", e.Type())
+		}
+	}
+}
+
+func TestRestartPolicy(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	config := DefaultConfig()
+	config.RestartPolicy = &RestartPolicy{MaxRestarts: 2, Backoff: 10 * time.Millisecond}
+	vt := New(config)
+	defer vt.Close()
+	sub := vt.Subscribe()
+	if err := vt.Start(ctx, WaitReady()); err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		pid := vt.PID()
+		if err := vt.Input(ctx, "exit 3\r"); err != nil {
+			t.Fatal(err)
+		}
+		restart := nextEvent[RestartEvent](ctx, t, sub)
+		if restart.Attempt != attempt || restart.Status.Code != 3 {
+			t.Errorf("unexpected restart %+v", restart)
+		}
+		if want := 10 * time.Millisecond << (attempt - 1); restart.Delay != want {
+			t.Errorf("expected a delay of %v, got %v", want, restart.Delay)
+		}
+		if init := nextEvent[InitEvent](ctx, t, sub); init.PID == pid {
+			t.Errorf("expected a new process after restart %d", attempt)
+		}
+		if _, err := vt.WaitFor(ctx, func(s *SnapshotEvent) bool { return vt.AtPrompt() }); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The third exit is past MaxRestarts and ends the session
+	if err := vt.Input(ctx, "exit 5\r"); err != nil {
+		t.Fatal(err)
+	}
+	if status, err := vt.WaitForExit(ctx); err != nil || status.Code != 5 {
+		t.Fatalf("expected exit status 5, got %v %v", status, err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	for len(sub) > 0 {
+		if event := <-sub; event.Type() == EventTypeRestart {
+			t.Errorf("expected no more restarts, got %+v", event)
+		}
+	}
+}
+
+func TestRestartPolicySuccess(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	config := DefaultConfig()
+	config.RestartPolicy = &RestartPolicy{Backoff: 10 * time.Millisecond}
+	vt := New(config)
+	defer vt.Close()
+	if err := vt.Start(ctx, WaitReady()); err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+	events := vt.Events()
+	if err := vt.Input(ctx, "exit\r"); err != nil {
+		t.Fatal(err)
+	}
+	for event := range events {
+		if event.Type() == EventTypeRestart {
+			t.Errorf("expected no restart after a successful exit, got %+v", event)
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	if vt.Events() != events {
+		t.Error("expected the terminal to stay exited")
+	}
+}
+
+func TestRestartPolicyCloseWhileWaiting(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	config := DefaultConfig()
+	config.RestartPolicy = &RestartPolicy{Backoff: time.Hour, OnSuccess: true}
+	vt := New(config)
+	defer vt.Close()
+	sub := vt.Subscribe()
+	if err := vt.Start(ctx, WaitReady()); err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+	if err := vt.Input(ctx, "exit\r"); err != nil {
+		t.Fatal(err)
+	}
+	if restart := nextEvent[RestartEvent](ctx, t, sub); restart.Delay != 30*time.Second {
+		t.Errorf("expected the delay to be capped at 30s, got %v", restart.Delay)
+	}
+
+	events := vt.Events()
+	vt.Close()
+	for {
+		select {
+		case _, ok := <-events:
+			if !ok {
+				return
+			}
+		case <-ctx.Done():
+			t.Fatal("events channel not closed after Close")
+		}
+	}
+}
+
+func TestRestartPolicyDelay(t *testing.T) {
+	policy := RestartPolicy{Backoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second}
+	for i, d := range want {
+		if got := policy.delay(i + 1); got != d {
+			t.Errorf("delay(%d) = %v, want %v", i+1, got, d)
+		}
+	}
+	if got := (RestartPolicy{}).delay(1); got != time.Second {
+		t.Errorf("expected a default backoff of 1s, got %v", got)
+	}
+}
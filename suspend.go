@@ -0,0 +1,59 @@
+package htlib
+
+import (
+	"sync"
+	"time"
+)
+
+// Suspended reports whether the terminal has been stopped with Suspend.
+func (vt *VirtualTerminal) Suspended() bool {
+	vt.suspension.mu.Lock()
+	defer vt.suspension.mu.Unlock()
+	return !vt.suspension.since.IsZero()
+}
+
+// suspensionClock keeps the periods a terminal was suspended.
+type suspensionClock struct {
+	mu      sync.Mutex
+	since   time.Time // start of the current suspension, zero if running
+	groups  []int     // process groups stopped by the current suspension
+	periods []suspendedPeriod
+}
+
+type suspendedPeriod struct {
+	start, end time.Time
+}
+
+// end finishes the current suspension at t. The caller must hold c.mu.
+func (c *suspensionClock) end(t time.Time) {
+	if c.since.IsZero() {
+		return
+	}
+	c.periods = append(c.periods, suspendedPeriod{start: c.since, end: t})
+	c.since, c.groups = time.Time{}, nil
+}
+
+// between returns how long the terminal was suspended between from and to.
+func (c *suspensionClock) between(from, to time.Time) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	periods := c.periods
+	if !c.since.IsZero() {
+		periods = append(periods[:len(periods):len(periods)], suspendedPeriod{start: c.since, end: to})
+	}
+	var total time.Duration
+	for _, p := range periods {
+		start, end := p.start, p.end
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if end.After(start) {
+			total += end.Sub(start)
+		}
+	}
+	return total
+}
@@ -0,0 +1,110 @@
+package htlib
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSuspendResume(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	config := DefaultConfig()
+	config.Binary = "/bin/sh"
+	config.Args = []string{"-c", "i=0; while :; do i=$((i+1)); echo tick $i; sleep 0.02; done"}
+	vt := New(config)
+	defer vt.Close()
+
+	if err := vt.Start(ctx); err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+	if _, err := vt.WaitForText(ctx, "tick 3"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := vt.Suspend(); err != nil {
+		t.Fatal(err)
+	}
+	if !vt.Suspended() {
+		t.Error("expected the terminal to be suspended")
+	}
+	if err := vt.Suspend(); err != nil {
+		t.Errorf("expected suspending twice to do nothing, got %v", err)
+	}
+
+	// Let output already written reach the screen, then expect no more
+	time.Sleep(100 * time.Millisecond)
+	stopped := vt.Screen().Text()
+	time.Sleep(200 * time.Millisecond)
+	if text := vt.Screen().Text(); text != stopped {
+		t.Errorf("expected no output while suspended, got %q after %q", text, stopped)
+	}
+
+	if err := vt.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	if vt.Suspended() {
+		t.Error("expected the terminal to be running")
+	}
+	if err := vt.Resume(); err != nil {
+		t.Errorf("expected resuming twice to do nothing, got %v", err)
+	}
+	waitUntil(t, func() bool { return vt.Screen().Text() != stopped })
+
+	if d := vt.suspension.between(time.Now().Add(-time.Minute), time.Now()); d < 300*time.Millisecond {
+		t.Errorf("expected at least 300ms suspended, got %v", d)
+	}
+}
+
+func TestSuspendNotStarted(t *testing.T) {
+	vt := New(DefaultConfig())
+	if err := vt.Suspend(); !errors.Is(err, ErrNotStarted) {
+		t.Errorf("expected ErrNotStarted, got %v", err)
+	}
+	if vt.Suspended() {
+		t.Error("expected a failed Suspend to leave the terminal running")
+	}
+	if err := vt.Resume(); err != nil {
+		t.Errorf("expected Resume to do nothing, got %v", err)
+	}
+}
+
+func TestSuspensionBetween(t *testing.T) {
+	var c suspensionClock
+	base := time.Now()
+	at := func(s int) time.Time { return base.Add(time.Duration(s) * time.Second) }
+
+	c.since = at(10)
+	c.end(at(20))
+	c.since = at(30)
+
+	tests := []struct {
+		from, to int
+		want     time.Duration
+	}{
+		{0, 5, 0},
+		{0, 15, 5 * time.Second},
+		{12, 25, 8 * time.Second},
+		{0, 40, 20 * time.Second}, // the current suspension counts up to 40
+		{22, 28, 0},
+	}
+	for _, tt := range tests {
+		if got := c.between(at(tt.from), at(tt.to)); got != tt.want {
+			t.Errorf("between(%d, %d) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestRecorderSkipsSuspension(t *testing.T) {
+	vt, _ := newTestTerminal()
+	r := vt.Record()
+	defer r.Close()
+
+	vt.suspension.since = r.start.Add(time.Second)
+	vt.suspension.end(r.start.Add(3 * time.Second))
+	if got := r.offset(r.start.Add(5 * time.Second)); got != 3*time.Second {
+		t.Errorf("expected 3s without the suspension, got %v", got)
+	}
+}
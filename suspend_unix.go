@@ -0,0 +1,78 @@
+//go:build unix
+
+package htlib
+
+import (
+	"fmt"
+	"syscall"
+	"time"
+)
+
+// Suspend stops the program running in the terminal and the command in
+// the foreground with SIGSTOP, for testing how a program copes with being
+// stopped or for throttling a noisy session. Unlike SuspendForeground,
+// which types Ctrl-Z, the shell is stopped too and does not notice.
+//
+// Time spent suspended is left out of recordings and of watchdog prompt
+// timeouts, so a session plays back as if it had not been stopped. Event
+// times remain wall-clock times. Suspend does nothing if the terminal is
+// already suspended; it fails like Signal if there is no process.
+func (vt *VirtualTerminal) Suspend() error {
+	vt.suspension.mu.Lock()
+	defer vt.suspension.mu.Unlock()
+	if !vt.suspension.since.IsZero() {
+		return nil
+	}
+
+	pid, err := vt.livePID()
+	if err != nil {
+		return err
+	}
+	// Stop the shell first so it cannot react to its job stopping
+	groups := []int{pid}
+	if pgid, ok := foregroundGroup(pid); ok && pgid != pid {
+		groups = append(groups, pgid)
+	}
+	if err := stopGroups(groups, syscall.Kill); err != nil {
+		return err
+	}
+	vt.suspension.since = time.Now()
+	vt.suspension.groups = groups
+	return nil
+}
+
+// Resume continues a terminal stopped with Suspend. It does nothing if the
+// terminal is not suspended.
+func (vt *VirtualTerminal) Resume() error {
+	vt.suspension.mu.Lock()
+	defer vt.suspension.mu.Unlock()
+	if vt.suspension.since.IsZero() {
+		return nil
+	}
+
+	// Continue the job before the shell, which would otherwise see it stopped
+	var firstErr error
+	for i := len(vt.suspension.groups) - 1; i >= 0; i-- {
+		pgid := vt.suspension.groups[i]
+		if err := syscall.Kill(-pgid, syscall.SIGCONT); err != nil && err != syscall.ESRCH && firstErr == nil {
+			firstErr = fmt.Errorf("failed to continue process group %d: %w", pgid, err)
+		}
+	}
+	vt.suspension.end(time.Now())
+	return firstErr
+}
+
+// stopGroups sends SIGSTOP to each process group in turn with kill. If one
+// cannot be stopped, the groups stopped before it are continued again, as
+// there is then no suspension for Resume to end.
+func stopGroups(groups []int, kill func(pid int, sig syscall.Signal) error) error {
+	for i, pgid := range groups {
+		if err := kill(-pgid, syscall.SIGSTOP); err != nil {
+			for j := i - 1; j >= 0; j-- {
+				kill(-groups[j], syscall.SIGCONT)
+			}
+			return fmt.Errorf("failed to stop process group %d: %w", pgid, err)
+		}
+	}
+	return nil
+}
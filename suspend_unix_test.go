@@ -0,0 +1,29 @@
+//go:build unix
+
+package htlib
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"syscall"
+	"testing"
+)
+
+func TestStopGroupsRollsBack(t *testing.T) {
+	var sent []string
+	kill := func(pid int, sig syscall.Signal) error {
+		sent = append(sent, fmt.Sprintf("%d %v", pid, sig))
+		if pid == -2 && sig == syscall.SIGSTOP {
+			return syscall.EPERM
+		}
+		return nil
+	}
+	if err := stopGroups([]int{1, 2}, kill); !errors.Is(err, syscall.EPERM) {
+		t.Fatalf("expected the failure, got %v", err)
+	}
+	expected := []string{"-1 stopped (signal)", "-2 stopped (signal)", "-1 continued"}
+	if !slices.Equal(sent, expected) {
+		t.Errorf("expected the first group to be continued, got %q", sent)
+	}
+}
@@ -0,0 +1,14 @@
+package htlib
+
+import "fmt"
+
+// Suspend is not available on Windows, which cannot stop a process with a
+// signal. It returns an error matching ErrUnsupported.
+func (vt *VirtualTerminal) Suspend() error {
+	return fmt.Errorf("%w: processes cannot be suspended on Windows", ErrUnsupported)
+}
+
+// Resume does nothing on Windows, where Suspend is not available.
+func (vt *VirtualTerminal) Resume() error {
+	return nil
+}
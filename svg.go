@@ -0,0 +1,157 @@
+package htlib
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+)
+
+// Geometry of SVG exports, in pixels.
+const (
+	svgFontSize   = 14
+	svgCellWidth  = 84 // tenths of a pixel: 0.6em, the advance of common monospace fonts
+	svgCellHeight = 17
+	svgBaseline   = 13 // from the top of a cell
+	svgPadding    = 10
+)
+
+// SVGOption configures an SVG export.
+type SVGOption func(*svgOptions)
+
+type svgOptions struct {
+	heatmap  *Heatmap
+	metadata *Metadata
+}
+
+// WithHeatmapOverlay shades each cell of the export by how often it changed
+// in h, from transparent for cells that never changed to translucent red
+// for the most changed ones.
+func WithHeatmapOverlay(h *Heatmap) SVGOption {
+	return func(o *svgOptions) {
+		o.heatmap = h
+	}
+}
+
+// WithSVGMetadata describes the session in the export: m.Title becomes
+// the <title> of the image, shown by viewers as its name, and m as JSON
+// goes into its <metadata> element. vt.SVG adds vt.Metadata() this way.
+func WithSVGMetadata(m Metadata) SVGOption {
+	return func(o *svgOptions) {
+		o.metadata = &m
+	}
+}
+
+// SVG renders the screen as a standalone SVG image on a monospace grid,
+// with colors, attributes and a visible cursor in reverse video, like a
+// single frame of termtosvg. It embeds well in docs and PR descriptions:
+//
+//	os.WriteFile("screen.svg", []byte(vt.Screen().SVG()), 0o644)
+func (s *Screen) SVG(opts ...SVGOption) string {
+	var o svgOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	width := strconv.FormatFloat(float64(20*svgPadding+s.Cols*svgCellWidth)/10, 'f', -1, 64)
+	height := 2*svgPadding + s.Rows*svgCellHeight
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%s" height="%d" viewBox="0 0 %s %d">`, width, height, width, height)
+	if m := o.metadata; m != nil {
+		if m.Title != "" {
+			fmt.Fprintf(&b, `<title>%s</title>`, html.EscapeString(m.Title))
+		}
+		if data, err := json.Marshal(m); err == nil {
+			fmt.Fprintf(&b, `<metadata>%s</metadata>`, html.EscapeString(string(data)))
+		}
+	}
+	fmt.Fprintf(&b, `<rect width="100%%" height="100%%" fill="%s"/>`, defaultBgHex)
+	fmt.Fprintf(&b, `<g font-family="monospace" font-size="%d" xml:space="preserve">`, svgFontSize)
+
+	for row, line := range s.cells {
+		y := svgPadding + row*svgCellHeight
+		for col := 0; col < len(line); {
+			style := s.cellStyle(row, col)
+			start := col
+			var text strings.Builder
+			for col < len(line) && s.cellStyle(row, col) == style {
+				text.WriteString(line[col].String())
+				col++
+			}
+
+			x := svgX(start)
+			fg, bg := style.colors()
+			if bg != defaultBgHex {
+				fmt.Fprintf(&b, `<rect x="%s" y="%d" width="%s" height="%d" fill="%s"/>`, x, y, svgWidth(col-start), svgCellHeight, bg)
+			}
+			if strings.TrimSpace(text.String()) == "" || style.Invisible {
+				continue
+			}
+			fmt.Fprintf(&b, `<text x="%s" y="%d" fill="%s"%s>%s</text>`, x, y+svgBaseline, fg, style.svgAttrs(), html.EscapeString(text.String()))
+		}
+	}
+	b.WriteString("</g>")
+
+	if h := o.heatmap; h != nil {
+		if highest := h.Max(); highest > 0 {
+			for _, cell := range h.Hottest(h.Cols * h.Rows) {
+				if cell.Row >= s.Rows || cell.Col >= s.Cols {
+					continue
+				}
+				fmt.Fprintf(&b, `<rect x="%s" y="%d" width="%s" height="%d" fill="#ff0000" fill-opacity="%.2f"/>`,
+					svgX(cell.Col), svgPadding+cell.Row*svgCellHeight,
+					svgWidth(1), svgCellHeight, 0.6*float64(cell.Count)/float64(highest))
+			}
+		}
+	}
+	b.WriteString("</svg>")
+	return b.String()
+}
+
+// svgWidth returns the width of cols cells in pixels.
+func svgWidth(cols int) string {
+	return strconv.FormatFloat(float64(cols*svgCellWidth)/10, 'f', -1, 64)
+}
+
+// svgX returns the x coordinate of the 0-based column in pixels.
+func svgX(col int) string {
+	return strconv.FormatFloat(float64(10*svgPadding+col*svgCellWidth)/10, 'f', -1, 64)
+}
+
+// svgAttrs returns the SVG text attributes for the attributes of style.
+func (s Style) svgAttrs() string {
+	var attrs strings.Builder
+	if s.Bold {
+		attrs.WriteString(` font-weight="bold"`)
+	}
+	if s.Italic {
+		attrs.WriteString(` font-style="italic"`)
+	}
+	if s.Faint {
+		attrs.WriteString(` opacity="0.5"`)
+	}
+	var decorations []string
+	if s.Underline {
+		decorations = append(decorations, "underline")
+	}
+	if s.Strikethrough {
+		decorations = append(decorations, "line-through")
+	}
+	if len(decorations) > 0 {
+		fmt.Fprintf(&attrs, ` text-decoration="%s"`, strings.Join(decorations, " "))
+	}
+	return attrs.String()
+}
+
+// SVG renders the current screen like Screen.SVG, with the session's
+// Metadata embedded as by WithSVGMetadata.
+func (vt *VirtualTerminal) SVG(opts ...SVGOption) string {
+	return vt.Screen().SVG(append([]SVGOption{WithSVGMetadata(vt.Metadata())}, opts...)...)
+}
+
+// SVG renders the snapshot like Screen.SVG.
+func (e SnapshotEvent) SVG(opts ...SVGOption) string {
+	return e.Screen().SVG(opts...)
+}
@@ -0,0 +1,80 @@
+package htlib
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScreenSVG(t *testing.T) {
+	m := newScreenModel(10, 2)
+	m.feed("a<b \x1b[1;41mERR\x1b[0m\r\n\x1b[3mx")
+	svg := m.snapshot().SVG()
+
+	// The export is well-formed XML
+	decoder := xml.NewDecoder(strings.NewReader(svg))
+	for {
+		if _, err := decoder.Token(); err != nil {
+			if err.Error() != "EOF" {
+				t.Fatalf("invalid SVG: %v\n%s", err, svg)
+			}
+			break
+		}
+	}
+
+	for _, want := range []string{
+		`width="104" height="54"`,
+		`<text x="10" y="23" fill="#e5e5e5">a&lt;b </text>`,
+		`<rect x="43.6" y="10" width="25.2" height="17" fill="#cd0000"/>`,
+		`<text x="43.6" y="23" fill="#e5e5e5" font-weight="bold">ERR</text>`,
+		`<text x="10" y="40" fill="#e5e5e5" font-style="italic">x</text>`,
+		// The cursor after the x
+		`<rect x="18.4" y="27" width="8.4" height="17" fill="#e5e5e5"/>`,
+	} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("expected %s in\n%s", want, svg)
+		}
+	}
+}
+
+func TestScreenSVGHeatmapOverlay(t *testing.T) {
+	m := newScreenModel(4, 2)
+	h := &Heatmap{Cols: 4, Rows: 2, Counts: [][]int{{4, 0, 0, 0}, {0, 2, 0, 0}}}
+	svg := m.snapshot().SVG(WithHeatmapOverlay(h))
+
+	if strings.Count(svg, `fill="#ff0000"`) != 2 {
+		t.Errorf("expected two shaded cells in\n%s", svg)
+	}
+	if !strings.Contains(svg, `<rect x="10" y="10" width="8.4" height="17" fill="#ff0000" fill-opacity="0.60"/>`) ||
+		!strings.Contains(svg, `fill-opacity="0.30"`) {
+		t.Errorf("expected opacity by change count in\n%s", svg)
+	}
+}
+
+func TestTerminalSVG(t *testing.T) {
+	vt, _ := newTestTerminal()
+	defer vt.Close()
+	vt.config.Metadata = Metadata{Title: "a <b> & c", GitSHA: "abc123"}
+
+	var doc struct {
+		Title    string `xml:"title"`
+		Metadata string `xml:"metadata"`
+	}
+	svg := vt.SVG()
+	if err := xml.Unmarshal([]byte(svg), &doc); err != nil {
+		t.Fatalf("invalid SVG: %v\n%s", err, svg)
+	}
+	var meta Metadata
+	if err := json.Unmarshal([]byte(doc.Metadata), &meta); err != nil {
+		t.Fatalf("invalid metadata %q: %v", doc.Metadata, err)
+	}
+	if doc.Title != "a <b> & c" || !reflect.DeepEqual(meta, vt.Metadata()) {
+		t.Errorf("unexpected title %q and metadata %+v", doc.Title, meta)
+	}
+
+	if svg := vt.Screen().SVG(); strings.Contains(svg, "<metadata>") {
+		t.Errorf("expected no metadata without the option, got\n%s", svg)
+	}
+}
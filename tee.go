@@ -0,0 +1,64 @@
+package htlib
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// OutputTee copies the terminal's output to a writer. Its methods may be
+// called from any goroutine.
+type OutputTee struct {
+	vt *VirtualTerminal
+
+	mu  sync.Mutex
+	w   io.Writer
+	err error
+}
+
+// TeeOutput starts copying the raw output of the terminal, escape
+// sequences included, to w as it arrives, e.g. to watch a session being
+// automated:
+//
+//	tee := vt.TeeOutput(os.Stdout)
+//	defer tee.Close()
+//
+// Output is written as it is handled, before it reaches subscribers and
+// Config.OutputProcessors, so a slow writer holds up the terminal. Call
+// Close to stop copying.
+func (vt *VirtualTerminal) TeeOutput(w io.Writer) *OutputTee {
+	t := &OutputTee{vt: vt, w: w}
+	vt.mu.Lock()
+	vt.tees = append(vt.tees, t)
+	vt.mu.Unlock()
+	return t
+}
+
+// Close stops copying and returns the first error writing to the writer,
+// if any. It is safe to call Close more than once.
+func (t *OutputTee) Close() error {
+	t.vt.mu.Lock()
+	t.vt.tees = without(t.vt.tees, t)
+	t.vt.mu.Unlock()
+	return t.Err()
+}
+
+// Err returns the first error writing to the writer. Copying stops after
+// an error.
+func (t *OutputTee) Err() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.err
+}
+
+// write copies one OutputEvent.
+func (t *OutputTee) write(seq string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.err != nil {
+		return
+	}
+	if _, err := io.WriteString(t.w, seq); err != nil {
+		t.err = fmt.Errorf("failed to copy output: %w", err)
+	}
+}
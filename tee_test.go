@@ -0,0 +1,30 @@
+package htlib
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTeeOutput(t *testing.T) {
+	vt, _ := newTestTerminal()
+	defer vt.Close()
+
+	var buf bytes.Buffer
+	tee := vt.TeeOutput(&buf)
+	vt.trackEvent(OutputEvent{Seq: "ls\r\n"})
+	vt.trackEvent(ResizeEvent{Cols: 100, Rows: 30})
+	vt.trackEvent(OutputEvent{Seq: "\x1b[32ma.txt\x1b[0m\r\n"})
+	if err := tee.Close(); err != nil {
+		t.Fatal(err)
+	}
+	vt.trackEvent(OutputEvent{Seq: "not copied"})
+	if got := buf.String(); got != "ls\r\n\x1b[32ma.txt\x1b[0m\r\n" {
+		t.Errorf("unexpected output %q", got)
+	}
+
+	tee = vt.TeeOutput(failingWriter{})
+	vt.trackEvent(OutputEvent{Seq: "x"})
+	if err := tee.Close(); err == nil || err.Error() != "failed to copy output: disk full" {
+		t.Errorf("expected the write error, got %v", err)
+	}
+}
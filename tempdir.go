@@ -0,0 +1,67 @@
+package htlib
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// TempDir creates a new unique directory for the session, changes the
+// shell's working directory to it and returns its path. The directory is
+// created under TMPDIR (taken from Config.Env when set there) and is removed
+// with all of its contents on Close unless Config.KeepTempDirs is set.
+//
+// The cd command is typed into the terminal like any other input, so
+// commands sent afterwards run inside the new directory.
+func (vt *VirtualTerminal) TempDir(ctx context.Context) (string, error) {
+	dir, err := os.MkdirTemp(vt.tempRoot(), "htlib-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp dir: %w", err)
+	}
+
+	vt.mu.Lock()
+	vt.tempDirs = append(vt.tempDirs, dir)
+	vt.mu.Unlock()
+
+	if err := vt.Input(ctx, "cd "+shellQuote(dir)+"\n"); err != nil {
+		return dir, err
+	}
+	return dir, nil
+}
+
+// tempRoot returns the TMPDIR configured for the terminal, falling back to
+// the default temp directory.
+func (vt *VirtualTerminal) tempRoot() string {
+	for i := len(vt.config.Env) - 1; i >= 0; i-- {
+		if value, ok := strings.CutPrefix(vt.config.Env[i], "TMPDIR="); ok && value != "" {
+			return value
+		}
+	}
+	return os.TempDir()
+}
+
+// removeTempDirs deletes directories created by TempDir.
+func (vt *VirtualTerminal) removeTempDirs() error {
+	vt.mu.Lock()
+	dirs := vt.tempDirs
+	vt.tempDirs = nil
+	vt.mu.Unlock()
+
+	if vt.config.KeepTempDirs {
+		return nil
+	}
+
+	var firstErr error
+	for _, dir := range dirs {
+		if err := os.RemoveAll(dir); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to remove temp dir: %w", err)
+		}
+	}
+	return firstErr
+}
+
+// shellQuote quotes s for use as a single POSIX shell word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,73 @@
+package htlib
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"/tmp/dir", "'/tmp/dir'"},
+		{"it's", `'it'\''s'`},
+		{"a b", "'a b'"},
+	}
+
+	for _, tt := range tests {
+		if result := shellQuote(tt.input); result != tt.expected {
+			t.Errorf("shellQuote(%q): expected %s, got %s", tt.input, tt.expected, result)
+		}
+	}
+}
+
+func TestTempDir(t *testing.T) {
+	root := t.TempDir()
+	cfg := DefaultConfig()
+	cfg.Env = []string{"TMPDIR=" + root}
+
+	vt := New(cfg)
+	ctx := context.Background()
+
+	if err := vt.Start(ctx); err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+
+	// Wait for init
+	<-vt.Events()
+
+	dir, err := vt.TempDir(ctx)
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	if !strings.HasPrefix(dir, root) {
+		t.Errorf("expected temp dir under %s, got %s", root, dir)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := vt.Input(ctx, "pwd\n"); err != nil {
+		t.Fatalf("failed to send input: %v", err)
+	}
+	for {
+		snapshot, err := vt.WaitForSnapshot(ctx)
+		if err != nil {
+			t.Fatalf("pwd output never appeared: %v", err)
+		}
+		if strings.Count(snapshot.Text, dir) >= 2 {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	_ = vt.Close()
+
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("expected temp dir to be removed on close, got %v", err)
+	}
+}
@@ -0,0 +1,188 @@
+package htlib
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/io41/htlib.go/htproto"
+)
+
+// Transcript is a session log: every input sent to the terminal followed
+// by the output that came before the next input.
+type Transcript struct {
+	Metadata Metadata          `json:"metadata"`
+	Entries  []TranscriptEntry `json:"entries"`
+}
+
+// TranscriptEntry is one input and the output that followed it. The first
+// entry of a transcript holds the output seen before the first input and
+// has neither Input, Redacted nor Keys.
+type TranscriptEntry struct {
+	Time time.Time `json:"time"`
+	// Input is the raw text sent with Input, Batch or similar
+	Input string `json:"input,omitempty"`
+	// Redacted is set instead of Input for a secret sent with InputSecret
+	Redacted bool `json:"redacted,omitempty"`
+	// Keys are the key names sent with SendKeys
+	Keys []string `json:"keys,omitempty"`
+	// Output is the output as plain text, without escape sequences and with
+	// carriage returns applied as in ReadScrollback, then normalized with
+	// Config.Normalization. While the entry is the last one and recording
+	// goes on, its last line is left as is until it is complete.
+	Output string `json:"output"`
+}
+
+// String formats the transcript as a readable log: the metadata that is
+// set, one field per line, then each input on a timestamped line followed
+// by its output, indented:
+//
+//	# title: login test
+//	# command: bash
+//
+//	[15:04:05.123] input "ls\r"
+//	  ls
+//	  a.txt  b.txt
+//	  $
+func (t *Transcript) String() string {
+	var b strings.Builder
+	if fields := t.Metadata.fields(); len(fields) > 0 {
+		for _, f := range fields {
+			fmt.Fprintf(&b, "# %s: %s\n", f.Name, f.Value)
+		}
+		b.WriteString("\n")
+	}
+	for _, e := range t.Entries {
+		stamp := e.Time.Format("15:04:05.000")
+		switch {
+		case len(e.Keys) > 0:
+			fmt.Fprintf(&b, "[%s] keys %s\n", stamp, strings.Join(e.Keys, " "))
+		case e.Redacted:
+			fmt.Fprintf(&b, "[%s] input %s\n", stamp, redacted)
+		case e.Input != "":
+			fmt.Fprintf(&b, "[%s] input %q\n", stamp, e.Input)
+		default:
+			fmt.Fprintf(&b, "[%s] output\n", stamp)
+		}
+		if e.Output != "" {
+			for _, line := range strings.Split(e.Output, "\n") {
+				b.WriteString(strings.TrimRight("  "+line, " ") + "\n")
+			}
+		}
+	}
+	return b.String()
+}
+
+// TranscriptRecorder records a Transcript of the session.
+type TranscriptRecorder struct {
+	vt *VirtualTerminal
+
+	mu      sync.Mutex
+	entries []TranscriptEntry
+	output  *lineHistory // output of the last entry
+	// normalization is Config.Normalization, applied to the output
+	normalization Normalization
+	closed        bool
+}
+
+// RecordTranscript starts recording every input sent to the terminal
+// alongside the output produced before the next input, which makes it easy
+// to follow what an agent or test did and what it saw:
+//
+//	tr := vt.RecordTranscript()
+//	defer func() {
+//	    tr.Close()
+//	    t.Log(tr.Transcript())
+//	}()
+//
+// Inputs are recorded as they are sent and output as it arrives, so output
+// that was already on its way when an input was sent belongs to that input.
+// Call Close to stop recording.
+func (vt *VirtualTerminal) RecordTranscript() *TranscriptRecorder {
+	r := &TranscriptRecorder{vt: vt, normalization: vt.config.Normalization}
+	r.begin(TranscriptEntry{Time: time.Now()})
+
+	vt.mu.Lock()
+	vt.transcripts = append(vt.transcripts, r)
+	vt.mu.Unlock()
+	return r
+}
+
+// Close stops recording. It is safe to call Close more than once.
+func (r *TranscriptRecorder) Close() {
+	r.vt.mu.Lock()
+	r.vt.transcripts = without(r.vt.transcripts, r)
+	r.vt.mu.Unlock()
+
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
+}
+
+// Transcript returns what has been recorded so far.
+func (r *TranscriptRecorder) Transcript() *Transcript {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t := &Transcript{
+		Metadata: r.vt.Metadata(),
+		Entries:  append([]TranscriptEntry(nil), r.entries...),
+	}
+	lines, _ := r.output.snapshot()
+	output := strings.Join(lines, "\n")
+	if r.output.pending() && !r.closed {
+		output = r.normalization.applyLines(output)
+	} else {
+		output = r.normalization.Apply(output)
+	}
+	t.Entries[len(t.Entries)-1].Output = output
+	return t
+}
+
+// input records a command sent to the terminal.
+func (r *TranscriptRecorder) input(cmd command, now time.Time) {
+	switch cmd.Type {
+	case htproto.CommandInput:
+		if cmd.Secret {
+			r.begin(TranscriptEntry{Time: now, Redacted: true})
+			break
+		}
+		r.begin(TranscriptEntry{Time: now, Input: recordedPayload(cmd)})
+	case htproto.CommandSendKeys:
+		r.begin(TranscriptEntry{Time: now, Keys: append([]string(nil), cmd.Keys...)})
+	}
+}
+
+// begin finishes the last entry and starts e.
+func (r *TranscriptRecorder) begin(e TranscriptEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if n := len(r.entries); n > 0 {
+		lines, _ := r.output.snapshot()
+		r.entries[n-1].Output = r.normalization.Apply(strings.Join(lines, "\n"))
+	}
+	r.entries = append(r.entries, e)
+	next := &lineHistory{raw: true}
+	if r.output != nil {
+		next.echo, next.display = r.output.echo, r.output.display
+	}
+	r.output = next
+}
+
+// expectEcho is lineHistory.expectEcho for the output of this and the
+// following entries. It is called before the input is written, as the
+// echo may arrive before the input is recorded.
+func (r *TranscriptRecorder) expectEcho(echo, display string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.output.expectEcho(echo, display)
+}
+
+// write records output.
+func (r *TranscriptRecorder) write(seq string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.output.write(seq)
+}
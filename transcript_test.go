@@ -0,0 +1,118 @@
+package htlib
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTranscript(t *testing.T) {
+	vt, _ := newTestTerminal()
+	defer vt.Close()
+	ctx := context.Background()
+	vt.config.Metadata = Metadata{Title: "listing", Env: map[string]string{"LANG": "C"}}
+
+	tr := vt.RecordTranscript()
+	vt.trackEvent(OutputEvent{Seq: "\x1b[32m$\x1b[0m "})
+	vt.Input(ctx, "ls\r")
+	vt.trackEvent(OutputEvent{Seq: "ls\r\na.txt\r\nprogress 10%\rprogress 100%\r\n$ "})
+	vt.SendKeys(ctx, "C-c", "Enter")
+	vt.trackEvent(OutputEvent{Seq: "^C\r\n$ "})
+	tr.Close()
+	tr.Close()
+	vt.Input(ctx, "ignored\r")
+
+	transcript := tr.Transcript()
+	if len(transcript.Entries) != 3 {
+		t.Fatalf("expected 3 entries, got %+v", transcript.Entries)
+	}
+	entries := transcript.Entries
+	if entries[0].Input != "" || entries[0].Output != "$" {
+		t.Errorf("unexpected first entry %+v", entries[0])
+	}
+	if entries[1].Input != "ls\r" || entries[1].Output != "ls\na.txt\nprogress 100%\n$" {
+		t.Errorf("unexpected second entry %+v", entries[1])
+	}
+	if len(entries[2].Keys) != 2 || entries[2].Output != "^C\n$" {
+		t.Errorf("unexpected third entry %+v", entries[2])
+	}
+
+	stamp := func(e TranscriptEntry) string { return "[" + e.Time.Format("15:04:05.000") + "]" }
+	expected := "# title: listing\n# command: " + vt.Metadata().Command + "\n# env.LANG: C\n\n" +
+		stamp(entries[0]) + " output\n  $\n" +
+		stamp(entries[1]) + " input \"ls\\r\"\n  ls\n  a.txt\n  progress 100%\n  $\n" +
+		stamp(entries[2]) + " keys C-c Enter\n  ^C\n  $\n"
+	if s := transcript.String(); s != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, s)
+	}
+
+	if _, err := json.Marshal(transcript); err != nil {
+		t.Error(err)
+	}
+	if entries[1].Time.After(time.Now()) || entries[1].Time.Before(entries[0].Time) {
+		t.Errorf("unexpected times %v %v", entries[0].Time, entries[1].Time)
+	}
+}
+
+func TestTranscriptSecret(t *testing.T) {
+	vt, stdin := newTestTerminal()
+	defer vt.Close()
+	ctx := context.Background()
+
+	tr := vt.RecordTranscript()
+	// Typed at a prompt, the secret would also become a command in Summary
+	vt.trackEvent(OutputEvent{Seq: "\x1b]133;A\x07Password: \x1b]133;B\x07"})
+	if err := InputSecret("hunter2")(ctx, vt); err != nil {
+		t.Fatal(err)
+	}
+	vt.trackEvent(OutputEvent{Seq: "\r\nok\r\n"})
+	tr.Close()
+
+	if !strings.Contains(stdin.String(), "hunter2") {
+		t.Errorf("expected the secret to be sent, got %q", stdin.String())
+	}
+	transcript := tr.Transcript()
+	data, _ := json.Marshal(transcript)
+	commands := vt.Summary().Commands
+	if len(commands) != 1 || commands[0].Command != "<redacted>" {
+		t.Errorf("expected a redacted command, got %+v", commands)
+	}
+	summary, _ := json.Marshal(vt.Summary())
+	for _, s := range []string{transcript.String(), string(data), string(summary)} {
+		if strings.Contains(s, "hunter2") {
+			t.Errorf("expected the secret to be redacted, got\n%s", s)
+		}
+	}
+	if e := transcript.Entries[1]; !e.Redacted || e.Input != "" || !strings.Contains(transcript.String(), "] input <redacted>\n") {
+		t.Errorf("unexpected entry %+v in\n%s", e, transcript.String())
+	}
+}
+
+func TestTranscriptCloseWhileWriting(t *testing.T) {
+	vt, _ := newTestTerminal()
+	defer vt.Close()
+	ctx := context.Background()
+
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				vt.Input(ctx, "x")
+			}
+		}
+	}()
+	for range 5000 {
+		// Closing a recorder that is not the last moves the others
+		first := vt.RecordTranscript()
+		vt.RecordTranscript()
+		first.Close()
+	}
+	close(stop)
+	<-done
+}
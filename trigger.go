@@ -0,0 +1,139 @@
+package htlib
+
+import (
+	"context"
+	"sync"
+)
+
+// Trigger is a rule registered with AddTrigger that runs an action whenever
+// its matcher matches.
+type Trigger struct {
+	matcher Matcher
+	action  Action
+	once    bool
+
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu    sync.Mutex
+	fired int
+	err   error
+}
+
+// TriggerOption configures a Trigger registered with AddTrigger.
+type TriggerOption func(*Trigger)
+
+// TriggerOnce removes the trigger after it has fired once.
+func TriggerOnce() TriggerOption {
+	return func(t *Trigger) {
+		t.once = true
+	}
+}
+
+// AddTrigger registers a rule that runs action every time m matches, for the
+// life of the session or until the trigger is removed:
+//
+//	vt.AddTrigger(htlib.OutputContains("Password:"), htlib.InputSecret(pw))
+//
+// The matcher is evaluated whenever the terminal produces output or is
+// resized. Output seen by the matcher accumulates from the moment the trigger
+// is added and is consumed up to the end of the match each time it fires, so
+// an output matcher does not fire twice for the same output. Screen matchers
+// are re-evaluated against the whole screen and fire again on the next output
+// while the text remains visible; combine them with TriggerOnce unless that
+// is intended.
+//
+// Actions run on the trigger's own goroutine, not on the work queue, so a
+// trigger can answer a prompt while a queued job is waiting for it. Events
+// arriving while an action runs are still seen by the matcher unless the
+// subscription buffer overflows.
+func (vt *VirtualTerminal) AddTrigger(m Matcher, action Action, opts ...TriggerOption) *Trigger {
+	t := &Trigger{
+		matcher: m,
+		action:  action,
+		done:    make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	var ctx context.Context
+	ctx, t.cancel = context.WithCancel(vt.current().ctx)
+	sub := vt.subscribeRaw()
+	go vt.runTrigger(ctx, t, sub)
+	return t
+}
+
+// Remove stops the trigger. An action that is currently running has its
+// context cancelled. It is safe to call Remove more than once and from
+// within the trigger's own action.
+func (t *Trigger) Remove() {
+	t.cancel()
+}
+
+// Done returns a channel that is closed once the trigger has stopped,
+// because it was removed, fired with TriggerOnce, or the terminal closed.
+func (t *Trigger) Done() <-chan struct{} {
+	return t.done
+}
+
+// Fired returns the number of times the trigger's action has run.
+func (t *Trigger) Fired() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.fired
+}
+
+// Err returns the error from the most recent action that failed, or nil.
+func (t *Trigger) Err() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.err
+}
+
+// runTrigger evaluates t against events from sub until it is stopped.
+func (vt *VirtualTerminal) runTrigger(ctx context.Context, t *Trigger, sub chan Event) {
+	defer close(t.done)
+	defer vt.Unsubscribe(sub)
+	defer t.cancel()
+
+	output := newOutputBuffer()
+	for {
+		var event Event
+		select {
+		case e, ok := <-sub:
+			if !ok {
+				return
+			}
+			event = e
+		case <-ctx.Done():
+			return
+		}
+
+		switch e := event.(type) {
+		case OutputEvent:
+			output.add(e)
+		case ResizeEvent:
+		default:
+			continue
+		}
+
+		match := t.matcher.Match(vt.newMatchContext(ctx, event, output.text))
+		if match == nil {
+			continue
+		}
+		output.consume(match[0])
+
+		err := t.action(ctx, vt)
+		t.mu.Lock()
+		t.fired++
+		if err != nil {
+			t.err = err
+		}
+		t.mu.Unlock()
+
+		if t.once {
+			return
+		}
+	}
+}
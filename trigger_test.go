@@ -0,0 +1,115 @@
+package htlib
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a bytes.Buffer that is safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// newTestTerminal returns a terminal that appears started and records
+// commands written to ht.
+func newTestTerminal() (*VirtualTerminal, *syncBuffer) {
+	var buf syncBuffer
+	vt := New(DefaultConfig())
+	vt.started = true
+	vt.stdin = &nopWriteCloser{Writer: &buf}
+	return vt, &buf
+}
+
+// waitUntil polls cond until it returns true or the test times out.
+func waitUntil(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestTriggerRespondsToOutput(t *testing.T) {
+	vt, stdin := newTestTerminal()
+	defer vt.Close()
+
+	trigger := vt.AddTrigger(OutputContains("Password:"), InputSecret("hunter2"))
+
+	vt.dispatch(OutputEvent{Seq: "Pass"})
+	vt.dispatch(OutputEvent{Seq: "word: "})
+	waitUntil(t, func() bool { return trigger.Fired() == 1 })
+
+	if !strings.Contains(stdin.String(), `"payload":"hunter2\n"`) {
+		t.Errorf("expected secret to be sent, got %q", stdin.String())
+	}
+
+	// Output is reset after firing, so unrelated output does not re-fire
+	vt.dispatch(OutputEvent{Seq: "\r\nWelcome\r\n"})
+	vt.dispatch(OutputEvent{Seq: "Password: "})
+	waitUntil(t, func() bool { return trigger.Fired() == 2 })
+
+	trigger.Remove()
+	select {
+	case <-trigger.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("trigger did not stop after Remove")
+	}
+}
+
+func TestTriggerOnce(t *testing.T) {
+	vt, _ := newTestTerminal()
+	defer vt.Close()
+
+	failure := errors.New("action failed")
+	trigger := vt.AddTrigger(OutputContains("y/n"), func(ctx context.Context, vt *VirtualTerminal) error {
+		return failure
+	}, TriggerOnce())
+
+	vt.dispatch(OutputEvent{Seq: "Continue? y/n"})
+	select {
+	case <-trigger.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("trigger did not stop after firing once")
+	}
+
+	if trigger.Fired() != 1 {
+		t.Errorf("expected 1 firing, got %d", trigger.Fired())
+	}
+	if !errors.Is(trigger.Err(), failure) {
+		t.Errorf("expected action error, got %v", trigger.Err())
+	}
+}
+
+func TestTriggerStopsOnClose(t *testing.T) {
+	vt, _ := newTestTerminal()
+
+	trigger := vt.AddTrigger(OutputContains("never"), InputAction("x"))
+	vt.Close()
+
+	select {
+	case <-trigger.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("trigger did not stop after Close")
+	}
+}
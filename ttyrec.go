@@ -0,0 +1,129 @@
+package htlib
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// ttyrecSize is the xterm window resize sequence, CSI 8 ; rows ; cols t,
+// by which ttyrec files carry the terminal size.
+var ttyrecSize = regexp.MustCompile(`\x1b\[8;(\d+);(\d+)t`)
+
+// ttyrecDefaultSize is the size assumed for ttyrec files that do not say.
+var ttyrecDefaultSize = Size{Cols: 80, Rows: 24}
+
+// RecordTTYRec starts writing the session to w in the ttyrec format, which
+// ttyplay, ipbt and termplay read. ttyrec has no header, so the size of
+// the terminal is written as xterm's window resize sequence at the start
+// and on every resize. See RecordTo.
+func (vt *VirtualTerminal) RecordTTYRec(w io.Writer) (*StreamRecorder, error) {
+	return vt.RecordTo(NewTTYRecEncoder(w))
+}
+
+// NewTTYRecEncoder returns a RecordingEncoder that writes ttyrec to w.
+func NewTTYRecEncoder(w io.Writer) RecordingEncoder {
+	return &ttyrecEncoder{w: w}
+}
+
+// ttyrecEncoder writes ttyrec: records of a 12-byte header, the time in
+// seconds and microseconds and the length of the data, all little-endian
+// uint32, followed by the data.
+type ttyrecEncoder struct {
+	w     io.Writer
+	start time.Time
+	size  string // resize sequence to put before the first output
+}
+
+func (e *ttyrecEncoder) Begin(cols, rows int, start time.Time, metadata Metadata) error {
+	e.start = start
+	e.size = ttyrecResize(cols, rows)
+	return nil
+}
+
+func (e *ttyrecEncoder) Output(offset time.Duration, seq string) error {
+	seq, e.size = e.size+seq, ""
+	return e.record(offset, seq)
+}
+
+func (e *ttyrecEncoder) Resize(offset time.Duration, cols, rows int) error {
+	return e.record(offset, ttyrecResize(cols, rows))
+}
+
+func (e *ttyrecEncoder) record(offset time.Duration, data string) error {
+	t := e.start.Add(offset)
+	record := make([]byte, 12, 12+len(data))
+	binary.LittleEndian.PutUint32(record[0:], uint32(t.Unix()))
+	binary.LittleEndian.PutUint32(record[4:], uint32(t.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(record[8:], uint32(len(data)))
+	_, err := e.w.Write(append(record, data...))
+	return err
+}
+
+// ttyrecResize returns the window resize sequence for a size.
+func ttyrecResize(cols, rows int) string {
+	return fmt.Sprintf("\x1b[8;%d;%dt", rows, cols)
+}
+
+// ReadTTYRec reads a ttyrec file into a Cast, so it can be played back and
+// inspected like an asciicast. Offsets are relative to the first record.
+// The size is taken from a window resize sequence at the start, as written
+// by RecordTTYRec, or else assumed to be 80x24; later resize sequences
+// become resize events. It returns an error matching ErrInvalidRecording
+// if a record is cut short.
+func ReadTTYRec(r io.Reader) (*Cast, error) {
+	cast := &Cast{Header: CastHeader{
+		Version: 2,
+		Width:   ttyrecDefaultSize.Cols,
+		Height:  ttyrecDefaultSize.Rows,
+	}}
+
+	var start time.Time
+	header := make([]byte, 12)
+	for n := 0; ; n++ {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if errors.Is(err, io.EOF) {
+				return cast, nil
+			}
+			return nil, fmt.Errorf("%w: ttyrec record %d: %v", ErrInvalidRecording, n, err)
+		}
+		sec := binary.LittleEndian.Uint32(header[0:])
+		usec := binary.LittleEndian.Uint32(header[4:])
+		// The length is not trusted for the allocation, so that a corrupt
+		// header cannot make it reserve up to 4 GiB; the buffer grows with
+		// the data actually read
+		size := int64(binary.LittleEndian.Uint32(header[8:]))
+		data, err := io.ReadAll(io.LimitReader(r, size))
+		if err != nil {
+			return nil, fmt.Errorf("%w: ttyrec record %d: %v", ErrInvalidRecording, n, err)
+		}
+		if int64(len(data)) < size {
+			return nil, fmt.Errorf("%w: ttyrec record %d: %v", ErrInvalidRecording, n, io.ErrUnexpectedEOF)
+		}
+
+		t := time.Unix(int64(sec), int64(usec)*1000)
+		if n == 0 {
+			start = t
+			cast.Header.Timestamp = t.Unix()
+		}
+		offset := max(t.Sub(start), 0)
+
+		seq := string(data)
+		for _, m := range ttyrecSize.FindAllStringSubmatchIndex(seq, -1) {
+			rows, _ := strconv.Atoi(seq[m[2]:m[3]])
+			cols, _ := strconv.Atoi(seq[m[4]:m[5]])
+			if n == 0 && m[0] == 0 {
+				cast.Header.Width, cast.Header.Height = cols, rows
+				continue
+			}
+			cast.Events = append(cast.Events, CastEvent{Offset: offset, Code: "r", Data: Size{Cols: cols, Rows: rows}.String()})
+		}
+		if seq = ttyrecSize.ReplaceAllString(seq, ""); seq != "" {
+			cast.Events = append(cast.Events, CastEvent{Offset: offset, Code: "o", Data: seq})
+		}
+	}
+}
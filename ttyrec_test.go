@@ -0,0 +1,72 @@
+package htlib
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTTYRec(t *testing.T) {
+	vt, _ := newTestTerminal()
+	defer vt.Close()
+
+	var buf syncBuffer
+	r, err := vt.RecordTTYRec(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(d time.Duration) time.Time { return r.start.Add(d) }
+	send := func(event Event) {
+		vt.trackEvent(event)
+		vt.dispatch(event)
+	}
+	send(OutputEvent{Seq: "$ ls\r\n", Time: at(250 * time.Millisecond)})
+	send(ResizeEvent{Cols: 80, Rows: 24, Time: at(time.Second)})
+	send(OutputEvent{Seq: "a.txt", Time: at(1500 * time.Millisecond)})
+	waitUntil(t, func() bool { return bytes.Contains([]byte(buf.String()), []byte("a.txt")) })
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte(buf.String())
+	cast, err := ReadTTYRec(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cast.Header.Width != 120 || cast.Header.Height != 40 || cast.Header.Timestamp != r.start.Unix() {
+		t.Errorf("unexpected header %+v", cast.Header)
+	}
+	if len(cast.Events) != 4 {
+		t.Fatalf("expected the screen, two outputs and a resize, got %+v", cast.Events)
+	}
+	expected := []CastEvent{
+		{Offset: 250 * time.Millisecond, Code: "o", Data: "$ ls\r\n"},
+		{Offset: time.Second, Code: "r", Data: "80x24"},
+		{Offset: 1500 * time.Millisecond, Code: "o", Data: "a.txt"},
+	}
+	for i, want := range expected {
+		got := cast.Events[i+1]
+		// Microsecond timestamps lose the sub-microsecond part of the start
+		if got.Code != want.Code || got.Data != want.Data || (got.Offset-want.Offset).Abs() > time.Microsecond {
+			t.Errorf("event %d: expected %+v, got %+v", i+1, want, got)
+		}
+	}
+	if screen := cast.ScreenAt(time.Hour); screen.Text() != "$ ls\na.txt"+string(bytes.Repeat([]byte("\n"), 22)) {
+		t.Errorf("unexpected final screen %q", screen.Text())
+	}
+
+	if _, err := ReadTTYRec(bytes.NewReader(data[:len(data)-1])); !errors.Is(err, ErrInvalidRecording) {
+		t.Errorf("expected ErrInvalidRecording for a truncated file, got %v", err)
+	}
+	// A corrupt length is not allocated up front
+	if _, err := ReadTTYRec(bytes.NewReader([]byte("\x01\x00\x00\x00\x00\x00\x00\x00\xff\xff\xff\xffhi"))); !errors.Is(err, ErrInvalidRecording) {
+		t.Errorf("expected ErrInvalidRecording for a corrupt length, got %v", err)
+	}
+
+	// Files from other recorders have no size
+	cast, err = ReadTTYRec(bytes.NewReader([]byte("\x01\x00\x00\x00\x00\x00\x00\x00\x02\x00\x00\x00hi")))
+	if err != nil || cast.Header.Width != 80 || len(cast.Events) != 1 || cast.Events[0].Data != "hi" {
+		t.Errorf("unexpected cast %+v, %v", cast, err)
+	}
+}
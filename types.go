@@ -2,7 +2,11 @@ package htlib
 
 import (
 	"encoding/json"
+	"fmt"
+	"regexp"
 	"time"
+
+	"github.com/io41/htlib.go/htproto"
 )
 
 // Config represents the configuration for a VirtualTerminal.
@@ -19,8 +23,86 @@ type Config struct {
 	Rows int
 	// HtBinary is the path to the ht binary (default: "ht")
 	HtBinary string
+	// HtArgs are extra flags passed to ht before the command, for options
+	// of newer ht builds such as a scrollback limit
+	HtArgs []string
+	// SerialPort is a serial device such as /dev/ttyUSB0 to drive instead
+	// of running Binary under ht; the screen is emulated locally
+	SerialPort string
+	// BaudRate is the speed of SerialPort (default: 115200)
+	BaudRate int
 	// Env is additional environment variables to pass to the process
 	Env []string
+	// Dir is the working directory the program starts in (default: the
+	// current directory); it does not apply to SerialPort
+	Dir string
+	// TranslateLineDrawing rewrites DEC Special Graphics characters in raw Seq
+	// fields to Unicode box-drawing characters (ht already renders them in Text)
+	TranslateLineDrawing bool
+	// Normalization is applied to rendered Text, output matchers and
+	// transcripts (the zero value keeps them byte-exact)
+	Normalization Normalization
+	// KeepTempDirs keeps directories created by TempDir after Close
+	KeepTempDirs bool
+	// OutputProcessors transform OutputEvents delivered on Events() and to subscribers
+	OutputProcessors []OutputProcessor
+	// ScrollbackLines is the number of output lines kept for ReadScrollback (default: 10000)
+	ScrollbackLines int
+	// Metadata describes the session in recordings, transcripts and exports
+	Metadata Metadata
+	// Watchdogs are enforced for the life of the session once Start is called
+	Watchdogs []WatchdogPolicy
+	// PromptPatterns recognize the shell prompt at the end of the current
+	// output line (default: common bash, zsh and fish prompts)
+	PromptPatterns []*regexp.Regexp
+	// OnReady actions run in order once the shell shows its first prompt,
+	// e.g. to set PS1 or cd into a workspace; Start waits for them
+	OnReady []Action
+	// KeyProfile encodes the keys sent with SendKeys, e.g. AutoKeys() to
+	// follow the modes of full-screen programs (default: ht translates keys)
+	KeyProfile KeyProfile
+	// ChangeEvents emits a ChangeEvent describing the changed screen
+	// regions after every event that changed the screen
+	ChangeEvents bool
+	// RestartPolicy relaunches ht when the program exits unexpectedly
+	// (default: the terminal stays exited)
+	RestartPolicy *RestartPolicy
+	// EventBuffer is the capacity of the Events channel and of subscriber
+	// channels without WithBuffer (default: 100)
+	EventBuffer int
+	// Overflow decides what happens to events when the Events channel is
+	// full (default: OverflowBlock)
+	Overflow OverflowPolicy
+	// MaxEventSize is the longest event line accepted from ht, in bytes;
+	// longer events, such as snapshots of huge screens, are skipped and
+	// counted in DroppedEvents (default: DefaultMaxEventSize, 64 MiB)
+	MaxEventSize int
+	// StderrEvents sends a StderrEvent for every line ht writes to its
+	// standard error; the last lines are always kept for Stderr
+	StderrEvents bool
+	// Subscribe lists the ht event types to receive (default: all types
+	// htlib knows), e.g. to leave out mouse and resize events or to ask
+	// for a type htlib does not know, which is delivered as UnknownEvent.
+	// Init, output and snapshot events are always included, since the
+	// local screen model and the wait helpers are built from them; use
+	// WithEventTypes to receive fewer. Types htlib makes itself, such as
+	// EventTypeExit, are not passed to ht. It does not apply to SerialPort.
+	Subscribe []EventType
+}
+
+// eventBuffer returns the capacity of event channels.
+func (c Config) eventBuffer() int {
+	return eventBufferSize(c.EventBuffer)
+}
+
+// eventBufferSize returns the capacity of an event channel asked for with
+// Config.EventBuffer or WithBuffer: n, or 100 if n is not positive. Every
+// event channel is sized here, so that none can be unbuffered.
+func eventBufferSize(n int) int {
+	if n <= 0 {
+		return 100
+	}
+	return n
 }
 
 // DefaultConfig returns a Config with sensible defaults.
@@ -33,9 +115,22 @@ func DefaultConfig() Config {
 		Rows:     0,
 		HtBinary: "ht",
 		Env:      []string{},
+
+		Normalization: DefaultNormalization(),
 	}
 }
 
+// Size is a terminal size in columns and rows.
+type Size struct {
+	Cols int
+	Rows int
+}
+
+// String returns the size in "COLSxROWS" format.
+func (s Size) String() string {
+	return fmt.Sprintf("%dx%d", s.Cols, s.Rows)
+}
+
 // EventType represents the type of event received from ht.
 type EventType string
 
@@ -50,6 +145,17 @@ const (
 	EventTypeSnapshot EventType = "snapshot"
 	// EventTypeMouse is emitted when mouse events occur
 	EventTypeMouse EventType = "mouse"
+	// EventTypeChange is emitted after the local screen changed, if
+	// Config.ChangeEvents is set
+	EventTypeChange EventType = "change"
+	// EventTypeExit is emitted last, when the program in the terminal ends
+	EventTypeExit EventType = "exit"
+	// EventTypeStderr is emitted for lines ht writes to its standard
+	// error, if Config.StderrEvents is set
+	EventTypeStderr EventType = "stderr"
+	// EventTypeRestart is emitted when Config.RestartPolicy restarts the
+	// terminal
+	EventTypeRestart EventType = "restart"
 )
 
 // Event represents an event received from the ht process.
@@ -57,6 +163,17 @@ type Event interface {
 	Type() EventType
 }
 
+// UnknownEvent is an event of a type htlib does not know, sent by ht when
+// the type is listed in Config.Subscribe.
+type UnknownEvent struct {
+	EventType EventType
+	// Data is the event's data as sent by ht
+	Data json.RawMessage
+	Time time.Time
+}
+
+func (e UnknownEvent) Type() EventType { return e.EventType }
+
 // InitEvent is emitted once at startup and contains the initial terminal state.
 type InitEvent struct {
 	Cols int    `json:"cols"`
@@ -92,7 +209,13 @@ type SnapshotEvent struct {
 	Rows int    `json:"rows"`
 	Seq  string `json:"seq"`  // Raw VT100 output
 	Text string `json:"text"` // Rendered text view
-	Time time.Time
+	// Cursor is where the dump in Seq leaves the cursor
+	Cursor Cursor `json:"-"`
+	// Request numbers the snapshots of a process from 1 in the order they
+	// were requested, matching the request they answer. It is 0 for
+	// snapshots not received from ht.
+	Request uint64 `json:"-"`
+	Time    time.Time
 }
 
 func (e SnapshotEvent) Type() EventType { return EventTypeSnapshot }
@@ -120,24 +243,5 @@ type MouseModifiers struct {
 	Alt   bool
 }
 
-// rawEvent is used for unmarshaling JSON events from ht.
-type rawEvent struct {
-	Type string          `json:"type"`
-	Data json.RawMessage `json:"data"`
-}
-
 // command represents a command to send to ht via STDIN.
-type command struct {
-	Type    string      `json:"type"`
-	Payload interface{} `json:"payload,omitempty"`
-	Keys    []string    `json:"keys,omitempty"`
-	Cols    int         `json:"cols,omitempty"`
-	Rows    int         `json:"rows,omitempty"`
-	Event   string      `json:"event,omitempty"`
-	Button  string      `json:"button,omitempty"`
-	Row     int         `json:"row,omitempty"`
-	Col     int         `json:"col,omitempty"`
-	Shift   bool        `json:"shift,omitempty"`
-	Ctrl    bool        `json:"ctrl,omitempty"`
-	Alt     bool        `json:"alt,omitempty"`
-}
+type command = htproto.Command
@@ -1,37 +1,101 @@
 package htlib
 
 import (
-	"bufio"
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
 	"os/exec"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/io41/htlib.go/htproto"
 )
 
 // VirtualTerminal represents a headless terminal session managed by ht.
 type VirtualTerminal struct {
 	config Config
-	cmd    *exec.Cmd
+	proc   *htProcess
 	stdin  io.WriteCloser
 	stdout io.ReadCloser
 	stderr io.ReadCloser
 
 	// Event handling
-	events      chan Event
-	subscribers []chan Event
+	subscribers []*subscriber
+	output      OutputProcessor
 	mu          sync.RWMutex
 	started     bool
+	starting    bool
 	closed      bool
 
-	// Background goroutine management
-	ctx    context.Context
-	cancel context.CancelFunc
-	wg     sync.WaitGroup
+	// Serial work queue for Enqueue
+	queue workQueue
+
+	// Charset state carried across output events
+	charsets charsetTranslator
+
+	// Plain-text output history for ReadScrollback
+	history lineHistory
+
+	// Shell prompt state for WaitForPrompt
+	prompt promptTracker
+
+	// Statistics for Summary
+	stats sessionStats
+
+	// keyProfile encodes SendKeys names, or nil to leave them to ht
+	keyProfile KeyProfile
+
+	// transcripts being recorded with RecordTranscript
+	transcripts []*TranscriptRecorder
+	// eventLoggers are the loggers started with LogEventsTo
+	eventLoggers []*EventLogger
+	// tees are the writers output is copied to with TeeOutput
+	tees []*OutputTee
+
+	// conditions registered with RegisterCondition on this terminal
+	conditions map[string]Matcher
+
+	// Local screen model for Screen
+	screen *screenModel
+	// changes is the screen last reported in a ChangeEvent
+	changes changeTracker
+	// log numbers recent events for ChangesSince
+	log eventLog
+
+	// Directories created by TempDir, removed on Close
+	tempDirs []string
+
+	// suspension keeps the periods stopped with Suspend
+	suspension suspensionClock
+
+	// Process state learned from events
+	pid        int
+	lastScreen *SnapshotEvent
+	// cols and rows are the size last reported by ht, see Size
+	cols, rows int
+	// snapshots numbers snapshot requests for WaitForSnapshot
+	snapshots snapshotRequests
+	// writes orders the commands written to stdin, so that concurrent
+	// callers and writes given up by their caller do not interleave
+	writes writeQueue
+
+	// sess is the context and channels of the current ht process, replaced
+	// by Restart; wg tracks its background goroutines
+	sess      *session
+	wg        sync.WaitGroup
+	restartMu sync.Mutex
+	// supervision is the state of Config.RestartPolicy
+	supervision supervision
+
+	// stderrTail is the end of ht's standard error, see Stderr
+	stderrTail lineTail
+
+	// dropped counts events lost to full channels, see DroppedEvents
+	dropped atomic.Uint64
 
 	// Error handling
 	err error
@@ -49,68 +113,211 @@ func New(config Config) *VirtualTerminal {
 		config.Size = "120x40"
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-
-	return &VirtualTerminal{
+	vt := &VirtualTerminal{
 		config:      config,
-		events:      make(chan Event, 100),
-		subscribers: make([]chan Event, 0),
-		ctx:         ctx,
-		cancel:      cancel,
+		subscribers: make([]*subscriber, 0),
+		output:      ChainOutputProcessors(config.OutputProcessors...),
+		history:     lineHistory{limit: config.ScrollbackLines},
+		keyProfile:  config.KeyProfile,
+		sess:        newSession(config.eventBuffer()),
+		stderrTail:  lineTail{limit: stderrTailLines},
 	}
+	vt.screen = newScreenModel(vt.configSize())
+	return vt
 }
 
 // Start launches the ht subprocess and begins processing events.
-func (vt *VirtualTerminal) Start(ctx context.Context) error {
-	vt.mu.Lock()
-	defer vt.mu.Unlock()
+// By default Start returns as soon as the process is running; pass
+// WaitReady to block until the terminal is usable. If Config.OnReady is
+// set, Start always waits for the first prompt and then runs the hooks.
+// If waiting or a hook fails, ht is stopped again and the terminal is left
+// unstarted, so Start can be retried; Close is still needed to release it.
+func (vt *VirtualTerminal) Start(ctx context.Context, opts ...StartOption) error {
+	vt.restartMu.Lock()
+	defer vt.restartMu.Unlock()
+
+	var options startOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return vt.launch(ctx, options)
+}
+
+// launch starts ht as described for Start. The caller must hold restartMu.
+func (vt *VirtualTerminal) launch(ctx context.Context, options startOptions) error {
+	// Watchdogs subscribe before ht starts so they see all of its output
+	watchdogs := make([]*Watchdog, 0, len(vt.config.Watchdogs))
+	for _, policy := range vt.config.Watchdogs {
+		watchdogs = append(watchdogs, vt.AddWatchdog(policy))
+	}
+
+	if err := vt.start(ctx, options); err != nil {
+		for _, w := range watchdogs {
+			w.Remove()
+		}
+		return err
+	}
+	vt.startSupervising()
+
+	var err error
+	if options.waitReady || len(vt.config.OnReady) > 0 {
+		if err = vt.waitReady(ctx); err != nil {
+			err = fmt.Errorf("waiting for terminal to become ready: %w", err)
+		}
+	}
+	if err == nil {
+		err = vt.runOnReady(ctx)
+	}
+	if err != nil {
+		// Leave the terminal as it was before Start rather than running
+		// with a process the caller believes failed
+		vt.replaceSession(false)
+		return err
+	}
+	return nil
+}
 
-	if vt.started {
+// start launches the ht subprocess and the background goroutines.
+func (vt *VirtualTerminal) start(ctx context.Context, options startOptions) error {
+	vt.mu.Lock()
+	if vt.started || vt.starting {
+		vt.mu.Unlock()
 		return ErrAlreadyStarted
 	}
 	if vt.closed {
+		vt.mu.Unlock()
+		return ErrClosed
+	}
+	vt.starting = true
+	vt.mu.Unlock()
+
+	proc, err := vt.spawnWithRetry(ctx, options)
+
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	vt.starting = false
+	if err != nil {
+		return err
+	}
+	if vt.closed {
+		proc.kill()
 		return ErrClosed
 	}
 
+	vt.proc = proc
+	vt.stdin = proc.stdin
+	vt.stdout = proc.stdout
+	vt.stderr = proc.stderr
+	vt.started = true
+	vt.stats.begin(time.Now())
+
+	// Start background goroutines
+	readDone := make(chan struct{})
+	stderrDone := make(chan struct{})
+	vt.wg.Add(2)
+	go vt.readEvents(proc.events, proc.first, readDone)
+	if proc.stderr != nil {
+		vt.wg.Add(1)
+		go vt.readStderr(proc.stderr, stderrDone)
+	} else {
+		close(stderrDone)
+	}
+	go vt.waitForExit(vt.sess, readDone, stderrDone)
+
+	return nil
+}
+
+// htProcess is a spawned ht process that is not yet attached to the terminal.
+// For Config.SerialPort, serial is set instead of cmd.
+type htProcess struct {
+	cmd    *exec.Cmd
+	serial *serialBridge
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+	stderr io.ReadCloser
+	events *eventReader
+
+	// first is the first event line, read early when confirming the start
+	first string
+}
+
+// spawn creates the ht command and starts it.
+func (vt *VirtualTerminal) spawn() (*htProcess, error) {
+	if vt.config.SerialPort != "" {
+		return vt.spawnSerial()
+	}
+
 	// Build command arguments
 	args := vt.buildArgs()
 
 	// Create command
-	vt.cmd = exec.CommandContext(vt.ctx, vt.config.HtBinary, args...)
+	proc := &htProcess{cmd: exec.CommandContext(vt.current().ctx, vt.config.HtBinary, args...)}
 	if len(vt.config.Env) > 0 {
-		vt.cmd.Env = append(vt.cmd.Env, vt.config.Env...)
+		proc.cmd.Env = append(proc.cmd.Env, vt.config.Env...)
 	}
+	proc.cmd.Dir = vt.config.Dir
 
 	// Setup pipes
 	var err error
-	vt.stdin, err = vt.cmd.StdinPipe()
+	proc.stdin, err = proc.cmd.StdinPipe()
 	if err != nil {
-		return fmt.Errorf("failed to create stdin pipe: %w", err)
+		return nil, fmt.Errorf("failed to create stdin pipe: %w", err)
 	}
 
-	vt.stdout, err = vt.cmd.StdoutPipe()
+	proc.stdout, err = proc.cmd.StdoutPipe()
 	if err != nil {
-		return fmt.Errorf("failed to create stdout pipe: %w", err)
+		return nil, fmt.Errorf("failed to create stdout pipe: %w", err)
 	}
 
-	vt.stderr, err = vt.cmd.StderrPipe()
+	proc.stderr, err = proc.cmd.StderrPipe()
 	if err != nil {
-		return fmt.Errorf("failed to create stderr pipe: %w", err)
+		return nil, fmt.Errorf("failed to create stderr pipe: %w", err)
 	}
 
 	// Start the command
-	if err := vt.cmd.Start(); err != nil {
-		return fmt.Errorf("failed to start ht process: %w", err)
+	if err := proc.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start ht process: %w", err)
 	}
 
-	vt.started = true
+	proc.events = vt.newEventReader(proc.stdout)
+	return proc, nil
+}
 
-	// Start background goroutines
-	vt.wg.Add(2)
-	go vt.readEvents()
-	go vt.waitForExit()
+// kill stops a process that was never attached and reaps it.
+func (p *htProcess) kill() {
+	if p.serial != nil {
+		p.serial.close()
+		return
+	}
+	p.cmd.Process.Kill()
+	p.cmd.Wait()
+}
 
-	return nil
+// forceStop kills the process and closes its pipes without waiting, to
+// unblock the goroutines reading them.
+func (p *htProcess) forceStop() {
+	if p.serial != nil {
+		p.serial.port.Close()
+		p.serial.events.Close()
+		return
+	}
+	p.cmd.Process.Kill()
+	p.stdout.Close()
+	p.stderr.Close()
+}
+
+// wait waits for the process to exit and returns its exit status, with
+// the error from waiting for it, e.g. an *exec.ExitError.
+func (p *htProcess) wait() (ExitStatus, error) {
+	if p.serial != nil {
+		err := p.serial.wait()
+		if err != nil {
+			return ExitStatus{Code: -1}, err
+		}
+		return ExitStatus{}, nil
+	}
+	err := p.cmd.Wait()
+	return exitStatusOf(p.cmd.ProcessState), err
 }
 
 // buildArgs constructs the command line arguments for ht.
@@ -126,8 +333,8 @@ func (vt *VirtualTerminal) buildArgs() []string {
 	}
 	args = append(args, "--size", size)
 
-	// Add subscription to all events
-	args = append(args, "--subscribe", "init,output,resize,snapshot,mouse")
+	args = append(args, "--subscribe", htproto.SubscribeArg(vt.subscribeTypes()...))
+	args = append(args, vt.config.HtArgs...)
 
 	// Add binary and its arguments
 	args = append(args, vt.config.Binary)
@@ -136,393 +343,604 @@ func (vt *VirtualTerminal) buildArgs() []string {
 	return args
 }
 
-// readEvents reads events from stdout and dispatches them.
-func (vt *VirtualTerminal) readEvents() {
-	defer vt.wg.Done()
-	defer close(vt.events)
-
-	scanner := bufio.NewScanner(vt.stdout)
-	for scanner.Scan() {
-		line := scanner.Text()
-		event, err := vt.parseEvent(line)
-		if err != nil {
-			// Log error but continue
+// subscribeTypes returns the event types to ask ht for: Config.Subscribe,
+// or all types when it is empty, plus those htlib depends on. Types that
+// htlib makes itself are left out, as ht does not send them.
+func (vt *VirtualTerminal) subscribeTypes() []string {
+	if len(vt.config.Subscribe) == 0 {
+		return nil
+	}
+	types := []string{htproto.EventInit, htproto.EventSnapshot, htproto.EventOutput}
+	for _, t := range vt.config.Subscribe {
+		switch t {
+		case EventTypeChange, EventTypeExit, EventTypeStderr, EventTypeRestart:
 			continue
 		}
+		if !slices.Contains(types, string(t)) {
+			types = append(types, string(t))
+		}
+	}
+	return types
+}
 
-		// Send to main events channel
-		select {
-		case vt.events <- event:
-		case <-vt.ctx.Done():
-			return
+// newEventReader reads ht's event lines from r, counting those over
+// Config.MaxEventSize in DroppedEvents.
+func (vt *VirtualTerminal) newEventReader(r io.Reader) *eventReader {
+	return newEventReader(r, vt.config.MaxEventSize, func(_ int, head []byte) {
+		vt.dropped.Add(1)
+		if isSnapshotLine(head) {
+			vt.snapshots.lose()
 		}
+	})
+}
 
-		// Send to subscribers
-		vt.mu.RLock()
-		for _, sub := range vt.subscribers {
-			select {
-			case sub <- event:
-			default:
-				// Skip if subscriber is not ready
-			}
+// readEvents reads events from stdout and dispatches them, starting with
+// first if a line was already read while confirming the start. It closes
+// done when it stops reading.
+func (vt *VirtualTerminal) readEvents(events *eventReader, first string, done chan<- struct{}) {
+	defer vt.wg.Done()
+	defer close(done)
+
+	if first != "" && !vt.handleLine(first) {
+		return
+	}
+	for {
+		line, ok := events.next()
+		if !ok {
+			break
+		}
+		if !vt.handleLine(line) {
+			return
 		}
-		vt.mu.RUnlock()
 	}
 
-	if err := scanner.Err(); err != nil {
+	if err := events.err; err != nil {
 		vt.mu.Lock()
 		vt.err = fmt.Errorf("error reading stdout: %w", err)
 		vt.mu.Unlock()
 	}
 }
 
-// waitForExit waits for the ht process to exit.
-func (vt *VirtualTerminal) waitForExit() {
-	defer vt.wg.Done()
+// handleLine parses and dispatches one event line. It returns false if the
+// terminal was closed while dispatching.
+func (vt *VirtualTerminal) handleLine(line string) bool {
+	event, err := vt.parseEvent(line)
+	if err != nil {
+		// Log error but continue. A snapshot still uses up its request
+		// number, so that later snapshots match their requests.
+		if isSnapshotLine([]byte(line)) {
+			vt.snapshots.lose()
+		}
+		return true
+	}
+	if snapshot, ok := event.(SnapshotEvent); ok {
+		snapshot.Request = vt.snapshots.receive()
+		event = snapshot
+	}
+	vt.trackEvent(event)
+	return vt.dispatch(event) && vt.dispatchChange(event)
+}
+
+// without returns a new slice holding items except item. The event
+// loggers, output tees and transcripts are copied under vt.mu and then
+// ranged over without it, so they are always replaced rather than changed
+// in place.
+func without[T comparable](items []T, item T) []T {
+	var rest []T
+	for _, it := range items {
+		if it != item {
+			rest = append(rest, it)
+		}
+	}
+	return rest
+}
+
+// trackEvent updates internal state from an event before it is dispatched.
+func (vt *VirtualTerminal) trackEvent(event Event) {
+	vt.mu.RLock()
+	loggers, tees := vt.eventLoggers, vt.tees
+	vt.mu.RUnlock()
+	for _, l := range loggers {
+		l.log(event)
+	}
+	if e, ok := event.(OutputEvent); ok {
+		for _, t := range tees {
+			t.write(e.Seq)
+		}
+	}
 
-	err := vt.cmd.Wait()
+	switch e := event.(type) {
+	case InitEvent:
+		vt.mu.Lock()
+		vt.pid = e.PID
+		vt.lastScreen = &SnapshotEvent{Cols: e.Cols, Rows: e.Rows, Seq: e.Seq, Text: e.Text, Time: e.Time}
+		vt.cols, vt.rows = e.Cols, e.Rows
+		vt.mu.Unlock()
+		vt.screen.load(e.Cols, e.Rows, e.Seq)
+		vt.prompt.write(e.Seq, vt.promptPatterns())
+		select {
+		case <-vt.current().initDone:
+		default:
+			close(vt.current().initDone)
+		}
+	case OutputEvent:
+		vt.history.write(e.Seq)
+		vt.mu.RLock()
+		transcripts := vt.transcripts
+		vt.mu.RUnlock()
+		for _, t := range transcripts {
+			t.write(e.Seq)
+		}
+		vt.screen.feed(e.Seq)
+		vt.prompt.write(e.Seq, vt.promptPatterns())
+		vt.stats.output(len(e.Seq), &vt.prompt, time.Now())
+	case ResizeEvent:
+		vt.mu.Lock()
+		vt.cols, vt.rows = e.Cols, e.Rows
+		vt.mu.Unlock()
+		vt.screen.setSize(e.Cols, e.Rows)
+		vt.stats.resize(e.Cols, e.Rows, time.Now())
+	case SnapshotEvent:
+		vt.mu.Lock()
+		vt.lastScreen = &e
+		vt.cols, vt.rows = e.Cols, e.Rows
+		vt.mu.Unlock()
+	}
+}
+
+// waitForInit blocks until the InitEvent has been received.
+func (vt *VirtualTerminal) waitForInit(ctx context.Context) error {
+	select {
+	case <-vt.current().initDone:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-vt.current().ctx.Done():
+		return vt.closedErr()
+	}
+}
+
+// closedErr returns the error reported to waits interrupted by Close or by
+// the ht process exiting, carrying the last screen that was observed.
+func (vt *VirtualTerminal) closedErr() error {
+	vt.mu.RLock()
+	defer vt.mu.RUnlock()
+	return &TerminalClosedError{Screen: vt.lastScreen}
+}
+
+// PID returns the process ID of the program running inside the terminal,
+// or 0 if the InitEvent has not been received yet.
+func (vt *VirtualTerminal) PID() int {
+	vt.mu.RLock()
+	defer vt.mu.RUnlock()
+	return vt.pid
+}
+
+// waitForExit waits for the ht process to exit. Reading ends when ht
+// closes its stdout and stderr on exit; waiting for that first keeps
+// cmd.Wait from closing the pipes while the last lines are still unread. It then sends
+// the ExitEvent and closes the events channel of s, the session of the
+// process.
+func (vt *VirtualTerminal) waitForExit(s *session, readDone, stderrDone <-chan struct{}) {
+	defer vt.wg.Done()
+	defer s.closeEvents()
+
+	<-readDone
+	<-stderrDone
+	status, err := vt.proc.wait()
+	exit := ExitEvent{Code: status.Code, Signal: status.Signal, Time: time.Now()}
+	var started bool
+	select {
+	case <-s.initDone:
+		started = true
+	default:
+	}
+	failure := &ProcessExitError{Status: status, Stderr: vt.Stderr(), Started: started, Err: err}
+	if err != nil && vt.proc.serial == nil {
+		err = failure
+	}
 	vt.mu.Lock()
+	switch {
+	case vt.err != nil:
+		// reading the events failed
+		exit.Err = vt.err
+	case vt.proc.serial != nil:
+		exit.Err = err
+	}
 	if err != nil && vt.err == nil {
-		vt.err = fmt.Errorf("ht process exited: %w", err)
+		vt.err = err
 	}
+	s.status = status
 	vt.mu.Unlock()
+	if exit.Err == nil && !started {
+		exit.Err = failure
+	}
+	close(s.exited)
+
+	vt.trackEvent(exit)
+	vt.dispatch(exit)
 
 	// Cancel context to stop all operations
-	vt.cancel()
+	s.cancel()
 }
 
 // parseEvent parses a JSON event line from ht.
 func (vt *VirtualTerminal) parseEvent(line string) (Event, error) {
-	var raw rawEvent
-	if err := json.Unmarshal([]byte(line), &raw); err != nil {
-		return nil, fmt.Errorf("failed to parse event: %w", err)
+	event, err := htproto.ParseEvent([]byte(line))
+	if err != nil {
+		return nil, err
 	}
 
 	now := time.Now()
 
-	switch raw.Type {
-	case "init":
-		var data struct {
-			Cols int    `json:"cols"`
-			Rows int    `json:"rows"`
-			PID  int    `json:"pid"`
-			Seq  string `json:"seq"`
-			Text string `json:"text"`
-		}
-		if err := json.Unmarshal(raw.Data, &data); err != nil {
-			return nil, err
-		}
+	switch e := event.(type) {
+	case htproto.InitEvent:
 		return InitEvent{
-			Cols: data.Cols,
-			Rows: data.Rows,
-			PID:  data.PID,
-			Seq:  data.Seq,
-			Text: data.Text,
+			Cols: e.Cols,
+			Rows: e.Rows,
+			PID:  e.PID,
+			Seq:  vt.translateSeq(e.Seq, false),
+			Text: vt.Normalize(e.Text),
 			Time: now,
 		}, nil
 
-	case "output":
-		var data struct {
-			Seq string `json:"seq"`
-		}
-		if err := json.Unmarshal(raw.Data, &data); err != nil {
-			return nil, err
-		}
+	case htproto.OutputEvent:
 		return OutputEvent{
-			Seq:  data.Seq,
+			Seq:  vt.translateSeq(e.Seq, true),
 			Time: now,
 		}, nil
 
-	case "resize":
-		var data struct {
-			Cols int `json:"cols"`
-			Rows int `json:"rows"`
-		}
-		if err := json.Unmarshal(raw.Data, &data); err != nil {
-			return nil, err
-		}
+	case htproto.ResizeEvent:
 		return ResizeEvent{
-			Cols: data.Cols,
-			Rows: data.Rows,
+			Cols: e.Cols,
+			Rows: e.Rows,
 			Time: now,
 		}, nil
 
-	case "snapshot":
-		var data struct {
-			Cols int    `json:"cols"`
-			Rows int    `json:"rows"`
-			Seq  string `json:"seq"`
-			Text string `json:"text"`
-		}
-		if err := json.Unmarshal(raw.Data, &data); err != nil {
-			return nil, err
-		}
+	case htproto.SnapshotEvent:
 		return SnapshotEvent{
-			Cols: data.Cols,
-			Rows: data.Rows,
-			Seq:  data.Seq,
-			Text: data.Text,
-			Time: now,
+			Cols:   e.Cols,
+			Rows:   e.Rows,
+			Seq:    vt.translateSeq(e.Seq, false),
+			Text:   vt.Normalize(e.Text),
+			Cursor: parseCursor(e.Seq, e.Cols, e.Rows),
+			Time:   now,
 		}, nil
 
-	case "mouse":
-		var data struct {
-			Event  string `json:"event"`
-			Button string `json:"button"`
-			Row    int    `json:"row"`
-			Col    int    `json:"col"`
-			Shift  bool   `json:"shift"`
-			Ctrl   bool   `json:"ctrl"`
-			Alt    bool   `json:"alt"`
-		}
-		if err := json.Unmarshal(raw.Data, &data); err != nil {
-			return nil, err
-		}
+	case htproto.MouseEvent:
 		return MouseEvent{
-			Event:  data.Event,
-			Button: data.Button,
-			Row:    data.Row,
-			Col:    data.Col,
-			Shift:  data.Shift,
-			Ctrl:   data.Ctrl,
-			Alt:    data.Alt,
+			Event:  e.Event,
+			Button: e.Button,
+			Row:    e.Row,
+			Col:    e.Col,
+			Shift:  e.Shift,
+			Ctrl:   e.Ctrl,
+			Alt:    e.Alt,
 			Time:   now,
 		}, nil
 
+	case htproto.UnknownEvent:
+		return UnknownEvent{
+			EventType: EventType(e.Type),
+			Data:      e.Data,
+			Time:      now,
+		}, nil
+
 	default:
-		return nil, fmt.Errorf("%w: %s", ErrInvalidEvent, raw.Type)
+		return nil, fmt.Errorf("%w: %s", ErrInvalidEvent, event.EventType())
+	}
+}
+
+// translateSeq applies line-drawing translation to a raw sequence when
+// enabled. Streamed output carries charset state between events, while
+// self-contained screen dumps (init and snapshot) are translated on their own.
+func (vt *VirtualTerminal) translateSeq(seq string, stream bool) string {
+	if !vt.config.TranslateLineDrawing {
+		return seq
+	}
+	if stream {
+		return vt.charsets.translate(seq)
 	}
+	return TranslateLineDrawing(seq)
 }
 
 // sendCommand sends a JSON command to ht via stdin.
-func (vt *VirtualTerminal) sendCommand(cmd command) error {
-	vt.mu.RLock()
-	defer vt.mu.RUnlock()
+func (vt *VirtualTerminal) sendCommand(ctx context.Context, cmd command) error {
+	return vt.sendCommands(ctx, cmd)
+}
+
+// sendCommands sends one or more JSON commands to ht in a single write.
+func (vt *VirtualTerminal) sendCommands(ctx context.Context, cmds ...command) error {
+	_, err := vt.writeCommands(ctx, cmds...)
+	return err
+}
+
+// writeCommands sends commands like sendCommands. It returns the request
+// number of the last snapshot requested, which its SnapshotEvent will
+// carry, or 0 if cmds request none.
+//
+// If ctx is done while waiting for ht to read its input, writeCommands
+// returns ctx.Err(), but the write carries on in the background: a
+// partial command would corrupt the stream. Later writes wait for it.
+func (vt *VirtualTerminal) writeCommands(ctx context.Context, cmds ...command) (uint64, error) {
+	return vt.write(ctx, false, cmds)
+}
 
+// write implements writeCommands. If try is set, it returns ErrBusy
+// rather than waiting for earlier writes, and returns as soon as the
+// write has started.
+func (vt *VirtualTerminal) write(ctx context.Context, try bool, cmds []command) (uint64, error) {
+	vt.mu.RLock()
 	if !vt.started {
-		return ErrNotStarted
+		vt.mu.RUnlock()
+		return 0, ErrNotStarted
 	}
 	if vt.closed {
-		return ErrClosed
+		vt.mu.RUnlock()
+		return 0, ErrClosed
 	}
-
-	data, err := json.Marshal(cmd)
-	if err != nil {
-		return fmt.Errorf("failed to marshal command: %w", err)
+	if try {
+		select {
+		case <-vt.sess.ctx.Done():
+			vt.mu.RUnlock()
+			return 0, ErrClosed
+		default:
+		}
+		if !vt.writes.tryAcquire() {
+			vt.mu.RUnlock()
+			return 0, ErrBusy
+		}
 	}
 
-	data = append(data, '\n')
-	if _, err := vt.stdin.Write(data); err != nil {
-		return fmt.Errorf("failed to write command: %w", err)
+	var data []byte
+	now := time.Now()
+	for _, cmd := range cmds {
+		var err error
+		if data, err = htproto.AppendCommand(data, cmd); err != nil {
+			vt.mu.RUnlock()
+			if try {
+				vt.writes.release()
+			}
+			return 0, err
+		}
+	}
+	for _, cmd := range cmds {
+		atPrompt, _ := vt.prompt.state()
+		vt.stats.input(cmd, atPrompt, now)
+		if submitsLine(cmd) {
+			vt.prompt.busy()
+		}
+		if cmd.Display != "" {
+			echo, display := strings.TrimRight(cmd.Payload, "\r\n"), strings.TrimRight(cmd.Display, "\r\n")
+			vt.history.expectEcho(echo, display)
+			for _, t := range vt.transcripts {
+				t.expectEcho(echo, display)
+			}
+		}
+	}
+	// The write may block, so it is done without holding the lock, which
+	// would keep Close from closing stdin
+	stdin, transcripts := vt.stdin, vt.transcripts
+	vt.mu.RUnlock()
+
+	if !try {
+		if err := vt.writes.acquire(ctx); err != nil {
+			return 0, err
+		}
+	}
+	write := func() (uint64, error) {
+		defer vt.writes.release()
+		if _, err := stdin.Write(data); err != nil {
+			err = fmt.Errorf("failed to write command: %w", err)
+			vt.stats.error(err)
+			return 0, err
+		}
+		for _, t := range transcripts {
+			for _, cmd := range cmds {
+				t.input(cmd, now)
+			}
+		}
+		// Snapshot requests are numbered in the order ht reads them, which
+		// is the order of the writes
+		if n := countSnapshots(cmds); n > 0 {
+			vt.snapshots.mu.Lock()
+			defer vt.snapshots.mu.Unlock()
+			vt.snapshots.requested += n
+			return vt.snapshots.requested, nil
+		}
+		return 0, nil
+	}
+	if try {
+		go write()
+		return 0, nil
+	}
+	if ctx.Done() == nil {
+		return write()
 	}
 
-	return nil
+	type result struct {
+		request uint64
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		request, err := write()
+		done <- result{request, err}
+	}()
+	select {
+	case r := <-done:
+		return r.request, r.err
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
 }
 
 // Input sends raw input to the terminal.
 func (vt *VirtualTerminal) Input(ctx context.Context, text string) error {
-	cmd := command{
-		Type:    "input",
-		Payload: text,
-	}
-	return vt.sendCommand(cmd)
+	return vt.sendCommand(ctx, htproto.Input(text))
 }
 
 // SendKeys sends named keys to the terminal.
 // Examples: "Enter", "C-c", "Left", "F1", etc.
+// If a KeyProfile is set, the keys it knows are encoded locally.
 func (vt *VirtualTerminal) SendKeys(ctx context.Context, keys ...string) error {
-	cmd := command{
-		Type: "sendKeys",
-		Keys: keys,
-	}
-	return vt.sendCommand(cmd)
+	return vt.sendCommands(ctx, vt.keyCommands(keys)...)
 }
 
 // Resize resizes the terminal to the specified dimensions.
 func (vt *VirtualTerminal) Resize(ctx context.Context, cols, rows int) error {
-	cmd := command{
-		Type: "resize",
-		Cols: cols,
-		Rows: rows,
-	}
-	return vt.sendCommand(cmd)
+	return vt.sendCommand(ctx, htproto.Resize(cols, rows))
 }
 
 // TakeSnapshot requests a snapshot of the terminal state.
 // Use WaitForSnapshot to receive the snapshot event.
 func (vt *VirtualTerminal) TakeSnapshot(ctx context.Context) error {
-	cmd := command{
-		Type: "takeSnapshot",
-	}
-	return vt.sendCommand(cmd)
+	return vt.sendCommand(ctx, htproto.TakeSnapshot())
 }
 
 // MouseClick sends a mouse click event to the terminal.
 // button can be: "left", "right", "middle", "wheel_up", "wheel_down"
 // row and col are 1-based coordinates (top-left is 1,1)
 func (vt *VirtualTerminal) MouseClick(ctx context.Context, button string, row, col int) error {
-	cmd := command{
-		Type:   "mouse",
-		Event:  "click",
-		Button: button,
-		Row:    row,
-		Col:    col,
-	}
-	return vt.sendCommand(cmd)
+	return vt.sendCommand(ctx, htproto.Mouse("click", button, row, col, false, false, false))
 }
 
 // MousePress sends a mouse button press event to the terminal.
 func (vt *VirtualTerminal) MousePress(ctx context.Context, button string, row, col int) error {
-	cmd := command{
-		Type:   "mouse",
-		Event:  "press",
-		Button: button,
-		Row:    row,
-		Col:    col,
-	}
-	return vt.sendCommand(cmd)
+	return vt.sendCommand(ctx, htproto.Mouse("press", button, row, col, false, false, false))
 }
 
 // MouseRelease sends a mouse button release event to the terminal.
 func (vt *VirtualTerminal) MouseRelease(ctx context.Context, button string, row, col int) error {
-	cmd := command{
-		Type:   "mouse",
-		Event:  "release",
-		Button: button,
-		Row:    row,
-		Col:    col,
-	}
-	return vt.sendCommand(cmd)
+	return vt.sendCommand(ctx, htproto.Mouse("release", button, row, col, false, false, false))
 }
 
 // MouseDrag sends a mouse drag event to the terminal.
 func (vt *VirtualTerminal) MouseDrag(ctx context.Context, button string, row, col int) error {
-	cmd := command{
-		Type:   "mouse",
-		Event:  "drag",
-		Button: button,
-		Row:    row,
-		Col:    col,
-	}
-	return vt.sendCommand(cmd)
+	return vt.sendCommand(ctx, htproto.Mouse("drag", button, row, col, false, false, false))
 }
 
 // MouseScroll sends a mouse scroll event to the terminal.
 // button should be "wheel_up" or "wheel_down"
 func (vt *VirtualTerminal) MouseScroll(ctx context.Context, button string, row, col int) error {
-	cmd := command{
-		Type:   "mouse",
-		Event:  "click",
-		Button: button,
-		Row:    row,
-		Col:    col,
-	}
-	return vt.sendCommand(cmd)
+	return vt.sendCommand(ctx, htproto.Mouse("click", button, row, col, false, false, false))
 }
 
 // MouseClickWithModifiers sends a mouse click event with modifier keys.
 func (vt *VirtualTerminal) MouseClickWithModifiers(ctx context.Context, button string, row, col int, modifiers MouseModifiers) error {
-	cmd := command{
-		Type:   "mouse",
-		Event:  "click",
-		Button: button,
-		Row:    row,
-		Col:    col,
-		Shift:  modifiers.Shift,
-		Ctrl:   modifiers.Ctrl,
-		Alt:    modifiers.Alt,
-	}
-	return vt.sendCommand(cmd)
+	return vt.sendCommand(ctx, htproto.Mouse("click", button, row, col, modifiers.Shift, modifiers.Ctrl, modifiers.Alt))
 }
 
 // MousePressWithModifiers sends a mouse press event with modifier keys.
 func (vt *VirtualTerminal) MousePressWithModifiers(ctx context.Context, button string, row, col int, modifiers MouseModifiers) error {
-	cmd := command{
-		Type:   "mouse",
-		Event:  "press",
-		Button: button,
-		Row:    row,
-		Col:    col,
-		Shift:  modifiers.Shift,
-		Ctrl:   modifiers.Ctrl,
-		Alt:    modifiers.Alt,
-	}
-	return vt.sendCommand(cmd)
+	return vt.sendCommand(ctx, htproto.Mouse("press", button, row, col, modifiers.Shift, modifiers.Ctrl, modifiers.Alt))
 }
 
 // MouseReleaseWithModifiers sends a mouse release event with modifier keys.
 func (vt *VirtualTerminal) MouseReleaseWithModifiers(ctx context.Context, button string, row, col int, modifiers MouseModifiers) error {
-	cmd := command{
-		Type:   "mouse",
-		Event:  "release",
-		Button: button,
-		Row:    row,
-		Col:    col,
-		Shift:  modifiers.Shift,
-		Ctrl:   modifiers.Ctrl,
-		Alt:    modifiers.Alt,
-	}
-	return vt.sendCommand(cmd)
+	return vt.sendCommand(ctx, htproto.Mouse("release", button, row, col, modifiers.Shift, modifiers.Ctrl, modifiers.Alt))
 }
 
 // MouseDragWithModifiers sends a mouse drag event with modifier keys.
 func (vt *VirtualTerminal) MouseDragWithModifiers(ctx context.Context, button string, row, col int, modifiers MouseModifiers) error {
-	cmd := command{
-		Type:   "mouse",
-		Event:  "drag",
-		Button: button,
-		Row:    row,
-		Col:    col,
-		Shift:  modifiers.Shift,
-		Ctrl:   modifiers.Ctrl,
-		Alt:    modifiers.Alt,
-	}
-	return vt.sendCommand(cmd)
+	return vt.sendCommand(ctx, htproto.Mouse("drag", button, row, col, modifiers.Shift, modifiers.Ctrl, modifiers.Alt))
 }
 
 // WaitForSnapshot requests a snapshot and waits for the response.
-// This is a convenience method that combines TakeSnapshot with event waiting.
+// This is a convenience method that combines TakeSnapshot with event
+// waiting. It returns the snapshot answering its own request, even while
+// other goroutines take snapshots; only if that one was lost by a full
+// channel does it return the next one. If ht's answer is over
+// Config.MaxEventSize or cannot be parsed, it returns an error matching
+// ErrInvalidEvent instead of waiting for it.
 func (vt *VirtualTerminal) WaitForSnapshot(ctx context.Context) (*SnapshotEvent, error) {
-	// Subscribe to events temporarily
-	eventChan := vt.Subscribe()
-	defer vt.Unsubscribe(eventChan)
-
-	// Request snapshot
-	if err := vt.TakeSnapshot(ctx); err != nil {
-		return nil, err
-	}
-
-	// Wait for snapshot event
-	for {
-		select {
-		case event := <-eventChan:
-			if snapshot, ok := event.(SnapshotEvent); ok {
-				return &snapshot, nil
-			}
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		case <-vt.ctx.Done():
-			return nil, ErrClosed
-		}
-	}
+	return vt.requestSnapshot(ctx)
 }
 
 // Events returns a channel that receives all events from the terminal.
-// This channel is closed when the terminal is closed.
+// The channel is closed after the ExitEvent, once the process has exited
+// or the terminal is closed. After Restart, Events returns the channel of
+// the new process. When it is full, events are handled according to
+// Config.Overflow: by default the terminal waits for it to be read.
 func (vt *VirtualTerminal) Events() <-chan Event {
-	return vt.events
+	return vt.current().events
 }
 
 // Subscribe creates a new subscriber channel for receiving events.
-// The caller is responsible for reading from this channel to avoid blocking.
-// Call Unsubscribe when done.
-func (vt *VirtualTerminal) Subscribe() chan Event {
+// The caller is responsible for reading from this channel; by default,
+// events that do not fit into its Config.EventBuffer (see WithBuffer) are
+// dropped and counted in DroppedEvents, see WithOverflow. A subscriber
+// created after startup can ask for the events it missed with WithReplay.
+// Call Unsubscribe when done. The channel is closed by Close, or at once if
+// the terminal is already closed.
+func (vt *VirtualTerminal) Subscribe(opts ...SubscribeOption) chan Event {
+	return vt.subscribe(opts...).ch
+}
+
+// subscribe registers a new subscriber.
+func (vt *VirtualTerminal) subscribe(opts ...SubscribeOption) *subscriber {
+	sub := &subscriber{
+		overflow: OverflowDropNewest,
+		done:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(sub)
+	}
+	if sub.buffer <= 0 {
+		sub.buffer = vt.config.EventBuffer
+	}
+	sub.buffer = eventBufferSize(sub.buffer)
+
 	vt.mu.Lock()
 	defer vt.mu.Unlock()
 
-	ch := make(chan Event, 100)
-	vt.subscribers = append(vt.subscribers, ch)
-	return ch
+	// The replayed events are read while no subscriber can be added, and
+	// events up to sub.after are not delivered again
+	var replay []Event
+	if sub.replay {
+		var logged []loggedEvent
+		logged, sub.after = vt.log.recent(sub.history)
+		replay = vt.replayed(sub, logged)
+	}
+	if sub.synced {
+		// dispatch reads the subscribers under vt.mu after the screen
+		// model has been updated, so an event is either delivered or in
+		// the copy; dispatch skips those that are both by update number
+		sub.model = vt.screen.clone()
+		sub.screenAfter = sub.model.updates
+	}
+	sub.ch = make(chan Event, sub.buffer+len(replay))
+	for _, event := range replay {
+		sub.ch <- event
+	}
+	if vt.closed {
+		// Nothing will be sent after Close; end the channel after the replay
+		sub.close()
+		return sub
+	}
+
+	vt.subscribers = append(vt.subscribers, sub)
+	return sub
+}
+
+// subscribeScreen creates a raw subscriber that does not drop events,
+// together with a copy of the screen model that it continues from: the
+// subscriber receives exactly the output, resizes and InitEvents that the
+// copy does not include yet. It is for recorders that replay the output
+// on their own screen model.
+func (vt *VirtualTerminal) subscribeScreen() (chan Event, *screenModel) {
+	sub := vt.subscribe(WithOverflow(OverflowBlock), func(s *subscriber) {
+		s.raw, s.synced = true, true
+	})
+	return sub.ch, sub.model
+}
+
+// subscribeRaw creates a subscriber that bypasses Config.OutputProcessors,
+// for internal waits that must see every output event unchanged.
+func (vt *VirtualTerminal) subscribeRaw(opts ...SubscribeOption) chan Event {
+	return vt.Subscribe(append(opts, func(s *subscriber) {
+		s.raw = true
+	})...)
 }
 
 // Unsubscribe removes a subscriber channel.
@@ -531,45 +949,92 @@ func (vt *VirtualTerminal) Unsubscribe(ch chan Event) {
 	defer vt.mu.Unlock()
 
 	for i, sub := range vt.subscribers {
-		if sub == ch {
+		if sub.ch == ch {
 			// Remove from slice
 			vt.subscribers = append(vt.subscribers[:i], vt.subscribers[i+1:]...)
-			close(ch)
+			sub.close()
 			return
 		}
 	}
 }
 
-// Close terminates the ht process and cleans up resources.
+// Close terminates the ht process and cleans up resources. It waits for
+// the background goroutines, which can take as long as an OutputProcessor,
+// an output tee or a subscriber with OverflowBlock takes; see Shutdown to
+// bound the wait.
 func (vt *VirtualTerminal) Close() error {
+	return vt.Shutdown(context.Background())
+}
+
+// CloseWithTimeout closes the terminal like Shutdown, giving up waiting
+// after d.
+func (vt *VirtualTerminal) CloseWithTimeout(d time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return vt.Shutdown(ctx)
+}
+
+// Shutdown closes the terminal like Close, but stops waiting for the
+// background goroutines once ctx is done. It then kills ht if it is still
+// running, closes its pipes and the subscriber channels, and returns an
+// error matching ctx.Err(); goroutines stuck in user code, e.g. a blocked
+// output tee, are left to finish on their own:
+//
+//	if err := vt.CloseWithTimeout(5 * time.Second); err != nil {
+//	    log.Printf("terminal did not shut down cleanly: %v", err)
+//	}
+func (vt *VirtualTerminal) Shutdown(ctx context.Context) error {
 	vt.mu.Lock()
 	if vt.closed {
 		vt.mu.Unlock()
 		return nil
 	}
 	vt.closed = true
+	s, stdin, proc := vt.sess, vt.stdin, vt.proc
 	vt.mu.Unlock()
 
 	// Cancel context to stop background goroutines
-	vt.cancel()
+	s.cancel()
 
 	// Close stdin to signal ht to exit
-	if vt.stdin != nil {
-		vt.stdin.Close()
+	if stdin != nil {
+		stdin.Close()
 	}
 
 	// Wait for background goroutines
-	vt.wg.Wait()
+	var forced error
+	done := make(chan struct{})
+	go func() {
+		vt.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		if proc != nil {
+			proc.forceStop()
+		}
+		forced = fmt.Errorf("failed to stop background goroutines: %w", ctx.Err())
+	}
+	// Events is closed even if no process ever ran to close it
+	s.closeEvents()
 
 	// Close all subscriber channels
 	vt.mu.Lock()
 	for _, sub := range vt.subscribers {
-		close(sub)
+		sub.close()
 	}
 	vt.subscribers = nil
 	vt.mu.Unlock()
 
-	return vt.err
+	if err := vt.removeTempDirs(); err != nil && forced == nil {
+		return err
+	}
+	if forced != nil {
+		return forced
+	}
+
+	return vt.Err()
 }
 
 // Err returns any error that occurred during operation.
@@ -579,8 +1044,21 @@ func (vt *VirtualTerminal) Err() error {
 	return vt.err
 }
 
-// Size returns the current terminal size.
+// Size returns the current terminal size: the size last reported by ht in
+// an InitEvent, ResizeEvent or SnapshotEvent, or the configured size
+// before ht has reported one. See OnResize to follow changes.
 func (vt *VirtualTerminal) Size() (cols, rows int) {
+	vt.mu.RLock()
+	cols, rows = vt.cols, vt.rows
+	vt.mu.RUnlock()
+	if cols > 0 && rows > 0 {
+		return cols, rows
+	}
+	return vt.configSize()
+}
+
+// configSize returns the size from Config that ht is started with.
+func (vt *VirtualTerminal) configSize() (cols, rows int) {
 	if vt.config.Cols > 0 && vt.config.Rows > 0 {
 		return vt.config.Cols, vt.config.Rows
 	}
@@ -1,7 +1,12 @@
 package htlib
 
 import (
+	"bufio"
 	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -195,6 +200,55 @@ func TestSubscribe(t *testing.T) {
 	}
 }
 
+func TestConfigSubscribe(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	config := DefaultConfig()
+	config.Subscribe = []EventType{EventTypeResize, EventTypeExit, EventTypeChange, EventTypeStderr, EventTypeRestart}
+	vt := New(config)
+	if got := strings.Join(vt.subscribeTypes(), ","); got != "init,snapshot,output,resize" {
+		t.Errorf("expected init, snapshot and output only to be added, got %q", got)
+	}
+	if err := vt.Start(ctx); err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+	defer vt.Close()
+
+	// The local screen model still follows the output
+	if err := vt.Input(ctx, "echo subscribed\r"); err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(t, func() bool { return strings.Contains(vt.Screen().Text(), "subscribed") })
+
+	if got := New(DefaultConfig()).subscribeTypes(); got != nil {
+		t.Errorf("expected all types by default, got %v", got)
+	}
+}
+
+func TestBuildArgs(t *testing.T) {
+	config := DefaultConfig()
+	config.Size = "80x24"
+	config.HtArgs = []string{"--scrollback", "5000"}
+	config.Args = []string{"-l"}
+	got := strings.Join(New(config).buildArgs(), " ")
+	if want := "--size 80x24 --subscribe init,output,resize,snapshot,mouse --scrollback 5000 /bin/bash -l"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestParseUnknownEvent(t *testing.T) {
+	vt := New(DefaultConfig())
+	event, err := vt.parseEvent(`{"type":"bell","data":{"count":2}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unknown, ok := event.(UnknownEvent)
+	if !ok || unknown.Type() != "bell" || string(unknown.Data) != `{"count":2}` || unknown.Time.IsZero() {
+		t.Errorf("unexpected event %#v", event)
+	}
+}
+
 func TestWaitForSnapshot(t *testing.T) {
 	vt := New(DefaultConfig())
 	ctx := context.Background()
@@ -302,3 +356,379 @@ func TestSendKeys(t *testing.T) {
 		}
 	}
 }
+
+func TestResizeSequence(t *testing.T) {
+	vt := New(DefaultConfig())
+	ctx := context.Background()
+
+	if err := vt.Start(ctx); err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+	defer vt.Close()
+
+	// Wait for init
+	<-vt.Events()
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	sizes := []Size{{Cols: 80, Rows: 24}, {Cols: 40, Rows: 10}, {Cols: 120, Rows: 40}}
+	snapshots, err := vt.ResizeSequence(ctx, 50*time.Millisecond, sizes...)
+	if err != nil {
+		t.Fatalf("failed to run resize sequence: %v", err)
+	}
+
+	if len(snapshots) != len(sizes) {
+		t.Fatalf("expected %d snapshots, got %d", len(sizes), len(snapshots))
+	}
+	for i, size := range sizes {
+		if snapshots[i].Cols != size.Cols || snapshots[i].Rows != size.Rows {
+			t.Errorf("snapshot %d: expected size %s, got %dx%d", i, size, snapshots[i].Cols, snapshots[i].Rows)
+		}
+	}
+}
+
+func TestStartWaitReady(t *testing.T) {
+	vt := New(DefaultConfig())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := vt.Start(ctx, WaitReady()); err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+	defer vt.Close()
+
+	if vt.PID() == 0 {
+		t.Error("expected PID to be known once ready")
+	}
+}
+
+func TestStartInDir(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	config := DefaultConfig()
+	config.Dir = t.TempDir()
+	config.Binary = "/bin/sh"
+	config.Args = []string{"-c", "echo cwd=$(pwd -P)"}
+	vt := New(config)
+	defer vt.Close()
+
+	if err := vt.Start(ctx); err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+	dir, err := filepath.EvalSymlinks(config.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vt.WaitForExit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if text := vt.Screen().Text(); !strings.Contains(text, "cwd="+dir+"\n") {
+		t.Errorf("expected the program to start in %s, got %q", dir, text)
+	}
+
+	config.Dir = filepath.Join(dir, "missing")
+	if err := New(config).Start(ctx); err == nil {
+		t.Error("expected an error for a missing directory")
+	}
+}
+
+func TestCloseInterruptsWaits(t *testing.T) {
+	vt := New(DefaultConfig())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := vt.Start(ctx, WaitReady()); err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := vt.WaitFor(ctx, func(*SnapshotEvent) bool { return false })
+		result <- err
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	start := time.Now()
+	_ = vt.Close()
+
+	select {
+	case err := <-result:
+		var closedErr *TerminalClosedError
+		if !errors.As(err, &closedErr) {
+			t.Fatalf("expected TerminalClosedError, got %v", err)
+		}
+		if closedErr.Screen == nil {
+			t.Error("expected final screen on error")
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("wait took %v to be interrupted", elapsed)
+		}
+	case <-ctx.Done():
+		t.Fatal("wait was not interrupted by Close")
+	}
+}
+
+func TestWaitForText(t *testing.T) {
+	vt := New(DefaultConfig())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := vt.Start(ctx, WaitReady()); err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+	defer vt.Close()
+
+	if err := vt.Input(ctx, "sleep 0.3; echo wait-for-$((40+2))\n"); err != nil {
+		t.Fatalf("failed to send input: %v", err)
+	}
+
+	snapshot, err := vt.WaitForText(ctx, "wait-for-42")
+	if err != nil {
+		t.Fatalf("failed waiting for text: %v", err)
+	}
+	if !strings.Contains(snapshot.Text, "wait-for-42") {
+		t.Errorf("expected snapshot to contain text, got %q", snapshot.Text)
+	}
+
+	shortCtx, shortCancel := context.WithTimeout(ctx, 300*time.Millisecond)
+	defer shortCancel()
+	if _, err := vt.WaitForText(shortCtx, "never-printed"); err != context.DeadlineExceeded {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestWaitForStable(t *testing.T) {
+	vt, _ := newTestTerminal()
+	defer vt.Close()
+	ctx := context.Background()
+
+	const quiet = 100 * time.Millisecond
+	done := make(chan error, 1)
+	start := time.Now()
+	go func() {
+		done <- vt.WaitForStable(ctx, quiet)
+	}()
+
+	// Keep printing for a while; each output restarts the quiet window
+	for i := 0; i < 5; i++ {
+		time.Sleep(quiet / 2)
+		vt.dispatch(OutputEvent{Seq: "tick\r\n"})
+	}
+
+	if err := <-done; err != nil {
+		t.Fatalf("failed waiting for stable output: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 5*quiet/2+quiet {
+		t.Errorf("returned after %v, before output settled", elapsed)
+	}
+
+	shortCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if err := vt.WaitForStable(shortCtx, quiet); err != context.DeadlineExceeded {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestWaitForPollInterval(t *testing.T) {
+	vt, stdin := newTestTerminal()
+	defer vt.Close()
+
+	// Answer every snapshot request; the screen shows "ready" from the third
+	var requests int
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		seen := 0
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(time.Millisecond):
+			}
+			n := strings.Count(stdin.String(), "takeSnapshot")
+			for ; seen < n; seen++ {
+				text := "busy"
+				if seen >= 2 {
+					text = "ready"
+				}
+				vt.dispatch(SnapshotEvent{Text: text, Request: vt.snapshots.receive()})
+			}
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	snapshot, err := vt.WaitFor(ctx, func(s *SnapshotEvent) bool {
+		requests++
+		return s.Text == "ready"
+	}, WithPollInterval(20*time.Millisecond))
+	if err != nil {
+		t.Fatalf("failed waiting: %v", err)
+	}
+	if snapshot.Text != "ready" || requests != 3 {
+		t.Errorf("expected ready after 3 snapshots, got %q after %d", snapshot.Text, requests)
+	}
+	// Without output, only the poll interval drives re-checks; the default
+	// interval would need at least 500ms for three snapshots
+	if elapsed := time.Since(start); elapsed > 400*time.Millisecond {
+		t.Errorf("poll interval not applied, took %v", elapsed)
+	}
+}
+
+func TestWriteHonorsContext(t *testing.T) {
+	vt := New(DefaultConfig())
+	r, w := io.Pipe()
+	vt.started, vt.stdin = true, w
+
+	// Nothing reads the pipe, so the write blocks until ctx ends
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := vt.Input(ctx, "first"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the deadline, got %v", err)
+	}
+	// The next command waits for the abandoned write rather than mixing
+	// with it
+	done := make(chan error)
+	go func() { done <- vt.Resize(context.Background(), 100, 30) }()
+
+	lines := bufio.NewScanner(r)
+	for _, want := range []string{`"payload":"first"`, `"type":"resize"`} {
+		if !lines.Scan() || !strings.Contains(lines.Text(), want) {
+			t.Fatalf("expected %s, got %q", want, lines.Text())
+		}
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// A blocked write does not keep Close from closing stdin
+	go func() { done <- vt.Input(context.Background(), "stuck") }()
+	time.Sleep(20 * time.Millisecond)
+	vt.Close()
+	if err := <-done; err == nil {
+		t.Error("expected the write to fail once stdin is closed")
+	}
+}
+
+// stuckWriter blocks every write until release is closed, signalling on
+// entered.
+type stuckWriter struct{ entered, release chan struct{} }
+
+func (w stuckWriter) Write(p []byte) (int, error) {
+	select {
+	case w.entered <- struct{}{}:
+	default:
+	}
+	<-w.release
+	return len(p), nil
+}
+
+func TestCloseWithTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	vt := New(DefaultConfig())
+	if err := vt.Start(ctx); err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+	sub := vt.Subscribe()
+
+	// A tee that never returns wedges the goroutine reading ht's events
+	entered, release := make(chan struct{}, 1), make(chan struct{})
+	defer close(release)
+	vt.TeeOutput(stuckWriter{entered, release})
+	if err := vt.Input(ctx, "echo wedged\r"); err != nil {
+		t.Fatal(err)
+	}
+	<-entered
+
+	start := time.Now()
+	err := vt.CloseWithTimeout(100 * time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Close took %v", elapsed)
+	}
+	for range sub {
+	}
+	if err := vt.Close(); err != nil {
+		t.Errorf("expected closing again to do nothing, got %v", err)
+	}
+}
+
+func TestCloseWithUnreadEvents(t *testing.T) {
+	for range 5 {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		config := DefaultConfig()
+		config.EventBuffer = 1
+		config.Overflow = OverflowBlock
+		vt := New(config)
+		if err := vt.Start(ctx); err != nil {
+			t.Fatalf("failed to start: %v", err)
+		}
+		// Neither Events nor this subscriber is read
+		vt.Subscribe(WithOverflow(OverflowBlock), WithBuffer(1))
+		if err := vt.Input(ctx, "seq 1 100000\r"); err != nil {
+			t.Fatal(err)
+		}
+		waitUntil(t, func() bool { return len(vt.Events()) == cap(vt.Events()) })
+
+		// ht is killed while it is blocked writing, which Close reports
+		if err := vt.CloseWithTimeout(5 * time.Second); errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("Close did not complete: %v", err)
+		}
+		for range vt.Events() {
+		}
+		cancel()
+	}
+}
+
+func TestSizeTracksResizes(t *testing.T) {
+	vt := New(Config{Size: "80x24"})
+	if cols, rows := vt.Size(); cols != 80 || rows != 24 {
+		t.Errorf("expected the configured size, got %dx%d", cols, rows)
+	}
+	for _, event := range []Event{
+		InitEvent{Cols: 100, Rows: 30},
+		ResizeEvent{Cols: 120, Rows: 40},
+	} {
+		vt.trackEvent(event)
+	}
+	if cols, rows := vt.Size(); cols != 120 || rows != 40 {
+		t.Errorf("expected the size after the resize, got %dx%d", cols, rows)
+	}
+	vt.trackEvent(SnapshotEvent{Cols: 90, Rows: 20})
+	if cols, rows := vt.Size(); cols != 90 || rows != 20 {
+		t.Errorf("expected the size of the snapshot, got %dx%d", cols, rows)
+	}
+}
+
+func TestOnResize(t *testing.T) {
+	vt := New(DefaultConfig())
+	defer vt.Close()
+	sizes := make(chan Size, 10)
+	stop := vt.OnResize(func(size Size) { sizes <- size })
+
+	vt.dispatch(OutputEvent{Seq: "ignored"})
+	vt.dispatch(ResizeEvent{Cols: 100, Rows: 30})
+	if size := <-sizes; size != (Size{Cols: 100, Rows: 30}) {
+		t.Errorf("unexpected size %v", size)
+	}
+
+	stop()
+	waitUntil(t, func() bool {
+		vt.mu.RLock()
+		defer vt.mu.RUnlock()
+		return len(vt.subscribers) == 0
+	})
+	vt.dispatch(ResizeEvent{Cols: 1, Rows: 1})
+	select {
+	case size := <-sizes:
+		t.Errorf("expected no calls after stop, got %v", size)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
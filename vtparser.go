@@ -0,0 +1,1057 @@
+package htlib
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// screenModel is a VT100/xterm emulator that keeps a grid of cells, the
+// cursor and the terminal modes up to date from a stream of output.
+type screenModel struct {
+	mu sync.Mutex
+
+	cols, rows int
+	grid       [][]Cell // the buffer being shown
+	other      [][]Cell // the main buffer while the alternate one is shown
+	cursor     Cursor
+	style      Style // the style characters are printed with
+	wrap       bool  // the last character was printed in the last column
+	saved      savedCursor
+	top        int // scroll region, inclusive
+	bottom     int
+	tabs       []bool
+	modes      Modes
+	charsets   charsetTranslator
+	title      string
+	last       rune  // last printed character, for REP
+	keyboard   []int // kitty keyboard flags pushed with CSI > u
+
+	// pending holds an escape sequence or UTF-8 character split across chunks
+	pending string
+	// discarding is set while the rest of an over-long string sequence is
+	// skipped
+	discarding bool
+	// updates counts the calls to feed, load and setSize, so that a clone
+	// can tell which updates it already includes
+	updates uint64
+}
+
+// savedCursor is the state saved by DECSC and restored by DECRC.
+type savedCursor struct {
+	row, col int
+	style    Style
+	wrap     bool
+	origin   bool
+	charsets charsetTranslator
+}
+
+// newScreenModel returns a blank screen of the given size.
+func newScreenModel(cols, rows int) *screenModel {
+	m := &screenModel{}
+	m.reset(cols, rows)
+	return m
+}
+
+// reset returns the terminal to its power-on state with the given size.
+func (m *screenModel) reset(cols, rows int) {
+	m.cols, m.rows = max(cols, 1), max(rows, 1)
+	m.grid = newGrid(m.cols, m.rows)
+	m.other = nil
+	m.cursor = Cursor{Visible: true}
+	m.style = Style{}
+	m.wrap = false
+	m.saved = savedCursor{}
+	m.top, m.bottom = 0, m.rows-1
+	m.tabs = defaultTabs(m.cols)
+	m.modes = Modes{AutoWrap: true}
+	m.charsets = charsetTranslator{}
+	m.title = ""
+	m.last = 0
+	m.keyboard = nil
+	m.pending = ""
+	m.discarding = false
+}
+
+// newGrid returns rows blank lines of cols cells.
+func newGrid(cols, rows int) [][]Cell {
+	grid := make([][]Cell, rows)
+	for i := range grid {
+		grid[i] = newLine(cols)
+	}
+	return grid
+}
+
+// newLine returns a blank line of cols cells.
+func newLine(cols int) []Cell {
+	line := make([]Cell, cols)
+	for i := range line {
+		line[i] = blankCell
+	}
+	return line
+}
+
+// defaultTabs returns tab stops every 8 columns.
+func defaultTabs(cols int) []bool {
+	tabs := make([]bool, cols)
+	for i := 8; i < cols; i += 8 {
+		tabs[i] = true
+	}
+	return tabs
+}
+
+// snapshot returns a copy of the current state.
+func (m *screenModel) snapshot() *Screen {
+	screen, _ := m.snapshotUpdate()
+	return screen
+}
+
+// snapshotUpdate returns a copy of the current state with the number of
+// updates it includes.
+func (m *screenModel) snapshotUpdate() (*Screen, uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return &Screen{
+		Cols:   m.cols,
+		Rows:   m.rows,
+		Cursor: m.cursor,
+		Modes:  m.modes,
+		Title:  m.title,
+		cells:  cloneGrid(m.grid),
+	}, m.updates
+}
+
+// clone returns an independent copy of the model.
+func (m *screenModel) clone() *screenModel {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c := &screenModel{
+		cols:     m.cols,
+		rows:     m.rows,
+		grid:     cloneGrid(m.grid),
+		other:    cloneGrid(m.other),
+		cursor:   m.cursor,
+		style:    m.style,
+		wrap:     m.wrap,
+		saved:    m.saved,
+		top:      m.top,
+		bottom:   m.bottom,
+		tabs:     append([]bool(nil), m.tabs...),
+		modes:    m.modes,
+		charsets: m.charsets,
+		title:    m.title,
+		last:     m.last,
+		keyboard: append([]int(nil), m.keyboard...),
+		pending:  m.pending,
+		updates:  m.updates,
+
+		discarding: m.discarding,
+	}
+	return c
+}
+
+// updateCount returns the number of updates applied to the model.
+func (m *screenModel) updateCount() uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.updates
+}
+
+// dump returns a sequence that recreates the model on a terminal of the
+// same size: both screen buffers, the cursor and the position saved with
+// DECSC, the scroll region, the modes and the title. Character sets and
+// tab stops are not included.
+func (m *screenModel) dump() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b strings.Builder
+	b.WriteString("\x1bc")
+	if m.title != "" {
+		fmt.Fprintf(&b, "\x1b]2;%s\x07", m.title)
+	}
+
+	main := m.grid
+	if m.modes.AltScreen {
+		main = m.other
+	}
+	dumpGrid(&b, main)
+
+	// The saved cursor is restored when leaving the alternate screen
+	fmt.Fprintf(&b, "\x1b[%d;%dH%s", m.saved.row+1, m.saved.col+1, m.saved.style.sgr())
+	if m.modes.AltScreen {
+		b.WriteString("\x1b[?1049h")
+		dumpGrid(&b, m.grid)
+	} else {
+		b.WriteString("\x1b7")
+	}
+
+	if m.top != 0 || m.bottom != m.rows-1 {
+		fmt.Fprintf(&b, "\x1b[%d;%dr", m.top+1, m.bottom+1)
+	}
+	for _, mode := range []struct {
+		on  bool
+		seq string
+	}{
+		{m.modes.ApplicationCursorKeys, "\x1b[?1h"},
+		{m.modes.ApplicationKeypad, "\x1b="},
+		{!m.modes.AutoWrap, "\x1b[?7l"},
+		{m.modes.Insert, "\x1b[4h"},
+		{m.modes.LineFeedNewLine, "\x1b[20h"},
+		{m.modes.Origin, "\x1b[?6h"},
+		{m.modes.BracketedPaste, "\x1b[?2004h"},
+		{m.modes.MouseTracking, "\x1b[?1000h"},
+		{!m.cursor.Visible, "\x1b[?25l"},
+		{m.cursor.Blinking, "\x1b[?12h"},
+	} {
+		if mode.on {
+			b.WriteString(mode.seq)
+		}
+	}
+	if m.modes.KittyKeyboard != 0 {
+		fmt.Fprintf(&b, "\x1b[>%du", m.modes.KittyKeyboard)
+	}
+	if m.cursor.Shape != CursorDefault {
+		// Steady shapes are the even DECSCUSR values, blinking ones odd
+		ps := 2 * int(m.cursor.Shape)
+		if m.cursor.Blinking {
+			ps--
+		}
+		fmt.Fprintf(&b, "\x1b[%d q", ps)
+	}
+
+	row := m.cursor.Row
+	if m.modes.Origin {
+		row -= m.top
+	}
+	fmt.Fprintf(&b, "\x1b[%d;%dH%s", row+1, m.cursor.Col+1, m.style.sgr())
+	return b.String()
+}
+
+// dumpGrid writes the cells of grid with the SGR sequences they need,
+// leaving out blank cells at the end of each row.
+func dumpGrid(b *strings.Builder, grid [][]Cell) {
+	style := Style{}
+	b.WriteString(style.sgr())
+	for row, line := range grid {
+		end := len(line)
+		for end > 0 && line[end-1] == blankCell {
+			end--
+		}
+		if end == 0 {
+			continue
+		}
+		fmt.Fprintf(b, "\x1b[%dH", row+1)
+		for _, cell := range line[:end] {
+			if cell.Style != style {
+				style = cell.Style
+				b.WriteString(style.sgr())
+			}
+			b.WriteString(cell.String())
+		}
+	}
+}
+
+// cloneGrid returns a deep copy of grid.
+func cloneGrid(grid [][]Cell) [][]Cell {
+	if grid == nil {
+		return nil
+	}
+	cells := make([][]Cell, len(grid))
+	for i, line := range grid {
+		cells[i] = append([]Cell(nil), line...)
+	}
+	return cells
+}
+
+// resize changes the screen size, keeping the content at the top left.
+// When rows are removed below the cursor's reach, lines are dropped from
+// the top so the cursor stays on screen.
+func (m *screenModel) resize(cols, rows int) {
+	cols, rows = max(cols, 1), max(rows, 1)
+	if cols == m.cols && rows == m.rows {
+		return
+	}
+
+	drop := max(0, m.cursor.Row-rows+1)
+	m.grid = resizeGrid(m.grid, cols, rows, drop)
+	if m.other != nil {
+		m.other = resizeGrid(m.other, cols, rows, 0)
+	}
+
+	tabs := defaultTabs(cols)
+	copy(tabs, m.tabs)
+	m.tabs = tabs
+
+	m.cols, m.rows = cols, rows
+	m.top, m.bottom = 0, rows-1
+	m.wrap = false
+	m.cursor.Row = min(m.cursor.Row-drop, rows-1)
+	m.cursor.Col = min(m.cursor.Col, cols-1)
+}
+
+// resizeGrid returns grid cut or padded to cols and rows after dropping
+// its first drop lines.
+func resizeGrid(grid [][]Cell, cols, rows, drop int) [][]Cell {
+	grid = grid[drop:]
+	resized := make([][]Cell, rows)
+	for i := range resized {
+		resized[i] = newLine(cols)
+		if i < len(grid) {
+			copy(resized[i], grid[i])
+		}
+	}
+	return resized
+}
+
+// feed processes a chunk of output.
+func (m *screenModel) feed(seq string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.updates++
+	m.write(seq)
+}
+
+// load resets the screen to the given size and processes a screen dump.
+func (m *screenModel) load(cols, rows int, seq string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.updates++
+	m.reset(cols, rows)
+	m.write(seq)
+}
+
+// setSize resizes the screen.
+func (m *screenModel) setSize(cols, rows int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.updates++
+	m.resize(cols, rows)
+}
+
+// maxPendingSequence is the longest unterminated escape sequence kept
+// across chunks. Longer OSC, DCS and other string sequences are dropped up
+// to their terminator, as xterm does, instead of being buffered without
+// bound.
+const maxPendingSequence = 64 * 1024
+
+// write processes a chunk of output. The caller must hold m.mu.
+func (m *screenModel) write(seq string) {
+	seq = m.pending + seq
+	m.pending = ""
+
+	if m.discarding {
+		end := stringTerminator(seq)
+		if end < 0 {
+			if strings.HasSuffix(seq, "\x1b") {
+				// The ESC may start the ST that ends the sequence
+				m.pending = "\x1b"
+			}
+			return
+		}
+		m.discarding = false
+		seq = seq[end:]
+	}
+
+	for i := 0; i < len(seq); {
+		c := seq[i]
+		switch {
+		case c == 0x1b:
+			end := skipEscape(seq, i)
+			if end >= len(seq) && !escapeComplete(seq[i:]) {
+				if len(seq)-i > maxPendingSequence {
+					m.discarding = isStringSequence(seq[i:])
+					return
+				}
+				m.pending = seq[i:]
+				return
+			}
+			m.escape(seq[i:end])
+			i = end
+		case c < 0x20 || c == 0x7f:
+			m.control(c)
+			i++
+		case c < utf8.RuneSelf:
+			m.print(rune(c))
+			i++
+		default:
+			if !utf8.FullRuneInString(seq[i:]) {
+				m.pending = seq[i:]
+				return
+			}
+			r, size := utf8.DecodeRuneInString(seq[i:])
+			m.print(r)
+			i += size
+		}
+	}
+}
+
+// isStringSequence reports whether esc starts an OSC, DCS, SOS, PM or APC
+// sequence, which runs until BEL or ST.
+func isStringSequence(esc string) bool {
+	return len(esc) > 1 && strings.IndexByte("]PX^_", esc[1]) >= 0
+}
+
+// stringTerminator returns the offset just past the BEL or ST that ends
+// a string sequence in s, or -1 if there is none.
+func stringTerminator(s string) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] == 0x07 {
+			return i + 1
+		}
+		if s[i] == 0x1b && i+1 < len(s) && s[i+1] == '\\' {
+			return i + 2
+		}
+	}
+	return -1
+}
+
+// print writes one character at the cursor, wrapping lazily like a VT100:
+// the cursor stays in the last column until the next character is printed.
+func (m *screenModel) print(r rune) {
+	if r < utf8.RuneSelf && m.charsets.active() {
+		if g, ok := decSpecialGraphics[byte(r)]; ok {
+			r = g
+		}
+	}
+
+	// Combining characters, variation selectors and joined emoji become
+	// part of the cluster in the previous cell
+	if col := m.previousCell(); col >= 0 && joinsGrapheme(m.grid[m.cursor.Row][col].String(), r) {
+		m.extendCell(col, r)
+		return
+	}
+	width := runeWidth(r)
+	if width == 0 {
+		return
+	}
+	m.last = r
+
+	if m.wrap {
+		m.cursor.Col = 0
+		m.lineFeed()
+	}
+	if width == 2 && m.cursor.Col == m.cols-1 && m.cols > 1 {
+		// A wide character that does not fit wraps as a whole
+		if !m.modes.AutoWrap {
+			m.cursor.Col--
+		} else {
+			m.splitWide(m.cursor.Row, m.cursor.Col)
+			m.grid[m.cursor.Row][m.cursor.Col] = m.blank()
+			m.cursor.Col = 0
+			m.lineFeed()
+		}
+	}
+	width = min(width, m.cols)
+
+	line := m.grid[m.cursor.Row]
+	col := m.cursor.Col
+	if m.modes.Insert {
+		copy(line[col+width:], line[col:])
+	}
+	m.splitWide(m.cursor.Row, col)
+	m.splitWide(m.cursor.Row, col+width-1)
+	line[col] = Cell{Rune: r, Style: m.style}
+	if width == 2 {
+		line[col+1] = Cell{Style: m.style}
+	}
+
+	if col+width < m.cols {
+		m.cursor.Col += width
+	} else {
+		m.cursor.Col = m.cols - 1
+		if m.modes.AutoWrap {
+			m.wrap = true
+		}
+	}
+}
+
+// previousCell returns the column of the cell printed to before the
+// cursor, or -1 at the start of a line.
+func (m *screenModel) previousCell() int {
+	col := m.cursor.Col - 1
+	if m.wrap {
+		col = m.cursor.Col
+	}
+	if col > 0 && m.grid[m.cursor.Row][col].Rune == 0 {
+		col--
+	}
+	return col
+}
+
+// extendCell adds r to the grapheme cluster in the cell at col on the
+// cursor row. A cluster that becomes wide, such as a symbol followed by
+// the emoji presentation selector, takes up the next cell if the cursor
+// is on it.
+func (m *screenModel) extendCell(col int, r rune) {
+	line := m.grid[m.cursor.Row]
+	cell := &line[col]
+	cell.Grapheme = cell.String() + string(r)
+
+	wide := col+1 < m.cols && line[col+1].Rune == 0
+	if wide || graphemeWidth(cell.Grapheme) < 2 || m.wrap || m.cursor.Col != col+1 {
+		return
+	}
+	m.splitWide(m.cursor.Row, col+1)
+	line[col+1] = Cell{Style: cell.Style}
+	if col+2 < m.cols {
+		m.cursor.Col++
+	} else if m.modes.AutoWrap {
+		m.wrap = true
+	}
+}
+
+// splitWide blanks both halves of a wide character at col that is about
+// to be partly overwritten.
+func (m *screenModel) splitWide(row, col int) {
+	line := m.grid[row]
+	switch {
+	case col < 0 || col >= len(line):
+	case line[col].Rune == 0 && col > 0:
+		line[col-1], line[col] = m.blank(), m.blank()
+	case col+1 < len(line) && line[col+1].Rune == 0:
+		line[col], line[col+1] = m.blank(), m.blank()
+	}
+}
+
+// control handles a C0 control character.
+func (m *screenModel) control(c byte) {
+	switch c {
+	case '\b':
+		m.moveTo(m.cursor.Row, m.cursor.Col-1)
+	case '\t':
+		m.tab(1)
+	case '\n', '\v', '\f':
+		if m.modes.LineFeedNewLine {
+			m.cursor.Col = 0
+		}
+		m.lineFeed()
+	case '\r':
+		m.moveTo(m.cursor.Row, 0)
+	case 0x0e: // SO
+		m.charsets.shifted = true
+	case 0x0f: // SI
+		m.charsets.shifted = false
+	}
+}
+
+// tab moves to the n-th next tab stop, or back to the n-th previous one if
+// n is negative.
+func (m *screenModel) tab(n int) {
+	col := m.cursor.Col
+	for ; n > 0 && col < m.cols-1; n-- {
+		for col++; col < m.cols-1 && !m.tabs[col]; col++ {
+		}
+	}
+	for ; n < 0 && col > 0; n++ {
+		for col--; col > 0 && !m.tabs[col]; col-- {
+		}
+	}
+	m.moveTo(m.cursor.Row, col)
+}
+
+// lineFeed moves down one row, scrolling at the bottom of the scroll region.
+func (m *screenModel) lineFeed() {
+	m.wrap = false
+	switch {
+	case m.cursor.Row == m.bottom:
+		m.scrollUp(1)
+	case m.cursor.Row < m.rows-1:
+		m.cursor.Row++
+	}
+}
+
+// reverseIndex moves up one row, scrolling at the top of the scroll region.
+func (m *screenModel) reverseIndex() {
+	m.wrap = false
+	switch {
+	case m.cursor.Row == m.top:
+		m.scrollDown(1)
+	case m.cursor.Row > 0:
+		m.cursor.Row--
+	}
+}
+
+// scrollUp moves the lines of the scroll region up by n.
+func (m *screenModel) scrollUp(n int) {
+	m.shiftLines(m.top, m.bottom, n)
+}
+
+// scrollDown moves the lines of the scroll region down by n.
+func (m *screenModel) scrollDown(n int) {
+	m.shiftLines(m.top, m.bottom, -n)
+}
+
+// shiftLines moves lines first to last up by n (down if n is negative),
+// filling the vacated lines with blanks.
+func (m *screenModel) shiftLines(first, last, n int) {
+	height := last - first + 1
+	if n == 0 || height <= 0 {
+		return
+	}
+	if n >= height || -n >= height {
+		for row := first; row <= last; row++ {
+			m.grid[row] = m.blankLine()
+		}
+		return
+	}
+
+	lines := m.grid[first : last+1]
+	if n > 0 {
+		copy(lines, lines[n:])
+		for i := height - n; i < height; i++ {
+			lines[i] = m.blankLine()
+		}
+	} else {
+		copy(lines[-n:], lines)
+		for i := 0; i < -n; i++ {
+			lines[i] = m.blankLine()
+		}
+	}
+}
+
+// moveTo moves the cursor to row and col, clamped to the screen.
+func (m *screenModel) moveTo(row, col int) {
+	m.wrap = false
+	m.cursor.Row = max(0, min(row, m.rows-1))
+	m.cursor.Col = max(0, min(col, m.cols-1))
+}
+
+// moveToOrigin moves the cursor to a position given by CUP or VPA, which
+// is relative to the scroll region in origin mode.
+func (m *screenModel) moveToOrigin(row, col int) {
+	if m.modes.Origin {
+		row = max(m.top, min(row+m.top, m.bottom))
+	}
+	m.moveTo(row, col)
+}
+
+// erase blanks the cells from col first to col last of row.
+func (m *screenModel) erase(row, first, last int) {
+	line := m.grid[row]
+	blank := m.blank()
+	for col := max(first, 0); col <= min(last, m.cols-1); col++ {
+		line[col] = blank
+	}
+}
+
+// blank returns the cell left by erasing, which keeps the current
+// background color like xterm does.
+func (m *screenModel) blank() Cell {
+	return Cell{Rune: ' ', Style: Style{Bg: m.style.Bg}}
+}
+
+// blankLine returns an erased line.
+func (m *screenModel) blankLine() []Cell {
+	line := make([]Cell, m.cols)
+	blank := m.blank()
+	for i := range line {
+		line[i] = blank
+	}
+	return line
+}
+
+// saveCursor implements DECSC.
+func (m *screenModel) saveCursor() {
+	m.saved = savedCursor{
+		row:      m.cursor.Row,
+		col:      m.cursor.Col,
+		style:    m.style,
+		wrap:     m.wrap,
+		origin:   m.modes.Origin,
+		charsets: m.charsets,
+	}
+}
+
+// restoreCursor implements DECRC.
+func (m *screenModel) restoreCursor() {
+	m.moveTo(m.saved.row, m.saved.col)
+	m.style = m.saved.style
+	m.wrap = m.saved.wrap
+	m.modes.Origin = m.saved.origin
+	m.charsets = m.saved.charsets
+}
+
+// escape interprets a complete escape sequence.
+func (m *screenModel) escape(esc string) {
+	if len(esc) < 2 {
+		return
+	}
+
+	switch esc[1] {
+	case '[':
+		m.csi(esc[2:])
+	case ']':
+		m.osc(esc[2:])
+	case '(', ')':
+		m.charsets.designate(esc)
+	case '7':
+		m.saveCursor()
+	case '8':
+		m.restoreCursor()
+	case 'D':
+		m.lineFeed()
+	case 'E':
+		m.cursor.Col = 0
+		m.lineFeed()
+	case 'M':
+		m.reverseIndex()
+	case 'H':
+		m.tabs[m.cursor.Col] = true
+	case 'c':
+		m.reset(m.cols, m.rows)
+	case '=':
+		m.modes.ApplicationKeypad = true
+	case '>':
+		m.modes.ApplicationKeypad = false
+	case '#':
+		if esc == "\x1b#8" { // DECALN: fill the screen with 'E'
+			for _, line := range m.grid {
+				for col := range line {
+					line[col] = Cell{Rune: 'E'}
+				}
+			}
+			m.moveTo(0, 0)
+		}
+	}
+}
+
+// osc interprets an operating system command, of which only window titles
+// affect the screen state.
+func (m *screenModel) osc(body string) {
+	body = strings.TrimSuffix(strings.TrimSuffix(body, "\x07"), "\x1b\\")
+	ps, pt, _ := strings.Cut(body, ";")
+	if ps == "0" || ps == "2" {
+		m.title = pt
+	}
+}
+
+// csi interprets the parameters, intermediates and final byte of a CSI
+// sequence.
+func (m *screenModel) csi(body string) {
+	if body == "" {
+		return
+	}
+	final := body[len(body)-1]
+	params := body[:len(body)-1]
+
+	var private byte
+	if params != "" && params[0] >= '<' && params[0] <= '?' {
+		private, params = params[0], params[1:]
+	}
+	var intermediate byte
+	if n := len(params); n > 0 && params[n-1] >= 0x20 && params[n-1] <= 0x2f {
+		intermediate, params = params[n-1], params[:n-1]
+	}
+
+	fields := strings.Split(params, ";")
+	// arg returns the n-th parameter, or def if it is missing or zero
+	arg := func(n, def int) int {
+		if n < len(fields) {
+			value, _, _ := strings.Cut(fields[n], ":")
+			if v, err := strconv.Atoi(value); err == nil && v > 0 {
+				return v
+			}
+		}
+		return def
+	}
+
+	switch private {
+	case 0:
+	case '?':
+		if final == 'h' || final == 'l' {
+			for _, f := range fields {
+				mode, _ := strconv.Atoi(f)
+				m.setPrivateMode(mode, final == 'h')
+			}
+		}
+		return
+	case '>', '<', '=':
+		if final == 'u' {
+			m.keyboardFlags(private, arg(0, 0), arg(1, 1))
+		}
+		return
+	default:
+		return
+	}
+
+	switch intermediate {
+	case 0:
+	case '!':
+		if final == 'p' {
+			m.softReset()
+		}
+		return
+	case ' ':
+		if final == 'q' {
+			m.setCursorStyle(arg(0, 0))
+		}
+		return
+	default:
+		return
+	}
+
+	row, col := m.cursor.Row, m.cursor.Col
+	switch final {
+	case '@': // ICH
+		line := m.grid[row]
+		n := min(arg(0, 1), m.cols-col)
+		copy(line[col+n:], line[col:])
+		m.erase(row, col, col+n-1)
+		m.wrap = false
+	case 'A': // CUU
+		minRow := 0
+		if row >= m.top {
+			minRow = m.top
+		}
+		m.moveTo(max(row-arg(0, 1), minRow), col)
+	case 'B', 'e': // CUD, VPR
+		maxRow := m.rows - 1
+		if row <= m.bottom {
+			maxRow = m.bottom
+		}
+		m.moveTo(min(row+arg(0, 1), maxRow), col)
+	case 'C', 'a': // CUF, HPR
+		m.moveTo(row, col+arg(0, 1))
+	case 'D': // CUB
+		m.moveTo(row, col-arg(0, 1))
+	case 'E': // CNL
+		m.moveTo(row+arg(0, 1), 0)
+	case 'F': // CPL
+		m.moveTo(row-arg(0, 1), 0)
+	case 'G', '`': // CHA, HPA
+		m.moveTo(row, arg(0, 1)-1)
+	case 'H', 'f': // CUP, HVP
+		m.moveToOrigin(arg(0, 1)-1, arg(1, 1)-1)
+	case 'I': // CHT
+		m.tab(arg(0, 1))
+	case 'Z': // CBT
+		m.tab(-arg(0, 1))
+	case 'd': // VPA
+		m.moveToOrigin(arg(0, 1)-1, col)
+	case 'J': // ED
+		switch arg(0, 0) {
+		case 0:
+			m.erase(row, col, m.cols-1)
+			for r := row + 1; r < m.rows; r++ {
+				m.erase(r, 0, m.cols-1)
+			}
+		case 1:
+			for r := 0; r < row; r++ {
+				m.erase(r, 0, m.cols-1)
+			}
+			m.erase(row, 0, col)
+		case 2, 3:
+			for r := 0; r < m.rows; r++ {
+				m.erase(r, 0, m.cols-1)
+			}
+		}
+		m.wrap = false
+	case 'K': // EL
+		switch arg(0, 0) {
+		case 0:
+			m.erase(row, col, m.cols-1)
+		case 1:
+			m.erase(row, 0, col)
+		case 2:
+			m.erase(row, 0, m.cols-1)
+		}
+		m.wrap = false
+	case 'L': // IL
+		if row >= m.top && row <= m.bottom {
+			m.shiftLines(row, m.bottom, -arg(0, 1))
+			m.moveTo(row, 0)
+		}
+	case 'M': // DL
+		if row >= m.top && row <= m.bottom {
+			m.shiftLines(row, m.bottom, arg(0, 1))
+			m.moveTo(row, 0)
+		}
+	case 'P': // DCH
+		line := m.grid[row]
+		n := min(arg(0, 1), m.cols-col)
+		copy(line[col:], line[col+n:])
+		m.erase(row, m.cols-n, m.cols-1)
+		m.wrap = false
+	case 'X': // ECH
+		m.erase(row, col, col+arg(0, 1)-1)
+		m.wrap = false
+	case 'S': // SU
+		m.scrollUp(arg(0, 1))
+	case 'T': // SD
+		if len(fields) <= 1 {
+			m.scrollDown(arg(0, 1))
+		}
+	case 'b': // REP
+		if m.last != 0 {
+			for n := arg(0, 1); n > 0; n-- {
+				m.print(m.last)
+			}
+		}
+	case 'g': // TBC
+		switch arg(0, 0) {
+		case 0:
+			m.tabs[col] = false
+		case 3:
+			clear(m.tabs)
+		}
+	case 'h', 'l': // SM, RM
+		set := final == 'h'
+		for _, f := range fields {
+			switch f {
+			case "4":
+				m.modes.Insert = set
+			case "20":
+				m.modes.LineFeedNewLine = set
+			}
+		}
+	case 'r': // DECSTBM
+		top, bottom := arg(0, 1)-1, arg(1, m.rows)-1
+		if bottom > m.rows-1 {
+			bottom = m.rows - 1
+		}
+		if top < bottom {
+			m.top, m.bottom = top, bottom
+			m.moveToOrigin(0, 0)
+		}
+	case 'm': // SGR
+		m.style.applySGR(params)
+	case 's': // SCOSC
+		m.saveCursor()
+	case 'u': // SCORC
+		m.restoreCursor()
+	}
+}
+
+// maxKeyboardStack bounds the kitty keyboard flag stack, as in kitty.
+const maxKeyboardStack = 16
+
+// keyboardFlags handles the kitty keyboard protocol sequences CSI > flags u
+// (push), CSI < n u (pop n entries) and CSI = flags ; mode u (set, or or
+// clear bits).
+func (m *screenModel) keyboardFlags(op byte, value, mode int) {
+	switch op {
+	case '>':
+		if len(m.keyboard) == maxKeyboardStack {
+			m.keyboard = m.keyboard[1:]
+		}
+		m.keyboard = append(m.keyboard, m.modes.KittyKeyboard)
+		m.modes.KittyKeyboard = value
+	case '<':
+		for range max(value, 1) {
+			if len(m.keyboard) == 0 {
+				m.modes.KittyKeyboard = 0
+				return
+			}
+			m.modes.KittyKeyboard = m.keyboard[len(m.keyboard)-1]
+			m.keyboard = m.keyboard[:len(m.keyboard)-1]
+		}
+	case '=':
+		switch mode {
+		case 1:
+			m.modes.KittyKeyboard = value
+		case 2:
+			m.modes.KittyKeyboard |= value
+		case 3:
+			m.modes.KittyKeyboard &^= value
+		}
+	}
+}
+
+// setPrivateMode sets or resets a DEC private mode.
+func (m *screenModel) setPrivateMode(mode int, set bool) {
+	switch mode {
+	case 1:
+		m.modes.ApplicationCursorKeys = set
+	case 6:
+		m.modes.Origin = set
+		m.moveToOrigin(0, 0)
+	case 7:
+		m.modes.AutoWrap = set
+		if !set {
+			m.wrap = false
+		}
+	case 12:
+		m.cursor.Blinking = set
+	case 25:
+		m.cursor.Visible = set
+	case 47, 1047:
+		m.switchScreen(set, mode == 1047 && !set)
+	case 1048:
+		if set {
+			m.saveCursor()
+		} else {
+			m.restoreCursor()
+		}
+	case 1049:
+		if set {
+			m.saveCursor()
+			m.switchScreen(true, false)
+			for r := 0; r < m.rows; r++ {
+				m.erase(r, 0, m.cols-1)
+			}
+		} else {
+			m.switchScreen(false, false)
+			m.restoreCursor()
+		}
+	case 1000, 1002, 1003:
+		m.modes.MouseTracking = set
+	case 2004:
+		m.modes.BracketedPaste = set
+	}
+}
+
+// setCursorStyle implements DECSCUSR: 0 and 1 select a blinking block, 2 a
+// steady block, 3 and 4 an underline and 5 and 6 a bar, odd values blinking.
+func (m *screenModel) setCursorStyle(ps int) {
+	switch ps {
+	case 0:
+		m.cursor.Shape, m.cursor.Blinking = CursorDefault, false
+	case 1, 2:
+		m.cursor.Shape = CursorBlock
+	case 3, 4:
+		m.cursor.Shape = CursorUnderline
+	case 5, 6:
+		m.cursor.Shape = CursorBar
+	default:
+		return
+	}
+	if ps > 0 {
+		m.cursor.Blinking = ps%2 == 1
+	}
+}
+
+// switchScreen shows the alternate screen buffer or returns to the main
+// one, clearing the alternate buffer on the way out if clear is set.
+func (m *screenModel) switchScreen(alt, clear bool) {
+	if alt == m.modes.AltScreen {
+		return
+	}
+	if !alt && clear {
+		m.grid = newGrid(m.cols, m.rows)
+	}
+	if m.other == nil {
+		m.other = newGrid(m.cols, m.rows)
+	}
+	m.grid, m.other = m.other, m.grid
+	m.modes.AltScreen = alt
+	m.wrap = false
+}
+
+// softReset implements DECSTR.
+func (m *screenModel) softReset() {
+	m.cursor.Visible = true
+	m.style = Style{}
+	m.modes.Insert = false
+	m.modes.Origin = false
+	m.modes.AutoWrap = true
+	m.modes.ApplicationCursorKeys = false
+	m.modes.ApplicationKeypad = false
+	m.top, m.bottom = 0, m.rows-1
+	m.charsets = charsetTranslator{}
+	m.saved = savedCursor{}
+}
@@ -0,0 +1,246 @@
+package htlib
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScreenModel(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []string
+		text   string
+		cursor Cursor
+	}{
+		{
+			name:   "print and newline",
+			chunks: []string{"hello\r\nworld"},
+			text:   "hello\nworld\n\n",
+			cursor: Cursor{Row: 1, Col: 5, Visible: true},
+		},
+		{
+			name:   "autowrap",
+			chunks: []string{"0123456789ab"},
+			text:   "0123456789\nab\n\n",
+			cursor: Cursor{Row: 1, Col: 2, Visible: true},
+		},
+		{
+			name:   "no autowrap overwrites last column",
+			chunks: []string{"\x1b[?7l0123456789ab"},
+			text:   "012345678b\n\n\n",
+			cursor: Cursor{Row: 0, Col: 9, Visible: true},
+		},
+		{
+			name:   "scrolls at bottom",
+			chunks: []string{"a\r\nb\r\nc\r\nd\r\ne"},
+			text:   "b\nc\nd\ne",
+			cursor: Cursor{Row: 3, Col: 1, Visible: true},
+		},
+		{
+			name:   "erase in line and display",
+			chunks: []string{"abcdef\r\nghijkl\x1b[1;3H\x1b[K\x1b[2;3H\x1b[1K"},
+			text:   "ab\n   jkl\n\n",
+			cursor: Cursor{Row: 1, Col: 2, Visible: true},
+		},
+		{
+			name:   "clear screen",
+			chunks: []string{"abc\r\ndef\x1b[2J"},
+			text:   "\n\n\n",
+			cursor: Cursor{Row: 1, Col: 3, Visible: true},
+		},
+		{
+			name:   "insert and delete characters",
+			chunks: []string{"abcdef\x1b[1;2H\x1b[2@XY\x1b[1;6H\x1b[P"},
+			text:   "aXYbcef\n\n\n",
+			cursor: Cursor{Row: 0, Col: 5, Visible: true},
+		},
+		{
+			name:   "insert mode",
+			chunks: []string{"abc\r\x1b[4hX\x1b[4lY"},
+			text:   "XYbc\n\n\n",
+			cursor: Cursor{Row: 0, Col: 2, Visible: true},
+		},
+		{
+			name:   "insert and delete lines",
+			chunks: []string{"1\r\n2\r\n3\r\n4\x1b[2;1H\x1b[L\x1b[4;1H\x1b[M"},
+			text:   "1\n\n2\n",
+			cursor: Cursor{Row: 3, Col: 0, Visible: true},
+		},
+		{
+			name:   "scroll region",
+			chunks: []string{"top\x1b[2;3r\x1b[2;1Ha\r\nb\r\nc\x1b[r"},
+			text:   "top\nb\nc\n",
+			cursor: Cursor{Row: 0, Col: 0, Visible: true},
+		},
+		{
+			name:   "reverse index scrolls down",
+			chunks: []string{"a\r\nb\x1b[H\x1bM"},
+			text:   "\na\nb\n",
+			cursor: Cursor{Row: 0, Col: 0, Visible: true},
+		},
+		{
+			name:   "alternate screen",
+			chunks: []string{"$ vim", "\x1b[?1049h\x1b[Hediting", "\x1b[?1049l"},
+			text:   "$ vim\n\n\n",
+			cursor: Cursor{Row: 0, Col: 5, Visible: true},
+		},
+		{
+			name:   "line drawing charset",
+			chunks: []string{"\x1b(0lqk\x1b(B|\x0emq\x0f"},
+			text:   "┌─┐|mq\n\n\n",
+			cursor: Cursor{Row: 0, Col: 6, Visible: true},
+		},
+		{
+			name:   "shifted line drawing",
+			chunks: []string{"\x1b)0\x0elqk\x0fx"},
+			text:   "┌─┐x\n\n\n",
+			cursor: Cursor{Row: 0, Col: 4, Visible: true},
+		},
+		{
+			name:   "split escape and utf-8",
+			chunks: []string{"a\x1b[", "2;3Hé", "\xc3", "\xa9"},
+			text:   "a\n  éé\n\n",
+			cursor: Cursor{Row: 1, Col: 4, Visible: true},
+		},
+		{
+			name:   "tabs",
+			chunks: []string{"\tx\x1b[3g\x1b[1;4H\x1bH\r\ty"},
+			text:   "   y    x\n\n\n",
+			cursor: Cursor{Row: 0, Col: 4, Visible: true},
+		},
+		{
+			name:   "repeat and erase characters",
+			chunks: []string{"ab\x1b[3bcdef\x1b[1;2H\x1b[2X"},
+			text:   "a  bbcdef\n\n\n",
+			cursor: Cursor{Row: 0, Col: 1, Visible: true},
+		},
+		{
+			name:   "origin mode",
+			chunks: []string{"\x1b[2;3r\x1b[?6h\x1b[1;1Hx\x1b[9;1Hy"},
+			text:   "\nx\ny\n",
+			cursor: Cursor{Row: 2, Col: 1, Visible: true},
+		},
+		{
+			name:   "save and restore cursor",
+			chunks: []string{"\x1b[2;2H\x1b7\x1b[4;4H\x1b8x"},
+			text:   "\n x\n\n",
+			cursor: Cursor{Row: 1, Col: 2, Visible: true},
+		},
+		{
+			name:   "full reset",
+			chunks: []string{"abc\x1b[?25l\x1bc"},
+			text:   "\n\n\n",
+			cursor: Cursor{Row: 0, Col: 0, Visible: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newScreenModel(10, 4)
+			for _, chunk := range tt.chunks {
+				m.feed(chunk)
+			}
+			screen := m.snapshot()
+			if text := screen.Text(); text != tt.text {
+				t.Errorf("expected text %q, got %q", tt.text, text)
+			}
+			if screen.Cursor != tt.cursor {
+				t.Errorf("expected cursor %+v, got %+v", tt.cursor, screen.Cursor)
+			}
+		})
+	}
+}
+
+func TestScreenModelModes(t *testing.T) {
+	m := newScreenModel(80, 24)
+	m.feed("\x1b[?1h\x1b=\x1b[?2004h\x1b[?1000h\x1b[20h\x1b]2;my title\x07")
+	expected := Modes{
+		ApplicationCursorKeys: true,
+		ApplicationKeypad:     true,
+		AutoWrap:              true,
+		LineFeedNewLine:       true,
+		BracketedPaste:        true,
+		MouseTracking:         true,
+	}
+	screen := m.snapshot()
+	if screen.Modes != expected {
+		t.Errorf("expected modes %+v, got %+v", expected, screen.Modes)
+	}
+	if screen.Title != "my title" {
+		t.Errorf("expected title, got %q", screen.Title)
+	}
+
+	m.feed("\x1b[?1049h\x1b[!p")
+	screen = m.snapshot()
+	if !screen.Modes.AltScreen || screen.Modes.ApplicationCursorKeys {
+		t.Errorf("expected soft reset to keep the alternate screen only, got %+v", screen.Modes)
+	}
+}
+
+func TestScreenModelKittyKeyboard(t *testing.T) {
+	m := newScreenModel(80, 24)
+	steps := []struct {
+		seq      string
+		expected int
+	}{
+		{"\x1b[>1u", 1},
+		{"\x1b[>3u", 3},
+		{"\x1b[=4;2u", 7},
+		{"\x1b[=2;3u", 5},
+		{"\x1b[<u", 1},
+		{"\x1b[<5u", 0},
+		{"\x1b[=8u", 8},
+	}
+	for _, step := range steps {
+		m.feed(step.seq)
+		if got := m.snapshot().Modes.KittyKeyboard; got != step.expected {
+			t.Errorf("after %q: expected flags %d, got %d", step.seq, step.expected, got)
+		}
+	}
+
+	m.feed("\x1bc")
+	if got := m.snapshot().Modes.KittyKeyboard; got != 0 {
+		t.Errorf("expected a reset to clear the flags, got %d", got)
+	}
+}
+
+func TestScreenModelResize(t *testing.T) {
+	m := newScreenModel(10, 4)
+	m.feed("1\r\n2\r\n3\r\n4")
+	m.setSize(5, 2)
+
+	screen := m.snapshot()
+	if screen.Text() != "3\n4" {
+		t.Errorf("expected the lines around the cursor to be kept, got %q", screen.Text())
+	}
+	if screen.Cursor != (Cursor{Row: 1, Col: 1, Visible: true}) {
+		t.Errorf("unexpected cursor %+v", screen.Cursor)
+	}
+
+	m.setSize(8, 3)
+	m.feed("\r\n" + strings.Repeat("x", 9))
+	if text := m.snapshot().Text(); text != "4\nxxxxxxxx\nx" {
+		t.Errorf("expected the new width to wrap, got %q", text)
+	}
+}
+
+func TestScreenModelLongStringSequence(t *testing.T) {
+	m := newScreenModel(10, 2)
+	chunk := strings.Repeat("x", 16*1024)
+	m.feed("a\x1b]0;")
+	for range 8 {
+		m.feed(chunk)
+	}
+	m.feed(chunk + "\x1b")
+	m.feed("\\b")
+
+	m.mu.Lock()
+	pending := len(m.pending)
+	m.mu.Unlock()
+	if pending != 0 {
+		t.Errorf("expected nothing pending, got %d bytes", pending)
+	}
+	if text := m.snapshot().Text(); text != "ab\n" {
+		t.Errorf("expected the sequence to be dropped up to its terminator, got %q", text)
+	}
+}
@@ -0,0 +1,173 @@
+package htlib
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// WaitForText waits until text appears on the screen and returns the
+// snapshot that contains it. The screen is re-checked whenever the terminal
+// produces output, so no sleeps are needed before calling it:
+//
+//	vt.Input(ctx, "make build\n")
+//	snapshot, err := vt.WaitForText(ctx, "Build succeeded")
+func (vt *VirtualTerminal) WaitForText(ctx context.Context, text string, opts ...WaitOption) (*SnapshotEvent, error) {
+	return vt.WaitFor(ctx, func(snapshot *SnapshotEvent) bool {
+		return strings.Contains(snapshot.Text, text)
+	}, opts...)
+}
+
+// WaitFor takes snapshots until pred returns true for one of them and
+// returns that snapshot. It is the building block for arbitrary screen
+// conditions:
+//
+//	snapshot, err := vt.WaitFor(ctx, func(s *htlib.SnapshotEvent) bool {
+//	    return strings.Count(s.Text, "PASS") >= 3
+//	}, htlib.WithPollInterval(100*time.Millisecond))
+//
+// A new snapshot is taken whenever the terminal produces output or is
+// resized, and at least once per poll interval (default 250ms) to catch
+// changes whose events were missed.
+func (vt *VirtualTerminal) WaitFor(ctx context.Context, pred func(*SnapshotEvent) bool, opts ...WaitOption) (*SnapshotEvent, error) {
+	options := waitOptions{pollInterval: screenPollInterval}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return vt.waitForScreen(ctx, options.pollInterval, pred)
+}
+
+// WaitOption configures screen waits such as WaitFor and WaitForText.
+type WaitOption func(*waitOptions)
+
+// waitOptions holds the settings applied by WaitOptions.
+type waitOptions struct {
+	pollInterval time.Duration
+}
+
+// WithPollInterval sets how often the screen is re-checked when no output
+// arrives. Non-positive values are ignored.
+func WithPollInterval(d time.Duration) WaitOption {
+	return func(o *waitOptions) {
+		if d > 0 {
+			o.pollInterval = d
+		}
+	}
+}
+
+// WaitForStable waits until the terminal has produced no output for the
+// quiet period, which is the usual way to tell that a command has finished
+// printing:
+//
+//	vt.Input(ctx, "ls -la\n")
+//	vt.WaitForStable(ctx, 200*time.Millisecond)
+//	snapshot, err := vt.WaitForSnapshot(ctx)
+//
+// The quiet window starts when WaitForStable is called, so it returns after
+// at least one quiet period even if nothing is printed.
+func (vt *VirtualTerminal) WaitForStable(ctx context.Context, quiet time.Duration) error {
+	sub := vt.subscribeRaw()
+	defer vt.Unsubscribe(sub)
+	return vt.waitQuiet(ctx, sub, quiet)
+}
+
+// screenPollInterval is the default interval at which screen waits
+// re-check the screen when no output arrives, catching changes whose events
+// were dropped.
+const screenPollInterval = 250 * time.Millisecond
+
+// waitForScreen takes snapshots until match returns true for one of them.
+// A new snapshot is taken whenever the terminal produces output or is
+// resized, and at least every poll interval.
+func (vt *VirtualTerminal) waitForScreen(ctx context.Context, poll time.Duration, match func(*SnapshotEvent) bool) (*SnapshotEvent, error) {
+	sub := vt.subscribeRaw()
+	defer vt.Unsubscribe(sub)
+
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+
+	for {
+		snapshot, err := vt.WaitForSnapshot(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if match(snapshot) {
+			return snapshot, nil
+		}
+
+		if err := vt.waitForChange(ctx, sub, ticker.C); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// waitForChange blocks until sub delivers an event that may have changed
+// the screen, or tick fires. Snapshot events are ignored so that taking a
+// snapshot does not immediately trigger another one.
+func (vt *VirtualTerminal) waitForChange(ctx context.Context, sub chan Event, tick <-chan time.Time) error {
+	for {
+		select {
+		case event, ok := <-sub:
+			if !ok {
+				return vt.closedErr()
+			}
+			switch event.(type) {
+			case OutputEvent, ResizeEvent:
+				return nil
+			}
+		case <-tick:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-vt.current().ctx.Done():
+			return vt.closedErr()
+		}
+	}
+}
+
+// waitForEvent reads events from sub until match returns true.
+func (vt *VirtualTerminal) waitForEvent(ctx context.Context, sub chan Event, match func(Event) bool) (Event, error) {
+	for {
+		select {
+		case event, ok := <-sub:
+			if !ok {
+				return nil, vt.closedErr()
+			}
+			if match(event) {
+				return event, nil
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-vt.current().ctx.Done():
+			return nil, vt.closedErr()
+		}
+	}
+}
+
+// waitQuiet reads events from sub until no OutputEvent has arrived for the
+// quiet period.
+func (vt *VirtualTerminal) waitQuiet(ctx context.Context, sub chan Event, quiet time.Duration) error {
+	timer := time.NewTimer(quiet)
+	defer timer.Stop()
+
+	for {
+		select {
+		case event, ok := <-sub:
+			if !ok {
+				return vt.closedErr()
+			}
+			if _, isOutput := event.(OutputEvent); isOutput {
+				if !timer.Stop() {
+					<-timer.C
+				}
+				timer.Reset(quiet)
+			}
+		case <-timer.C:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-vt.current().ctx.Done():
+			return vt.closedErr()
+		}
+	}
+}
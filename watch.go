@@ -0,0 +1,203 @@
+package htlib
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrStopWatch can be returned by a WatchFunc to end Watch without error.
+var ErrStopWatch = errors.New("stop watching")
+
+// WatchFunc receives each new screen state seen by Watch. Returning
+// ErrStopWatch stops watching; any other error stops Watch and is returned
+// by it.
+type WatchFunc func(snapshot *SnapshotEvent) error
+
+// WatchOption configures Watch.
+type WatchOption func(*watchOptions)
+
+// watchOptions holds the settings applied by WatchOptions.
+type watchOptions struct {
+	maxInterval time.Duration
+	factor      float64
+	until       func(*SnapshotEvent) bool
+	everyTick   bool
+}
+
+// WithWatchBackoff makes Watch poll less often while the screen does not
+// change: after each unchanged snapshot the interval is multiplied by
+// factor, up to max. Any change resets it to the initial interval.
+func WithWatchBackoff(max time.Duration, factor float64) WatchOption {
+	return func(o *watchOptions) {
+		o.maxInterval = max
+		o.factor = factor
+	}
+}
+
+// WatchUntil stops Watch after the callback has seen a snapshot for which
+// pred returns true.
+func WatchUntil(pred func(*SnapshotEvent) bool) WatchOption {
+	return func(o *watchOptions) {
+		o.until = pred
+	}
+}
+
+// WatchEveryTick makes Watch call the callback for every snapshot it takes,
+// not just when the screen changed.
+func WatchEveryTick() WatchOption {
+	return func(o *watchOptions) {
+		o.everyTick = true
+	}
+}
+
+// Watch takes a snapshot every interval and calls fn whenever the screen
+// text or cursor changed, which makes it easy to monitor long-running TUIs
+// such as htop or build dashboards:
+//
+//	err := vt.Watch(ctx, time.Second, func(s *htlib.SnapshotEvent) error {
+//	    log.Println(s.Text)
+//	    return nil
+//	}, htlib.WithWatchBackoff(10*time.Second, 2),
+//	    htlib.WatchUntil(func(s *htlib.SnapshotEvent) bool {
+//	        return strings.Contains(s.Text, "Build finished")
+//	    }))
+//
+// The first snapshot is always passed to fn. Watch blocks until a stop
+// condition is met (in which case it returns nil), fn returns an error,
+// ctx is done or the terminal is closed.
+func (vt *VirtualTerminal) Watch(ctx context.Context, interval time.Duration, fn WatchFunc, opts ...WatchOption) error {
+	var options watchOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	delay := interval
+	var last *SnapshotEvent
+	for {
+		snapshot, err := vt.WaitForSnapshot(ctx)
+		if err != nil {
+			return err
+		}
+
+		changed := last == nil || snapshot.Text != last.Text || snapshot.Cursor != last.Cursor
+		if changed || options.everyTick {
+			if err := fn(snapshot); err != nil {
+				if errors.Is(err, ErrStopWatch) {
+					return nil
+				}
+				return err
+			}
+			if options.until != nil && options.until(snapshot) {
+				return nil
+			}
+		}
+		last = snapshot
+
+		delay = nextWatchDelay(delay, interval, changed, options)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-vt.current().ctx.Done():
+			timer.Stop()
+			return vt.closedErr()
+		}
+	}
+}
+
+// nextWatchDelay returns the delay before the next snapshot.
+func nextWatchDelay(delay, interval time.Duration, changed bool, options watchOptions) time.Duration {
+	if changed || options.factor <= 1 || options.maxInterval <= interval {
+		return interval
+	}
+	return min(time.Duration(float64(delay)*options.factor), options.maxInterval)
+}
+
+// RegionChange reports new content in a watched screen region.
+type RegionChange struct {
+	Rect Rect
+	// Old and New are the region's text before and after the change, as
+	// returned by Screen.Region
+	Old  string
+	New  string
+	Time time.Time
+}
+
+// RegionWatcher delivers changes of one screen region; see WatchRegion.
+type RegionWatcher struct {
+	// C receives a RegionChange whenever the region's text changes. It is
+	// closed after Close or when the terminal closes.
+	C <-chan RegionChange
+
+	vt   *VirtualTerminal
+	sub  chan Event
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// WatchRegion watches the text inside r, such as a status bar or progress
+// area, and ignores output anywhere else on the screen:
+//
+//	w := vt.WatchRegion(htlib.Rect{Row: 39, Col: 0, Rows: 1, Cols: 120})
+//	defer w.Close()
+//	for change := range w.C {
+//	    log.Printf("status: %q -> %q", change.Old, change.New)
+//	}
+//
+// The region is read from the local screen model (see Screen) after each
+// output, so no snapshots are taken. If changes arrive faster than C is
+// read, intermediate states are skipped, but each change's Old is the New
+// of the one before.
+func (vt *VirtualTerminal) WatchRegion(r Rect) *RegionWatcher {
+	c := make(chan RegionChange, 16)
+	w := &RegionWatcher{
+		C:    c,
+		vt:   vt,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	last := vt.Region(r)
+	w.sub = vt.subscribeRaw()
+	go w.run(r, last, c)
+	return w
+}
+
+// Close stops watching and closes C. It is safe to call Close more than
+// once.
+func (w *RegionWatcher) Close() {
+	w.once.Do(func() {
+		close(w.stop)
+		w.vt.Unsubscribe(w.sub)
+	})
+	<-w.done
+}
+
+// run compares the region after every screen update.
+func (w *RegionWatcher) run(r Rect, last string, c chan<- RegionChange) {
+	defer close(w.done)
+	defer close(c)
+
+	for event := range w.sub {
+		switch event.(type) {
+		case InitEvent, OutputEvent, ResizeEvent:
+		default:
+			continue
+		}
+
+		text := w.vt.Region(r)
+		if text == last {
+			continue
+		}
+		select {
+		case c <- RegionChange{Rect: r, Old: last, New: text, Time: time.Now()}:
+			last = text
+		case <-w.stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package htlib
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNextWatchDelay(t *testing.T) {
+	backoff := watchOptions{maxInterval: time.Second, factor: 2}
+	tests := []struct {
+		name     string
+		delay    time.Duration
+		changed  bool
+		options  watchOptions
+		expected time.Duration
+	}{
+		{"no backoff", 100 * time.Millisecond, false, watchOptions{}, 100 * time.Millisecond},
+		{"grows when unchanged", 100 * time.Millisecond, false, backoff, 200 * time.Millisecond},
+		{"capped", 800 * time.Millisecond, false, backoff, time.Second},
+		{"reset on change", 800 * time.Millisecond, true, backoff, 100 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextWatchDelay(tt.delay, 100*time.Millisecond, tt.changed, tt.options); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestWatch(t *testing.T) {
+	vt := New(DefaultConfig())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := vt.Start(ctx, WaitReady()); err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+	defer vt.Close()
+
+	var calls int
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		vt.Input(ctx, "echo watch-$((6*7))\n")
+	}()
+	err := vt.Watch(ctx, 20*time.Millisecond, func(s *SnapshotEvent) error {
+		calls++
+		return nil
+	}, WithWatchBackoff(100*time.Millisecond, 2), WatchUntil(func(s *SnapshotEvent) bool {
+		return strings.Contains(s.Text, "watch-42")
+	}))
+	if err != nil {
+		t.Fatalf("watch failed: %v", err)
+	}
+	// Unchanged snapshots are not reported
+	if calls < 2 || calls > 10 {
+		t.Errorf("expected a few calls for the changed screens, got %d", calls)
+	}
+
+	// Callbacks can stop watching or fail it
+	calls = 0
+	err = vt.Watch(ctx, 10*time.Millisecond, func(s *SnapshotEvent) error {
+		calls++
+		if calls == 3 {
+			return ErrStopWatch
+		}
+		return nil
+	}, WatchEveryTick())
+	if err != nil || calls != 3 {
+		t.Errorf("expected ErrStopWatch to end after 3 calls, got %d calls, %v", calls, err)
+	}
+
+	failure := errors.New("boom")
+	err = vt.Watch(ctx, 10*time.Millisecond, func(s *SnapshotEvent) error { return failure })
+	if !errors.Is(err, failure) {
+		t.Errorf("expected callback error, got %v", err)
+	}
+
+	shortCtx, shortCancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer shortCancel()
+	err = vt.Watch(shortCtx, 10*time.Millisecond, func(s *SnapshotEvent) error { return nil })
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestWatchRegion(t *testing.T) {
+	vt, _ := newTestTerminal()
+	defer vt.Close()
+
+	send := func(event Event) {
+		vt.trackEvent(event)
+		vt.dispatch(event)
+	}
+	send(InitEvent{Cols: 20, Rows: 3, Seq: "\x1b[2J\x1b[3;1Hstatus: idle"})
+	status := Rect{Row: 2, Col: 0, Rows: 1, Cols: 20}
+	watcher := vt.WatchRegion(status)
+
+	next := func() RegionChange {
+		t.Helper()
+		select {
+		case change := <-watcher.C:
+			return change
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for a region change")
+			return RegionChange{}
+		}
+	}
+
+	// Output elsewhere on the screen is ignored
+	send(OutputEvent{Seq: "\x1b[1;1Hnoise noise"})
+	send(OutputEvent{Seq: "\x1b[3;9H\x1b[Kbuilding"})
+	change := next()
+	if change.Old != "status: idle" || change.New != "status: building" || change.Rect != status {
+		t.Errorf("unexpected first change %+v", change)
+	}
+
+	send(OutputEvent{Seq: "\x1b[1;1Hmore noise"})
+	send(OutputEvent{Seq: "\x1b[3;9H\x1b[Kdone"})
+	if change = next(); change.Old != "status: building" || change.New != "status: done" {
+		t.Errorf("unexpected second change %+v", change)
+	}
+
+	watcher.Close()
+	watcher.Close()
+	if _, ok := <-watcher.C; ok {
+		t.Error("expected C to be closed")
+	}
+}
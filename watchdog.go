@@ -0,0 +1,311 @@
+package htlib
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultWatchdogWindow is the measurement window used when
+// WatchdogPolicy.Window is zero.
+const DefaultWatchdogWindow = time.Minute
+
+// clockTicks is the kernel's USER_HZ, the unit of CPU times in /proc.
+const clockTicks = 100
+
+// WatchdogPolicy describes limits for an unattended session and what to do
+// when one of them is exceeded. Zero limits are not checked.
+type WatchdogPolicy struct {
+	// Name identifies the policy in WatchdogTrip reports
+	Name string
+	// Window is the period over which output and CPU usage are measured (default: 1 minute)
+	Window time.Duration
+	// MaxOutputBytes trips the watchdog when more output than this arrives within Window
+	MaxOutputBytes int64
+	// MaxCPU trips the watchdog when the processes in the terminal use more
+	// than this many CPU cores on average over Window (1.0 is one full core).
+	// CPU usage is read from /proc and is not checked where it is unavailable.
+	MaxCPU float64
+	// PromptTimeout trips the watchdog when no prompt has been printed for this long
+	PromptTimeout time.Duration
+	// Prompt recognizes a prompt in the output (default: common shell prompts)
+	Prompt Matcher
+	// Action runs when the watchdog trips, e.g. KeysAction(Ctrl('c')) or CloseAction()
+	Action Action
+	// Notify is called when the watchdog trips, before Action runs
+	Notify func(WatchdogTrip)
+}
+
+// WatchdogTrip describes why a watchdog fired.
+type WatchdogTrip struct {
+	Policy string
+	Reason string
+	Time   time.Time
+	// OutputBytes is the output received within the window
+	OutputBytes int64
+	// CPU is the average number of cores used within the window, or 0 if unknown
+	CPU float64
+	// SincePrompt is the time since the last prompt (or since the watchdog
+	// started), not counting time the terminal was suspended
+	SincePrompt time.Duration
+}
+
+// CloseAction returns an Action that closes the terminal, killing the
+// program running inside it.
+func CloseAction() Action {
+	return func(ctx context.Context, vt *VirtualTerminal) error {
+		return vt.Close()
+	}
+}
+
+// Watchdog is a running WatchdogPolicy registered with AddWatchdog.
+type Watchdog struct {
+	policy WatchdogPolicy
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu    sync.Mutex
+	trips []WatchdogTrip
+	err   error
+}
+
+// AddWatchdog starts enforcing policy for the life of the session or until
+// the watchdog is removed. When a limit is exceeded Notify and Action run,
+// and the measurements start over, so a persistent problem trips the
+// watchdog again after another full window or timeout:
+//
+//	vt.AddWatchdog(htlib.WatchdogPolicy{
+//	    MaxOutputBytes: 10 << 20,          // 10 MB per minute
+//	    PromptTimeout:  15 * time.Minute,
+//	    Action:         htlib.KeysAction(htlib.Ctrl('c')),
+//	})
+func (vt *VirtualTerminal) AddWatchdog(policy WatchdogPolicy) *Watchdog {
+	if policy.Window <= 0 {
+		policy.Window = DefaultWatchdogWindow
+	}
+	if policy.Prompt == nil {
+		policy.Prompt = OutputMatches(defaultPromptPattern)
+	}
+
+	w := &Watchdog{
+		policy: policy,
+		done:   make(chan struct{}),
+	}
+	var ctx context.Context
+	ctx, w.cancel = context.WithCancel(vt.current().ctx)
+	sub := vt.subscribeRaw()
+	go vt.runWatchdog(ctx, w, sub)
+	return w
+}
+
+// Remove stops the watchdog. It is safe to call Remove more than once.
+func (w *Watchdog) Remove() {
+	w.cancel()
+}
+
+// Done returns a channel that is closed once the watchdog has stopped.
+func (w *Watchdog) Done() <-chan struct{} {
+	return w.done
+}
+
+// Trips returns the times the watchdog has fired so far.
+func (w *Watchdog) Trips() []WatchdogTrip {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]WatchdogTrip(nil), w.trips...)
+}
+
+// Err returns the error from the most recent action that failed, or nil.
+func (w *Watchdog) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// watchdogSample is a measurement taken at a point in time.
+type watchdogSample struct {
+	at    time.Time
+	value float64
+}
+
+// checkInterval returns how often the policy's limits are checked.
+func (p WatchdogPolicy) checkInterval() time.Duration {
+	interval := time.Second
+	for _, d := range []time.Duration{p.Window / 4, p.PromptTimeout / 4} {
+		if d > 0 && d < interval {
+			interval = d
+		}
+	}
+	return interval
+}
+
+// runWatchdog measures the session until the watchdog is stopped.
+func (vt *VirtualTerminal) runWatchdog(ctx context.Context, w *Watchdog, sub chan Event) {
+	defer close(w.done)
+	defer vt.Unsubscribe(sub)
+	defer w.cancel()
+
+	policy := w.policy
+	ticker := time.NewTicker(policy.checkInterval())
+	defer ticker.Stop()
+
+	var output []watchdogSample
+	var cpu []watchdogSample
+	prompt := newOutputBuffer()
+	start := time.Now()
+	lastPrompt := start
+
+	for {
+		select {
+		case event, ok := <-sub:
+			if !ok {
+				return
+			}
+			e, isOutput := event.(OutputEvent)
+			if !isOutput {
+				continue
+			}
+			now := time.Now()
+			if policy.MaxOutputBytes > 0 {
+				output = append(output, watchdogSample{at: now, value: float64(len(e.Seq))})
+			}
+			if policy.PromptTimeout > 0 {
+				prompt.add(e)
+				if match := policy.Prompt.Match(vt.newMatchContext(ctx, event, prompt.text)); match != nil {
+					prompt.consume(match[0])
+					lastPrompt = now
+				}
+			}
+			continue
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		now := time.Now()
+		cutoff := now.Add(-policy.Window)
+		output = trimSamples(output, cutoff)
+
+		trip := WatchdogTrip{
+			Policy:      policy.Name,
+			Time:        now,
+			SincePrompt: now.Sub(lastPrompt) - vt.suspension.between(lastPrompt, now),
+		}
+		for _, s := range output {
+			trip.OutputBytes += int64(s.value)
+		}
+
+		if policy.MaxCPU > 0 {
+			if seconds, err := processTreeCPU(vt.PID()); err == nil {
+				cpu = append(trimSamples(cpu, cutoff), watchdogSample{at: now, value: seconds})
+				// Only judge CPU usage once a full window has been observed
+				if first := cpu[0]; now.Sub(start) >= policy.Window && now.After(first.at) {
+					trip.CPU = (seconds - first.value) / now.Sub(first.at).Seconds()
+				}
+			}
+		}
+
+		switch {
+		case policy.MaxOutputBytes > 0 && trip.OutputBytes > policy.MaxOutputBytes:
+			trip.Reason = fmt.Sprintf("output %d bytes in %s exceeds %d", trip.OutputBytes, policy.Window, policy.MaxOutputBytes)
+		case policy.MaxCPU > 0 && trip.CPU > policy.MaxCPU:
+			trip.Reason = fmt.Sprintf("CPU usage %.2f cores over %s exceeds %.2f", trip.CPU, policy.Window, policy.MaxCPU)
+		case policy.PromptTimeout > 0 && trip.SincePrompt > policy.PromptTimeout:
+			trip.Reason = fmt.Sprintf("no prompt for %s", trip.SincePrompt.Round(time.Millisecond))
+		default:
+			continue
+		}
+
+		w.mu.Lock()
+		w.trips = append(w.trips, trip)
+		w.mu.Unlock()
+
+		if policy.Notify != nil {
+			policy.Notify(trip)
+		}
+		if policy.Action != nil {
+			if err := policy.Action(ctx, vt); err != nil {
+				w.mu.Lock()
+				w.err = err
+				w.mu.Unlock()
+			}
+		}
+
+		// Start measuring afresh so one problem is not reported on every tick
+		output, cpu = nil, nil
+		start, lastPrompt = time.Now(), time.Now()
+	}
+}
+
+// trimSamples drops samples taken before cutoff.
+func trimSamples(samples []watchdogSample, cutoff time.Time) []watchdogSample {
+	i := 0
+	for i < len(samples) && samples[i].at.Before(cutoff) {
+		i++
+	}
+	return samples[i:]
+}
+
+// processTreeCPU returns the CPU time in seconds used by pid and all of its
+// descendants, including children that have already exited.
+func processTreeCPU(pid int) (float64, error) {
+	if pid == 0 {
+		return 0, ErrNotStarted
+	}
+	if _, err := os.Stat("/proc/self"); err != nil {
+		return 0, fmt.Errorf("%w: /proc not available", ErrUnsupported)
+	}
+
+	parents, err := procParents()
+	if err != nil {
+		return 0, err
+	}
+
+	var ticks int64
+	pids := []int{pid}
+	for len(pids) > 0 {
+		p := pids[0]
+		pids = pids[1:]
+
+		stat, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(p), "stat"))
+		if err != nil {
+			if p == pid {
+				return 0, err
+			}
+			continue // exited while we were walking the tree
+		}
+		ticks += parseStatCPU(string(stat))
+
+		for child, parent := range parents {
+			if parent == p {
+				pids = append(pids, child)
+			}
+		}
+	}
+	return float64(ticks) / clockTicks, nil
+}
+
+// parseStatCPU returns utime+stime+cutime+cstime from a /proc/<pid>/stat
+// line, in clock ticks.
+func parseStatCPU(stat string) int64 {
+	end := strings.LastIndexByte(stat, ')')
+	if end < 0 {
+		return 0
+	}
+	// Fields after the command name start at field 3 (state); utime is 14
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 15 {
+		return 0
+	}
+	var total int64
+	for _, f := range fields[11:15] {
+		n, _ := strconv.ParseInt(f, 10, 64)
+		total += n
+	}
+	return total
+}
@@ -0,0 +1,143 @@
+package htlib
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWatchdogOutputLimit(t *testing.T) {
+	vt, stdin := newTestTerminal()
+	defer vt.Close()
+
+	var mu sync.Mutex
+	var notified []WatchdogTrip
+	w := vt.AddWatchdog(WatchdogPolicy{
+		Name:           "flood",
+		Window:         200 * time.Millisecond,
+		MaxOutputBytes: 100,
+		Action:         KeysAction(Ctrl('c')),
+		Notify: func(trip WatchdogTrip) {
+			mu.Lock()
+			notified = append(notified, trip)
+			mu.Unlock()
+		},
+	})
+	defer w.Remove()
+
+	vt.dispatch(OutputEvent{Seq: strings.Repeat("y\r\n", 50)})
+	waitUntil(t, func() bool { return len(w.Trips()) > 0 })
+
+	trip := w.Trips()[0]
+	if trip.Policy != "flood" || trip.OutputBytes != 150 || !strings.Contains(trip.Reason, "output") {
+		t.Errorf("unexpected trip %+v", trip)
+	}
+	waitUntil(t, func() bool { return strings.Contains(stdin.String(), `"keys":["C-c"]`) })
+
+	mu.Lock()
+	if len(notified) == 0 {
+		t.Error("expected Notify to be called")
+	}
+	mu.Unlock()
+}
+
+func TestWatchdogPromptTimeout(t *testing.T) {
+	vt, _ := newTestTerminal()
+	defer vt.Close()
+
+	failure := errors.New("notify failed")
+	w := vt.AddWatchdog(WatchdogPolicy{
+		PromptTimeout: 150 * time.Millisecond,
+		Action: func(ctx context.Context, vt *VirtualTerminal) error {
+			return failure
+		},
+	})
+	defer w.Remove()
+
+	// Prompts keep the watchdog quiet
+	for i := 0; i < 5; i++ {
+		vt.dispatch(OutputEvent{Seq: "output\r\nuser@host:~$ "})
+		time.Sleep(50 * time.Millisecond)
+	}
+	if trips := w.Trips(); len(trips) != 0 {
+		t.Fatalf("expected no trips while prompts appear, got %+v", trips)
+	}
+
+	// A command that never returns to the prompt trips it
+	vt.dispatch(OutputEvent{Seq: "sleep 1000\r\n"})
+	waitUntil(t, func() bool { return len(w.Trips()) > 0 })
+
+	if trip := w.Trips()[0]; !strings.Contains(trip.Reason, "no prompt") {
+		t.Errorf("unexpected trip reason %q", trip.Reason)
+	}
+	if !errors.Is(w.Err(), failure) {
+		t.Errorf("expected action error, got %v", w.Err())
+	}
+
+	w.Remove()
+	select {
+	case <-w.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("watchdog did not stop after Remove")
+	}
+}
+
+func TestParseStatCPU(t *testing.T) {
+	stat := "1234 (my (odd) cmd) S 1 1234 1234 0 -1 4194304 100 0 0 0 250 50 7 3 20 0 1 0 100 0 0"
+	if got := parseStatCPU(stat); got != 310 {
+		t.Errorf("expected 310 ticks, got %d", got)
+	}
+	if got := parseStatCPU("garbage"); got != 0 {
+		t.Errorf("expected 0 for invalid stat, got %d", got)
+	}
+}
+
+func TestProcessTreeCPU(t *testing.T) {
+	if _, err := os.Stat("/proc/self"); err != nil {
+		t.Skip("/proc not available")
+	}
+
+	seconds, err := processTreeCPU(os.Getpid())
+	if err != nil {
+		t.Fatalf("failed to read CPU time: %v", err)
+	}
+	if seconds < 0 {
+		t.Errorf("expected non-negative CPU time, got %v", seconds)
+	}
+
+	if _, err := processTreeCPU(0); err == nil {
+		t.Error("expected error for unknown PID")
+	}
+}
+
+func TestConfigWatchdogs(t *testing.T) {
+	config := DefaultConfig()
+	config.Watchdogs = []WatchdogPolicy{{
+		Window:         200 * time.Millisecond,
+		MaxOutputBytes: 1000,
+		Action:         CloseAction(),
+	}}
+	vt := New(config)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := vt.Start(ctx, WaitReady()); err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+	defer vt.Close()
+
+	if err := vt.Input(ctx, "yes | head -c 20000\n"); err != nil {
+		t.Fatalf("failed to send input: %v", err)
+	}
+
+	// The watchdog closes the runaway session
+	select {
+	case <-vt.current().ctx.Done():
+	case <-ctx.Done():
+		t.Fatal("watchdog did not close the terminal")
+	}
+}
@@ -0,0 +1,107 @@
+package htlib
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/io41/htlib.go/htproto"
+)
+
+// writeQueue lets commands be written to ht's stdin one at a time, in the
+// order they were sent. Unlike a mutex, which makes no promise about who
+// goes next, it hands the turn to the longest waiting writer, and a waiter
+// can give up when its context ends. The zero value is ready to use.
+type writeQueue struct {
+	mu      sync.Mutex
+	busy    bool
+	waiting []chan struct{}
+}
+
+// acquire waits for the turn to write, or returns ctx.Err().
+func (q *writeQueue) acquire(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.busy {
+		q.busy = true
+		q.mu.Unlock()
+		return nil
+	}
+	turn := make(chan struct{})
+	q.waiting = append(q.waiting, turn)
+	q.mu.Unlock()
+
+	select {
+	case <-turn:
+		return nil
+	case <-ctx.Done():
+	}
+	q.mu.Lock()
+	if i := slices.Index(q.waiting, turn); i >= 0 {
+		q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+		q.mu.Unlock()
+		return ctx.Err()
+	}
+	q.mu.Unlock()
+	// The turn was handed over while giving up; pass it on
+	q.release()
+	return ctx.Err()
+}
+
+// tryAcquire takes the turn to write if nobody has it.
+func (q *writeQueue) tryAcquire() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.busy {
+		return false
+	}
+	q.busy = true
+	return true
+}
+
+// release hands the turn to the next writer.
+func (q *writeQueue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.waiting) == 0 {
+		q.busy = false
+		return
+	}
+	close(q.waiting[0])
+	q.waiting = q.waiting[1:]
+}
+
+// Flush waits until every command sent before it, from any goroutine, has
+// been written to ht. Commands are written one at a time in the order they
+// were sent, even when a caller gave up waiting for its own. Flush does
+// not wait for ht to handle them; see Apply for that.
+func (vt *VirtualTerminal) Flush(ctx context.Context) error {
+	if err := vt.writes.acquire(ctx); err != nil {
+		return err
+	}
+	vt.writes.release()
+	return nil
+}
+
+// TryInput sends raw input like Input, but never waits: it returns ErrBusy
+// if earlier commands are still being written, because ht is not keeping
+// up with its input, and ErrClosed if the terminal is closed or its process
+// has exited. It suits latency-sensitive callers such as interactive
+// proxies, which prefer dropping a keystroke over stalling:
+//
+//	if err := vt.TryInput(key); errors.Is(err, htlib.ErrBusy) {
+//	    dropped++
+//	}
+//
+// TryInput returns once the write has started; an error writing it is
+// only counted in Summary.
+func (vt *VirtualTerminal) TryInput(text string) error {
+	_, err := vt.write(context.Background(), true, []command{htproto.Input(text)})
+	return err
+}
+
+// TrySendKeys sends named keys like SendKeys, but never waits; see
+// TryInput.
+func (vt *VirtualTerminal) TrySendKeys(keys ...string) error {
+	_, err := vt.write(context.Background(), true, vt.keyCommands(keys))
+	return err
+}
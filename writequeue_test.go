@@ -0,0 +1,120 @@
+package htlib
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteQueueOrder(t *testing.T) {
+	var q writeQueue
+	ctx := context.Background()
+	if err := q.acquire(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	order := make(chan int, 5)
+	waiting := func(n int) func() bool {
+		return func() bool {
+			q.mu.Lock()
+			defer q.mu.Unlock()
+			return len(q.waiting) == n
+		}
+	}
+	for i := range 5 {
+		go func() {
+			if err := q.acquire(ctx); err != nil {
+				t.Error(err)
+			}
+			order <- i
+			q.release()
+		}()
+		waitUntil(t, waiting(i+1))
+	}
+
+	// A waiter that gives up leaves its place
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := q.acquire(cancelled); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the context error, got %v", err)
+	}
+
+	q.release()
+	for want := range 5 {
+		if got := <-order; got != want {
+			t.Fatalf("expected writer %d, got %d", want, got)
+		}
+	}
+	waitUntil(t, func() bool {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		return !q.busy
+	})
+}
+
+func TestFlush(t *testing.T) {
+	vt := New(DefaultConfig())
+	defer vt.Close()
+	r, w := io.Pipe()
+	vt.started, vt.stdin = true, w
+
+	// The input is given up, but still written before Flush returns
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := vt.Input(ctx, "late"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the deadline, got %v", err)
+	}
+	flushed := make(chan error)
+	go func() { flushed <- vt.Flush(context.Background()) }()
+	select {
+	case <-flushed:
+		t.Fatal("expected Flush to wait for the pending write")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	lines := bufio.NewScanner(r)
+	if !lines.Scan() || !strings.Contains(lines.Text(), `"payload":"late"`) {
+		t.Fatalf("unexpected write %q", lines.Text())
+	}
+	if err := <-flushed; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTryInput(t *testing.T) {
+	vt := New(DefaultConfig())
+	if err := vt.TryInput("x"); !errors.Is(err, ErrNotStarted) {
+		t.Errorf("expected ErrNotStarted, got %v", err)
+	}
+	r, w := io.Pipe()
+	vt.started, vt.stdin = true, w
+
+	// Nothing reads the pipe, so the first write stays pending
+	if err := vt.TryInput("first"); err != nil {
+		t.Fatal(err)
+	}
+	if err := vt.TrySendKeys(KeyEnter); !errors.Is(err, ErrBusy) {
+		t.Errorf("expected ErrBusy, got %v", err)
+	}
+
+	lines := bufio.NewScanner(r)
+	if !lines.Scan() || !strings.Contains(lines.Text(), `"payload":"first"`) {
+		t.Fatalf("unexpected write %q", lines.Text())
+	}
+	waitUntil(t, func() bool {
+		err := vt.TrySendKeys(KeyEnter)
+		return !errors.Is(err, ErrBusy)
+	})
+	if !lines.Scan() || !strings.Contains(lines.Text(), `"keys":["Enter"]`) {
+		t.Fatalf("unexpected write %q", lines.Text())
+	}
+
+	vt.Close()
+	if err := vt.TryInput("x"); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}